@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AbdullahTarakji/portpilot/internal/agent"
+	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+func agentCmd() *cobra.Command {
+	var (
+		listen   string
+		token    string
+		certFile string
+		keyFile  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "agent",
+		Short: "Serve this machine's scans to other portpilot instances over TLS",
+		Long: `Serve scans of this machine over HTTPS, so a portpilot elsewhere can show
+its ports alongside its own. Add the agent to that machine's config:
+
+  agents:
+    - name: vm1
+      address: vm1.internal:7070
+      token_env: VM1_TOKEN
+      fingerprint: sha256:...
+
+Every request must carry the shared token, taken from --token or
+$` + agent.TokenEnv + `. The agent only serves scans; it can't kill or otherwise
+touch processes.
+
+Without --cert and --key, a self-signed certificate is generated on first
+start and kept in the portpilot config directory. Its fingerprint is printed
+at startup for the fingerprint field of the client's config.`,
+		Example: `  PORTPILOT_AGENT_TOKEN=$(openssl rand -hex 32) portpilot agent
+  portpilot agent --listen 10.0.0.5:7070 --cert agent.crt --key agent.key`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if token == "" {
+				token = os.Getenv(agent.TokenEnv)
+			}
+			if token == "" {
+				return fmt.Errorf("a token is required: pass --token or set $%s", agent.TokenEnv)
+			}
+			if (certFile == "") != (keyFile == "") {
+				return fmt.Errorf("--cert and --key must be given together")
+			}
+
+			var cert tls.Certificate
+			var err error
+			if certFile != "" {
+				cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+			} else {
+				cert, err = selfSignedCert()
+			}
+			if err != nil {
+				return fmt.Errorf("loading certificate: %w", err)
+			}
+
+			s, err := scanner.New()
+			if err != nil {
+				return err
+			}
+			l, err := agent.Listen(listen, cert)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			hs := &http.Server{Handler: agent.NewServer(s, token).Handler(), ReadHeaderTimeout: 10 * time.Second}
+			go func() {
+				<-ctx.Done()
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				_ = hs.Shutdown(shutdownCtx)
+			}()
+
+			fmt.Fprintf(os.Stderr, "Serving scans on %s\nCertificate fingerprint: %s\n", l.Addr(), agent.Fingerprint(cert))
+			if err := hs.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&listen, "listen", agent.DefaultAddress, "host:port to listen on")
+	cmd.Flags().StringVar(&token, "token", "", "Shared token clients must present (default $"+agent.TokenEnv+")")
+	cmd.Flags().StringVar(&certFile, "cert", "", "TLS certificate file (PEM)")
+	cmd.Flags().StringVar(&keyFile, "key", "", "TLS key file (PEM)")
+
+	return cmd
+}
+
+// selfSignedCert loads the agent's saved self-signed certificate, creating
+// one for this machine's hostname and addresses on first use.
+func selfSignedCert() (tls.Certificate, error) {
+	path, err := config.AgentCertPath()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	hosts := []string{"localhost"}
+	if name, err := os.Hostname(); err == nil {
+		hosts = append(hosts, name)
+	}
+	if addrs, err := net.InterfaceAddrs(); err == nil {
+		for _, a := range addrs {
+			if ipnet, ok := a.(*net.IPNet); ok {
+				hosts = append(hosts, ipnet.IP.String())
+			}
+		}
+	}
+	return agent.LoadOrCreateCertificate(path, hosts)
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AbdullahTarakji/portpilot/internal/daemon"
+	"github.com/AbdullahTarakji/portpilot/internal/notify"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// noDaemon makes commands scan locally even when a daemon is running.
+var noDaemon bool
+
+// newScanner returns a client for the running daemon, so commands reuse its
+// latest scan, or a local scanner when there is none.
+func newScanner() (scanner.Scanner, error) {
+	if !noDaemon {
+		if c, err := daemon.Connect(daemon.DefaultAddress()); err == nil {
+			return c, nil
+		}
+	}
+	return scanner.New()
+}
+
+func daemonCmd() *cobra.Command {
+	var (
+		listen   string
+		interval time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Serve a continuously refreshed scan over a local HTTP/JSON API",
+		Long: `Keep a continuously refreshed scan in memory and serve it over HTTP on a
+Unix socket or a loopback TCP address.
+
+While the daemon is running, list, check, conflicts, watch and kill read its
+latest scan instead of scanning again. Pass --no-daemon to scan directly.
+
+Endpoints:
+  GET  /health              daemon status
+  GET  /ports?filter=QUERY  listeners, in the --output json format
+  GET  /ports/{port}        listeners on one port
+  POST /ports/{port}/kill   body {"signal": "TERM", "grace": "5s"}
+  GET  /events              Server-Sent Events stream of open/close events
+
+Open and close events are recorded in the history (see "portpilot history")
+and sent to the notifiers from the config.
+
+The default address is $` + daemon.AddressEnv + ` if set, otherwise portpilot.sock in
+$XDG_RUNTIME_DIR or portpilot-<uid>/daemon.sock in the temp directory. A Unix
+socket's directory must belong to you alone.`,
+		Example: `  portpilot daemon
+  portpilot daemon --listen 127.0.0.1:7766
+  curl --unix-socket $XDG_RUNTIME_DIR/portpilot.sock 'http://localhost/ports?filter=proc:node'`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := loadConfig()
+			if interval == 0 {
+				interval = time.Duration(cfg.RefreshInterval) * time.Second
+			}
+			if interval < 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+
+			s, err := scanner.New()
+			if err != nil {
+				return err
+			}
+			srv := daemon.NewServer(s, cfg.GroupForPort)
+			if store := openHistory(cfg); store != nil {
+				srv.OnScan(func(ports []scanner.PortInfo, at time.Time) {
+					if _, err := store.Record(ports, at); err != nil {
+						fmt.Fprintf(os.Stderr, "History error: %v\n", err)
+					}
+				})
+			}
+			logErr := func(err error) { fmt.Fprintf(os.Stderr, "Scan error: %v\n", err) }
+			if err := srv.Refresh(); err != nil {
+				logErr(err)
+			}
+
+			notifier, err := notify.New(cfg.Notifiers, cfg.GroupForPort)
+			if err != nil {
+				return err
+			}
+
+			l, err := daemon.Listen(listen)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			if notifier.Len() > 0 {
+				evs, cancel := srv.Subscribe()
+				defer cancel()
+				go func() {
+					for e := range evs {
+						if err := notifier.Notify(ctx, e); err != nil {
+							fmt.Fprintf(os.Stderr, "Notify error: %v\n", err)
+						}
+					}
+				}()
+			}
+			go srv.Run(ctx, interval, logErr)
+
+			hs := &http.Server{Handler: srv.Handler(), ReadHeaderTimeout: 10 * time.Second}
+			go func() {
+				<-ctx.Done()
+				// Event streams never finish on their own, so don't wait long.
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), time.Second)
+				defer cancel()
+				if err := hs.Shutdown(shutdownCtx); err != nil {
+					_ = hs.Close()
+				}
+			}()
+
+			fmt.Fprintf(os.Stderr, "Serving API on %s, scanning every %s\n", listen, interval)
+			if err := hs.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&listen, "listen", daemon.DefaultAddress(), "Unix socket path or loopback host:port to listen on")
+	cmd.Flags().DurationVar(&interval, "interval", 0, "Time between scans (default: refresh_interval from the config)")
+
+	return cmd
+}
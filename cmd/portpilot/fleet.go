@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/AbdullahTarakji/portpilot/internal/agent"
+	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/fleet"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// newFleet returns a scanner covering this machine and every agent and SSH
+// host in the config, or just the local scanner when there are none.
+func newFleet(local scanner.Scanner, cfg *config.Config) (scanner.Scanner, error) {
+	if len(cfg.Agents) == 0 && len(cfg.SSH) == 0 {
+		return local, nil
+	}
+	sources := []fleet.Source{{Scanner: local}}
+	for _, a := range cfg.Agents {
+		c, err := agent.NewClient(a)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, fleet.Source{Name: a.Name, Scanner: c, Timeout: a.ScanTimeout()})
+	}
+	for _, h := range cfg.SSH {
+		run := scanner.SSHRunner{Host: h.Host, Port: h.Port, User: h.User, Jump: h.Jump, Identity: h.Identity}
+		r, err := scanner.NewRemote(run, h.RemoteOS())
+		if err != nil {
+			return nil, fmt.Errorf("ssh host %s: %w", h.Name, err)
+		}
+		sources = append(sources, fleet.Source{Name: h.Name, Scanner: r, Timeout: h.ScanTimeout()})
+	}
+	return fleet.New(sources...), nil
+}
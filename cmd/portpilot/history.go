@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/events"
+	"github.com/AbdullahTarakji/portpilot/internal/history"
+)
+
+// openHistory returns the history store, or nil when history is disabled
+// or has nowhere to live.
+func openHistory(cfg *config.Config) *history.Store {
+	if cfg.History.Disabled {
+		return nil
+	}
+	path, err := config.HistoryPath()
+	if err != nil {
+		return nil
+	}
+	return history.Open(path, cfg.History.Retention())
+}
+
+func historyCmd() *cobra.Command {
+	var (
+		port    int
+		process string
+		since   string
+		until   string
+		format  string
+	)
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show when ports were opened and closed",
+		Long: `Show the port open and close events recorded while the daemon or the TUI
+was running, oldest first.
+
+--since and --until take a duration before now (2h, 1d) or a time
+(2026-03-01 14:00, or 14:00 for today).`,
+		Example: `  portpilot history --port 3000 --since 1d
+  portpilot history --since "2026-03-01 13:00" --until "2026-03-01 18:00"
+  portpilot history --process postgres -o jsonl`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			now := time.Now()
+			filter := history.Filter{Port: port, Process: process}
+			var err error
+			if since != "" {
+				if filter.Since, err = history.ParseTime(since, now); err != nil {
+					return fmt.Errorf("--since: %w", err)
+				}
+			}
+			if until != "" {
+				if filter.Until, err = history.ParseTime(until, now); err != nil {
+					return fmt.Errorf("--until: %w", err)
+				}
+			}
+			if format != "table" && format != "json" && format != "jsonl" {
+				return fmt.Errorf("unknown output format %q (available: table, json, jsonl)", format)
+			}
+
+			cfg := loadConfig()
+			store := openHistory(cfg)
+			if store == nil {
+				return fmt.Errorf("history is disabled in the config")
+			}
+			evs, err := store.Query(filter)
+			if err != nil {
+				return err
+			}
+			return printEvents(evs, format)
+		},
+	}
+
+	cmd.Flags().IntVar(&port, "port", 0, "Only events on this port")
+	cmd.Flags().StringVar(&process, "process", "", "Only events of processes whose name contains this")
+	cmd.Flags().StringVar(&since, "since", "", "Only events after this time or duration ago")
+	cmd.Flags().StringVar(&until, "until", "", "Only events before this time or duration ago")
+	cmd.Flags().StringVarP(&format, "output", "o", "table", "Output format: table|json|jsonl")
+
+	return cmd
+}
+
+func printEvents(evs []events.Event, format string) error {
+	switch format {
+	case "json":
+		if evs == nil {
+			evs = []events.Event{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(evs)
+	case "jsonl":
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range evs {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(evs) == 0 {
+		fmt.Println("No matching events")
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tEVENT\tPORT\tPROTO\tPID\tPROCESS\tUSER\tCOMMAND")
+	fmt.Fprintln(tw, "----\t-----\t----\t-----\t---\t-------\t----\t-------")
+	for _, e := range evs {
+		p := e.Port
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%d\t%s\t%s\t%s\n",
+			e.Time.Local().Format("2006-01-02 15:04:05"), e.Kind, p.Port, p.Protocol, p.PID, p.ProcessName, p.User, p.Command)
+	}
+	return tw.Flush()
+}
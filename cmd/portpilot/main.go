@@ -2,18 +2,28 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
 	"strings"
-	"text/tabwriter"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
 
+	"github.com/AbdullahTarakji/portpilot/internal/columns"
 	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/daemon"
+	"github.com/AbdullahTarakji/portpilot/internal/metrics"
+	"github.com/AbdullahTarakji/portpilot/internal/output"
 	"github.com/AbdullahTarakji/portpilot/internal/process"
+	"github.com/AbdullahTarakji/portpilot/internal/query"
 	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+	"github.com/AbdullahTarakji/portpilot/internal/systemd"
 	"github.com/AbdullahTarakji/portpilot/internal/tui"
 )
 
@@ -26,6 +36,7 @@ var (
 
 func main() {
 	if err := rootCmd().Execute(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 }
@@ -36,14 +47,14 @@ func rootCmd() *cobra.Command {
 		Short: "PortPilot — manage ports and processes",
 		Long:  "A CLI + TUI tool for discovering, inspecting, and managing listening ports and their processes.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			s, err := scanner.New()
+			local, err := scanner.New()
 			if err != nil {
 				return err
 			}
-			cfg, err := config.Load()
+			cfg := loadConfig()
+			s, err := newFleet(local, cfg)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Warning: config error: %v\n", err)
-				cfg = config.DefaultConfig()
+				return err
 			}
 			return tui.Run(s, cfg)
 		},
@@ -51,11 +62,23 @@ func rootCmd() *cobra.Command {
 		SilenceErrors: true,
 	}
 
+	root.PersistentFlags().BoolVar(&noDaemon, "no-daemon", false, "Scan directly even if a portpilot daemon is running")
+
 	root.AddCommand(
 		listCmd(),
 		killCmd(),
+		unitCmd(systemd.Stop),
+		unitCmd(systemd.Restart),
+		unitCmd(systemd.Status),
 		checkCmd(),
+		conflictsCmd(),
 		watchCmd(),
+		serveCmd(),
+		daemonCmd(),
+		agentCmd(),
+		historyCmd(),
+		recordCmd(),
+		schemaCmd(),
 		versionCmd(),
 	)
 
@@ -67,35 +90,75 @@ func listCmd() *cobra.Command {
 		jsonOutput  bool
 		portFilter  int
 		procFilter  string
+		queryFilter string
+		viewName    string
+		out         outputFlags
 	)
 
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List listening ports",
+		Example: `  portpilot list --filter 'port:3000-3999 proc:node'
+  portpilot list --filter 'cpu>20 OR mem>10'
+  portpilot list --filter '-user:root proto:tcp'
+  portpilot list --view mine
+  portpilot list --columns port,proc,addr,uptime
+  portpilot list -o csv --no-headers
+  portpilot list --template '{{.Port}} {{.ProcessName}}'`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			s, err := scanner.New()
+			q, err := query.Parse(queryFilter)
+			if err != nil {
+				return fmt.Errorf("invalid filter: %w", err)
+			}
+
+			cfg := loadConfig()
+			var view config.View
+			if viewName != "" {
+				v, ok := cfg.View(viewName)
+				if !ok {
+					return fmt.Errorf("unknown view %q", viewName)
+				}
+				view = v
+			}
+			// Views are validated when the config is parsed.
+			viewQuery, _ := query.Parse(view.Filter)
+			sortKey, asc := view.SortKey()
+			if jsonOutput {
+				out.format = string(output.JSON)
+			}
+			opts, err := out.options(cfg.ColumnsFor(view))
+			if err != nil {
+				return err
+			}
+
+			s, err := newScanner()
 			if err != nil {
 				return err
 			}
 
-			ports, err := s.Scan()
+			ports, meta, err := scanPorts(s)
 			if err != nil {
 				return fmt.Errorf("scanning ports: %w", err)
 			}
 
 			ports = applyFilters(ports, portFilter, procFilter)
-
-			if jsonOutput {
-				return printJSON(ports)
+			ports = viewQuery.Filter(ports, cfg.GroupForPort)
+			ports = q.Filter(ports, cfg.GroupForPort)
+			if viewName != "" {
+				ports = columns.Sort(ports, sortKey, asc, columns.Env{GroupFor: cfg.GroupForPort})
 			}
-			printTable(ports)
-			return nil
+
+			warnScan(meta)
+			return writePorts(ports, meta, opts, cfg)
 		},
 	}
 
-	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output in JSON format")
+	cmd.Flags().BoolVar(&jsonOutput, "json", false, "Output in JSON format (same as --output json)")
 	cmd.Flags().IntVar(&portFilter, "port", 0, "Filter by port number")
 	cmd.Flags().StringVar(&procFilter, "process", "", "Filter by process name")
+	cmd.Flags().StringVar(&queryFilter, "filter", "", "Filter with a query, e.g. 'port:3000-3999 proc:node'")
+	cmd.Flags().StringVar(&viewName, "view", "", "Use a saved view from the config")
+	out.register(cmd)
 
 	return cmd
 }
@@ -104,12 +167,19 @@ func killCmd() *cobra.Command {
 	var (
 		force     bool
 		signalStr string
+		filter    process.PortFilter
 	)
 
 	cmd := &cobra.Command{
 		Use:   "kill <port>",
-		Short: "Kill the process on a specified port",
-		Args:  cobra.ExactArgs(1),
+		Short: "Kill the processes on a specified port",
+		Long: `Kill every process listening on a port: IPv4 and IPv6 listeners, SO_REUSEPORT
+listeners and forked workers sharing a socket alike. --protocol and --address
+narrow them down; listeners on all addresses match any --address.
+
+Nothing is killed when some of the listeners belong to processes hidden from
+this user, since the port would stay taken.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			port := 0
 			if _, err := fmt.Sscanf(args[0], "%d", &port); err != nil {
@@ -122,98 +192,241 @@ func killCmd() *cobra.Command {
 			}
 
 			// Find what's on the port first
-			s, scanErr := scanner.New()
-			if scanErr != nil {
-				return scanErr
+			s, err := newScanner()
+			if err != nil {
+				return err
 			}
-			ports, scanErr := s.Scan()
-			if scanErr != nil {
-				return fmt.Errorf("scanning: %w", scanErr)
+			targets, err := process.FindByPort(s, port, filter)
+			switch {
+			case errors.Is(err, process.ErrPortFree):
+				fmt.Println(capitalize(err.Error()))
+				return nil
+			case errors.Is(err, process.ErrPermission):
+				return fmt.Errorf("%w; rerun with sudo to see them", err)
+			case err != nil:
+				return err
 			}
 
-			var target *scanner.PortInfo
-			for _, p := range ports {
-				if p.Port == port {
-					target = &p
-					break
+			// One line per process, with the first socket it holds.
+			byPID := make(map[int]scanner.PortInfo)
+			for _, p := range targets {
+				if _, ok := byPID[p.PID]; !ok {
+					byPID[p.PID] = p
 				}
 			}
-
-			if target == nil {
-				fmt.Printf("No process found on port %d\n", port)
-				return nil
-			}
+			pids := process.PIDs(targets)
 
 			if !force {
-				fmt.Printf("Kill %q (PID %d) on port %d? [y/N] ", target.ProcessName, target.PID, target.Port)
-				var answer string
-				_, _ = fmt.Scanln(&answer)
-				if strings.ToLower(answer) != "y" {
+				question := "Kill them all?"
+				if len(pids) == 1 {
+					t := byPID[pids[0]]
+					question = fmt.Sprintf("Kill %q (PID %d) on port %d?", t.ProcessName, t.PID, port)
+				} else {
+					fmt.Printf("%d processes are listening on port %d:\n", len(pids), port)
+					for _, pid := range pids {
+						t := byPID[pid]
+						fmt.Printf("  %q (PID %d) on %s/%s\n", t.ProcessName, t.PID, t.Address, t.Protocol)
+					}
+				}
+				if !confirm(question) {
 					fmt.Println("Cancelled.")
 					return nil
 				}
 			}
 
-			if err := process.Kill(target.PID, sig); err != nil {
-				return fmt.Errorf("killing process: %w", err)
+			// The daemon's scan may be a refresh old: let it check that the
+			// confirmed processes still hold the port before signalling.
+			kill := func(pid int) error { return process.Kill(pid, sig) }
+			if c, ok := s.(*daemon.Client); ok {
+				resp, err := c.Kill(port, daemon.KillRequest{
+					Signal:   process.SignalName(sig),
+					Protocol: filter.Protocol,
+					Address:  filter.Address,
+					PIDs:     pids,
+				})
+				if err != nil {
+					return err
+				}
+				results := make(map[int]error)
+				for _, r := range resp.Results {
+					results[r.PID] = nil
+					if r.Error != "" {
+						results[r.PID] = errors.New(r.Error)
+					}
+				}
+				kill = func(pid int) error {
+					err, ok := results[pid]
+					if !ok {
+						return fmt.Errorf("no longer listening on port %d", port)
+					}
+					return err
+				}
 			}
 
-			fmt.Printf("Sent %v to PID %d (%s) on port %d\n", sig, target.PID, target.ProcessName, target.Port)
-			return nil
+			var errs []error
+			units := make(map[string]bool)
+			for _, pid := range pids {
+				t := byPID[pid]
+				if err := kill(pid); err != nil {
+					errs = append(errs, fmt.Errorf("killing %q (PID %d): %w", t.ProcessName, pid, err))
+					continue
+				}
+				fmt.Printf("Sent %v to PID %d (%s) on port %d\n", sig, pid, t.ProcessName, port)
+				if t.Unit != "" && !units[t.Unit] {
+					units[t.Unit] = true
+					fmt.Printf("%s is managed by systemd and may be restarted; use `portpilot stop %d` to stop it\n", t.Unit, port)
+				}
+			}
+			return errors.Join(errs...)
 		},
 	}
 
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation")
 	cmd.Flags().StringVarP(&signalStr, "signal", "s", "SIGTERM", "Signal to send (default SIGTERM)")
+	cmd.Flags().StringVar(&filter.Protocol, "protocol", "", "Only kill listeners of this protocol (tcp or udp)")
+	cmd.Flags().StringVar(&filter.Address, "address", "", "Only kill listeners bound to this address")
 
 	return cmd
 }
 
 func checkCmd() *cobra.Command {
-	return &cobra.Command{
+	var out outputFlags
+
+	cmd := &cobra.Command{
 		Use:   "check <port>",
 		Short: "Check if a port is in use",
-		Args:  cobra.ExactArgs(1),
+		Long: `Check if a port is in use. Exits with status 1 if it is.
+
+With --output other than table, the processes using the port are printed in
+that format; a free port prints an empty list.`,
+		Args: cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			port := 0
 			if _, err := fmt.Sscanf(args[0], "%d", &port); err != nil {
 				return fmt.Errorf("invalid port: %s", args[0])
 			}
 
-			s, err := scanner.New()
+			cfg := loadConfig()
+			opts, err := out.options(cfg.Columns)
 			if err != nil {
 				return err
 			}
 
-			ports, err := s.Scan()
+			s, err := newScanner()
+			if err != nil {
+				return err
+			}
+
+			ports, meta, err := scanPorts(s)
 			if err != nil {
 				return fmt.Errorf("scanning: %w", err)
 			}
+			warnScan(meta)
 
-			for _, p := range ports {
-				if p.Port == port {
-					fmt.Printf("Port %d is in use by %q (PID %d, %s)\n", port, p.ProcessName, p.PID, p.Protocol)
-					os.Exit(1)
+			inUse := applyFilters(ports, port, "")
+			if opts.Format != output.Table {
+				if err := writePorts(inUse, meta, opts, cfg); err != nil {
+					return err
 				}
+			} else if len(inUse) == 0 {
+				fmt.Printf("Port %d is free\n", port)
+			} else {
+				p := inUse[0]
+				fmt.Printf("Port %d is in use by %q (PID %d, %s)\n", port, p.ProcessName, p.PID, p.Protocol)
 			}
 
-			fmt.Printf("Port %d is free\n", port)
+			if len(inUse) > 0 {
+				os.Exit(1)
+			}
 			return nil
 		},
 	}
+
+	out.register(cmd)
+
+	return cmd
+}
+
+func conflictsCmd() *cobra.Command {
+	var out outputFlags
+
+	cmd := &cobra.Command{
+		Use:   "conflicts",
+		Short: "List ports bound by more than one process",
+		Long: `List ports that more than one process is listening on, for example
+after a restart left the old process running. Exits with status 1 if any are
+found.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg := loadConfig()
+			opts, err := out.options(cfg.Columns)
+			if err != nil {
+				return err
+			}
+
+			s, err := newScanner()
+			if err != nil {
+				return err
+			}
+
+			ports, meta, err := scanPorts(s)
+			if err != nil {
+				return fmt.Errorf("scanning: %w", err)
+			}
+			warnScan(meta)
+
+			conflicts := scanner.Conflicts(ports)
+			var result []scanner.PortInfo
+			for _, p := range columns.Sort(ports, "port", true, columns.Env{}) {
+				if conflicts[scanner.HostPort{Host: p.Host, Port: p.Port}] {
+					result = append(result, p)
+				}
+			}
+
+			if len(result) == 0 && opts.Format == output.Table {
+				fmt.Println("No port conflicts")
+				return nil
+			}
+			if err := writePorts(result, meta, opts, cfg); err != nil {
+				return err
+			}
+			if len(result) > 0 {
+				os.Exit(1)
+			}
+			return nil
+		},
+	}
+
+	out.register(cmd)
+
+	return cmd
 }
 
 func watchCmd() *cobra.Command {
 	var (
-		portFilter int
-		interval   int
+		portFilter  int
+		interval    int
+		queryFilter string
+		out         outputFlags
 	)
 
 	cmd := &cobra.Command{
 		Use:   "watch",
 		Short: "Watch ports with streaming output",
+		Long: `Watch ports with streaming output. The table format redraws the screen on
+each refresh; other formats append each scan to the output, which makes
+--output jsonl suitable for piping into other tools.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			s, err := scanner.New()
+			q, err := query.Parse(queryFilter)
+			if err != nil {
+				return fmt.Errorf("invalid filter: %w", err)
+			}
+
+			s, err := newScanner()
+			if err != nil {
+				return err
+			}
+			cfg := loadConfig()
+			opts, err := out.options(cfg.Columns)
 			if err != nil {
 				return err
 			}
@@ -222,17 +435,32 @@ func watchCmd() *cobra.Command {
 			defer ticker.Stop()
 
 			// Print immediately, then on each tick
+			var warned []string
 			for {
-				ports, err := s.Scan()
+				ports, meta, err := scanPorts(s)
 				if err != nil {
 					fmt.Fprintf(os.Stderr, "Scan error: %v\n", err)
 				} else {
-					filtered := applyFilters(ports, portFilter, "")
-					fmt.Print("\033[2J\033[H") // clear screen
-					fmt.Printf("PortPilot Watch — %s — %d ports\n\n",
-						time.Now().Format("15:04:05"), len(filtered))
-					printTable(filtered)
-					fmt.Printf("\nRefreshing every %ds... Press Ctrl+C to stop.\n", interval)
+					filtered := q.Filter(applyFilters(ports, portFilter, ""), cfg.GroupForPort)
+					if opts.Format != output.Table {
+						// Only warn again when the warnings change.
+						if !slices.Equal(meta.Warnings, warned) {
+							warnScan(meta)
+							warned = meta.Warnings
+						}
+						if err := writePorts(filtered, meta, opts, cfg); err != nil {
+							return err
+						}
+					} else {
+						fmt.Print("\033[2J\033[H") // clear screen
+						fmt.Printf("PortPilot Watch — %s — %d ports\n\n",
+							time.Now().Format("15:04:05"), len(filtered))
+						if err := writePorts(filtered, meta, opts, cfg); err != nil {
+							return err
+						}
+						warnScan(meta)
+						fmt.Printf("\nRefreshing every %ds... Press Ctrl+C to stop.\n", interval)
+					}
 				}
 
 				<-ticker.C
@@ -242,10 +470,89 @@ func watchCmd() *cobra.Command {
 
 	cmd.Flags().IntVar(&portFilter, "port", 0, "Watch a specific port")
 	cmd.Flags().IntVar(&interval, "interval", 2, "Refresh interval in seconds")
+	cmd.Flags().StringVar(&queryFilter, "filter", "", "Filter with a query, e.g. 'group:backend'")
+	out.register(cmd)
+
+	return cmd
+}
+
+func serveCmd() *cobra.Command {
+	var (
+		metricsAddr string
+		interval    time.Duration
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve Prometheus metrics about listening ports",
+		Long: `Scan ports on an interval and serve the result as Prometheus metrics at
+/metrics: one series per listener labelled with port, protocol, process, user
+and group, its CPU and memory usage, accept queue and backlog of TCP
+listeners, established connection counts and traffic rates where the platform
+can report them, and scan duration and error counters.`,
+		Example: `  portpilot serve --metrics :9966
+  portpilot serve --metrics 127.0.0.1:9966 --interval 30s`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+
+			s, err := scanner.New()
+			if err != nil {
+				return err
+			}
+			cfg := loadConfig()
+
+			exp := metrics.New(s, cfg.GroupForPort)
+			exp.SetSaturationRule(cfg.Saturation.Rule())
+			logErr := func(err error) { fmt.Fprintf(os.Stderr, "Scan error: %v\n", err) }
+			if err := exp.Refresh(); err != nil {
+				logErr(err)
+			}
+
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			go exp.Run(ctx, interval, logErr)
+
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", exp)
+			srv := &http.Server{Addr: metricsAddr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+			go func() {
+				<-ctx.Done()
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				_ = srv.Shutdown(shutdownCtx)
+			}()
+
+			fmt.Fprintf(os.Stderr, "Serving metrics on %s/metrics, scanning every %s\n", metricsAddr, interval)
+			if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				return err
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&metricsAddr, "metrics", ":9966", "Address to serve /metrics on")
+	cmd.Flags().DurationVar(&interval, "interval", 15*time.Second, "Time between scans")
 
 	return cmd
 }
 
+func schemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of --output json",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			data, err := output.JSONSchema()
+			if err != nil {
+				return err
+			}
+			_, err = os.Stdout.Write(data)
+			return err
+		},
+	}
+}
+
 func versionCmd() *cobra.Command {
 	return &cobra.Command{
 		Use:   "version",
@@ -258,6 +565,17 @@ func versionCmd() *cobra.Command {
 	}
 }
 
+// loadConfig loads ~/.portpilot.yaml, warning and falling back to the
+// defaults if it can't be read.
+func loadConfig() *config.Config {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: config error: %v\n", err)
+		return config.DefaultConfig()
+	}
+	return cfg
+}
+
 func applyFilters(ports []scanner.PortInfo, port int, proc string) []scanner.PortInfo {
 	if port == 0 && proc == "" {
 		return ports
@@ -275,20 +593,3 @@ func applyFilters(ports []scanner.PortInfo, port int, proc string) []scanner.Por
 	}
 	return result
 }
-
-func printTable(ports []scanner.PortInfo) {
-	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintln(w, "PORT\tPROTO\tPID\tPROCESS\tUSER\tCPU%\tMEM%\tSTATE")
-	fmt.Fprintln(w, "----\t-----\t---\t-------\t----\t----\t----\t-----")
-	for _, p := range ports {
-		fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\t%.1f\t%.1f\t%s\n",
-			p.Port, p.Protocol, p.PID, p.ProcessName, p.User, p.CPU, p.Mem, p.State)
-	}
-	w.Flush()
-}
-
-func printJSON(ports []scanner.PortInfo) error {
-	enc := json.NewEncoder(os.Stdout)
-	enc.SetIndent("", "  ")
-	return enc.Encode(ports)
-}
\ No newline at end of file
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/spf13/cobra"
+
+	"github.com/AbdullahTarakji/portpilot/internal/columns"
+	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/daemon"
+	"github.com/AbdullahTarakji/portpilot/internal/output"
+	"github.com/AbdullahTarakji/portpilot/internal/probe"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// outputFlags are the flags shared by every command that prints ports.
+type outputFlags struct {
+	format    string
+	template  string
+	columns   string
+	noHeaders bool
+}
+
+func (f *outputFlags) register(cmd *cobra.Command) {
+	formats := make([]string, len(output.Formats))
+	for i, ft := range output.Formats {
+		formats[i] = string(ft)
+	}
+	cmd.Flags().StringVarP(&f.format, "output", "o", "table", "Output format: "+strings.Join(formats, "|"))
+	cmd.Flags().StringVar(&f.template, "template", "", "Go template executed per port, e.g. '{{.Port}} {{.ProcessName}}'")
+	cmd.Flags().StringVar(&f.columns, "columns", "", "Comma-separated columns to show ("+strings.Join(columns.Keys(), ",")+")")
+	cmd.Flags().BoolVar(&f.noHeaders, "no-headers", false, "Leave out the header row of table, csv, tsv and markdown output")
+}
+
+// options resolves the flags. defaultCols are shown unless --columns is
+// given. A --template without --output implies the template format.
+func (f *outputFlags) options(defaultCols []string) (output.Options, error) {
+	format, err := output.ParseFormat(f.format)
+	if err != nil {
+		return output.Options{}, err
+	}
+	if f.template != "" && format == output.Table {
+		format = output.Template
+	}
+	if format == output.Template {
+		if f.template == "" {
+			return output.Options{}, fmt.Errorf("--output template needs --template")
+		}
+		if _, err := output.ParseTemplate(f.template); err != nil {
+			return output.Options{}, err
+		}
+	}
+
+	cols, err := columns.Select(defaultCols)
+	if f.columns != "" {
+		cols, err = columns.Parse(f.columns)
+	}
+	if err != nil {
+		return output.Options{}, err
+	}
+
+	opts := output.Options{
+		Format:    format,
+		Template:  f.template,
+		Columns:   cols,
+		NoHeaders: f.noHeaders,
+	}
+	if format == output.Table && term.IsTerminal(os.Stdout.Fd()) {
+		if w, _, err := term.GetSize(os.Stdout.Fd()); err == nil {
+			opts.Width = w
+		}
+	}
+	return opts, nil
+}
+
+// scanPorts runs a scan and records where and when it happened for the
+// output envelope. With a daemon, its latest scan and metadata are used.
+func scanPorts(s scanner.Scanner) ([]scanner.PortInfo, output.Meta, error) {
+	if c, ok := s.(*daemon.Client); ok {
+		return c.Ports("")
+	}
+	hostname, _ := os.Hostname()
+	meta := output.Meta{
+		Hostname:  hostname,
+		ScannedAt: time.Now().UTC().Truncate(time.Second),
+		Backend:   scanner.Backend(s),
+	}
+	res, err := scanner.ScanResult(s)
+	meta.Warnings = res.Warnings()
+	return res.Ports, meta, err
+}
+
+// warnScan prints what a scan left out to stderr, so it doesn't end up in
+// piped output.
+func warnScan(meta output.Meta) {
+	for _, w := range meta.Warnings {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", w)
+	}
+}
+
+// writePorts prints ports to stdout with the given options.
+func writePorts(ports []scanner.PortInfo, meta output.Meta, opts output.Options, cfg *config.Config) error {
+	opts.Meta = meta
+	opts.Env = tableEnv(ports, opts.Columns, cfg)
+	return output.Write(os.Stdout, ports, opts)
+}
+
+// tableEnv supplies group names to the table columns and, when the health
+// column is shown, probes each port so it can be filled in.
+func tableEnv(ports []scanner.PortInfo, cols []columns.Column, cfg *config.Config) columns.Env {
+	env := columns.Env{GroupFor: cfg.GroupForPort}
+	if !columns.Has(cols, "health") {
+		return env
+	}
+
+	health := make(map[string]string)
+	for _, p := range ports {
+		health[fmt.Sprintf("%d/%s", p.Port, p.Protocol)] = probe.Port(p.Port, p.Protocol, probe.DefaultTimeout).Status()
+	}
+	env.Health = func(p scanner.PortInfo) string {
+		return health[fmt.Sprintf("%d/%s", p.Port, p.Protocol)]
+	}
+	return env
+}
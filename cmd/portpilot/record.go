@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AbdullahTarakji/portpilot/internal/process"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+func recordCmd() *cobra.Command {
+	var details bool
+
+	cmd := &cobra.Command{
+		Use:   "record FILE",
+		Short: "Record the commands and files a scan reads, as a test fixture",
+		Long: `Run a scan and save the output of every command it ran and every file it
+read to FILE, as JSON. A replay of the file gives the same scan on any
+machine, which makes it a regression test for the parsers and a way to
+reproduce a bug report.
+
+The recording holds the command lines of the listening processes and, with
+--details, their environments. Review it before sharing it.`,
+		Example: `  portpilot record scan.json
+  sudo portpilot record --details internal/scanner/testdata/replay/linux.json`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			rec := scanner.NewRecorder(scanner.Local, runtime.GOOS)
+			s, err := scanner.NewWith(rec, runtime.GOOS)
+			if err != nil {
+				return err
+			}
+			ports, err := s.Scan()
+			if err != nil {
+				return err
+			}
+			if cc, ok := s.(scanner.ConnectionCounter); ok {
+				_, _ = cc.Connections()
+			}
+			if tc, ok := s.(scanner.TrafficCounter); ok {
+				_, _ = tc.Traffic()
+			}
+
+			if details {
+				var pids []int
+				seen := make(map[int]bool)
+				for _, p := range ports {
+					if p.PID > 0 && !seen[p.PID] {
+						seen[p.PID] = true
+						pids = append(pids, p.PID)
+					}
+				}
+				sort.Ints(pids)
+				for _, pid := range pids {
+					_, _ = process.GetDetailsWith(rec, runtime.GOOS, pid)
+				}
+			}
+
+			if err := rec.Recording().Save(args[0]); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Recorded a scan of %d ports to %s\n", len(ports), args[0])
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&details, "details", false, "Also record the process details of every listener")
+
+	return cmd
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	"github.com/spf13/cobra"
+
+	"github.com/AbdullahTarakji/portpilot/internal/process"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+	"github.com/AbdullahTarakji/portpilot/internal/systemd"
+)
+
+// unitVerbs describes the stop, restart and status commands.
+var unitVerbs = map[string]struct {
+	short, long, done string
+}{
+	systemd.Stop: {
+		short: "Stop the service on a port, through systemd if it manages it",
+		long: `Stop what is listening on a port. A listener that belongs to a systemd
+service is stopped with systemctl stop, so systemd doesn't restart it; any
+other process is sent SIGTERM.`,
+		done: "Stopped",
+	},
+	systemd.Restart: {
+		short: "Restart the systemd service on a port",
+		long: `Restart the systemd service listening on a port with systemctl restart.
+Listeners outside systemd can't be restarted.`,
+		done: "Restarted",
+	},
+	systemd.Status: {
+		short: "Show the systemd status of the service on a port",
+		long:  "Show systemctl status for the systemd service listening on a port.",
+	},
+}
+
+// unitCmd builds the stop, restart or status command, which act on the
+// systemd units of the listeners on a port.
+func unitCmd(verb string) *cobra.Command {
+	var force bool
+	desc := unitVerbs[verb]
+
+	cmd := &cobra.Command{
+		Use:   verb + " <port>",
+		Short: desc.short,
+		Long:  desc.long,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			port := 0
+			if _, err := fmt.Sscanf(args[0], "%d", &port); err != nil {
+				return fmt.Errorf("invalid port: %s", args[0])
+			}
+
+			s, err := newScanner()
+			if err != nil {
+				return err
+			}
+			ports, err := s.Scan()
+			if err != nil {
+				return fmt.Errorf("scanning: %w", err)
+			}
+			var targets []scanner.PortInfo
+			for _, p := range ports {
+				if p.Port == port {
+					targets = append(targets, p)
+				}
+			}
+			if len(targets) == 0 {
+				fmt.Printf("No process found on port %d\n", port)
+				return nil
+			}
+
+			units, unmanaged := systemd.Units(targets)
+			var errs []error
+			for _, p := range unmanaged {
+				switch verb {
+				case systemd.Stop:
+					if !force && !confirm(fmt.Sprintf("%q (PID %d) on port %d is not a systemd service. Send SIGTERM?", p.ProcessName, p.PID, p.Port)) {
+						fmt.Println("Cancelled.")
+						continue
+					}
+					if err := process.Kill(p.PID, syscall.SIGTERM); err != nil {
+						errs = append(errs, err)
+						continue
+					}
+					fmt.Printf("Sent SIGTERM to PID %d (%s) on port %d\n", p.PID, p.ProcessName, p.Port)
+				default:
+					errs = append(errs, fmt.Errorf("%q (PID %d) on port %d is %w", p.ProcessName, p.PID, p.Port, systemd.ErrNotManaged))
+				}
+			}
+
+			for _, u := range units {
+				if verb == systemd.Status {
+					if err := runStatus(u); err != nil {
+						errs = append(errs, err)
+					}
+					continue
+				}
+				if !force && !confirm(fmt.Sprintf("%s %s (port %d)?", capitalize(verb), u, port)) {
+					fmt.Println("Cancelled.")
+					continue
+				}
+				if _, err := systemd.Run(verb, u); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				fmt.Printf("%s %s\n", desc.done, u)
+			}
+			return errors.Join(errs...)
+		},
+	}
+
+	if verb != systemd.Status {
+		cmd.Flags().BoolVarP(&force, "force", "f", false, "Skip confirmation")
+	}
+	return cmd
+}
+
+// runStatus passes systemctl status through to the terminal. systemctl
+// exits non-zero for units that aren't running, which isn't an error here.
+func runStatus(u systemd.Unit) error {
+	c := systemd.Command(systemd.Status, u)
+	c.Stdout, c.Stderr = os.Stdout, os.Stderr
+	var exitErr *exec.ExitError
+	if err := c.Run(); err != nil && !errors.As(err, &exitErr) {
+		return fmt.Errorf("systemctl status %s: %w", u.Name, err)
+	}
+	return nil
+}
+
+// confirm asks a yes/no question on the terminal; anything but y is no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	var answer string
+	_, _ = fmt.Scanln(&answer)
+	return strings.ToLower(answer) == "y"
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
@@ -0,0 +1,89 @@
+// Package action expands and runs user-defined command templates against a port.
+package action
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+var placeholderRe = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// Placeholders returns the names that may appear in braces in a command
+// template, in alphabetical order.
+func Placeholders() []string {
+	names := make([]string, 0, len(fields(scanner.PortInfo{}, "")))
+	for name := range fields(scanner.PortInfo{}, "") {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func fields(p scanner.PortInfo, group string) map[string]string {
+	host := p.Host
+	if host == "" {
+		host = "localhost"
+	}
+	return map[string]string{
+		"port":      strconv.Itoa(p.Port),
+		"pid":       strconv.Itoa(p.PID),
+		"process":   p.ProcessName,
+		"user":      p.User,
+		"protocol":  strings.ToLower(p.Protocol),
+		"state":     p.State,
+		"command":   p.Command,
+		"address":   p.Address,
+		"container": p.Container,
+		"project":   p.Project,
+		"dir":       p.WorkingDir,
+		"unit":      p.Unit,
+		"group":     group,
+		"host":      host,
+	}
+}
+
+// Expand fills the placeholders in a command template with the fields of a
+// port. Values are shell-quoted so the result is safe to pass to sh -c.
+func Expand(tmpl string, p scanner.PortInfo, group string) (string, error) {
+	values := fields(p, group)
+
+	var unknown []string
+	out := placeholderRe.ReplaceAllStringFunc(tmpl, func(m string) string {
+		name := m[1 : len(m)-1]
+		v, ok := values[name]
+		if !ok {
+			unknown = append(unknown, m)
+			return m
+		}
+		return shellQuote(v)
+	})
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("unknown placeholder %s in %q", strings.Join(unknown, ", "), tmpl)
+	}
+	return out, nil
+}
+
+// Command expands the template and returns a command that runs it through
+// the shell.
+func Command(tmpl string, p scanner.PortInfo, group string) (*exec.Cmd, error) {
+	expanded, err := Expand(tmpl, p, group)
+	if err != nil {
+		return nil, err
+	}
+	return exec.Command("sh", "-c", expanded), nil
+}
+
+// shellQuote wraps s in single quotes unless it only contains characters
+// that are safe to leave bare.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.,:/@+=%") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
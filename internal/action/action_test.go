@@ -0,0 +1,137 @@
+package action
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+func testPort() scanner.PortInfo {
+	return scanner.PortInfo{
+		Port:        3000,
+		Protocol:    "TCP",
+		PID:         4242,
+		ProcessName: "node",
+		User:        "mike",
+		State:       "LISTEN",
+		Command:     "node server.js --name 'demo'",
+		Address:     "127.0.0.1",
+		Container:   "3f4e5d6c7b8a",
+	}
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"open http://localhost:{port}", "open http://localhost:3000"},
+		{"kill -CONT {pid}", "kill -CONT 4242"},
+		{"echo {process}@{group}", "echo node@web"},
+		{"echo {command}", `echo 'node server.js --name '\''demo'\'''`},
+		{"echo {protocol}", "echo tcp"},
+		{"curl http://{address}:{port}", "curl http://127.0.0.1:3000"},
+		{"docker logs {container}", "docker logs 3f4e5d6c7b8a"},
+		{"no placeholders", "no placeholders"},
+	}
+
+	for _, tt := range tests {
+		got, err := Expand(tt.tmpl, testPort(), "web")
+		if err != nil {
+			t.Errorf("Expand(%q): unexpected error: %v", tt.tmpl, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Expand(%q): got %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestExpandConfiguredActions(t *testing.T) {
+	cfg, err := config.Parse([]byte(`
+actions:
+  - name: Open in browser
+    command: open http://localhost:{port}
+  - name: Container logs
+    command: docker logs {container}
+    interactive: true
+`))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	want := []string{"open http://localhost:3000", "docker logs 3f4e5d6c7b8a"}
+	for i, a := range cfg.Actions {
+		got, err := Expand(a.Command, testPort(), "")
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", a.Name, err)
+			continue
+		}
+		if got != want[i] {
+			t.Errorf("%s: got %q, want %q", a.Name, got, want[i])
+		}
+	}
+}
+
+func TestExpandHost(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"", "ssh localhost curl -s localhost:3000"},
+		{"db1", "ssh db1 curl -s localhost:3000"},
+	}
+	for _, tt := range tests {
+		p := testPort()
+		p.Host = tt.host
+		got, err := Expand("ssh {host} curl -s localhost:{port}", p, "")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("host %q: got %q, want %q", tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestExpandUnknownPlaceholder(t *testing.T) {
+	if _, err := Expand("docker logs {nope}", testPort(), ""); err == nil {
+		t.Error("expected error for unknown placeholder")
+	}
+}
+
+func TestExpandEmptyValueIsQuoted(t *testing.T) {
+	got, err := Expand("echo [{group}]", testPort(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "echo ['']" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestCommandRunsThroughShell(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	cmd, err := Command("echo {process}:{port}", testPort(), "")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		t.Fatalf("running command: %v", err)
+	}
+	if string(out) != "node:3000\n" {
+		t.Errorf("output: got %q", out)
+	}
+}
+
+func TestPlaceholders(t *testing.T) {
+	names := Placeholders()
+	if len(names) == 0 || names[0] != "address" {
+		t.Errorf("Placeholders: got %v", names)
+	}
+}
@@ -0,0 +1,133 @@
+package agent
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+type fakeScanner struct {
+	ports []scanner.PortInfo
+	err   error
+}
+
+func (f fakeScanner) Scan() ([]scanner.PortInfo, error) { return f.ports, f.err }
+
+func (f fakeScanner) Backend() string { return "fake" }
+
+// startAgent serves s over TLS on a loopback port and returns the agent's
+// config entry, pinned to its certificate.
+func startAgent(t *testing.T, s scanner.Scanner, token string) config.Agent {
+	t.Helper()
+	cert, err := SelfSigned([]string{"127.0.0.1"})
+	if err != nil {
+		t.Fatalf("SelfSigned: %v", err)
+	}
+	l, err := Listen("127.0.0.1:0", cert)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	hs := &http.Server{Handler: NewServer(s, token).Handler()}
+	go func() { _ = hs.Serve(l) }()
+	t.Cleanup(func() { _ = hs.Close() })
+	return config.Agent{Name: "vm1", Address: l.Addr().String(), Token: token, Fingerprint: Fingerprint(cert)}
+}
+
+func TestClientScan(t *testing.T) {
+	want := []scanner.PortInfo{{Port: 5432, Protocol: "TCP", PID: 200, ProcessName: "postgres"}}
+	a := startAgent(t, fakeScanner{ports: want}, "s3cret")
+
+	c, err := NewClient(a)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	ports, err := c.Scan()
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(ports) != 1 || ports[0].ProcessName != "postgres" || ports[0].PID != 200 {
+		t.Errorf("got %+v", ports)
+	}
+}
+
+func TestClientScanResult(t *testing.T) {
+	// The agent runs without root: sshd's owner is hidden from it.
+	a := startAgent(t, fakeScanner{ports: []scanner.PortInfo{{Port: 22, Protocol: "TCP"}}}, "s3cret")
+	c, _ := NewClient(a)
+	res, err := c.ScanResult()
+	if err != nil {
+		t.Fatalf("ScanResult: %v", err)
+	}
+	want := []string{"1 socket has no owner information; rerun with sudo to see other users' processes"}
+	if got := res.Reported; !slices.Equal(got, want) {
+		t.Errorf("warnings: got %q, want %q", got, want)
+	}
+}
+
+func TestClientRejected(t *testing.T) {
+	a := startAgent(t, fakeScanner{}, "s3cret")
+
+	wrongToken := a
+	wrongToken.Token = "guess"
+	otherCert, _ := SelfSigned([]string{"127.0.0.1"})
+	wrongPin := a
+	wrongPin.Fingerprint = Fingerprint(otherCert)
+	unpinned := a
+	unpinned.Fingerprint = ""
+
+	for name, tc := range map[string]struct {
+		agent config.Agent
+		want  string
+	}{
+		"token":       {wrongToken, "invalid or missing token"},
+		"fingerprint": {wrongPin, "does not match"},
+		"unpinned":    {unpinned, "certificate"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := NewClient(tc.agent)
+			if err != nil {
+				t.Fatalf("NewClient: %v", err)
+			}
+			if _, err := c.Scan(); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("got %v, want an error mentioning %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestScanError(t *testing.T) {
+	a := startAgent(t, fakeScanner{err: errors.New("ss not found")}, "s3cret")
+	c, _ := NewClient(a)
+	if _, err := c.Scan(); err == nil || !strings.Contains(err.Error(), "ss not found") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestLoadOrCreateCertificate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "portpilot", "agent.pem")
+	first, err := LoadOrCreateCertificate(path, []string{"vm1"})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	again, err := LoadOrCreateCertificate(path, []string{"vm1"})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if Fingerprint(first) != Fingerprint(again) {
+		t.Error("the saved certificate should be reused")
+	}
+}
+
+func TestNormalizeFingerprint(t *testing.T) {
+	for _, in := range []string{"sha256:ABCD", "ab:cd", " abcd "} {
+		if got := normalizeFingerprint(in); got != "sha256:abcd" {
+			t.Errorf("%q: got %q", in, got)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/output"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// requestTimeout bounds a scan request, including the agent's scan.
+const requestTimeout = 10 * time.Second
+
+// Client reads scans from one agent. It implements scanner.Scanner.
+type Client struct {
+	name  string
+	base  string
+	token string
+	http  *http.Client
+}
+
+// NewClient returns a client for the agent configured in a, without
+// contacting it.
+func NewClient(a config.Agent) (*Client, error) {
+	tlsConf, err := clientTLS(a.Address, a.Fingerprint, a.CA)
+	if err != nil {
+		return nil, fmt.Errorf("agent %s: %w", a.Name, err)
+	}
+	return &Client{
+		name:  a.Name,
+		base:  "https://" + a.Address,
+		token: a.Secret(),
+		http:  &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConf}, Timeout: requestTimeout},
+	}, nil
+}
+
+// Name returns the agent's name from the config.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Backend reports "agent".
+func (c *Client) Backend() string {
+	return "agent"
+}
+
+// Scan asks the agent for a fresh scan.
+func (c *Client) Scan() ([]scanner.PortInfo, error) {
+	res, err := c.ScanResult()
+	if err != nil {
+		return nil, err
+	}
+	return res.Ports, nil
+}
+
+// ScanResult asks the agent for a fresh scan, along with the warnings the
+// agent reported for it.
+func (c *Client) ScanResult() (scanner.Result, error) {
+	req, err := http.NewRequest(http.MethodGet, c.base+"/scan", nil)
+	if err != nil {
+		return scanner.Result{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return scanner.Result{}, fmt.Errorf("contacting agent: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return scanner.Result{}, fmt.Errorf("agent: %s", e.Error)
+		}
+		return scanner.Result{}, fmt.Errorf("agent: %s", resp.Status)
+	}
+	var env output.Envelope
+	if err := json.NewDecoder(resp.Body).Decode(&env); err != nil {
+		return scanner.Result{}, fmt.Errorf("decoding agent response: %w", err)
+	}
+	return scanner.Result{Ports: env.Ports, Reported: env.Warnings}, nil
+}
@@ -0,0 +1,106 @@
+// Package agent implements "portpilot agent", which serves the scans of the
+// machine it runs on to other portpilot instances over TLS, and the client
+// that reads them. Every request must carry the shared token as a bearer
+// token.
+package agent
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/output"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// TokenEnv holds the shared token when "portpilot agent" isn't given one.
+const TokenEnv = "PORTPILOT_AGENT_TOKEN"
+
+// DefaultAddress is where an agent listens unless told otherwise.
+const DefaultAddress = ":7070"
+
+// Server answers scan requests. Scans run on demand, one at a time.
+type Server struct {
+	scanner  scanner.Scanner
+	token    [sha256.Size]byte
+	hostname string
+	mu       sync.Mutex
+}
+
+// NewServer returns a server scanning with s that accepts requests
+// carrying token.
+func NewServer(s scanner.Scanner, token string) *Server {
+	hostname, _ := os.Hostname()
+	return &Server{scanner: s, token: sha256.Sum256([]byte(token)), hostname: hostname}
+}
+
+// Handler returns the agent's HTTP API:
+//
+//	GET /scan  a fresh scan, in the --output json format
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /scan", s.handleScan)
+	return s.authenticate(mux)
+}
+
+// authenticate rejects requests without the shared token. Comparing hashes
+// keeps the comparison constant-time whatever the length of the guess.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		sum := sha256.Sum256([]byte(token))
+		if !ok || subtle.ConstantTimeCompare(sum[:], s.token[:]) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("invalid or missing token"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	res, err := scanner.ScanResult(s.scanner)
+	at := time.Now()
+	s.mu.Unlock()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("scanning: %w", err))
+		return
+	}
+	meta := output.Meta{Hostname: s.hostname, ScannedAt: at, Backend: scanner.Backend(s.scanner), Warnings: res.Warnings()}
+	writeJSON(w, http.StatusOK, output.NewEnvelope(res.Ports, meta))
+}
+
+// Listen opens a TLS listener on addr serving cert.
+func Listen(addr string, cert tls.Certificate) (net.Listener, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return tls.NewListener(l, &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}), nil
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
@@ -0,0 +1,165 @@
+package agent
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// certLifetime is how long a generated certificate is valid.
+const certLifetime = 5 * 365 * 24 * time.Hour
+
+// LoadOrCreateCertificate reads the certificate and key PEM blocks stored
+// together at path, generating and saving a self-signed pair for hosts
+// first if the file doesn't exist. Keeping the pair means the fingerprint
+// clients pin survives restarts.
+func LoadOrCreateCertificate(path string, hosts []string) (tls.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		cert, err := tls.X509KeyPair(data, data)
+		if err != nil {
+			return tls.Certificate{}, fmt.Errorf("loading %s: %w", path, err)
+		}
+		return cert, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return tls.Certificate{}, err
+	}
+
+	certPEM, keyPEM, err := selfSigned(hosts)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := os.WriteFile(path, append(certPEM, keyPEM...), 0o600); err != nil {
+		return tls.Certificate{}, fmt.Errorf("saving certificate: %w", err)
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// SelfSigned returns a new self-signed certificate for hosts, which may be
+// names or IP addresses.
+func SelfSigned(hosts []string) (tls.Certificate, error) {
+	certPEM, keyPEM, err := selfSigned(hosts)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+func selfSigned(hosts []string) (certPEM, keyPEM []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "portpilot agent"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(certLifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if h != "" {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// Fingerprint returns the SHA-256 fingerprint of a certificate's leaf, in
+// the "sha256:HEX" form the config's fingerprint field takes.
+func Fingerprint(cert tls.Certificate) string {
+	if len(cert.Certificate) == 0 {
+		return ""
+	}
+	return fingerprint(cert.Certificate[0])
+}
+
+func fingerprint(der []byte) string {
+	sum := sha256.Sum256(der)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// normalizeFingerprint accepts a fingerprint with or without the "sha256:"
+// prefix and with or without colons between bytes, as openssl prints it.
+func normalizeFingerprint(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.TrimPrefix(s, "sha256:")
+	return "sha256:" + strings.ReplaceAll(s, ":", "")
+}
+
+// clientTLS returns the TLS settings for reaching an agent at address. A
+// pinned fingerprint replaces chain verification, since agents usually run
+// with self-signed certificates; otherwise the certificate must chain to
+// the CA bundle at caFile, or to the system roots if that is empty.
+func clientTLS(address, pin, caFile string) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	conf := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+
+	if pin != "" {
+		want := normalizeFingerprint(pin)
+		conf.InsecureSkipVerify = true
+		conf.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return fmt.Errorf("agent sent no certificate")
+			}
+			if got := fingerprint(cs.PeerCertificates[0].Raw); got != want {
+				return fmt.Errorf("certificate fingerprint %s does not match the configured %s", got, want)
+			}
+			return nil
+		}
+		return conf, nil
+	}
+
+	if caFile != "" {
+		data, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+		conf.RootCAs = pool
+	}
+	return conf, nil
+}
@@ -0,0 +1,168 @@
+// Package cgroup reads the resource accounting of the cgroup v2 a process
+// runs in: memory use against its limit, CPU time and throttling, and task
+// counts.
+package cgroup
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotV2 is returned for a process that isn't in a cgroup v2 hierarchy,
+// such as on cgroup v1 hosts or outside Linux.
+var ErrNotV2 = errors.New("not in a cgroup v2 hierarchy")
+
+// NearLimit is the share of a memory limit above which a cgroup is
+// considered close to it.
+const NearLimit = 0.9
+
+// Stats is the accounting of one cgroup. Limits are the tightest of the
+// cgroup and its ancestors; zero means unlimited.
+type Stats struct {
+	Path string // relative to the cgroup root, e.g. /system.slice/nginx.service
+
+	MemoryCurrent int64
+	MemoryMax     int64
+
+	CPUUsage     time.Duration
+	CPUThrottled time.Duration
+	NrThrottled  int64
+
+	PidsCurrent int64
+	PidsMax     int64
+}
+
+// MemoryRatio returns memory use as a share of the limit, or zero without
+// a limit.
+func (s Stats) MemoryRatio() float64 {
+	if s.MemoryMax <= 0 {
+		return 0
+	}
+	return float64(s.MemoryCurrent) / float64(s.MemoryMax)
+}
+
+// NearMemoryLimit reports whether memory use is at least NearLimit of the
+// limit.
+func (s Stats) NearMemoryLimit() bool {
+	return s.MemoryRatio() >= NearLimit
+}
+
+// Reader looks up the cgroup accounting of a process.
+type Reader interface {
+	Read(pid int) (Stats, error)
+}
+
+// FS reads cgroups from a proc filesystem and a cgroup v2 mount.
+type FS struct {
+	Proc string // e.g. /proc
+	Root string // e.g. /sys/fs/cgroup
+}
+
+// New returns a Reader for the running system.
+func New() Reader {
+	return FS{Proc: "/proc", Root: "/sys/fs/cgroup"}
+}
+
+// Read finds the cgroup of pid and reads its accounting. Files the kernel
+// doesn't provide, because a controller isn't enabled for the cgroup, are
+// left at zero.
+func (f FS) Read(pid int) (Stats, error) {
+	data, err := os.ReadFile(filepath.Join(f.Proc, strconv.Itoa(pid), "cgroup"))
+	if err != nil {
+		return Stats{}, fmt.Errorf("reading cgroup of pid %d: %w", pid, err)
+	}
+	rel, ok := unifiedPath(string(data))
+	if !ok {
+		return Stats{}, ErrNotV2
+	}
+	dir := filepath.Join(f.Root, filepath.FromSlash(rel))
+	if _, err := os.Stat(dir); err != nil {
+		return Stats{}, fmt.Errorf("reading cgroup %s: %w", rel, err)
+	}
+
+	s := Stats{Path: rel}
+	s.MemoryCurrent, _ = readInt(filepath.Join(dir, "memory.current"))
+	s.PidsCurrent, _ = readInt(filepath.Join(dir, "pids.current"))
+	if cpu, err := readKeyed(filepath.Join(dir, "cpu.stat")); err == nil {
+		s.CPUUsage = time.Duration(cpu["usage_usec"]) * time.Microsecond
+		s.CPUThrottled = time.Duration(cpu["throttled_usec"]) * time.Microsecond
+		s.NrThrottled = cpu["nr_throttled"]
+	}
+
+	// A limit anywhere above the cgroup applies to it too.
+	for p := rel; ; p = path.Dir(p) {
+		d := filepath.Join(f.Root, filepath.FromSlash(p))
+		s.MemoryMax = tighter(s.MemoryMax, d, "memory.max")
+		s.PidsMax = tighter(s.PidsMax, d, "pids.max")
+		if p == "/" || p == "." {
+			break
+		}
+	}
+	return s, nil
+}
+
+// unifiedPath returns the cgroup v2 path from the contents of
+// /proc/<pid>/cgroup, the line with hierarchy ID 0.
+func unifiedPath(cgroup string) (string, bool) {
+	for _, line := range strings.Split(cgroup, "\n") {
+		if p, ok := strings.CutPrefix(line, "0::"); ok {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+// tighter returns the smaller of limit and the limit in dir/file, where
+// zero means unlimited.
+func tighter(limit int64, dir, file string) int64 {
+	v, err := readInt(filepath.Join(dir, file))
+	if err != nil || v <= 0 {
+		return limit
+	}
+	if limit == 0 || v < limit {
+		return v
+	}
+	return limit
+}
+
+// readInt reads a single-value cgroup file. "max" reads as zero.
+func readInt(file string) (int64, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	s := strings.TrimSpace(string(data))
+	if s == "max" {
+		return 0, nil
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// readKeyed reads a flat keyed cgroup file such as cpu.stat.
+func readKeyed(file string) (map[string]int64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]int64)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, value, ok := strings.Cut(sc.Text(), " ")
+		if !ok {
+			continue
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64); err == nil {
+			values[key] = n
+		}
+	}
+	return values, sc.Err()
+}
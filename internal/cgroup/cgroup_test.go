@@ -0,0 +1,67 @@
+package cgroup
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var fixture = FS{Proc: "testdata/proc", Root: "testdata/sys/fs/cgroup"}
+
+func TestReadService(t *testing.T) {
+	s, err := fixture.Read(100)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := Stats{
+		Path:          "/system.slice/nginx.service",
+		MemoryCurrent: 500000000,
+		MemoryMax:     512 << 20,
+		CPUUsage:      723 * time.Second,
+		CPUThrottled:  1200 * time.Millisecond,
+		NrThrottled:   34,
+		PidsCurrent:   12,
+		PidsMax:       100,
+	}
+	if s != want {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+	if !s.NearMemoryLimit() {
+		t.Errorf("%.0f%% of the limit should be near it", s.MemoryRatio()*100)
+	}
+}
+
+func TestReadInheritsLimits(t *testing.T) {
+	s, err := fixture.Read(200)
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if s.MemoryMax != 2<<30 || s.PidsMax != 10000 {
+		t.Errorf("limits should come from the user slice: memory %d, pids %d", s.MemoryMax, s.PidsMax)
+	}
+	if s.CPUUsage != 0 {
+		t.Errorf("missing cpu.stat should read as zero, got %v", s.CPUUsage)
+	}
+	if s.NearMemoryLimit() {
+		t.Error("100 MiB of 2 GiB is not near the limit")
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	if _, err := fixture.Read(300); !errors.Is(err, ErrNotV2) {
+		t.Errorf("cgroup v1: got %v, want ErrNotV2", err)
+	}
+	if _, err := fixture.Read(400); err == nil {
+		t.Error("expected error for a cgroup that no longer exists")
+	}
+	if _, err := fixture.Read(999); err == nil {
+		t.Error("expected error for a missing process")
+	}
+}
+
+func TestMemoryRatioUnlimited(t *testing.T) {
+	s := Stats{MemoryCurrent: 1 << 30}
+	if s.MemoryRatio() != 0 || s.NearMemoryLimit() {
+		t.Errorf("no limit: ratio %v", s.MemoryRatio())
+	}
+}
@@ -0,0 +1,419 @@
+// Package columns defines the named columns shared by the TUI table and the
+// CLI's table output, along with how each one is displayed and sorted.
+package columns
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// Env supplies the values some columns show that aren't part of the scan
+// itself. Any field may be left unset.
+type Env struct {
+	GroupFor  func(port int) string
+	Health    func(p scanner.PortInfo) string
+	Saturated func(p scanner.PortInfo) bool
+	Stale     func(host string) bool
+	Now       time.Time
+}
+
+func (e Env) group(port int) string {
+	if e.GroupFor == nil {
+		return ""
+	}
+	return e.GroupFor(port)
+}
+
+func (e Env) health(p scanner.PortInfo) string {
+	if e.Health == nil {
+		return ""
+	}
+	return e.Health(p)
+}
+
+// IsStale reports whether a host's listeners are left over from an earlier
+// scan because it didn't answer the latest.
+func (e Env) IsStale(host string) bool {
+	return e.Stale != nil && e.Stale(host)
+}
+
+// IsSaturated reports whether a listener's accept queue has been stuck near
+// its backlog.
+func (e Env) IsSaturated(p scanner.PortInfo) bool {
+	return e.Saturated != nil && e.Saturated(p)
+}
+
+func (e Env) now() time.Time {
+	if e.Now.IsZero() {
+		return time.Now()
+	}
+	return e.Now
+}
+
+// Column describes one table column.
+type Column struct {
+	Key   string // canonical name used in config, flags and views
+	Title string
+	Width int // TUI width including padding
+	Value func(p scanner.PortInfo, env Env) string
+	// Less orders two ports by this column. When nil, the displayed values
+	// are compared case-insensitively.
+	Less func(a, b scanner.PortInfo) bool
+}
+
+var all = []Column{
+	{
+		Key: "port", Title: "Port", Width: 8,
+		Value: func(p scanner.PortInfo, _ Env) string { return strconv.Itoa(p.Port) },
+		Less:  func(a, b scanner.PortInfo) bool { return a.Port < b.Port },
+	},
+	{
+		Key: "proto", Title: "Proto", Width: 9,
+		Value: func(p scanner.PortInfo, _ Env) string { return p.Protocol },
+	},
+	{
+		Key: "pid", Title: "PID", Width: 8,
+		Value: func(p scanner.PortInfo, _ Env) string { return strconv.Itoa(p.PID) },
+		Less:  func(a, b scanner.PortInfo) bool { return a.PID < b.PID },
+	},
+	{
+		Key: "proc", Title: "Process", Width: 16,
+		Value: func(p scanner.PortInfo, _ Env) string { return p.ProcessName },
+	},
+	{
+		Key: "user", Title: "User", Width: 12,
+		Value: func(p scanner.PortInfo, _ Env) string { return p.User },
+	},
+	{
+		Key: "cpu", Title: "CPU%", Width: 8,
+		Value: func(p scanner.PortInfo, _ Env) string { return fmt.Sprintf("%.1f", p.CPU) },
+		Less:  func(a, b scanner.PortInfo) bool { return a.CPU < b.CPU },
+	},
+	{
+		Key: "mem", Title: "Mem%", Width: 8,
+		Value: func(p scanner.PortInfo, _ Env) string { return fmt.Sprintf("%.1f", p.Mem) },
+		Less:  func(a, b scanner.PortInfo) bool { return a.Mem < b.Mem },
+	},
+	{
+		Key: "state", Title: "State", Width: 9,
+		Value: func(p scanner.PortInfo, _ Env) string { return p.State },
+	},
+	{
+		Key: "addr", Title: "Address", Width: 17,
+		Value: func(p scanner.PortInfo, _ Env) string { return p.Address },
+	},
+	{
+		Key: "service", Title: "Service", Width: 12,
+		Value: func(p scanner.PortInfo, _ Env) string { return scanner.ServiceName(p.Port, p.Protocol) },
+	},
+	{
+		Key: "group", Title: "Group", Width: 12,
+		Value: func(p scanner.PortInfo, env Env) string { return env.group(p.Port) },
+	},
+	{
+		Key: "command", Title: "Command", Width: 30,
+		Value: func(p scanner.PortInfo, _ Env) string { return p.Command },
+	},
+	{
+		Key: "uptime", Title: "Uptime", Width: 10,
+		Value: func(p scanner.PortInfo, env Env) string {
+			if p.StartTime.IsZero() {
+				return ""
+			}
+			return FormatDuration(env.now().Sub(p.StartTime))
+		},
+		// Longer uptime means an earlier start; unknown start times sort
+		// as the shortest uptime.
+		Less: func(a, b scanner.PortInfo) bool {
+			switch {
+			case a.StartTime.IsZero():
+				return !b.StartTime.IsZero()
+			case b.StartTime.IsZero():
+				return false
+			}
+			return a.StartTime.After(b.StartTime)
+		},
+	},
+	{
+		Key: "rss", Title: "RSS", Width: 9,
+		Value: func(p scanner.PortInfo, _ Env) string { return FormatBytes(p.RSS) },
+		Less:  func(a, b scanner.PortInfo) bool { return a.RSS < b.RSS },
+	},
+	{
+		Key: "threads", Title: "Threads", Width: 11,
+		Value: func(p scanner.PortInfo, _ Env) string {
+			if p.Threads == 0 {
+				return ""
+			}
+			return strconv.Itoa(p.Threads)
+		},
+		Less: func(a, b scanner.PortInfo) bool { return a.Threads < b.Threads },
+	},
+	{
+		Key: "container", Title: "Container", Width: 14,
+		Value: func(p scanner.PortInfo, _ Env) string { return p.Container },
+	},
+	{
+		Key: "unit", Title: "Unit", Width: 22,
+		Value: func(p scanner.PortInfo, _ Env) string { return p.Unit },
+	},
+	{
+		Key: "project", Title: "Project", Width: 16,
+		Value: func(p scanner.PortInfo, _ Env) string { return p.Project },
+	},
+	{
+		Key: "branch", Title: "Branch", Width: 14,
+		Value: func(p scanner.PortInfo, _ Env) string { return p.Branch },
+	},
+	{
+		Key: "dir", Title: "Directory", Width: 30,
+		Value: func(p scanner.PortInfo, _ Env) string { return p.WorkingDir },
+	},
+	{
+		Key: "queue", Title: "Queue", Width: 12,
+		Value: func(p scanner.PortInfo, env Env) string {
+			if p.Protocol != "TCP" || p.SendQ <= 0 {
+				return ""
+			}
+			q := fmt.Sprintf("%d/%d", p.RecvQ, p.SendQ)
+			if env.IsSaturated(p) {
+				q += " ⚠"
+			}
+			return q
+		},
+		Less: func(a, b scanner.PortInfo) bool { return a.Saturation() < b.Saturation() },
+	},
+	{
+		Key: "rx", Title: "Rx/s", Width: 10,
+		Value: func(p scanner.PortInfo, _ Env) string { return FormatRate(p.RxBytes) },
+		Less:  func(a, b scanner.PortInfo) bool { return a.RxBytes < b.RxBytes },
+	},
+	{
+		Key: "tx", Title: "Tx/s", Width: 10,
+		Value: func(p scanner.PortInfo, _ Env) string { return FormatRate(p.TxBytes) },
+		Less:  func(a, b scanner.PortInfo) bool { return a.TxBytes < b.TxBytes },
+	},
+	{
+		Key: "rxpkts", Title: "RxPkt/s", Width: 10,
+		Value: func(p scanner.PortInfo, _ Env) string { return formatCount(p.RxPackets) },
+		Less:  func(a, b scanner.PortInfo) bool { return a.RxPackets < b.RxPackets },
+	},
+	{
+		Key: "txpkts", Title: "TxPkt/s", Width: 10,
+		Value: func(p scanner.PortInfo, _ Env) string { return formatCount(p.TxPackets) },
+		Less:  func(a, b scanner.PortInfo) bool { return a.TxPackets < b.TxPackets },
+	},
+	{
+		Key: "host", Title: "Host", Width: 14,
+		Value: func(p scanner.PortInfo, env Env) string {
+			host := p.Host
+			if host == "" {
+				host = "local"
+			}
+			if env.IsStale(p.Host) {
+				host += " (stale)"
+			}
+			return host
+		},
+	},
+	{
+		Key: "health", Title: "Health", Width: 10,
+		Value: func(p scanner.PortInfo, env Env) string { return env.health(p) },
+	},
+}
+
+// defaultKeys are the columns shown when none are configured.
+var defaultKeys = []string{"port", "proto", "pid", "proc", "user", "cpu", "mem", "state"}
+
+var aliases = map[string]string{
+	"process":  "proc",
+	"name":     "proc",
+	"protocol": "proto",
+	"memory":   "mem",
+	"address":  "addr",
+	"bind":     "addr",
+	"cmd":      "command",
+	"proj":     "project",
+	"cwd":      "dir",
+	"hostname": "host",
+}
+
+// All returns every available column.
+func All() []Column {
+	return append([]Column(nil), all...)
+}
+
+// Default returns the columns shown when none are configured.
+func Default() []Column {
+	cols, _ := Select(defaultKeys)
+	return cols
+}
+
+// Keys returns the canonical keys of every available column.
+func Keys() []string {
+	keys := make([]string, len(all))
+	for i, c := range all {
+		keys[i] = c.Key
+	}
+	return keys
+}
+
+// Lookup finds a column by key or alias, ignoring case.
+func Lookup(key string) (Column, bool) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	if canon, ok := aliases[key]; ok {
+		key = canon
+	}
+	for _, c := range all {
+		if c.Key == key {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+// Select resolves a list of column keys in the given order. An empty list
+// selects the default columns.
+func Select(keys []string) ([]Column, error) {
+	if len(keys) == 0 {
+		keys = defaultKeys
+	}
+	cols := make([]Column, 0, len(keys))
+	seen := make(map[string]bool)
+	for _, k := range keys {
+		c, ok := Lookup(k)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q (available: %s)", k, strings.Join(Keys(), ", "))
+		}
+		if seen[c.Key] {
+			return nil, fmt.Errorf("column %q listed twice", c.Key)
+		}
+		seen[c.Key] = true
+		cols = append(cols, c)
+	}
+	return cols, nil
+}
+
+// Parse resolves a comma-separated column list such as "port,proc,addr".
+func Parse(list string) ([]Column, error) {
+	var keys []string
+	for _, k := range strings.Split(list, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return Select(keys)
+}
+
+// Has reports whether cols contains the column with the given key.
+func Has(cols []Column, key string) bool {
+	for _, c := range cols {
+		if c.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Sort returns a copy of ports sorted by the given column. Unknown keys sort
+// by port.
+func Sort(ports []scanner.PortInfo, key string, asc bool, env Env) []scanner.PortInfo {
+	c, ok := Lookup(key)
+	if !ok {
+		c = all[0]
+	}
+
+	less := c.Less
+	if less == nil {
+		less = func(a, b scanner.PortInfo) bool {
+			return strings.ToLower(c.Value(a, env)) < strings.ToLower(c.Value(b, env))
+		}
+	}
+
+	sorted := make([]scanner.PortInfo, len(ports))
+	copy(sorted, ports)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if asc {
+			return less(sorted[i], sorted[j])
+		}
+		return less(sorted[j], sorted[i])
+	})
+	return sorted
+}
+
+// FormatDuration renders a duration compactly using its two largest units,
+// e.g. "3d4h", "2h13m" or "45s".
+func FormatDuration(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int(d/time.Hour) % 24
+	mins := int(d/time.Minute) % 60
+	secs := int(d/time.Second) % 60
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, mins)
+	case mins > 0:
+		return fmt.Sprintf("%dm%ds", mins, secs)
+	default:
+		return fmt.Sprintf("%ds", secs)
+	}
+}
+
+// FormatRate renders a byte rate, e.g. "1.5M/s". Rates under one byte a
+// second render as "".
+func FormatRate(bytesPerSec float64) string {
+	if s := FormatBytes(int64(bytesPerSec)); s != "" {
+		return s + "/s"
+	}
+	return ""
+}
+
+// formatCount renders a rate of events per second, with k and M for
+// thousands and millions. Zero renders as "".
+func formatCount(v float64) string {
+	switch {
+	case v < 0.05:
+		return ""
+	case v < 10:
+		return fmt.Sprintf("%.1f", v)
+	case v < 1e4:
+		return fmt.Sprintf("%.0f", v)
+	case v < 1e6:
+		return fmt.Sprintf("%.0fk", v/1e3)
+	default:
+		return fmt.Sprintf("%.1fM", v/1e6)
+	}
+}
+
+// FormatBytes renders a byte count with a binary unit suffix, e.g. "512K"
+// or "12.3M". Zero renders as "".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n <= 0 {
+		return ""
+	}
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	v := float64(n) / unit
+	for _, suffix := range []string{"K", "M", "G"} {
+		if v < unit {
+			if v < 10 {
+				return fmt.Sprintf("%.1f%s", v, suffix)
+			}
+			return fmt.Sprintf("%.0f%s", v, suffix)
+		}
+		v /= unit
+	}
+	return fmt.Sprintf("%.1fT", v)
+}
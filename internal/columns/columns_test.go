@@ -0,0 +1,201 @@
+package columns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{"port", "port", true},
+		{"PROC", "proc", true},
+		{"process", "proc", true},
+		{"protocol", "proto", true},
+		{" mem ", "mem", true},
+		{"address", "addr", true},
+		{"uptime", "uptime", true},
+		{"bogus", "", false},
+	}
+	for _, tt := range tests {
+		c, ok := Lookup(tt.key)
+		if ok != tt.ok || c.Key != tt.want {
+			t.Errorf("Lookup(%q): got %q/%v, want %q/%v", tt.key, c.Key, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestSelect(t *testing.T) {
+	cols, err := Select([]string{"cpu", "port", "process"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cols) != 3 || cols[0].Key != "cpu" || cols[2].Key != "proc" {
+		t.Errorf("Select: got %v", cols)
+	}
+
+	def, err := Select(nil)
+	if err != nil || len(def) != 8 || def[3].Key != "proc" {
+		t.Errorf("Select(nil): got %d columns, err %v", len(def), err)
+	}
+
+	if _, err := Select([]string{"port", "nope"}); err == nil {
+		t.Error("expected error for unknown column")
+	}
+	if _, err := Select([]string{"port", "proc", "process"}); err == nil {
+		t.Error("expected error for a column listed twice")
+	}
+}
+
+func TestParse(t *testing.T) {
+	cols, err := Parse("port, proc,addr,,uptime")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var keys []string
+	for _, c := range cols {
+		keys = append(keys, c.Key)
+	}
+	if len(keys) != 4 || keys[2] != "addr" || keys[3] != "uptime" {
+		t.Errorf("Parse: got %v", keys)
+	}
+	if !Has(cols, "uptime") || Has(cols, "cpu") {
+		t.Error("Has: unexpected result")
+	}
+}
+
+func TestSort(t *testing.T) {
+	ports := []scanner.PortInfo{
+		{Port: 8080, ProcessName: "java", CPU: 5},
+		{Port: 3000, ProcessName: "Node", CPU: 50},
+		{Port: 5432, ProcessName: "postgres", CPU: 1},
+	}
+
+	byCPU := Sort(ports, "cpu", false, Env{})
+	if byCPU[0].Port != 3000 || byCPU[2].Port != 5432 {
+		t.Errorf("sort by cpu desc: got %v", byCPU)
+	}
+
+	byName := Sort(ports, "process", true, Env{})
+	if byName[0].ProcessName != "java" || byName[1].ProcessName != "Node" {
+		t.Errorf("sort by proc asc should ignore case: got %v", byName)
+	}
+
+	if ports[0].Port != 8080 {
+		t.Error("Sort should not modify its input")
+	}
+
+	byDefault := Sort(ports, "bogus", true, Env{})
+	if byDefault[0].Port != 3000 {
+		t.Errorf("unknown key should sort by port: got %v", byDefault)
+	}
+}
+
+func TestSortByEnvColumn(t *testing.T) {
+	ports := []scanner.PortInfo{{Port: 3000}, {Port: 5432}, {Port: 8080}}
+	groups := map[int]string{3000: "web", 5432: "db", 8080: "api"}
+	env := Env{GroupFor: func(port int) string { return groups[port] }}
+
+	sorted := Sort(ports, "group", true, env)
+	if sorted[0].Port != 8080 || sorted[1].Port != 5432 || sorted[2].Port != 3000 {
+		t.Errorf("sort by group: got %v", sorted)
+	}
+}
+
+func TestQueueColumn(t *testing.T) {
+	col, ok := Lookup("queue")
+	if !ok {
+		t.Fatal("queue column missing")
+	}
+	full := scanner.PortInfo{Port: 8080, Protocol: "TCP", RecvQ: 97, SendQ: 100}
+	idle := scanner.PortInfo{Port: 80, Protocol: "TCP", RecvQ: 0, SendQ: 511}
+	udp := scanner.PortInfo{Port: 53, Protocol: "UDP", RecvQ: 4096}
+
+	env := Env{Saturated: func(p scanner.PortInfo) bool { return p.Port == 8080 }}
+	for p, want := range map[*scanner.PortInfo]string{&full: "97/100 ⚠", &idle: "0/511", &udp: ""} {
+		if got := col.Value(*p, env); got != want {
+			t.Errorf("port %d: got %q, want %q", p.Port, got, want)
+		}
+	}
+
+	sorted := Sort([]scanner.PortInfo{full, udp, idle}, "queue", false, Env{})
+	if sorted[0].Port != 8080 {
+		t.Errorf("sort by saturation: got %v", sorted)
+	}
+}
+
+func TestTrafficColumns(t *testing.T) {
+	busy := scanner.PortInfo{Port: 443, Protocol: "TCP", RxBytes: 1.5 * 1024 * 1024, TxBytes: 300, RxPackets: 12500, TxPackets: 2.25}
+	idle := scanner.PortInfo{Port: 22, Protocol: "TCP"}
+	for key, want := range map[string]string{"rx": "1.5M/s", "tx": "300B/s", "rxpkts": "12k", "txpkts": "2.2"} {
+		col, _ := Lookup(key)
+		if got := col.Value(busy, Env{}); got != want {
+			t.Errorf("%s: got %q, want %q", key, got, want)
+		}
+		if got := col.Value(idle, Env{}); got != "" {
+			t.Errorf("%s of an idle port: got %q", key, got)
+		}
+	}
+
+	sorted := Sort([]scanner.PortInfo{busy, idle}, "rx", true, Env{})
+	if sorted[0].Port != 22 {
+		t.Errorf("sort by rx: got %v", sorted)
+	}
+}
+
+func TestSortByUptime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ports := []scanner.PortInfo{
+		{Port: 1, StartTime: now.Add(-time.Hour)},
+		{Port: 2},
+		{Port: 3, StartTime: now.Add(-48 * time.Hour)},
+	}
+	sorted := Sort(ports, "uptime", false, Env{Now: now})
+	if sorted[0].Port != 3 || sorted[1].Port != 1 || sorted[2].Port != 2 {
+		t.Errorf("sort by uptime desc: got %v", sorted)
+	}
+
+	up, _ := Lookup("uptime")
+	if got := up.Value(ports[2], Env{Now: now}); got != "2d0h" {
+		t.Errorf("uptime value: got %q, want 2d0h", got)
+	}
+	if got := up.Value(ports[1], Env{Now: now}); got != "" {
+		t.Errorf("unknown uptime: got %q, want empty", got)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := map[time.Duration]string{
+		0:                             "0s",
+		45 * time.Second:              "45s",
+		3*time.Minute + 5*time.Second: "3m5s",
+		2*time.Hour + 13*time.Minute:  "2h13m",
+		76*time.Hour + 30*time.Minute: "3d4h",
+		-time.Second:                  "0s",
+	}
+	for d, want := range tests {
+		if got := FormatDuration(d); got != want {
+			t.Errorf("FormatDuration(%v): got %q, want %q", d, got, want)
+		}
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		0:                          "",
+		512:                        "512B",
+		2048:                       "2.0K",
+		20480 * 1024:               "20M",
+		3 * 1024 * 1024 * 1024 / 2: "1.5G",
+	}
+	for n, want := range tests {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d): got %q, want %q", n, got, want)
+		}
+	}
+}
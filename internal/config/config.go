@@ -3,10 +3,19 @@ package config
 
 import (
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/AbdullahTarakji/portpilot/internal/columns"
+	"github.com/AbdullahTarakji/portpilot/internal/history"
+	"github.com/AbdullahTarakji/portpilot/internal/query"
+	"github.com/AbdullahTarakji/portpilot/internal/saturation"
 )
 
 // Config represents the portpilot configuration.
@@ -14,6 +23,124 @@ type Config struct {
 	Groups          map[string]Group `yaml:"groups"`
 	RefreshInterval int              `yaml:"refresh_interval"`
 	ShowSystemPorts bool             `yaml:"show_system_ports"`
+	Columns         []string         `yaml:"columns"`
+	Actions         []Action         `yaml:"actions"`
+	Views           []View           `yaml:"views"`
+	Notifiers       []Notifier       `yaml:"notifiers"`
+	History         History          `yaml:"history"`
+	Saturation      Saturation       `yaml:"saturation"`
+	Agents          []Agent          `yaml:"agents"`
+	SSH             []SSHHost        `yaml:"ssh"`
+}
+
+// History controls the port event log kept by the daemon and the TUI.
+// MaxAge takes a duration such as "7d" or "12h" and MaxSizeMB a size in
+// megabytes; unset fields use history.DefaultRetention.
+type History struct {
+	Disabled  bool   `yaml:"disabled"`
+	MaxAge    string `yaml:"max_age"`
+	MaxSizeMB int    `yaml:"max_size_mb"`
+}
+
+// Retention returns the history limits. The config is validated when
+// parsed, so MaxAge is known to be valid.
+func (h History) Retention() history.Retention {
+	r := history.DefaultRetention
+	if d, err := history.ParseDuration(h.MaxAge); err == nil {
+		r.MaxAge = d
+	}
+	if h.MaxSizeMB > 0 {
+		r.MaxBytes = int64(h.MaxSizeMB) << 20
+	}
+	return r
+}
+
+// Saturation tunes when a TCP listener's accept queue counts as stuck:
+// Threshold is a percentage of the backlog and Scans the number of
+// consecutive scans it must be reached in. Unset fields use
+// saturation.DefaultRule.
+type Saturation struct {
+	Threshold float64 `yaml:"threshold"`
+	Scans     int     `yaml:"scans"`
+}
+
+// Rule returns the saturation rule.
+func (s Saturation) Rule() saturation.Rule {
+	r := saturation.DefaultRule
+	if s.Threshold > 0 {
+		r.Threshold = s.Threshold / 100
+	}
+	if s.Scans > 0 {
+		r.Scans = s.Scans
+	}
+	return r
+}
+
+// Agent is a remote "portpilot agent" whose ports the TUI shows alongside
+// the local ones. Address is the agent's host:port and Token the shared
+// secret it was started with, or TokenEnv the environment variable holding
+// it. The agent's certificate is checked against Fingerprint, the SHA-256
+// fingerprint it prints at startup, if set, else against the PEM bundle in
+// CA, else against the system roots. Timeout, a duration such as "3s",
+// is how long a scan of the agent may take before it is reported as
+// failing and its last listeners are marked stale.
+type Agent struct {
+	Name        string `yaml:"name"`
+	Address     string `yaml:"address"`
+	Token       string `yaml:"token"`
+	TokenEnv    string `yaml:"token_env"`
+	CA          string `yaml:"ca"`
+	Fingerprint string `yaml:"fingerprint"`
+	Timeout     string `yaml:"timeout"`
+}
+
+// Secret returns the agent's token, reading TokenEnv if Token is unset.
+func (a Agent) Secret() string {
+	if a.Token != "" {
+		return a.Token
+	}
+	return os.Getenv(a.TokenEnv)
+}
+
+// SSHHost is a remote machine scanned by running ss or lsof on it over
+// ssh, for hosts that can't run an agent. Host is the destination as ssh
+// takes it, [user@]hostname or an alias from ~/.ssh/config; Port, User and
+// Identity (a private key file) override its settings, and Jump lists the
+// jump hosts to go through, in order. OS is "linux" (the default) or
+// "darwin". Timeout is as for Agent.
+type SSHHost struct {
+	Name     string   `yaml:"name"`
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"`
+	User     string   `yaml:"user"`
+	Jump     []string `yaml:"jump"`
+	Identity string   `yaml:"identity"`
+	OS       string   `yaml:"os"`
+	Timeout  string   `yaml:"timeout"`
+}
+
+// ScanTimeout returns the agent's Timeout, or zero if unset. The config is
+// validated when parsed, so Timeout is known to be valid.
+func (a Agent) ScanTimeout() time.Duration {
+	d, _ := time.ParseDuration(a.Timeout)
+	return d
+}
+
+// ScanTimeout returns the host's Timeout, or zero if unset.
+func (h SSHHost) ScanTimeout() time.Duration {
+	d, _ := time.ParseDuration(h.Timeout)
+	return d
+}
+
+// RemoteOS returns the host's operating system as GOOS spells it.
+func (h SSHHost) RemoteOS() string {
+	switch h.OS {
+	case "", "linux":
+		return "linux"
+	case "darwin", "macos":
+		return "darwin"
+	}
+	return h.OS
 }
 
 // Group defines a named port group with associated color.
@@ -22,6 +149,64 @@ type Group struct {
 	Color string `yaml:"color"`
 }
 
+// Action is a user-defined command that can be run against a port from the
+// TUI action menu. Command is a template such as "open http://localhost:{port}";
+// see the action package for the available placeholders.
+type Action struct {
+	Name        string `yaml:"name"`
+	Command     string `yaml:"command"`
+	Interactive bool   `yaml:"interactive"`
+}
+
+// View is a saved combination of filter, sort, visible columns and grouping
+// that can be switched to in the TUI or used with "list --view". Sort names a
+// column and is descending when prefixed with "-", e.g. "-cpu". A view
+// without Columns shows the config's default columns.
+type View struct {
+	Name    string   `yaml:"name"`
+	Filter  string   `yaml:"filter"`
+	Sort    string   `yaml:"sort"`
+	Columns []string `yaml:"columns"`
+	Grouped bool     `yaml:"grouped"`
+}
+
+// SortKey returns the column the view sorts by and whether the order is
+// ascending. An empty Sort sorts by port, ascending.
+func (v View) SortKey() (key string, asc bool) {
+	if v.Sort == "" {
+		return "port", true
+	}
+	if key, ok := strings.CutPrefix(v.Sort, "-"); ok {
+		return key, false
+	}
+	return v.Sort, true
+}
+
+// Notifier types.
+const (
+	NotifierWebhook = "webhook"
+	NotifierSlack   = "slack"
+	NotifierExec    = "exec"
+	NotifierDesktop = "desktop"
+)
+
+// Notifier sends port open and close events to a sink. Type is one of
+// webhook (JSON POST to URL), slack (Slack-compatible POST to URL), exec
+// (runs Command, an action template) or desktop. Events limits the kinds
+// sent ("open", "close"; default both), Filter is a query the port must
+// match, and RateLimit caps the notifications sent per minute, dropping the
+// rest. Zero means unlimited.
+type Notifier struct {
+	Name      string            `yaml:"name"`
+	Type      string            `yaml:"type"`
+	URL       string            `yaml:"url"`
+	Headers   map[string]string `yaml:"headers"`
+	Command   string            `yaml:"command"`
+	Events    []string          `yaml:"events"`
+	Filter    string            `yaml:"filter"`
+	RateLimit int               `yaml:"rate_limit"`
+}
+
 // DefaultConfig returns a Config with sensible defaults.
 func DefaultConfig() *Config {
 	return &Config{
@@ -71,9 +256,188 @@ func Parse(data []byte) (*Config, error) {
 		cfg.Groups = make(map[string]Group)
 	}
 
+	for i, a := range cfg.Actions {
+		if a.Name == "" || a.Command == "" {
+			return nil, fmt.Errorf("parsing config: action %d needs a name and a command", i+1)
+		}
+	}
+
+	if _, err := columns.Select(cfg.Columns); err != nil {
+		return nil, fmt.Errorf("parsing config: columns: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	for i, v := range cfg.Views {
+		if err := validateView(v); err != nil {
+			return nil, fmt.Errorf("parsing config: view %d: %w", i+1, err)
+		}
+		if seen[v.Name] {
+			return nil, fmt.Errorf("parsing config: duplicate view %q", v.Name)
+		}
+		seen[v.Name] = true
+	}
+
+	seen = make(map[string]bool)
+	for i, n := range cfg.Notifiers {
+		if err := validateNotifier(n); err != nil {
+			return nil, fmt.Errorf("parsing config: notifier %d: %w", i+1, err)
+		}
+		if seen[n.Name] {
+			return nil, fmt.Errorf("parsing config: duplicate notifier %q", n.Name)
+		}
+		seen[n.Name] = true
+	}
+
+	if cfg.History.MaxAge != "" {
+		if d, err := history.ParseDuration(cfg.History.MaxAge); err != nil || d <= 0 {
+			return nil, fmt.Errorf("parsing config: history: invalid max_age %q", cfg.History.MaxAge)
+		}
+	}
+	if cfg.History.MaxSizeMB < 0 {
+		return nil, fmt.Errorf("parsing config: history: max_size_mb must not be negative")
+	}
+	if cfg.Saturation.Threshold < 0 || cfg.Saturation.Threshold > 100 {
+		return nil, fmt.Errorf("parsing config: saturation: threshold must be a percentage between 0 and 100")
+	}
+	if cfg.Saturation.Scans < 0 {
+		return nil, fmt.Errorf("parsing config: saturation: scans must not be negative")
+	}
+
+	seen = make(map[string]bool)
+	for i, a := range cfg.Agents {
+		if err := validateAgent(a); err != nil {
+			return nil, fmt.Errorf("parsing config: agent %d: %w", i+1, err)
+		}
+		if seen[a.Name] {
+			return nil, fmt.Errorf("parsing config: duplicate agent %q", a.Name)
+		}
+		seen[a.Name] = true
+	}
+	// Agents and SSH hosts share one namespace: the host column.
+	for i, h := range cfg.SSH {
+		if err := validateSSHHost(h); err != nil {
+			return nil, fmt.Errorf("parsing config: ssh host %d: %w", i+1, err)
+		}
+		if seen[h.Name] {
+			return nil, fmt.Errorf("parsing config: duplicate host name %q", h.Name)
+		}
+		seen[h.Name] = true
+	}
+
 	return cfg, nil
 }
 
+func validateNotifier(n Notifier) error {
+	if n.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	switch n.Type {
+	case NotifierWebhook, NotifierSlack:
+		u, err := url.Parse(n.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s: needs an http(s) url", n.Name)
+		}
+	case NotifierExec:
+		if n.Command == "" {
+			return fmt.Errorf("%s: needs a command", n.Name)
+		}
+	case NotifierDesktop:
+	default:
+		return fmt.Errorf("%s: unknown type %q (available: webhook, slack, exec, desktop)", n.Name, n.Type)
+	}
+	for _, e := range n.Events {
+		if e != "open" && e != "close" {
+			return fmt.Errorf("%s: unknown event %q (available: open, close)", n.Name, e)
+		}
+	}
+	if _, err := query.Parse(n.Filter); err != nil {
+		return fmt.Errorf("%s: invalid filter: %w", n.Name, err)
+	}
+	if n.RateLimit < 0 {
+		return fmt.Errorf("%s: rate_limit must not be negative", n.Name)
+	}
+	return nil
+}
+
+func validateAgent(a Agent) error {
+	if a.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if _, _, err := net.SplitHostPort(a.Address); err != nil {
+		return fmt.Errorf("%s: invalid address %q: %w", a.Name, a.Address, err)
+	}
+	if a.Token == "" && a.TokenEnv == "" {
+		return fmt.Errorf("%s: needs a token or token_env", a.Name)
+	}
+	return validateTimeout(a.Name, a.Timeout)
+}
+
+func validateSSHHost(h SSHHost) error {
+	if h.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if h.Host == "" {
+		return fmt.Errorf("%s: missing host", h.Name)
+	}
+	if h.Port < 0 || h.Port > 65535 {
+		return fmt.Errorf("%s: invalid port %d", h.Name, h.Port)
+	}
+	if goos := h.RemoteOS(); goos != "linux" && goos != "darwin" {
+		return fmt.Errorf("%s: unknown os %q (available: linux, darwin)", h.Name, h.OS)
+	}
+	return validateTimeout(h.Name, h.Timeout)
+}
+
+func validateTimeout(name, timeout string) error {
+	if timeout == "" {
+		return nil
+	}
+	if d, err := time.ParseDuration(timeout); err != nil || d <= 0 {
+		return fmt.Errorf("%s: invalid timeout %q", name, timeout)
+	}
+	return nil
+}
+
+func validateView(v View) error {
+	if v.Name == "" {
+		return fmt.Errorf("missing name")
+	}
+	if _, err := query.Parse(v.Filter); err != nil {
+		return fmt.Errorf("%s: invalid filter: %w", v.Name, err)
+	}
+	if _, err := columns.Select(v.Columns); err != nil {
+		return fmt.Errorf("%s: %w", v.Name, err)
+	}
+	if key, _ := v.SortKey(); !hasColumn(key) {
+		return fmt.Errorf("%s: unknown sort column %q", v.Name, key)
+	}
+	return nil
+}
+
+func hasColumn(key string) bool {
+	_, ok := columns.Lookup(key)
+	return ok
+}
+
+// ColumnsFor returns the columns a view shows: its own, or the config's
+// default columns if it has none.
+func (c *Config) ColumnsFor(v View) []string {
+	if len(v.Columns) > 0 {
+		return v.Columns
+	}
+	return c.Columns
+}
+
+// View returns the saved view with the given name.
+func (c *Config) View(name string) (View, bool) {
+	for _, v := range c.Views {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return View{}, false
+}
+
 // GroupForPort returns the group name for a port, or empty string if ungrouped.
 func (c *Config) GroupForPort(port int) string {
 	for name, g := range c.Groups {
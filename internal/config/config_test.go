@@ -4,6 +4,10 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/history"
+	"github.com/AbdullahTarakji/portpilot/internal/saturation"
 )
 
 func TestParse(t *testing.T) {
@@ -119,3 +123,303 @@ func TestLoadFromFile(t *testing.T) {
 		t.Errorf("refresh_interval: got %d, want 10", cfg.RefreshInterval)
 	}
 }
+
+func TestParseActions(t *testing.T) {
+	cfg, err := Parse([]byte(`
+actions:
+  - name: Open in browser
+    command: open http://localhost:{port}
+  - name: Logs
+    command: docker logs -f {process}
+    interactive: true
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Actions) != 2 {
+		t.Fatalf("actions: got %d, want 2", len(cfg.Actions))
+	}
+	if cfg.Actions[0].Command != "open http://localhost:{port}" {
+		t.Errorf("command: got %q", cfg.Actions[0].Command)
+	}
+	if !cfg.Actions[1].Interactive {
+		t.Error("second action should be interactive")
+	}
+}
+
+func TestParseActionMissingCommand(t *testing.T) {
+	_, err := Parse([]byte(`
+actions:
+  - name: Broken
+`))
+	if err == nil {
+		t.Error("expected error for action without a command")
+	}
+}
+
+func TestParseViews(t *testing.T) {
+	cfg, err := Parse([]byte(`
+views:
+  - name: mine
+    filter: "user:alice proc:node"
+    sort: -cpu
+    columns: [port, process, cpu]
+  - name: grouped
+    grouped: true
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Views) != 2 {
+		t.Fatalf("views: got %d, want 2", len(cfg.Views))
+	}
+
+	v, ok := cfg.View("mine")
+	if !ok {
+		t.Fatal("view mine not found")
+	}
+	if key, asc := v.SortKey(); key != "cpu" || asc {
+		t.Errorf("SortKey: got %q/%v, want cpu/false", key, asc)
+	}
+	if len(v.Columns) != 3 {
+		t.Errorf("columns: got %v", v.Columns)
+	}
+
+	g, _ := cfg.View("grouped")
+	if key, asc := g.SortKey(); key != "port" || !asc {
+		t.Errorf("default SortKey: got %q/%v, want port/true", key, asc)
+	}
+	if !g.Grouped {
+		t.Error("grouped view should be grouped")
+	}
+
+	if _, ok := cfg.View("nope"); ok {
+		t.Error("unexpected view nope")
+	}
+}
+
+func TestParseInvalidViews(t *testing.T) {
+	tests := map[string]string{
+		"missing name":    "views:\n  - filter: port:80\n",
+		"duplicate":       "views:\n  - name: a\n  - name: a\n",
+		"bad filter":      "views:\n  - name: a\n    filter: 'port:('\n",
+		"bad column":      "views:\n  - name: a\n    columns: [port, bogus]\n",
+		"bad sort":        "views:\n  - name: a\n    sort: bogus\n",
+		"bad top columns": "columns: [port, nope]\n",
+	}
+	for name, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseNotifiers(t *testing.T) {
+	cfg, err := Parse([]byte(`
+notifiers:
+  - name: team
+    type: slack
+    url: https://hooks.slack.com/services/T0/B0/x
+    events: [open]
+    filter: "port:5432 OR port:3306"
+    rate_limit: 5
+  - name: log
+    type: exec
+    command: "logger portpilot {port}"
+  - name: me
+    type: desktop
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Notifiers) != 3 {
+		t.Fatalf("notifiers: got %d, want 3", len(cfg.Notifiers))
+	}
+	n := cfg.Notifiers[0]
+	if n.Type != NotifierSlack || n.RateLimit != 5 || len(n.Events) != 1 || n.Filter == "" {
+		t.Errorf("slack notifier: got %+v", n)
+	}
+}
+
+func TestParseInvalidNotifiers(t *testing.T) {
+	tests := map[string]string{
+		"missing name":    "notifiers:\n  - type: desktop\n",
+		"unknown type":    "notifiers:\n  - name: a\n    type: email\n",
+		"webhook no url":  "notifiers:\n  - name: a\n    type: webhook\n",
+		"webhook bad url": "notifiers:\n  - name: a\n    type: webhook\n    url: ftp://x\n",
+		"exec no command": "notifiers:\n  - name: a\n    type: exec\n",
+		"bad event":       "notifiers:\n  - name: a\n    type: desktop\n    events: [restart]\n",
+		"bad filter":      "notifiers:\n  - name: a\n    type: desktop\n    filter: 'port:('\n",
+		"negative limit":  "notifiers:\n  - name: a\n    type: desktop\n    rate_limit: -1\n",
+		"duplicate name":  "notifiers:\n  - name: a\n    type: desktop\n  - name: a\n    type: desktop\n",
+	}
+	for name, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseHistory(t *testing.T) {
+	cfg, err := Parse([]byte("history:\n  max_age: 7d\n  max_size_mb: 5\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r := cfg.History.Retention()
+	if r.MaxAge != 7*24*time.Hour || r.MaxBytes != 5<<20 {
+		t.Errorf("retention: got %+v", r)
+	}
+
+	def, _ := Parse(nil)
+	if def.History.Retention() != history.DefaultRetention {
+		t.Errorf("default retention: got %+v", def.History.Retention())
+	}
+
+	for _, bad := range []string{"history:\n  max_age: soon\n", "history:\n  max_age: 0s\n", "history:\n  max_size_mb: -1\n"} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestParseSaturation(t *testing.T) {
+	cfg, err := Parse([]byte("saturation:\n  threshold: 95\n  scans: 5\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r := cfg.Saturation.Rule(); r.Threshold != 0.95 || r.Scans != 5 {
+		t.Errorf("rule: got %+v", r)
+	}
+
+	def, _ := Parse(nil)
+	if def.Saturation.Rule() != saturation.DefaultRule {
+		t.Errorf("default rule: got %+v", def.Saturation.Rule())
+	}
+
+	for _, bad := range []string{"saturation:\n  threshold: 150\n", "saturation:\n  threshold: -1\n", "saturation:\n  scans: -2\n"} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestParseAgents(t *testing.T) {
+	t.Setenv("VM1_TOKEN", "from-env")
+	cfg, err := Parse([]byte(`
+agents:
+  - name: vm1
+    address: vm1.internal:7070
+    token_env: VM1_TOKEN
+    fingerprint: sha256:ab12
+  - name: vm2
+    address: 10.0.0.2:7070
+    token: s3cret
+    timeout: 3s
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Agents) != 2 {
+		t.Fatalf("agents: got %d, want 2", len(cfg.Agents))
+	}
+	if got := cfg.Agents[0].Secret(); got != "from-env" {
+		t.Errorf("token_env: got %q", got)
+	}
+	if got := cfg.Agents[1].Secret(); got != "s3cret" {
+		t.Errorf("token: got %q", got)
+	}
+	if cfg.Agents[0].ScanTimeout() != 0 || cfg.Agents[1].ScanTimeout() != 3*time.Second {
+		t.Errorf("timeout: got %v, %v", cfg.Agents[0].ScanTimeout(), cfg.Agents[1].ScanTimeout())
+	}
+
+	for _, bad := range []string{
+		"agents:\n  - address: vm1:7070\n    token: x\n",
+		"agents:\n  - name: vm1\n    address: vm1\n    token: x\n",
+		"agents:\n  - name: vm1\n    address: vm1:7070\n",
+		"agents:\n  - {name: vm1, address: 'vm1:7070', token: x}\n  - {name: vm1, address: 'vm2:7070', token: x}\n",
+		"agents:\n  - {name: vm1, address: 'vm1:7070', token: x, timeout: soon}\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestParseSSHHosts(t *testing.T) {
+	cfg, err := Parse([]byte(`
+ssh:
+  - name: db1
+    host: ops@db1.internal
+    jump: [bastion.example.com]
+  - name: mini
+    host: mac-mini.local
+    os: macos
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.SSH) != 2 || cfg.SSH[0].Jump[0] != "bastion.example.com" {
+		t.Fatalf("got %+v", cfg.SSH)
+	}
+	if cfg.SSH[0].RemoteOS() != "linux" || cfg.SSH[1].RemoteOS() != "darwin" {
+		t.Errorf("os: got %q, %q", cfg.SSH[0].RemoteOS(), cfg.SSH[1].RemoteOS())
+	}
+
+	for _, bad := range []string{
+		"ssh:\n  - host: db1\n",
+		"ssh:\n  - name: db1\n",
+		"ssh:\n  - {name: db1, host: db1, os: windows}\n",
+		"ssh:\n  - {name: db1, host: db1, port: 70000}\n",
+		"ssh:\n  - {name: db1, host: db1, timeout: -1s}\n",
+		"agents:\n  - {name: db1, address: 'db1:7070', token: x}\nssh:\n  - {name: db1, host: db1}\n",
+	} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "portpilot", "state.yaml")
+
+	st, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("missing state file: %v", err)
+	}
+	if st.LastView != "" {
+		t.Errorf("LastView: got %q, want empty", st.LastView)
+	}
+
+	if err := SaveState(path, State{LastView: "mine"}); err != nil {
+		t.Fatalf("SaveState: %v", err)
+	}
+	st, err = LoadState(path)
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if st.LastView != "mine" {
+		t.Errorf("LastView: got %q, want mine", st.LastView)
+	}
+}
+
+func TestColumnsFor(t *testing.T) {
+	cfg, err := Parse([]byte(`
+columns: [port, proc, addr, uptime]
+views:
+  - name: own
+    columns: [port, cpu]
+  - name: inherit
+    filter: proc:node
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	own, _ := cfg.View("own")
+	if got := cfg.ColumnsFor(own); len(got) != 2 || got[1] != "cpu" {
+		t.Errorf("own columns: got %v", got)
+	}
+	inherit, _ := cfg.View("inherit")
+	if got := cfg.ColumnsFor(inherit); len(got) != 4 || got[3] != "uptime" {
+		t.Errorf("inherited columns: got %v", got)
+	}
+}
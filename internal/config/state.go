@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// State is UI state remembered between runs, kept apart from the
+// hand-edited config file.
+type State struct {
+	LastView string `yaml:"last_view,omitempty"`
+}
+
+// StatePath returns the location of the state file, usually
+// ~/.config/portpilot/state.yaml.
+func StatePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "portpilot", "state.yaml"), nil
+}
+
+// HistoryPath returns the location of the port event history, usually
+// ~/.config/portpilot/history.jsonl.
+func HistoryPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "portpilot", "history.jsonl"), nil
+}
+
+// AgentCertPath returns where "portpilot agent" keeps the self-signed
+// certificate and key it generates, usually ~/.config/portpilot/agent.pem.
+func AgentCertPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "portpilot", "agent.pem"), nil
+}
+
+// LoadState reads the state file at path. A missing file yields an empty
+// State.
+func LoadState(path string) (State, error) {
+	var st State
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return st, nil
+		}
+		return st, fmt.Errorf("reading state %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &st); err != nil {
+		return State{}, fmt.Errorf("parsing state %s: %w", path, err)
+	}
+	return st, nil
+}
+
+// SaveState writes st to path, creating its directory if needed.
+func SaveState(path string, st State) error {
+	data, err := yaml.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("writing state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/output"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+const (
+	// connectTimeout bounds how long Connect waits for a daemon, so
+	// commands stay fast when none is running.
+	connectTimeout = 300 * time.Millisecond
+	// requestTimeout bounds every other request. Kill requests also get
+	// their grace period on top.
+	requestTimeout = 10 * time.Second
+)
+
+// Client talks to a running daemon. It implements scanner.Scanner, so the
+// CLI can use it in place of a local scan.
+type Client struct {
+	http *http.Client
+	base string
+}
+
+// NewClient returns a client for the daemon at addr without checking that
+// one is running.
+func NewClient(addr string) (*Client, error) {
+	network, address, err := parseAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: connectTimeout}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, address)
+		},
+	}
+	base := "http://portpilot"
+	if network == "tcp" {
+		base = "http://" + address
+	}
+	return &Client{http: &http.Client{Transport: transport}, base: base}, nil
+}
+
+// Connect returns a client for the daemon at addr, or an error if no daemon
+// answers there. A Unix socket is only trusted if it and its directory
+// belong to the current user.
+func Connect(addr string) (*Client, error) {
+	network, address, err := parseAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+	if network == "unix" {
+		if err := checkSocket(address); err != nil {
+			return nil, err
+		}
+	}
+	c, err := NewClient(addr)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*connectTimeout)
+	defer cancel()
+	var h Health
+	if err := c.do(ctx, http.MethodGet, "/health", nil, &h); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Health reports the daemon's status.
+func (c *Client) Health() (Health, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	var h Health
+	err := c.do(ctx, http.MethodGet, "/health", nil, &h)
+	return h, err
+}
+
+// Ports returns the listeners matching a filter query, and the metadata of
+// the scan they came from.
+func (c *Client) Ports(filter string) ([]scanner.PortInfo, output.Meta, error) {
+	path := "/ports"
+	if filter != "" {
+		path += "?filter=" + url.QueryEscape(filter)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	var env output.Envelope
+	if err := c.do(ctx, http.MethodGet, path, nil, &env); err != nil {
+		return nil, output.Meta{}, err
+	}
+	return env.Ports, env.Meta(), nil
+}
+
+// Port returns the listeners on one port.
+func (c *Client) Port(port int) ([]scanner.PortInfo, output.Meta, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+	var env output.Envelope
+	if err := c.do(ctx, http.MethodGet, "/ports/"+strconv.Itoa(port), nil, &env); err != nil {
+		return nil, output.Meta{}, err
+	}
+	return env.Ports, env.Meta(), nil
+}
+
+// Scan returns every listener from the daemon's latest scan.
+func (c *Client) Scan() ([]scanner.PortInfo, error) {
+	ports, _, err := c.Ports("")
+	return ports, err
+}
+
+// Kill asks the daemon to signal the listeners on a port.
+func (c *Client) Kill(port int, req KillRequest) (KillResponse, error) {
+	timeout := requestTimeout
+	if grace, err := time.ParseDuration(req.Grace); err == nil {
+		timeout += grace
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var resp KillResponse
+	err := c.do(ctx, http.MethodPost, "/ports/"+strconv.Itoa(port)+"/kill", req, &resp)
+	return resp, err
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("contacting daemon: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		if json.NewDecoder(resp.Body).Decode(&e) == nil && e.Error != "" {
+			return fmt.Errorf("daemon: %s", e.Error)
+		}
+		return fmt.Errorf("daemon: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding daemon response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,454 @@
+package daemon
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/events"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+type fakeScanner struct {
+	mu    sync.Mutex
+	ports []scanner.PortInfo
+}
+
+func (f *fakeScanner) Scan() ([]scanner.PortInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.ports, nil
+}
+
+func (f *fakeScanner) Backend() string { return "fake" }
+
+func (f *fakeScanner) set(ports []scanner.PortInfo) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ports = ports
+}
+
+type killCall struct {
+	pid   int
+	sig   os.Signal
+	grace time.Duration
+}
+
+// newTestServer starts a daemon over a scanner holding ports, with process
+// signalling recorded instead of performed.
+func newTestServer(t *testing.T, ports []scanner.PortInfo) (*Server, *fakeScanner, *Client, *[]killCall) {
+	t.Helper()
+	fs := &fakeScanner{ports: ports}
+	srv := NewServer(fs, func(port int) string {
+		if port == 5432 {
+			return "database"
+		}
+		return ""
+	})
+	var calls []killCall
+	srv.terminate = func(pid int, sig os.Signal, grace time.Duration) (bool, error) {
+		calls = append(calls, killCall{pid, sig, grace})
+		return grace > 0, nil
+	}
+	if err := srv.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	// Kill is only served on a Unix socket.
+	path := filepath.Join(t.TempDir(), "pp.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	hs := &http.Server{Handler: srv.Handler()}
+	go func() { _ = hs.Serve(l) }()
+	t.Cleanup(func() { _ = hs.Close() })
+	c, err := Connect(path)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	return srv, fs, c, &calls
+}
+
+func testPorts() []scanner.PortInfo {
+	return []scanner.PortInfo{
+		{Port: 3000, Protocol: "TCP", PID: 100, ProcessName: "node"},
+		{Port: 5432, Protocol: "TCP", PID: 200, ProcessName: "postgres"},
+		{Port: 8080, Protocol: "TCP", PID: 300, ProcessName: "java"},
+		{Port: 8080, Protocol: "TCP", PID: 301, ProcessName: "java"},
+	}
+}
+
+func TestPorts(t *testing.T) {
+	_, _, c, _ := newTestServer(t, testPorts())
+
+	ports, meta, err := c.Ports("")
+	if err != nil {
+		t.Fatalf("Ports: %v", err)
+	}
+	if len(ports) != 4 || meta.Backend != "fake" || meta.ScannedAt.IsZero() || len(meta.Warnings) != 0 {
+		t.Errorf("got %d ports, meta %+v", len(ports), meta)
+	}
+
+	ports, _, err = c.Ports("group:database")
+	if err != nil || len(ports) != 1 || ports[0].Port != 5432 {
+		t.Errorf("filtered: got %v, err %v", ports, err)
+	}
+
+	if _, _, err := c.Ports("cpu>"); err == nil || !strings.Contains(err.Error(), "invalid filter") {
+		t.Errorf("expected invalid filter error, got %v", err)
+	}
+}
+
+func TestPortsWarnings(t *testing.T) {
+	ports := append(testPorts(), scanner.PortInfo{Port: 22, Protocol: "TCP"})
+	_, _, c, _ := newTestServer(t, ports)
+
+	_, meta, err := c.Ports("")
+	if err != nil {
+		t.Fatalf("Ports: %v", err)
+	}
+	if len(meta.Warnings) != 1 || !strings.Contains(meta.Warnings[0], "1 socket has no owner information") {
+		t.Errorf("warnings: got %q", meta.Warnings)
+	}
+}
+
+func TestPort(t *testing.T) {
+	_, _, c, _ := newTestServer(t, testPorts())
+
+	ports, _, err := c.Port(8080)
+	if err != nil || len(ports) != 2 {
+		t.Errorf("got %v, err %v", ports, err)
+	}
+	ports, _, err = c.Port(9999)
+	if err != nil || len(ports) != 0 {
+		t.Errorf("free port: got %v, err %v", ports, err)
+	}
+	if _, _, err := c.Port(70000); err == nil {
+		t.Error("expected error for out-of-range port")
+	}
+}
+
+func TestKill(t *testing.T) {
+	_, _, c, calls := newTestServer(t, testPorts())
+
+	resp, err := c.Kill(8080, KillRequest{Signal: "INT", Grace: "2s"})
+	if err != nil {
+		t.Fatalf("Kill: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].PID != 300 || !resp.Results[0].Forced || resp.Results[0].Signal != "SIGINT" {
+		t.Errorf("results: %+v", resp.Results)
+	}
+	if len(*calls) != 2 || (*calls)[1].sig != syscall.SIGINT || (*calls)[1].grace != 2*time.Second {
+		t.Errorf("calls: %+v", *calls)
+	}
+
+	*calls = nil
+	if _, err := c.Kill(3000, KillRequest{}); err != nil || len(*calls) != 1 || (*calls)[0].sig != syscall.SIGTERM {
+		t.Errorf("default signal: calls %+v, err %v", *calls, err)
+	}
+
+	if _, err := c.Kill(9999, KillRequest{}); err == nil || !strings.Contains(err.Error(), "no process") {
+		t.Errorf("expected not-found error, got %v", err)
+	}
+	if _, err := c.Kill(3000, KillRequest{Signal: "BOGUS"}); err == nil {
+		t.Error("expected error for unknown signal")
+	}
+	if _, err := c.Kill(3000, KillRequest{Grace: "soon"}); err == nil {
+		t.Error("expected error for invalid grace")
+	}
+}
+
+func TestKillRescans(t *testing.T) {
+	_, fs, c, calls := newTestServer(t, testPorts())
+
+	// node exited after the last scan and another process took the port.
+	fs.set([]scanner.PortInfo{{Port: 3000, Protocol: "TCP", PID: 150, ProcessName: "vite"}})
+	if _, err := c.Kill(3000, KillRequest{PIDs: []int{100}}); err == nil || !strings.Contains(err.Error(), "no process") {
+		t.Errorf("expected the confirmed process to be gone, got %v", err)
+	}
+	if len(*calls) != 0 {
+		t.Fatalf("a process that wasn't confirmed was signalled: %+v", *calls)
+	}
+
+	resp, err := c.Kill(3000, KillRequest{})
+	if err != nil || len(resp.Results) != 1 || resp.Results[0].PID != 150 {
+		t.Errorf("expected the current owner to be signalled, got %+v, %v", resp.Results, err)
+	}
+
+	fs.set(testPorts())
+	*calls = nil
+	if _, err := c.Kill(8080, KillRequest{PIDs: []int{301}}); err != nil || len(*calls) != 1 || (*calls)[0].pid != 301 {
+		t.Errorf("PIDs: calls %+v, err %v", *calls, err)
+	}
+	if _, err := c.Kill(8080, KillRequest{Protocol: "udp"}); err == nil {
+		t.Error("expected no UDP listeners on 8080")
+	}
+}
+
+func TestKillGuards(t *testing.T) {
+	srv, _, _, calls := newTestServer(t, testPorts())
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	post := func(url string, header map[string]string, body string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	asJSON := map[string]string{"Content-Type": "application/json"}
+	if code := post(ts.URL+"/ports/3000/kill", asJSON, "{}"); code != http.StatusForbidden {
+		t.Errorf("kill over TCP: got %d, want 403", code)
+	}
+
+	// A Unix socket client sending what a browser or a careless script
+	// would.
+	path := filepath.Join(t.TempDir(), "guard.sock")
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	hs := &http.Server{Handler: srv.Handler()}
+	go func() { _ = hs.Serve(l) }()
+	defer hs.Close()
+	unixClient := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}}
+	tests := []struct {
+		name   string
+		header map[string]string
+		body   string
+		want   int
+	}{
+		{"no content type", nil, "{}", http.StatusUnsupportedMediaType},
+		{"form", map[string]string{"Content-Type": "text/plain"}, "{}", http.StatusUnsupportedMediaType},
+		{"empty body", asJSON, "", http.StatusBadRequest},
+		{"origin", map[string]string{"Content-Type": "application/json", "Origin": "https://example.com"}, "{}", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodPost, "http://portpilot/ports/3000/kill", strings.NewReader(tt.body))
+		for k, v := range tt.header {
+			req.Header.Set(k, v)
+		}
+		resp, err := unixClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+	if len(*calls) != 0 {
+		t.Errorf("nothing should have been signalled, got %+v", *calls)
+	}
+
+	// Reading stays open on loopback TCP, but not under another name.
+	req, _ := http.NewRequest(http.MethodGet, ts.URL+"/health", nil)
+	req.Host = "evil.example"
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("rebound host: got %v, %v, want 403", resp, err)
+	} else {
+		resp.Body.Close()
+	}
+	resp, err := http.Get(ts.URL + "/health")
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("loopback health: got %v, %v", resp, err)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+}
+
+func TestEvents(t *testing.T) {
+	srv, fs, _, _ := newTestServer(t, testPorts()[:2])
+	ts := httptest.NewServer(srv.Handler())
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatalf("subscribing: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("content type: got %q", ct)
+	}
+
+	fs.set(testPorts()[1:3])
+	if err := srv.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	var got []events.Event
+	r := bufio.NewReader(resp.Body)
+	for len(got) < 2 {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+		if !ok {
+			continue
+		}
+		var e events.Event
+		if err := json.Unmarshal([]byte(data), &e); err != nil {
+			t.Fatalf("decoding event %q: %v", data, err)
+		}
+		got = append(got, e)
+	}
+	if got[0].Kind != events.Close || got[0].Port.Port != 3000 || got[1].Kind != events.Open || got[1].Port.Port != 8080 {
+		t.Errorf("events: %+v", got)
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		addr    string
+		network string
+		err     bool
+	}{
+		{"/run/user/1000/portpilot.sock", "unix", false},
+		{"unix:portpilot.sock", "unix", false},
+		{"127.0.0.1:7766", "tcp", false},
+		{"localhost:7766", "tcp", false},
+		{"[::1]:7766", "tcp", false},
+		{"0.0.0.0:7766", "", true},
+		{":7766", "", true},
+		{"10.0.0.5:7766", "", true},
+		{"nonsense", "", true},
+	}
+	for _, tt := range tests {
+		network, _, err := parseAddress(tt.addr)
+		if (err != nil) != tt.err || network != tt.network {
+			t.Errorf("parseAddress(%q): got %q, err %v", tt.addr, network, err)
+		}
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pp.sock")
+	if _, err := Connect(path); err == nil {
+		t.Fatal("expected error with no daemon running")
+	}
+
+	srv := NewServer(&fakeScanner{ports: testPorts()}, nil)
+	if err := srv.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	hs := &http.Server{Handler: srv.Handler()}
+	go func() { _ = hs.Serve(l) }()
+	defer hs.Close()
+
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("socket mode: %v, err %v", info.Mode(), err)
+	}
+	if _, err := Listen(path); err == nil || !strings.Contains(err.Error(), "already") {
+		t.Errorf("expected already-running error, got %v", err)
+	}
+
+	c, err := Connect(path)
+	if err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+	ports, err := c.Scan()
+	if err != nil || len(ports) != 4 {
+		t.Errorf("Scan: got %d ports, err %v", len(ports), err)
+	}
+}
+
+func TestDefaultSocketDir(t *testing.T) {
+	t.Setenv(AddressEnv, "")
+	t.Setenv("XDG_RUNTIME_DIR", "")
+	t.Setenv("TMPDIR", t.TempDir())
+	addr := DefaultAddress()
+	l, err := Listen(addr)
+	if err != nil {
+		t.Fatalf("Listen: %v", err)
+	}
+	defer l.Close()
+	info, err := os.Stat(filepath.Dir(addr))
+	if err != nil || info.Mode().Perm() != 0o700 {
+		t.Errorf("socket directory mode: %v, err %v", info.Mode(), err)
+	}
+}
+
+func TestConnectUntrustedSocket(t *testing.T) {
+	// Anyone could have put a socket in a directory others can write to.
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0o777); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "pp.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() { _ = http.Serve(l, NewServer(&fakeScanner{}, nil).Handler()) }()
+
+	if _, err := Connect(path); err == nil || !strings.Contains(err.Error(), "writable by other users") {
+		t.Errorf("got %v, want the socket refused", err)
+	}
+	if _, err := Listen(filepath.Join(dir, "other.sock")); err == nil {
+		t.Error("Listen should refuse a directory others can write to")
+	}
+}
+
+func TestListenRemovesStaleSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pp.sock")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen over stale socket: %v", err)
+	}
+	_ = l.Close()
+}
+
+func TestOnScan(t *testing.T) {
+	srv := NewServer(&fakeScanner{ports: testPorts()}, nil)
+	var got [][]scanner.PortInfo
+	srv.OnScan(func(ports []scanner.PortInfo, at time.Time) {
+		if at.IsZero() {
+			t.Error("scan time not set")
+		}
+		got = append(got, ports)
+	})
+	for i := 0; i < 2; i++ {
+		if err := srv.Refresh(); err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+	}
+	if len(got) != 2 || len(got[1]) != 4 {
+		t.Errorf("OnScan calls: %v", got)
+	}
+}
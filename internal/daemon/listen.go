@@ -0,0 +1,126 @@
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// AddressEnv overrides the address the daemon listens on and the CLI looks
+// for it at.
+const AddressEnv = "PORTPILOT_DAEMON"
+
+// DefaultAddress returns $PORTPILOT_DAEMON if set, else a Unix socket in
+// $XDG_RUNTIME_DIR, falling back to a socket in a per-user directory in the
+// temp directory, which Listen creates.
+func DefaultAddress() string {
+	if addr := os.Getenv(AddressEnv); addr != "" {
+		return addr
+	}
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		return filepath.Join(dir, "portpilot.sock")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("portpilot-%d", os.Getuid()), "daemon.sock")
+}
+
+// checkOwned returns an error unless path belongs to the current user and,
+// if it is a directory, only the user can add or replace files in it.
+// Another user could otherwise put a socket of their own in its place.
+func checkOwned(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("%s: can't tell its owner", path)
+	}
+	if int(st.Uid) != os.Getuid() {
+		return fmt.Errorf("%s belongs to another user", path)
+	}
+	if info.IsDir() && info.Mode().Perm()&0o022 != 0 {
+		return fmt.Errorf("%s is writable by other users", path)
+	}
+	return nil
+}
+
+// checkSocket returns an error unless the socket at path and its directory
+// belong to the current user, so a daemon answering there is theirs.
+func checkSocket(path string) error {
+	if err := checkOwned(filepath.Dir(path)); err != nil {
+		return fmt.Errorf("untrusted daemon socket: %w", err)
+	}
+	if err := checkOwned(path); err != nil {
+		return fmt.Errorf("untrusted daemon socket: %w", err)
+	}
+	return nil
+}
+
+// parseAddress splits an address into a network and address for net.Listen
+// and net.Dial. "unix:PATH" and anything containing a slash is a Unix
+// socket; everything else is a TCP host:port, which must be on loopback.
+// Kill is only served on Unix sockets.
+func parseAddress(addr string) (network, address string, err error) {
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		return "unix", path, nil
+	}
+	if strings.Contains(addr, "/") {
+		return "unix", addr, nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid daemon address %q: %w", addr, err)
+	}
+	if host != "localhost" {
+		ip := net.ParseIP(host)
+		if ip == nil || !ip.IsLoopback() {
+			return "", "", fmt.Errorf("daemon address %q is not on loopback", addr)
+		}
+	}
+	return "tcp", addr, nil
+}
+
+// Listen opens the daemon's listener. A leftover socket file from a daemon
+// that is no longer running is removed; a live one is an error. Unix sockets
+// are only accessible to the current user, and their directory, created if
+// missing, must belong to the user alone.
+func Listen(addr string) (net.Listener, error) {
+	network, address, err := parseAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if network == "unix" {
+		dir := filepath.Dir(address)
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("creating socket directory: %w", err)
+		}
+		if err := checkOwned(dir); err != nil {
+			return nil, fmt.Errorf("socket directory: %w", err)
+		}
+		if _, err := os.Stat(address); err == nil {
+			if conn, err := net.DialTimeout("unix", address, time.Second); err == nil {
+				_ = conn.Close()
+				return nil, fmt.Errorf("a daemon is already listening on %s", address)
+			}
+			if err := os.Remove(address); err != nil {
+				return nil, fmt.Errorf("removing stale socket: %w", err)
+			}
+		}
+	}
+
+	if network == "unix" {
+		// Create the socket with mode 0600 rather than restricting it
+		// afterwards, leaving no moment when others could connect.
+		old := unix.Umask(0o177)
+		defer unix.Umask(old)
+	}
+	return net.Listen(network, address)
+}
@@ -0,0 +1,428 @@
+// Package daemon keeps a continuously refreshed scan in memory and serves it
+// over a local HTTP/JSON API, along with a client the CLI uses to query it.
+//
+// Endpoints:
+//
+//	GET  /health              daemon status
+//	GET  /ports?filter=QUERY  all listeners, in the output.Envelope format
+//	GET  /ports/{port}        listeners on one port
+//	POST /ports/{port}/kill   rescan and signal the listeners on a port (Unix socket only)
+//	GET  /events              Server-Sent Events stream of open/close events
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/events"
+	"github.com/AbdullahTarakji/portpilot/internal/output"
+	"github.com/AbdullahTarakji/portpilot/internal/process"
+	"github.com/AbdullahTarakji/portpilot/internal/query"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// keepAlive is how often an idle event stream gets a comment line, so
+// proxies and clients don't time it out.
+const keepAlive = 15 * time.Second
+
+// Server serves the latest scan. Create one with NewServer.
+type Server struct {
+	scanner  scanner.Scanner
+	groupFor func(port int) string
+	hostname string
+	// terminate signals a process; process.Terminate outside of tests.
+	terminate func(pid int, sig os.Signal, grace time.Duration) (bool, error)
+
+	scanMu sync.Mutex // serializes scans
+
+	mu      sync.RWMutex
+	ports   []scanner.PortInfo
+	meta    output.Meta
+	scanned bool
+	subs    map[chan events.Event]struct{}
+	onScan  func(ports []scanner.PortInfo, at time.Time)
+}
+
+// NewServer creates a Server. groupFor names the config group of a port,
+// for group: filters, and may be nil.
+func NewServer(s scanner.Scanner, groupFor func(port int) string) *Server {
+	hostname, _ := os.Hostname()
+	return &Server{
+		scanner:   s,
+		groupFor:  groupFor,
+		hostname:  hostname,
+		terminate: process.Terminate,
+		subs:      make(map[chan events.Event]struct{}),
+	}
+}
+
+// Refresh runs a scan, replaces the served ports and publishes an event for
+// each listener that opened or closed since the previous scan. When the
+// scan fails the previous ports are kept.
+func (s *Server) Refresh() error {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	res, err := scanner.ScanResult(s.scanner)
+	if err != nil {
+		return err
+	}
+	ports := res.Ports
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	var evs []events.Event
+	if s.scanned {
+		evs = events.Diff(s.ports, ports, now)
+	}
+	s.ports = ports
+	s.meta = output.Meta{
+		Hostname:  s.hostname,
+		ScannedAt: now.Truncate(time.Second),
+		Backend:   scanner.Backend(s.scanner),
+		Warnings:  res.Warnings(),
+	}
+	s.scanned = true
+	onScan := s.onScan
+	for _, e := range evs {
+		for ch := range s.subs {
+			select {
+			case ch <- e:
+			default: // a slow subscriber misses events rather than stalling scans
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if onScan != nil {
+		onScan(ports, now)
+	}
+	return nil
+}
+
+// OnScan registers a function called with the result of every successful
+// scan, in order.
+func (s *Server) OnScan(fn func(ports []scanner.PortInfo, at time.Time)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onScan = fn
+}
+
+// Run refreshes every interval until ctx is cancelled. Scan errors are
+// passed to onError, which may be nil.
+func (s *Server) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Refresh(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the open and close events of every
+// later scan, and a function that ends the subscription. Events are dropped
+// if the channel is full.
+func (s *Server) Subscribe() (<-chan events.Event, func()) {
+	ch := make(chan events.Event, 64)
+	s.mu.Lock()
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			s.mu.Lock()
+			delete(s.subs, ch)
+			s.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Handler returns the HTTP API.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("GET /ports", s.handlePorts)
+	mux.HandleFunc("GET /ports/{port}", s.handlePort)
+	mux.HandleFunc("POST /ports/{port}/kill", s.handleKill)
+	mux.HandleFunc("GET /events", s.handleEvents)
+	return guard(mux)
+}
+
+// guard turns away requests from browsers: pages send an Origin with
+// anything but the simplest requests, and reaching a loopback TCP listener
+// through DNS rebinding leaves a Host that isn't loopback.
+func guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Origin") != "" {
+			writeError(w, http.StatusForbidden, fmt.Errorf("cross-origin requests are not allowed"))
+			return
+		}
+		if !overUnixSocket(r) && !loopbackHost(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("host %q is not loopback", r.Host))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// overUnixSocket reports whether r came in on a Unix socket, which only its
+// owner can connect to.
+func overUnixSocket(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
+
+// loopbackHost reports whether the Host of a request names this machine's
+// loopback interface.
+func loopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(strings.Trim(host, "[]"))
+	return ip != nil && ip.IsLoopback()
+}
+
+// Health is the body of GET /health.
+type Health struct {
+	Status    string    `json:"status"`
+	PID       int       `json:"pid"`
+	ScannedAt time.Time `json:"scanned_at,omitzero"`
+}
+
+// KillRequest is the optional body of POST /ports/{port}/kill.
+type KillRequest struct {
+	// Signal is a name or number understood by process.ParseSignal.
+	// Defaults to SIGTERM.
+	Signal string `json:"signal,omitempty"`
+	// Grace is how long to wait before following up with SIGKILL, as a Go
+	// duration such as "5s". Empty means never.
+	Grace string `json:"grace,omitempty"`
+	// Protocol and Address narrow the listeners down, as in
+	// process.PortFilter.
+	Protocol string `json:"protocol,omitempty"`
+	Address  string `json:"address,omitempty"`
+	// PIDs limits the kill to these processes, such as the ones a user
+	// confirmed. Other processes on the port are left alone.
+	PIDs []int `json:"pids,omitempty"`
+}
+
+// KillResult reports the outcome of signalling one process.
+type KillResult struct {
+	PID     int    `json:"pid"`
+	Process string `json:"process"`
+	Signal  string `json:"signal"`
+	// Forced is set when the process outlived the grace period and was
+	// sent SIGKILL.
+	Forced bool   `json:"forced"`
+	Error  string `json:"error,omitempty"`
+}
+
+// KillResponse is the body of POST /ports/{port}/kill.
+type KillResponse struct {
+	Port    int          `json:"port"`
+	Results []KillResult `json:"results"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	h := Health{Status: "ok", PID: os.Getpid(), ScannedAt: s.meta.ScannedAt}
+	s.mu.RUnlock()
+	writeJSON(w, http.StatusOK, h)
+}
+
+func (s *Server) handlePorts(w http.ResponseWriter, r *http.Request) {
+	q, err := query.Parse(r.URL.Query().Get("filter"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid filter: %w", err))
+		return
+	}
+	ports, meta := s.snapshot()
+	writeJSON(w, http.StatusOK, output.NewEnvelope(q.Filter(ports, s.groupFor), meta))
+}
+
+func (s *Server) handlePort(w http.ResponseWriter, r *http.Request) {
+	port, ok := portParam(w, r)
+	if !ok {
+		return
+	}
+	ports, meta := s.snapshot()
+	writeJSON(w, http.StatusOK, output.NewEnvelope(onPort(ports, port), meta))
+}
+
+func (s *Server) handleKill(w http.ResponseWriter, r *http.Request) {
+	// Anyone on the machine can connect to a loopback TCP port, so only
+	// the socket's owner may kill.
+	if !overUnixSocket(r) {
+		writeError(w, http.StatusForbidden, fmt.Errorf("kill is only served on the daemon's Unix socket"))
+		return
+	}
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/json" {
+		writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("the request body must be application/json"))
+		return
+	}
+	port, ok := portParam(w, r)
+	if !ok {
+		return
+	}
+
+	var req KillRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decoding request: %w", err))
+		return
+	}
+	if req.Signal == "" {
+		req.Signal = "SIGTERM"
+	}
+	sig, err := process.ParseSignal(req.Signal)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var grace time.Duration
+	if req.Grace != "" {
+		if grace, err = time.ParseDuration(req.Grace); err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid grace: %w", err))
+			return
+		}
+	}
+
+	// Signal what holds the port now rather than what the last scan found:
+	// a process may have exited since, and its PID been reused.
+	if err := s.Refresh(); err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("scanning: %w", err))
+		return
+	}
+	ports, _ := s.snapshot()
+	targets, err := process.OnPort(ports, port, process.PortFilter{Protocol: req.Protocol, Address: req.Address})
+	if err == nil && len(req.PIDs) > 0 {
+		targets = slices.DeleteFunc(targets, func(p scanner.PortInfo) bool { return !slices.Contains(req.PIDs, p.PID) })
+		if len(targets) == 0 {
+			err = fmt.Errorf("%w on port %d among PIDs %v", process.ErrPortFree, port, req.PIDs)
+		}
+	}
+	switch {
+	case errors.Is(err, process.ErrPortFree):
+		writeError(w, http.StatusNotFound, err)
+		return
+	case err != nil:
+		writeError(w, http.StatusForbidden, err)
+		return
+	}
+
+	resp := KillResponse{Port: port, Results: []KillResult{}}
+	signalled := make(map[int]bool)
+	for _, p := range targets {
+		if signalled[p.PID] {
+			continue
+		}
+		signalled[p.PID] = true
+		res := KillResult{PID: p.PID, Process: p.ProcessName, Signal: process.SignalName(sig)}
+		res.Forced, err = s.terminate(p.PID, sig, grace)
+		if err != nil {
+			res.Error = err.Error()
+		}
+		resp.Results = append(resp.Results, res)
+	}
+
+	// Pick up the change without waiting for the next tick.
+	go func() { _ = s.Refresh() }()
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	ch, cancel := s.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case e := <-ch:
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Kind, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// snapshot returns the latest scan and its metadata.
+func (s *Server) snapshot() ([]scanner.PortInfo, output.Meta) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ports, s.meta
+}
+
+func onPort(ports []scanner.PortInfo, port int) []scanner.PortInfo {
+	var result []scanner.PortInfo
+	for _, p := range ports {
+		if p.Port == port {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func portParam(w http.ResponseWriter, r *http.Request) (int, bool) {
+	port, err := strconv.Atoi(r.PathValue("port"))
+	if err != nil || port < 1 || port > 65535 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid port %q", r.PathValue("port")))
+		return 0, false
+	}
+	return port, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
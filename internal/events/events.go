@@ -0,0 +1,70 @@
+// Package events turns successive port scans into open and close events.
+package events
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// Kind says whether a listener appeared or went away.
+type Kind string
+
+// Event kinds.
+const (
+	Open  Kind = "open"
+	Close Kind = "close"
+)
+
+// Event records a listener that appeared or disappeared between two scans.
+type Event struct {
+	Kind Kind             `json:"kind" yaml:"kind"`
+	Time time.Time        `json:"time" yaml:"time"`
+	Port scanner.PortInfo `json:"port" yaml:"port"`
+}
+
+// Key identifies a listener across scans by its address, port, protocol
+// and PID, matching how scanners deduplicate their results, so the IPv4
+// and IPv6 sockets of a dual-stack listener are told apart. Listeners on
+// other hosts are prefixed with the host.
+func Key(p scanner.PortInfo) string {
+	key := fmt.Sprintf("%s/%d/%s/%d", p.Address, p.Port, p.Protocol, p.PID)
+	if p.Host != "" {
+		return p.Host + "/" + key
+	}
+	return key
+}
+
+// Diff compares two scans and returns an event for every listener that is
+// only in one of them, closes first, each ordered by port. A listener that
+// moved to another PID shows up as a close and an open.
+func Diff(prev, next []scanner.PortInfo, now time.Time) []Event {
+	before := make(map[string]bool, len(prev))
+	for _, p := range prev {
+		before[Key(p)] = true
+	}
+	after := make(map[string]bool, len(next))
+	for _, p := range next {
+		after[Key(p)] = true
+	}
+
+	var closed, opened []Event
+	for _, p := range prev {
+		if !after[Key(p)] {
+			closed = append(closed, Event{Kind: Close, Time: now, Port: p})
+		}
+	}
+	for _, p := range next {
+		if !before[Key(p)] {
+			opened = append(opened, Event{Kind: Open, Time: now, Port: p})
+		}
+	}
+	byPort := func(evs []Event) {
+		sort.SliceStable(evs, func(i, j int) bool { return evs[i].Port.Port < evs[j].Port.Port })
+	}
+	byPort(closed)
+	byPort(opened)
+	return append(closed, opened...)
+}
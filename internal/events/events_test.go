@@ -0,0 +1,68 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+func TestDiff(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	prev := []scanner.PortInfo{
+		{Port: 8080, Protocol: "TCP", PID: 10},
+		{Port: 3000, Protocol: "TCP", PID: 20},
+		{Port: 5432, Protocol: "TCP", PID: 30},
+	}
+	next := []scanner.PortInfo{
+		{Port: 5432, Protocol: "TCP", PID: 30},
+		{Port: 3000, Protocol: "TCP", PID: 21}, // restarted
+		{Port: 6379, Protocol: "TCP", PID: 40},
+	}
+
+	got := Diff(prev, next, now)
+	want := []struct {
+		kind Kind
+		port int
+		pid  int
+	}{
+		{Close, 3000, 20},
+		{Close, 8080, 10},
+		{Open, 3000, 21},
+		{Open, 6379, 40},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d events, want %d: %v", len(got), len(want), got)
+	}
+	for i, w := range want {
+		e := got[i]
+		if e.Kind != w.kind || e.Port.Port != w.port || e.Port.PID != w.pid || !e.Time.Equal(now) {
+			t.Errorf("event %d: got %s %d/%d, want %s %d/%d", i, e.Kind, e.Port.Port, e.Port.PID, w.kind, w.port, w.pid)
+		}
+	}
+}
+
+func TestDiffUnchanged(t *testing.T) {
+	ports := []scanner.PortInfo{{Port: 22, Protocol: "TCP", PID: 1}, {Port: 22, Protocol: "UDP", PID: 1}}
+	if got := Diff(ports, ports, time.Now()); len(got) != 0 {
+		t.Errorf("expected no events, got %v", got)
+	}
+	if got := Diff(nil, nil, time.Now()); len(got) != 0 {
+		t.Errorf("expected no events for empty scans, got %v", got)
+	}
+}
+
+func TestDiffDualStack(t *testing.T) {
+	// nginx listens on port 80 over IPv4 and IPv6, then drops IPv6.
+	prev := []scanner.PortInfo{
+		{Address: "0.0.0.0", Port: 80, Protocol: "TCP", PID: 50},
+		{Address: "::", Port: 80, Protocol: "TCP", PID: 50},
+	}
+	got := Diff(prev, prev[:1], time.Now())
+	if len(got) != 1 || got[0].Kind != Close || got[0].Port.Address != "::" {
+		t.Errorf("expected the IPv6 socket to close, got %v", got)
+	}
+	if Key(prev[0]) == Key(prev[1]) {
+		t.Errorf("both sockets have key %q", Key(prev[0]))
+	}
+}
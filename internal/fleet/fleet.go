@@ -0,0 +1,316 @@
+// Package fleet combines the scans of several machines into one, labelling
+// every listener with the host it was found on.
+package fleet
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// DefaultTimeout is how long a scan of a source without a Timeout may take.
+const DefaultTimeout = 5 * time.Second
+
+// Source is a scanner for one machine. Name becomes the Host of its
+// listeners; the local machine has an empty name.
+type Source struct {
+	Name    string
+	Scanner scanner.Scanner
+	// Timeout is how long a scan of the source may take before it is
+	// reported as failing. Zero means DefaultTimeout.
+	Timeout time.Duration
+}
+
+func (s Source) timeout() time.Duration {
+	if s.Timeout > 0 {
+		return s.Timeout
+	}
+	return DefaultTimeout
+}
+
+// Fleet scans its sources concurrently. It implements scanner.Scanner and
+// scanner.ResultScanner.
+//
+// A slow source doesn't hold up the others: a scan returns as soon as one
+// source answers, with the listeners of its last successful scan for the
+// rest, which go on scanning in the background. Updated tells when they
+// answer, and Latest returns what they found. A source isn't asked again
+// while a scan is still running, and is reported as failing once it takes
+// longer than its timeout.
+type Fleet struct {
+	sources []Source
+
+	mu      sync.Mutex
+	states  []sourceState
+	updated chan struct{} // closed when a scan ends, then replaced
+}
+
+// sourceState is what a Fleet knows about one source.
+type sourceState struct {
+	ports   []scanner.PortInfo // from the last successful scan
+	failed  []scanner.Failure  // backends that failed in it, with the host
+	warned  []string           // its warnings, prefixed with the host
+	scanned time.Time          // when ports were read; zero if never
+	latency time.Duration      // of the last finished scan
+	err     error              // of the last finished scan
+	started time.Time          // of the running scan
+	running bool               // whether a scan is running
+}
+
+// New returns a Fleet over sources.
+func New(sources ...Source) *Fleet {
+	return &Fleet{sources: sources, states: make([]sourceState, len(sources)), updated: make(chan struct{})}
+}
+
+// Hosts returns the names of the sources, in order.
+func (f *Fleet) Hosts() []string {
+	hosts := make([]string, len(f.sources))
+	for i, s := range f.sources {
+		hosts[i] = s.Name
+	}
+	return hosts
+}
+
+// Backend reports "fleet"; the sources each have their own.
+func (f *Fleet) Backend() string {
+	return "fleet"
+}
+
+// Scan starts a scan of every source that isn't scanning yet and returns
+// the listeners of every source as soon as one of them answers, or one
+// times out. Sources that haven't answered yet are left out until they do;
+// see Updated. If some sources fail or time out, their last listeners are
+// returned along with an *Error naming them; if none has ever answered, no
+// listeners are returned.
+func (f *Fleet) Scan() ([]scanner.PortInfo, error) {
+	res, err := f.ScanResult()
+	return res.Ports, err
+}
+
+// ScanResult scans like Scan, along with the backends that failed on each
+// source and their warnings, prefixed with the source's name.
+func (f *Fleet) ScanResult() (scanner.Result, error) {
+	f.mu.Lock()
+	now := time.Now()
+	var deadline time.Time
+	for i := range f.sources {
+		st := &f.states[i]
+		if !st.running {
+			st.running, st.started = true, now
+			go f.scan(i)
+		}
+		// A source that has already timed out isn't waited for again.
+		if d := st.started.Add(f.sources[i].timeout()); d.After(now) && (deadline.IsZero() || d.Before(deadline)) {
+			deadline = d
+		}
+	}
+	updated := f.updated
+	f.mu.Unlock()
+
+	if !deadline.IsZero() {
+		timer := time.NewTimer(time.Until(deadline))
+		defer timer.Stop()
+		select {
+		case <-updated:
+		case <-timer.C:
+		}
+	}
+	return f.Latest()
+}
+
+// Latest returns what the sources have found so far without scanning
+// them, like ScanResult.
+func (f *Fleet) Latest() (scanner.Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var res scanner.Result
+	failed := make(map[string]error)
+	answered := false
+	for i, s := range f.sources {
+		st := f.states[i]
+		if err := f.failure(i); err != nil {
+			failed[s.Name] = err
+		}
+		if !st.scanned.IsZero() {
+			answered = true
+			res.Ports = append(res.Ports, st.ports...)
+			res.Failed = append(res.Failed, st.failed...)
+			res.Reported = append(res.Reported, st.warned...)
+		}
+	}
+	if len(failed) == 0 {
+		return res, nil
+	}
+	if !answered {
+		return scanner.Result{}, &Error{Failed: failed}
+	}
+	if res.Ports == nil {
+		res.Ports = []scanner.PortInfo{}
+	}
+	return res, &Error{Failed: failed}
+}
+
+// Updated returns a channel that is closed when the next scan of a source
+// ends, whether it answered or failed.
+func (f *Fleet) Updated() <-chan struct{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.updated
+}
+
+// scan runs one scan of source i and records the outcome.
+func (f *Fleet) scan(i int) {
+	s := f.sources[i]
+	start := time.Now()
+	res, err := scanner.ScanResult(s.Scanner)
+	latency := time.Since(start)
+	ports := make([]scanner.PortInfo, len(res.Ports))
+	for j, p := range res.Ports {
+		p.Host = s.Name
+		ports[j] = p
+	}
+	label := hostLabel(s.Name)
+	failures := make([]scanner.Failure, len(res.Failed))
+	for j, fail := range res.Failed {
+		failures[j] = scanner.Failure{Backend: fail.Backend + " on " + label, Err: fail.Err}
+	}
+	var warned []string
+	for _, w := range res.Warnings() {
+		warned = append(warned, label+": "+w)
+	}
+
+	f.mu.Lock()
+	st := &f.states[i]
+	st.latency, st.err = latency, err
+	if err == nil {
+		st.ports, st.failed, st.warned, st.scanned = ports, failures, warned, time.Now()
+	}
+	st.running = false
+	close(f.updated)
+	f.updated = make(chan struct{})
+	f.mu.Unlock()
+}
+
+// failure returns why source i has no fresh listeners, or nil if it has.
+// f.mu must be held.
+func (f *Fleet) failure(i int) error {
+	st := f.states[i]
+	if st.running {
+		if timeout := f.sources[i].timeout(); time.Since(st.started) >= timeout {
+			return fmt.Errorf("no answer after %s", timeout)
+		}
+	}
+	return st.err
+}
+
+// Status is how a source fared in the latest scans.
+type Status struct {
+	Name string
+	// Latency is how long the source's last finished scan took.
+	Latency time.Duration
+	// Scanned is when its current listeners were read; zero if it has
+	// never answered.
+	Scanned time.Time
+	// Err says why the last scan failed or timed out, nil if it didn't.
+	Err error
+	// Scanning reports whether a scan of the source is running.
+	Scanning bool
+}
+
+// Stale reports whether the source's listeners are left over from an
+// earlier scan because the latest failed.
+func (s Status) Stale() bool {
+	return s.Err != nil && !s.Scanned.IsZero()
+}
+
+// Status returns the status of every source, in order.
+func (f *Fleet) Status() []Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	statuses := make([]Status, len(f.sources))
+	for i, s := range f.sources {
+		st := f.states[i]
+		statuses[i] = Status{Name: s.Name, Latency: st.latency, Scanned: st.scanned, Err: f.failure(i), Scanning: st.running}
+	}
+	return statuses
+}
+
+// Error reports the sources a scan failed on, by name.
+type Error struct {
+	Failed map[string]error
+}
+
+func (e *Error) Error() string {
+	names := make([]string, 0, len(e.Failed))
+	for name := range e.Failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s: %v", hostLabel(name), e.Failed[name])
+	}
+	return strings.Join(parts, "; ")
+}
+
+// hostLabel names a source in messages; the local machine has no name.
+func hostLabel(name string) string {
+	if name == "" {
+		return "local"
+	}
+	return name
+}
+
+// Clash is an address and port that listeners on several hosts are bound
+// to, typically a virtual IP held by more than one machine at once.
+type Clash struct {
+	Address  string
+	Port     int
+	Protocol string
+	Hosts    []string
+}
+
+// Clashes finds the specific addresses bound on more than one host.
+// Wildcard and loopback binds are left out: every host has its own.
+func Clashes(ports []scanner.PortInfo) []Clash {
+	type bind struct {
+		addr  string
+		port  int
+		proto string
+	}
+	hosts := make(map[bind][]string)
+	for _, p := range ports {
+		ip := net.ParseIP(p.Address)
+		if ip == nil || ip.IsUnspecified() || ip.IsLoopback() {
+			continue
+		}
+		b := bind{ip.String(), p.Port, p.Protocol}
+		if !slices.Contains(hosts[b], p.Host) {
+			hosts[b] = append(hosts[b], p.Host)
+		}
+	}
+
+	var clashes []Clash
+	for b, hs := range hosts {
+		if len(hs) > 1 {
+			sort.Strings(hs)
+			clashes = append(clashes, Clash{Address: b.addr, Port: b.port, Protocol: b.proto, Hosts: hs})
+		}
+	}
+	sort.Slice(clashes, func(i, j int) bool {
+		if clashes[i].Port != clashes[j].Port {
+			return clashes[i].Port < clashes[j].Port
+		}
+		if clashes[i].Address != clashes[j].Address {
+			return clashes[i].Address < clashes[j].Address
+		}
+		return clashes[i].Protocol < clashes[j].Protocol
+	})
+	return clashes
+}
@@ -0,0 +1,246 @@
+package fleet
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+type fakeScanner struct {
+	ports []scanner.PortInfo
+	err   error
+}
+
+func (f fakeScanner) Scan() ([]scanner.PortInfo, error) {
+	return f.ports, f.err
+}
+
+// scanAll scans f and waits for every source to answer.
+func scanAll(f *Fleet) ([]scanner.PortInfo, error) {
+	f.Scan()
+	for {
+		updated := f.Updated()
+		if !slices.ContainsFunc(f.Status(), func(st Status) bool { return st.Scanning }) {
+			res, err := f.Latest()
+			return res.Ports, err
+		}
+		<-updated
+	}
+}
+
+func TestScanLabelsHosts(t *testing.T) {
+	f := New(
+		Source{Scanner: fakeScanner{ports: []scanner.PortInfo{{Port: 3000, PID: 1}}}},
+		Source{Name: "vm1", Scanner: fakeScanner{ports: []scanner.PortInfo{{Port: 3000, PID: 1}, {Port: 5432, PID: 2}}}},
+	)
+	ports, err := scanAll(f)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	var hosts []string
+	for _, p := range ports {
+		hosts = append(hosts, p.Host)
+	}
+	if strings.Join(hosts, ",") != ",vm1,vm1" {
+		t.Errorf("hosts: got %q", hosts)
+	}
+	if got := f.Hosts(); len(got) != 2 || got[1] != "vm1" {
+		t.Errorf("Hosts: got %q", got)
+	}
+}
+
+func TestScanPartialFailure(t *testing.T) {
+	down := errors.New("connection refused")
+	f := New(
+		Source{Name: "vm1", Scanner: fakeScanner{ports: []scanner.PortInfo{{Port: 80}}}},
+		Source{Name: "vm2", Scanner: fakeScanner{err: down}},
+	)
+	ports, err := scanAll(f)
+	var fe *Error
+	if !errors.As(err, &fe) {
+		t.Fatalf("expected a fleet error, got %v", err)
+	}
+	if len(ports) != 1 || ports[0].Host != "vm1" {
+		t.Errorf("the reachable host's ports should be kept, got %+v", ports)
+	}
+	if !errors.Is(fe.Failed["vm2"], down) || err.Error() != "vm2: connection refused" {
+		t.Errorf("error: got %v", err)
+	}
+
+	f = New(Source{Name: "vm2", Scanner: fakeScanner{err: down}})
+	if ports, err := scanAll(f); err == nil || ports != nil {
+		t.Errorf("all sources failed: got %v, %v", ports, err)
+	}
+}
+
+// resultScanner reports res, like an agent or an SSH host.
+type resultScanner struct {
+	res scanner.Result
+}
+
+func (r resultScanner) Scan() ([]scanner.PortInfo, error) {
+	return r.res.Ports, nil
+}
+
+func (r resultScanner) ScanResult() (scanner.Result, error) {
+	return r.res, nil
+}
+
+func TestScanResultWarnings(t *testing.T) {
+	f := New(
+		Source{Scanner: resultScanner{scanner.Result{
+			Ports:  []scanner.PortInfo{{Port: 3000, PID: 1}},
+			Failed: []scanner.Failure{{Backend: "ps", Err: scanner.ErrBackendMissing}},
+		}}},
+		Source{Name: "vm1", Scanner: resultScanner{scanner.Result{
+			Ports:    []scanner.PortInfo{{Port: 22}},
+			Reported: []string{"1 socket has no owner information; rerun with sudo to see other users' processes"},
+		}}},
+		Source{Name: "vm2", Scanner: fakeScanner{ports: []scanner.PortInfo{{Port: 5432, PID: 2}}}},
+	)
+	scanAll(f)
+	res, err := f.Latest()
+	if err != nil {
+		t.Fatalf("Latest: %v", err)
+	}
+	if len(res.Ports) != 3 {
+		t.Errorf("ports: got %+v", res.Ports)
+	}
+	want := []string{
+		"local: ps failed: not installed",
+		"vm1: 1 socket has no owner information; rerun with sudo to see other users' processes",
+	}
+	if got := res.Warnings(); !slices.Equal(got, want) {
+		t.Errorf("warnings:\ngot  %q\nwant %q", got, want)
+	}
+	if len(res.Failed) != 1 || res.Failed[0].Backend != "ps on local" {
+		t.Errorf("failed: got %+v", res.Failed)
+	}
+}
+
+// switchScanner answers with ports until broken is set, then fails or,
+// with hang set, blocks until release is closed.
+type switchScanner struct {
+	ports   []scanner.PortInfo
+	broken  *bool
+	hang    bool
+	release chan struct{}
+}
+
+func (s switchScanner) Scan() ([]scanner.PortInfo, error) {
+	if !*s.broken {
+		return s.ports, nil
+	}
+	if s.hang {
+		<-s.release
+	}
+	return nil, errors.New("connection refused")
+}
+
+func TestScanKeepsStalePorts(t *testing.T) {
+	broken := false
+	f := New(
+		Source{Scanner: fakeScanner{ports: []scanner.PortInfo{{Port: 3000}}}},
+		Source{Name: "vm1", Scanner: switchScanner{ports: []scanner.PortInfo{{Port: 5432}}, broken: &broken}},
+	)
+	if _, err := scanAll(f); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	broken = true
+	ports, err := scanAll(f)
+	var fe *Error
+	if !errors.As(err, &fe) || fe.Failed["vm1"] == nil {
+		t.Fatalf("expected vm1 to fail, got %v", err)
+	}
+	if len(ports) != 2 || ports[1].Host != "vm1" {
+		t.Errorf("vm1's last ports should be kept, got %+v", ports)
+	}
+	st := f.Status()
+	if st[0].Stale() || !st[1].Stale() || st[1].Scanned.IsZero() {
+		t.Errorf("status: got %+v", st)
+	}
+}
+
+func TestScanTimeout(t *testing.T) {
+	broken := false
+	release := make(chan struct{})
+	defer close(release)
+	slow := switchScanner{ports: []scanner.PortInfo{{Port: 5432}}, broken: &broken, hang: true, release: release}
+	f := New(
+		Source{Scanner: fakeScanner{ports: []scanner.PortInfo{{Port: 3000}}}},
+		Source{Name: "vm1", Scanner: slow, Timeout: 20 * time.Millisecond},
+	)
+	if _, err := scanAll(f); err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+
+	broken = true
+	start := time.Now()
+	f.Scan()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("a hung source held the scan up for %s", elapsed)
+	}
+	time.Sleep(30 * time.Millisecond)
+	ports, err := f.Scan()
+	if err == nil || !strings.Contains(err.Error(), "vm1: no answer after 20ms") {
+		t.Errorf("error: got %v", err)
+	}
+	if len(ports) != 2 {
+		t.Errorf("vm1's last ports should be kept, got %+v", ports)
+	}
+}
+
+func TestScanDoesNotWaitForSlowSources(t *testing.T) {
+	broken := true
+	release := make(chan struct{})
+	slow := switchScanner{ports: []scanner.PortInfo{{Port: 5432}}, broken: &broken, hang: true, release: release}
+	f := New(
+		Source{Scanner: fakeScanner{ports: []scanner.PortInfo{{Port: 3000}}}},
+		Source{Name: "vm1", Scanner: slow, Timeout: time.Minute},
+	)
+	ports, err := f.Scan()
+	if err != nil || len(ports) != 1 || ports[0].Host != "" {
+		t.Fatalf("the first scan should return the local ports, got %+v, %v", ports, err)
+	}
+	if st := f.Status(); !st[1].Scanning || st[1].Err != nil {
+		t.Errorf("vm1 should still be scanning, got %+v", st[1])
+	}
+
+	updated := f.Updated()
+	close(release)
+	<-updated
+	res, err := f.Latest()
+	if err == nil || len(res.Ports) != 1 {
+		t.Errorf("vm1's failure should show once it answers, got %+v, %v", res.Ports, err)
+	}
+}
+
+func TestClashes(t *testing.T) {
+	ports := []scanner.PortInfo{
+		{Host: "", Port: 443, Protocol: "TCP", Address: "10.0.0.100"},
+		{Host: "vm1", Port: 443, Protocol: "TCP", Address: "10.0.0.100"},
+		{Host: "vm1", Port: 443, Protocol: "TCP", Address: "10.0.0.100"},
+		// Wildcard and loopback binds are per host.
+		{Host: "", Port: 80, Protocol: "TCP", Address: "0.0.0.0"},
+		{Host: "vm1", Port: 80, Protocol: "TCP", Address: "0.0.0.0"},
+		{Host: "", Port: 6379, Protocol: "TCP", Address: "127.0.0.1"},
+		{Host: "vm1", Port: 6379, Protocol: "TCP", Address: "127.0.0.1"},
+		{Host: "", Port: 53, Protocol: "UDP", Address: "*"},
+		{Host: "vm1", Port: 53, Protocol: "UDP", Address: "*"},
+		// Same address, different protocol.
+		{Host: "vm2", Port: 443, Protocol: "UDP", Address: "10.0.0.100"},
+	}
+	got := Clashes(ports)
+	if len(got) != 1 {
+		t.Fatalf("got %+v, want one clash", got)
+	}
+	c := got[0]
+	if c.Address != "10.0.0.100" || c.Port != 443 || strings.Join(c.Hosts, ",") != ",vm1" {
+		t.Errorf("got %+v", c)
+	}
+}
@@ -0,0 +1,380 @@
+// Package history keeps an append-only log of port open and close events in
+// a JSON Lines file, with size and age based retention.
+//
+// Several processes (the daemon, TUI sessions) may record into the same
+// file. Each one diffs its scan against the listeners the log says are
+// open, under an exclusive file lock, so an event is only written once no
+// matter how many recorders see it.
+package history
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/AbdullahTarakji/portpilot/internal/events"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// Retention limits how much history is kept. Zero fields mean unlimited.
+type Retention struct {
+	MaxAge   time.Duration
+	MaxBytes int64
+}
+
+// DefaultRetention keeps 30 days of history, up to 10 MB.
+var DefaultRetention = Retention{MaxAge: 30 * 24 * time.Hour, MaxBytes: 10 << 20}
+
+// pruneEvery is how often Record checks the age limit. The size limit is
+// checked on every write.
+const pruneEvery = time.Hour
+
+// Store is a history file. Create one with Open.
+type Store struct {
+	path      string
+	retention Retention
+
+	mu        sync.Mutex
+	open      map[string]events.Event // latest open event of each listener still open
+	offset    int64                   // how much of the file open reflects
+	file      os.FileInfo             // the file offset refers to
+	lastPrune time.Time
+}
+
+// Open returns the store at path. The file and its directory are created on
+// the first Record.
+func Open(path string, r Retention) *Store {
+	return &Store{path: path, retention: r, open: make(map[string]events.Event)}
+}
+
+// Path returns the location of the history file.
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Record compares a scan with the listeners the history says are open and
+// appends an event for each one that opened or closed. It returns the
+// events written.
+func (s *Store) Record(ports []scanner.PortInfo, now time.Time) ([]events.Event, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.lock()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock(f)
+
+	if err := s.catchUp(f); err != nil {
+		return nil, err
+	}
+
+	prev := make([]scanner.PortInfo, 0, len(s.open))
+	for _, e := range s.open {
+		prev = append(prev, e.Port)
+	}
+	evs := events.Diff(prev, ports, now)
+
+	if len(evs) > 0 {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		for _, e := range evs {
+			if err := enc.Encode(e); err != nil {
+				return nil, err
+			}
+		}
+		n, err := f.Write(buf.Bytes())
+		s.offset += int64(n)
+		if err != nil {
+			return nil, fmt.Errorf("writing history: %w", err)
+		}
+		for _, e := range evs {
+			s.apply(e)
+		}
+	}
+
+	if s.needsPrune(now) {
+		if err := s.prune(f, now); err != nil {
+			return evs, err
+		}
+	}
+	return evs, nil
+}
+
+// Filter selects events in Query. Zero fields match everything.
+type Filter struct {
+	Port int
+	// Process matches process names containing it, ignoring case.
+	Process string
+	Since   time.Time
+	Until   time.Time
+}
+
+func (f Filter) match(e events.Event) bool {
+	if f.Port != 0 && e.Port.Port != f.Port {
+		return false
+	}
+	if f.Process != "" && !strings.Contains(strings.ToLower(e.Port.ProcessName), strings.ToLower(f.Process)) {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && e.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Query returns the events matching f, oldest first. A missing file has no
+// events.
+func (s *Store) Query(f Filter) ([]events.Event, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+	defer file.Close()
+	if err := unix.Flock(int(file.Fd()), unix.LOCK_SH); err != nil {
+		return nil, fmt.Errorf("locking history: %w", err)
+	}
+
+	var result []events.Event
+	_, err = readEvents(file, func(e events.Event) {
+		if f.match(e) {
+			result = append(result, e)
+		}
+	})
+	return result, err
+}
+
+// Prune applies the retention limits now.
+func (s *Store) Prune(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock(f)
+	if err := s.catchUp(f); err != nil {
+		return err
+	}
+	return s.prune(f, now)
+}
+
+// lock opens the history file for appending and takes an exclusive lock
+// on it. If another process replaced the file while this one waited for
+// the lock, the new file is opened instead.
+func (s *Store) lock() (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return nil, fmt.Errorf("creating history directory: %w", err)
+	}
+	for {
+		f, err := os.OpenFile(s.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("opening history: %w", err)
+		}
+		if err := unix.Flock(int(f.Fd()), unix.LOCK_EX); err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("locking history: %w", err)
+		}
+		held, err1 := f.Stat()
+		current, err2 := os.Stat(s.path)
+		if err1 == nil && err2 == nil && os.SameFile(held, current) {
+			return f, nil
+		}
+		unlock(f)
+	}
+}
+
+func unlock(f *os.File) {
+	_ = unix.Flock(int(f.Fd()), unix.LOCK_UN)
+	_ = f.Close()
+}
+
+// catchUp reads the events other processes appended since this store last
+// looked, starting over if the file was replaced or truncated.
+func (s *Store) catchUp(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("reading history: %w", err)
+	}
+	if s.file == nil || !os.SameFile(s.file, info) || info.Size() < s.offset {
+		s.open = make(map[string]events.Event)
+		s.offset = 0
+	}
+	s.file = info
+
+	n, err := readEvents(io.NewSectionReader(f, s.offset, info.Size()-s.offset), s.apply)
+	s.offset += n
+	return err
+}
+
+func (s *Store) apply(e events.Event) {
+	key := events.Key(e.Port)
+	if e.Kind == events.Open {
+		s.open[key] = e
+	} else {
+		delete(s.open, key)
+	}
+}
+
+func (s *Store) needsPrune(now time.Time) bool {
+	if s.retention.MaxBytes > 0 && s.offset > s.retention.MaxBytes {
+		return true
+	}
+	return s.retention.MaxAge > 0 && now.Sub(s.lastPrune) >= pruneEvery
+}
+
+// prune rewrites the file without the events that are past the retention
+// limits, oldest first. The open event of a listener that is still open is
+// always kept, so it isn't reported as opening again.
+func (s *Store) prune(f *os.File, now time.Time) error {
+	s.lastPrune = now
+
+	var all []events.Event
+	if _, err := readEvents(io.NewSectionReader(f, 0, s.offset), func(e events.Event) {
+		all = append(all, e)
+	}); err != nil {
+		return err
+	}
+
+	lines := make([][]byte, len(all))
+	var size int64
+	for i, e := range all {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		lines[i] = line
+		size += int64(len(line)) + 1
+	}
+
+	// Over the size limit, drop the oldest events down to three quarters of
+	// it, so pruning doesn't run again on the next write.
+	target := s.retention.MaxBytes * 3 / 4
+	var out bytes.Buffer
+	for i, e := range all {
+		cur, isOpen := s.open[events.Key(e.Port)]
+		protected := e.Kind == events.Open && isOpen && cur.Time.Equal(e.Time)
+		expired := s.retention.MaxAge > 0 && now.Sub(e.Time) > s.retention.MaxAge
+		oversize := s.retention.MaxBytes > 0 && size > target
+		if !protected && (expired || oversize) {
+			size -= int64(len(lines[i])) + 1
+			continue
+		}
+		out.Write(lines[i])
+		out.WriteByte('\n')
+	}
+	if int64(out.Len()) == s.offset {
+		return nil
+	}
+
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, out.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("pruning history: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("pruning history: %w", err)
+	}
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return fmt.Errorf("pruning history: %w", err)
+	}
+	s.file = info
+	s.offset = info.Size()
+	return nil
+}
+
+// readEvents calls fn for each complete line of r and returns the number of
+// bytes consumed. Lines that don't parse are skipped, so a damaged line
+// doesn't hide the rest of the history.
+func readEvents(r io.Reader, fn func(events.Event)) (int64, error) {
+	br := bufio.NewReader(r)
+	var n int64
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			n += int64(len(line))
+			var e events.Event
+			if json.Unmarshal(line, &e) == nil {
+				fn(e)
+			}
+		}
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("reading history: %w", err)
+		}
+	}
+}
+
+// ParseDuration is time.ParseDuration with a "d" unit for days, e.g. "7d"
+// or "1d12h".
+func ParseDuration(s string) (time.Duration, error) {
+	days, rest, ok := strings.Cut(s, "d")
+	if !ok {
+		return time.ParseDuration(s)
+	}
+	n, err := strconv.Atoi(days)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	d := time.Duration(n) * 24 * time.Hour
+	if rest != "" {
+		more, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		d += more
+	}
+	return d, nil
+}
+
+// timeLayouts are the absolute forms ParseTime accepts, in local time
+// unless they carry a zone.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"15:04",
+}
+
+// ParseTime reads a --since or --until value: a duration before now such as
+// "2h" or "1d", or a time such as "2026-03-01 14:00" or "14:00" (today).
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if d, err := ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range timeLayouts {
+		t, err := time.ParseInLocation(layout, s, now.Location())
+		if err != nil {
+			continue
+		}
+		if layout == "15:04" {
+			y, m, d := now.Date()
+			t = time.Date(y, m, d, t.Hour(), t.Minute(), 0, 0, now.Location())
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q (use a duration like 2h or 1d, or a time like 2006-01-02 15:04)", s)
+}
@@ -0,0 +1,216 @@
+package history
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/events"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+var (
+	t0    = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	node  = scanner.PortInfo{Port: 3000, Protocol: "TCP", PID: 100, ProcessName: "node", User: "mike", Command: "node server.js"}
+	node2 = scanner.PortInfo{Port: 3000, Protocol: "TCP", PID: 101, ProcessName: "node", User: "mike"}
+	pg    = scanner.PortInfo{Port: 5432, Protocol: "TCP", PID: 200, ProcessName: "postgres", User: "postgres"}
+)
+
+func tempPath(t *testing.T) string {
+	return filepath.Join(t.TempDir(), "portpilot", "history.jsonl")
+}
+
+func kinds(evs []events.Event) string {
+	var parts []string
+	for _, e := range evs {
+		parts = append(parts, fmt.Sprintf("%s:%d/%s/%d", e.Kind, e.Port.Port, e.Port.Protocol, e.Port.PID))
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestRecordAndQuery(t *testing.T) {
+	s := Open(tempPath(t), Retention{})
+
+	steps := []struct {
+		ports []scanner.PortInfo
+		want  string
+	}{
+		{[]scanner.PortInfo{node, pg}, "open:3000/TCP/100 open:5432/TCP/200"},
+		{[]scanner.PortInfo{node, pg}, ""},
+		{[]scanner.PortInfo{node2, pg}, "close:3000/TCP/100 open:3000/TCP/101"},
+		{[]scanner.PortInfo{node2}, "close:5432/TCP/200"},
+	}
+	for i, step := range steps {
+		evs, err := s.Record(step.ports, t0.Add(time.Duration(i)*time.Hour))
+		if err != nil {
+			t.Fatalf("step %d: %v", i, err)
+		}
+		if got := kinds(evs); got != step.want {
+			t.Errorf("step %d: got %q, want %q", i, got, step.want)
+		}
+	}
+
+	all, err := s.Query(Filter{})
+	if err != nil || len(all) != 5 {
+		t.Fatalf("Query all: got %d events, err %v", len(all), err)
+	}
+	if all[0].Port.Command != "node server.js" || !all[0].Time.Equal(t0) {
+		t.Errorf("first event: %+v", all[0])
+	}
+
+	onPort, _ := s.Query(Filter{Port: 3000})
+	if len(onPort) != 3 {
+		t.Errorf("port filter: got %d events", len(onPort))
+	}
+	byProc, _ := s.Query(Filter{Process: "POST"})
+	if len(byProc) != 2 {
+		t.Errorf("process filter: got %d events", len(byProc))
+	}
+	window, _ := s.Query(Filter{Since: t0.Add(90 * time.Minute), Until: t0.Add(2 * time.Hour)})
+	if kinds(window) != "close:3000/TCP/100 open:3000/TCP/101" {
+		t.Errorf("time filter: got %q", kinds(window))
+	}
+}
+
+func TestQueryMissingFile(t *testing.T) {
+	evs, err := Open(tempPath(t), Retention{}).Query(Filter{})
+	if err != nil || len(evs) != 0 {
+		t.Errorf("got %v, err %v", evs, err)
+	}
+}
+
+func TestSharedFile(t *testing.T) {
+	path := tempPath(t)
+	a := Open(path, Retention{})
+	b := Open(path, Retention{})
+
+	if evs, _ := a.Record([]scanner.PortInfo{node}, t0); len(evs) != 1 {
+		t.Fatalf("first recorder: got %v", evs)
+	}
+	// The second recorder sees the same listener and must not log it again.
+	if evs, _ := b.Record([]scanner.PortInfo{node}, t0.Add(time.Second)); len(evs) != 0 {
+		t.Errorf("second recorder duplicated events: %v", evs)
+	}
+	if evs, _ := b.Record(nil, t0.Add(time.Minute)); kinds(evs) != "close:3000/TCP/100" {
+		t.Errorf("close: got %q", kinds(evs))
+	}
+	if evs, _ := a.Record(nil, t0.Add(2*time.Minute)); len(evs) != 0 {
+		t.Errorf("first recorder repeated the close: %v", evs)
+	}
+
+	// A fresh store, e.g. after a restart, picks up the state from the file.
+	c := Open(path, Retention{})
+	if evs, _ := c.Record([]scanner.PortInfo{pg}, t0.Add(time.Hour)); kinds(evs) != "open:5432/TCP/200" {
+		t.Errorf("restart: got %q", kinds(evs))
+	}
+}
+
+func TestPruneByAge(t *testing.T) {
+	s := Open(tempPath(t), Retention{MaxAge: 24 * time.Hour})
+	mustRecord(t, s, []scanner.PortInfo{node, pg}, t0)
+	mustRecord(t, s, []scanner.PortInfo{node}, t0.Add(time.Hour)) // pg closes
+
+	if err := s.Prune(t0.Add(48 * time.Hour)); err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	evs, _ := s.Query(Filter{})
+	// The old postgres events go; node's open stays since node is still open.
+	if kinds(evs) != "open:3000/TCP/100" {
+		t.Errorf("after prune: got %q", kinds(evs))
+	}
+	if got, _ := s.Record([]scanner.PortInfo{node}, t0.Add(49*time.Hour)); len(got) != 0 {
+		t.Errorf("node reported again after prune: %v", got)
+	}
+}
+
+func TestPruneBySize(t *testing.T) {
+	s := Open(tempPath(t), Retention{MaxBytes: 2048})
+	for i := 0; i < 40; i++ {
+		p := scanner.PortInfo{Port: 8000 + i, Protocol: "TCP", PID: 1000 + i, ProcessName: "worker"}
+		mustRecord(t, s, []scanner.PortInfo{node, p}, t0.Add(time.Duration(i)*time.Minute))
+	}
+
+	info, err := os.Stat(s.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() > 2048 {
+		t.Errorf("history is %d bytes, want at most 2048", info.Size())
+	}
+	evs, _ := s.Query(Filter{Port: 3000})
+	if len(evs) != 1 {
+		t.Errorf("the open event of a running listener should survive pruning, got %v", evs)
+	}
+	latest, _ := s.Query(Filter{Port: 8039})
+	if len(latest) != 1 {
+		t.Errorf("newest events should be kept, got %v", latest)
+	}
+}
+
+func TestDamagedLineSkipped(t *testing.T) {
+	path := tempPath(t)
+	s := Open(path, Retention{})
+	mustRecord(t, s, []scanner.PortInfo{node}, t0)
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString("{not json\n")
+	_ = f.Close()
+
+	mustRecord(t, s, []scanner.PortInfo{node, pg}, t0.Add(time.Hour))
+	evs, err := s.Query(Filter{})
+	if err != nil || len(evs) != 2 {
+		t.Errorf("got %d events, err %v", len(evs), err)
+	}
+}
+
+func mustRecord(t *testing.T, s *Store, ports []scanner.PortInfo, now time.Time) {
+	t.Helper()
+	if _, err := s.Record(ports, now); err != nil {
+		t.Fatalf("Record: %v", err)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"2h":    2 * time.Hour,
+		"90m":   90 * time.Minute,
+		"7d":    7 * 24 * time.Hour,
+		"1d12h": 36 * time.Hour,
+	}
+	for in, want := range tests {
+		if got, err := ParseDuration(in); err != nil || got != want {
+			t.Errorf("ParseDuration(%q): got %v, err %v", in, got, err)
+		}
+	}
+	for _, bad := range []string{"", "d", "xd", "1dx", "soon"} {
+		if _, err := ParseDuration(bad); err == nil {
+			t.Errorf("ParseDuration(%q): expected error", bad)
+		}
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2026, 3, 2, 18, 30, 0, 0, time.UTC)
+	tests := map[string]time.Time{
+		"2h":                   now.Add(-2 * time.Hour),
+		"1d":                   now.Add(-24 * time.Hour),
+		"2026-03-01 14:00":     time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC),
+		"2026-03-01":           time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		"14:15":                time.Date(2026, 3, 2, 14, 15, 0, 0, time.UTC),
+		"2026-03-01T14:00:00Z": time.Date(2026, 3, 1, 14, 0, 0, 0, time.UTC),
+	}
+	for in, want := range tests {
+		if got, err := ParseTime(in, now); err != nil || !got.Equal(want) {
+			t.Errorf("ParseTime(%q): got %v, err %v", in, got, err)
+		}
+	}
+	if _, err := ParseTime("yesterday", now); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,333 @@
+// Package metrics exposes scan results in the Prometheus text exposition
+// format, without depending on the Prometheus client library.
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/saturation"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+	"github.com/AbdullahTarakji/portpilot/internal/traffic"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Exporter scans ports on demand or on an interval and serves the latest
+// result as metrics. It implements http.Handler.
+type Exporter struct {
+	scanner  scanner.Scanner
+	groupFor func(port int) string
+
+	mu       sync.Mutex
+	ports    []scanner.PortInfo
+	queues   *saturation.Tracker
+	meter    *traffic.Meter // nil when the scanner can't read traffic
+	rates    map[int]traffic.Rate
+	conns    map[int]int // nil when the scanner can't count connections
+	duration time.Duration
+	lastScan time.Time
+	scans    int
+	errors   int
+}
+
+// New creates an Exporter. groupFor names the config group of a port and
+// may be nil.
+func New(s scanner.Scanner, groupFor func(port int) string) *Exporter {
+	e := &Exporter{scanner: s, groupFor: groupFor, queues: saturation.NewTracker(saturation.DefaultRule, 0)}
+	if _, ok := s.(scanner.TrafficCounter); ok {
+		e.meter = traffic.NewMeter()
+	}
+	return e
+}
+
+// SetSaturationRule changes the rule behind the queue saturation metric.
+// Queue history collected so far is dropped.
+func (e *Exporter) SetSaturationRule(rule saturation.Rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.queues = saturation.NewTracker(rule, 0)
+}
+
+// Refresh runs one scan and records the result. When the scan fails the
+// previous listeners are kept and the error counter goes up.
+func (e *Exporter) Refresh() error {
+	start := time.Now()
+	ports, err := e.scanner.Scan()
+	var conns map[int]int
+	var counters []scanner.ConnTraffic
+	var trafficErr error
+	if err == nil {
+		if c, ok := e.scanner.(scanner.ConnectionCounter); ok {
+			// Connection counts are optional; leave them out on failure.
+			conns, _ = c.Connections()
+		}
+		if e.meter != nil {
+			counters, trafficErr = e.scanner.(scanner.TrafficCounter).Traffic()
+		}
+	}
+	elapsed := time.Since(start)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.scans++
+	e.duration = elapsed
+	if err != nil {
+		e.errors++
+		return err
+	}
+	e.ports = ports
+	e.conns = conns
+	e.lastScan = time.Now()
+	e.queues.Observe(ports, e.lastScan)
+	if e.meter != nil && trafficErr == nil {
+		// Rates need two samples; until then they are left out.
+		e.rates = e.meter.Update(counters, e.lastScan)
+	}
+	return nil
+}
+
+// Run refreshes every interval until ctx is cancelled. Scan errors are
+// passed to onError, which may be nil.
+func (e *Exporter) Run(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Refresh(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// ServeHTTP writes the metrics of the latest scan.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	_ = e.Write(w)
+}
+
+// Write renders the metrics of the latest scan in the text format.
+func (e *Exporter) Write(w io.Writer) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	ports := append([]scanner.PortInfo(nil), e.ports...)
+	sort.SliceStable(ports, func(i, j int) bool {
+		a, b := ports[i], ports[j]
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.PID < b.PID
+	})
+
+	tw := &textWriter{w: bufio.NewWriter(w)}
+
+	listeners := e.listeners(ports)
+	tw.family("portpilot_listener", "gauge", "Listening sockets of a process on a port: 1, or more for several addresses or worker processes.")
+	for _, l := range listeners {
+		tw.sample("portpilot_listener", l.labels, float64(len(l.sockets)))
+	}
+	tw.family("portpilot_listener_cpu_percent", "gauge", "CPU usage of the processes owning the listener.")
+	for _, l := range listeners {
+		tw.sample("portpilot_listener_cpu_percent", l.labels, l.sum(func(p scanner.PortInfo) float64 { return p.CPU }))
+	}
+	tw.family("portpilot_listener_memory_percent", "gauge", "Memory usage of the processes owning the listener, as a share of physical memory.")
+	for _, l := range listeners {
+		tw.sample("portpilot_listener_memory_percent", l.labels, l.sum(func(p scanner.PortInfo) float64 { return p.Mem }))
+	}
+	tw.family("portpilot_listener_rss_bytes", "gauge", "Resident set size of the processes owning the listener.")
+	for _, l := range listeners {
+		tw.sample("portpilot_listener_rss_bytes", l.labels, l.sum(func(p scanner.PortInfo) float64 { return float64(p.RSS) }))
+	}
+
+	var queued []listener
+	for _, l := range listeners {
+		if l.sockets[0].Protocol == "TCP" && slices.ContainsFunc(l.sockets, func(p scanner.PortInfo) bool { return p.SendQ > 0 }) {
+			queued = append(queued, l)
+		}
+	}
+	if len(queued) > 0 {
+		tw.family("portpilot_listener_accept_queue", "gauge", "Connections waiting to be accepted by a TCP listener, over its sockets.")
+		for _, l := range queued {
+			tw.sample("portpilot_listener_accept_queue", l.labels, l.total(func(p scanner.PortInfo) int { return p.RecvQ }))
+		}
+		tw.family("portpilot_listener_backlog", "gauge", "Listen backlog of a TCP listener, over its sockets.")
+		for _, l := range queued {
+			tw.sample("portpilot_listener_backlog", l.labels, l.total(func(p scanner.PortInfo) int { return p.SendQ }))
+		}
+		tw.family("portpilot_listener_queue_saturated", "gauge", "1 if the accept queue of a socket has stayed near the backlog for several scans.")
+		for _, l := range queued {
+			v := 0.0
+			if slices.ContainsFunc(l.sockets, e.queues.Saturated) {
+				v = 1
+			}
+			tw.sample("portpilot_listener_queue_saturated", l.labels, v)
+		}
+	}
+
+	if e.conns != nil {
+		tw.family("portpilot_listener_connections", "gauge", "Established TCP connections to the listening port.")
+		seen := make(map[int]bool)
+		for _, p := range ports {
+			if p.Protocol != "TCP" || seen[p.Port] {
+				continue
+			}
+			seen[p.Port] = true
+			tw.sample("portpilot_listener_connections",
+				[]label{{"port", strconv.Itoa(p.Port)}, {"protocol", p.Protocol}}, float64(e.conns[p.Port]))
+		}
+	}
+
+	if e.rates != nil {
+		tcpPorts := make([]int, 0, len(ports))
+		seen := make(map[int]bool)
+		for _, p := range ports {
+			if p.Protocol == "TCP" && !seen[p.Port] {
+				seen[p.Port] = true
+				tcpPorts = append(tcpPorts, p.Port)
+			}
+		}
+		for _, m := range []struct {
+			name, help string
+			value      func(traffic.Rate) float64
+		}{
+			{"portpilot_listener_receive_bytes_per_second", "Bytes received per second on connections to the TCP port, between the last two scans.", func(r traffic.Rate) float64 { return r.BytesIn }},
+			{"portpilot_listener_transmit_bytes_per_second", "Bytes sent per second on connections to the TCP port, between the last two scans.", func(r traffic.Rate) float64 { return r.BytesOut }},
+			{"portpilot_listener_receive_packets_per_second", "Segments received per second on connections to the TCP port, between the last two scans.", func(r traffic.Rate) float64 { return r.PacketsIn }},
+			{"portpilot_listener_transmit_packets_per_second", "Segments sent per second on connections to the TCP port, between the last two scans.", func(r traffic.Rate) float64 { return r.PacketsOut }},
+		} {
+			tw.family(m.name, "gauge", m.help)
+			for _, port := range tcpPorts {
+				tw.sample(m.name, []label{{"port", strconv.Itoa(port)}, {"protocol", "TCP"}}, m.value(e.rates[port]))
+			}
+		}
+	}
+
+	tw.family("portpilot_listeners", "gauge", "Number of listening sockets found by the last scan.")
+	tw.sample("portpilot_listeners", nil, float64(len(ports)))
+	tw.family("portpilot_scan_duration_seconds", "gauge", "Duration of the last scan.")
+	tw.sample("portpilot_scan_duration_seconds", nil, e.duration.Seconds())
+	tw.family("portpilot_scans_total", "counter", "Scans run since start.")
+	tw.sample("portpilot_scans_total", nil, float64(e.scans))
+	tw.family("portpilot_scan_errors_total", "counter", "Scans that failed since start.")
+	tw.sample("portpilot_scan_errors_total", nil, float64(e.errors))
+	if !e.lastScan.IsZero() {
+		tw.family("portpilot_last_scan_timestamp_seconds", "gauge", "Unix time of the last successful scan.")
+		tw.sample("portpilot_last_scan_timestamp_seconds", nil, float64(e.lastScan.UnixMilli())/1000)
+	}
+
+	return tw.w.Flush()
+}
+
+// listener is the sockets sharing one set of listener labels: those of a
+// process listening on several addresses, or of forked workers sharing a
+// port.
+type listener struct {
+	labels  []label
+	sockets []scanner.PortInfo
+}
+
+// sum adds up a process figure over the listener's processes, counting
+// each process once.
+func (l listener) sum(value func(scanner.PortInfo) float64) float64 {
+	seen := make(map[int]bool)
+	total := 0.0
+	for _, p := range l.sockets {
+		if !seen[p.PID] {
+			seen[p.PID] = true
+			total += value(p)
+		}
+	}
+	return total
+}
+
+// total adds up a socket figure over the listener's sockets.
+func (l listener) total(value func(scanner.PortInfo) int) float64 {
+	n := 0
+	for _, p := range l.sockets {
+		n += value(p)
+	}
+	return float64(n)
+}
+
+// listeners groups ports by their labels, in order. The labels leave out
+// the PID and address: a series per PID would be left behind on every
+// restart.
+func (e *Exporter) listeners(ports []scanner.PortInfo) []listener {
+	var result []listener
+	index := make(map[string]int)
+	for _, p := range ports {
+		group := ""
+		if e.groupFor != nil {
+			group = e.groupFor(p.Port)
+		}
+		labels := []label{
+			{"port", strconv.Itoa(p.Port)},
+			{"protocol", p.Protocol},
+			{"process", p.ProcessName},
+			{"user", p.User},
+			{"group", group},
+		}
+		key := fmt.Sprint(labels)
+		if i, ok := index[key]; ok {
+			result[i].sockets = append(result[i].sockets, p)
+			continue
+		}
+		index[key] = len(result)
+		result = append(result, listener{labels: labels, sockets: []scanner.PortInfo{p}})
+	}
+	return result
+}
+
+type label struct {
+	name, value string
+}
+
+// textWriter writes metric families in the Prometheus text format.
+type textWriter struct {
+	w *bufio.Writer
+}
+
+func (t *textWriter) family(name, typ, help string) {
+	t.w.WriteString("# HELP " + name + " " + help + "\n")
+	t.w.WriteString("# TYPE " + name + " " + typ + "\n")
+}
+
+func (t *textWriter) sample(name string, labels []label, value float64) {
+	t.w.WriteString(name)
+	if len(labels) > 0 {
+		t.w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				t.w.WriteByte(',')
+			}
+			t.w.WriteString(l.name + `="` + escapeLabel(l.value) + `"`)
+		}
+		t.w.WriteByte('}')
+	}
+	t.w.WriteString(" " + strconv.FormatFloat(value, 'g', -1, 64) + "\n")
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value as the text format requires.
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
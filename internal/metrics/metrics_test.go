@@ -0,0 +1,239 @@
+package metrics
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/saturation"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+type fakeScanner struct {
+	ports []scanner.PortInfo
+	err   error
+}
+
+func (f *fakeScanner) Scan() ([]scanner.PortInfo, error) { return f.ports, f.err }
+
+type countingScanner struct {
+	fakeScanner
+	conns map[int]int
+}
+
+func (c *countingScanner) Connections() (map[int]int, error) { return c.conns, nil }
+
+func testPorts() []scanner.PortInfo {
+	return []scanner.PortInfo{
+		{Port: 5432, Protocol: "TCP", Address: "127.0.0.1", PID: 812, ProcessName: "postgres", User: "mike", CPU: 0.5, Mem: 1.2, RSS: 4096},
+		{Port: 3000, Protocol: "TCP", Address: "*", PID: 4242, ProcessName: "node", User: "mike", CPU: 12.5, Mem: 3},
+		{Port: 5353, Protocol: "UDP", Address: "*", PID: 100, ProcessName: "avahi", User: "avahi"},
+	}
+}
+
+func scrape(t *testing.T, e *Exporter) string {
+	t.Helper()
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("scraping: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != ContentType {
+		t.Errorf("content type: got %q", ct)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	return string(body)
+}
+
+func TestExporter(t *testing.T) {
+	s := &countingScanner{fakeScanner: fakeScanner{ports: testPorts()}, conns: map[int]int{5432: 7}}
+	groups := map[int]string{5432: "database"}
+	e := New(s, func(port int) string { return groups[port] })
+	if err := e.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	body := scrape(t, e)
+	want := []string{
+		"# TYPE portpilot_listener gauge\n",
+		`portpilot_listener{port="3000",protocol="TCP",process="node",user="mike",group=""} 1` + "\n",
+		`portpilot_listener{port="5432",protocol="TCP",process="postgres",user="mike",group="database"} 1` + "\n",
+		`portpilot_listener_cpu_percent{port="3000",protocol="TCP",process="node",user="mike",group=""} 12.5` + "\n",
+		`portpilot_listener_memory_percent{port="5432",protocol="TCP",process="postgres",user="mike",group="database"} 1.2` + "\n",
+		`portpilot_listener_rss_bytes{port="5432",protocol="TCP",process="postgres",user="mike",group="database"} 4096` + "\n",
+		`portpilot_listener_connections{port="5432",protocol="TCP"} 7` + "\n",
+		`portpilot_listener_connections{port="3000",protocol="TCP"} 0` + "\n",
+		"portpilot_listeners 3\n",
+		"# TYPE portpilot_scans_total counter\n",
+		"portpilot_scans_total 1\n",
+		"portpilot_scan_errors_total 0\n",
+		"portpilot_last_scan_timestamp_seconds ",
+		"portpilot_scan_duration_seconds ",
+	}
+	for _, w := range want {
+		if !strings.Contains(body, w) {
+			t.Errorf("missing %q in:\n%s", w, body)
+		}
+	}
+	if strings.Contains(body, `portpilot_listener_connections{port="5353"`) {
+		t.Error("UDP listeners should have no connection count")
+	}
+	if strings.Index(body, `port="3000"`) > strings.Index(body, `port="5432"`) {
+		t.Error("listeners should be sorted by port")
+	}
+}
+
+func TestExporterSharedListener(t *testing.T) {
+	// nginx workers sharing port 80 over IPv4 and IPv6.
+	ports := []scanner.PortInfo{
+		{Port: 80, Protocol: "TCP", Address: "0.0.0.0", PID: 812, ProcessName: "nginx", User: "www", CPU: 1, RSS: 1000},
+		{Port: 80, Protocol: "TCP", Address: "::", PID: 812, ProcessName: "nginx", User: "www", CPU: 1, RSS: 1000},
+		{Port: 80, Protocol: "TCP", Address: "0.0.0.0", PID: 813, ProcessName: "nginx", User: "www", CPU: 2, RSS: 3000},
+	}
+	e := New(&fakeScanner{ports: ports}, nil)
+	if err := e.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	body := scrape(t, e)
+	labels := `{port="80",protocol="TCP",process="nginx",user="www",group=""}`
+	for _, w := range []string{
+		"portpilot_listener" + labels + " 3\n",
+		"portpilot_listener_cpu_percent" + labels + " 3\n",
+		"portpilot_listener_rss_bytes" + labels + " 4000\n",
+	} {
+		if !strings.Contains(body, w) {
+			t.Errorf("missing %q in:\n%s", w, body)
+		}
+	}
+	if n := strings.Count(body, "portpilot_listener{"); n != 1 {
+		t.Errorf("got %d listener series, want 1", n)
+	}
+}
+
+func TestExporterQueues(t *testing.T) {
+	ports := testPorts()
+	ports[0].RecvQ, ports[0].SendQ = 100, 100
+	ports[1].RecvQ, ports[1].SendQ = 0, 511
+	e := New(&fakeScanner{ports: ports}, nil)
+	e.SetSaturationRule(saturation.Rule{Threshold: 0.9, Scans: 2})
+
+	pg := `{port="5432",protocol="TCP",process="postgres",user="mike",group=""}`
+	for scan, saturated := range []string{"0", "1"} {
+		if err := e.Refresh(); err != nil {
+			t.Fatalf("Refresh: %v", err)
+		}
+		body := scrape(t, e)
+		for _, w := range []string{
+			"portpilot_listener_accept_queue" + pg + " 100\n",
+			"portpilot_listener_backlog" + pg + " 100\n",
+			"portpilot_listener_queue_saturated" + pg + " " + saturated + "\n",
+			`portpilot_listener_queue_saturated{port="3000",protocol="TCP",process="node",user="mike",group=""} 0` + "\n",
+		} {
+			if !strings.Contains(body, w) {
+				t.Errorf("scan %d: missing %q", scan+1, w)
+			}
+		}
+		if strings.Contains(body, `portpilot_listener_backlog{port="5353"`) {
+			t.Error("UDP listeners have no backlog")
+		}
+	}
+}
+
+// trafficScanner reports a connection to port 5432 whose counters grow by
+// a fixed amount on every read.
+type trafficScanner struct {
+	fakeScanner
+	reads uint64
+}
+
+func (s *trafficScanner) Traffic() ([]scanner.ConnTraffic, error) {
+	s.reads++
+	return []scanner.ConnTraffic{{LocalPort: 5432, Local: "127.0.0.1:5432", Peer: "127.0.0.1:50000",
+		BytesIn: 1000 * s.reads, BytesOut: 2000 * s.reads, PacketsIn: s.reads, PacketsOut: s.reads}}, nil
+}
+
+func TestExporterTraffic(t *testing.T) {
+	e := New(&trafficScanner{fakeScanner: fakeScanner{ports: testPorts()}}, nil)
+	if err := e.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if body := scrape(t, e); strings.Contains(body, "_per_second") {
+		t.Error("rates need two scans")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if err := e.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	body := scrape(t, e)
+	for _, name := range []string{"receive_bytes", "transmit_bytes", "receive_packets", "transmit_packets"} {
+		busy := "portpilot_listener_" + name + `_per_second{port="5432",protocol="TCP"} `
+		idle := "portpilot_listener_" + name + `_per_second{port="3000",protocol="TCP"} 0` + "\n"
+		if !strings.Contains(body, busy) || strings.Contains(body, busy+"0\n") {
+			t.Errorf("expected a non-zero %s rate for port 5432", name)
+		}
+		if !strings.Contains(body, idle) {
+			t.Errorf("expected a zero %s rate for port 3000", name)
+		}
+	}
+	if strings.Contains(body, `_per_second{port="5353"`) {
+		t.Error("UDP ports have no connection traffic")
+	}
+}
+
+func TestExporterWithoutConnectionCounts(t *testing.T) {
+	e := New(&fakeScanner{ports: testPorts()}, nil)
+	if err := e.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if body := scrape(t, e); strings.Contains(body, "portpilot_listener_connections") {
+		t.Errorf("connections reported for a scanner that can't count them:\n%s", body)
+	}
+}
+
+func TestExporterScanError(t *testing.T) {
+	s := &fakeScanner{ports: testPorts()}
+	e := New(s, nil)
+	if err := e.Refresh(); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+
+	s.ports, s.err = nil, errors.New("ss not found")
+	if err := e.Refresh(); err == nil {
+		t.Fatal("expected scan error")
+	}
+
+	body := scrape(t, e)
+	for _, w := range []string{"portpilot_scans_total 2\n", "portpilot_scan_errors_total 1\n", "portpilot_listeners 3\n"} {
+		if !strings.Contains(body, w) {
+			t.Errorf("missing %q in:\n%s", w, body)
+		}
+	}
+}
+
+func TestExporterBeforeFirstScan(t *testing.T) {
+	body := scrape(t, New(&fakeScanner{}, nil))
+	if !strings.Contains(body, "portpilot_scans_total 0\n") {
+		t.Errorf("missing scan counter:\n%s", body)
+	}
+	if strings.Contains(body, "portpilot_last_scan_timestamp_seconds") {
+		t.Error("no timestamp expected before the first scan")
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	got := escapeLabel("a\"b\\c\nd")
+	if want := `a\"b\\c\nd`; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build darwin
+
+package notify
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+var appleScriptEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+// desktopCommand shows the notification through Notification Center.
+func desktopCommand(ctx context.Context, title, text string) *exec.Cmd {
+	script := `display notification "` + appleScriptEscaper.Replace(text) +
+		`" with title "portpilot" subtitle "` + appleScriptEscaper.Replace(title) + `"`
+	return exec.CommandContext(ctx, "osascript", "-e", script)
+}
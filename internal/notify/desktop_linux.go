@@ -0,0 +1,13 @@
+//go:build linux
+
+package notify
+
+import (
+	"context"
+	"os/exec"
+)
+
+// desktopCommand sends the notification over D-Bus with notify-send.
+func desktopCommand(ctx context.Context, title, text string) *exec.Cmd {
+	return exec.CommandContext(ctx, "notify-send", "--app-name=portpilot", title, text)
+}
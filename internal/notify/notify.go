@@ -0,0 +1,210 @@
+// Package notify delivers port open and close events to the sinks configured
+// under "notifiers": webhooks, Slack, shell commands and desktop
+// notifications.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/events"
+	"github.com/AbdullahTarakji/portpilot/internal/query"
+)
+
+// sendTimeout bounds a single delivery, so a stuck sink can't hold up the
+// others.
+const sendTimeout = 10 * time.Second
+
+// Message is what a sink delivers: the event plus a human-readable summary.
+// Webhooks receive it as JSON.
+type Message struct {
+	events.Event
+	Hostname string `json:"hostname"`
+	Group    string `json:"group,omitempty"`
+	Title    string `json:"title"`
+	Text     string `json:"text"`
+}
+
+// Sink delivers messages somewhere.
+type Sink interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// Notifier routes events to sinks according to their filters and rate
+// limits. It is safe for concurrent use.
+type Notifier struct {
+	routes   []*route
+	groupFor func(port int) string
+	hostname string
+}
+
+type route struct {
+	name    string
+	sink    Sink
+	kinds   map[events.Kind]bool // nil means every kind
+	filter  *query.Query
+	limiter *limiter
+}
+
+// New builds a Notifier from the config. groupFor names the config group of
+// a port and may be nil.
+func New(cfgs []config.Notifier, groupFor func(port int) string) (*Notifier, error) {
+	hostname, _ := os.Hostname()
+	n := &Notifier{groupFor: groupFor, hostname: hostname}
+	for _, c := range cfgs {
+		sink, err := newSink(c)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: %w", c.Name, err)
+		}
+		filter, err := query.Parse(c.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %s: invalid filter: %w", c.Name, err)
+		}
+		r := &route{name: c.Name, sink: sink, filter: filter, limiter: newLimiter(c.RateLimit, time.Minute)}
+		if len(c.Events) > 0 {
+			r.kinds = make(map[events.Kind]bool)
+			for _, k := range c.Events {
+				r.kinds[events.Kind(k)] = true
+			}
+		}
+		n.routes = append(n.routes, r)
+	}
+	return n, nil
+}
+
+func newSink(c config.Notifier) (Sink, error) {
+	switch c.Type {
+	case config.NotifierWebhook:
+		return &WebhookSink{URL: c.URL, Headers: c.Headers}, nil
+	case config.NotifierSlack:
+		return &WebhookSink{URL: c.URL, Headers: c.Headers, Slack: true}, nil
+	case config.NotifierExec:
+		return &ExecSink{Command: c.Command}, nil
+	case config.NotifierDesktop:
+		return DesktopSink{}, nil
+	}
+	return nil, fmt.Errorf("unknown type %q", c.Type)
+}
+
+// Len returns the number of configured sinks.
+func (n *Notifier) Len() int {
+	return len(n.routes)
+}
+
+// Notify sends an event to every sink whose filter matches it and whose
+// rate limit allows it. Errors from all sinks are joined.
+func (n *Notifier) Notify(ctx context.Context, e events.Event) error {
+	group := ""
+	if n.groupFor != nil {
+		group = n.groupFor(e.Port.Port)
+	}
+	m := n.message(e, group)
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for _, r := range n.routes {
+		if r.kinds != nil && !r.kinds[e.Kind] {
+			continue
+		}
+		if !r.filter.Match(e.Port, group) || !r.limiter.allow() {
+			continue
+		}
+		wg.Add(1)
+		go func(r *route) {
+			defer wg.Done()
+			sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+			defer cancel()
+			if err := r.sink.Send(sendCtx, m); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("notifier %s: %w", r.name, err))
+				mu.Unlock()
+			}
+		}(r)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// message builds the summary of an event, e.g. "Port 5432/TCP opened" and
+// "postgres (PID 812, user mike) on 127.0.0.1".
+func (n *Notifier) message(e events.Event, group string) Message {
+	p := e.Port
+	verb := "opened"
+	if e.Kind == events.Close {
+		verb = "closed"
+	}
+	title := fmt.Sprintf("Port %d/%s %s", p.Port, p.Protocol, verb)
+	if n.hostname != "" {
+		title += " on " + n.hostname
+	}
+
+	name := p.ProcessName
+	if name == "" {
+		name = "unknown process"
+	}
+	var details []string
+	if p.PID > 0 {
+		details = append(details, fmt.Sprintf("PID %d", p.PID))
+	}
+	if p.User != "" {
+		details = append(details, "user "+p.User)
+	}
+	if group != "" {
+		details = append(details, "group "+group)
+	}
+	text := name
+	if len(details) > 0 {
+		text += " (" + strings.Join(details, ", ") + ")"
+	}
+	if p.Address != "" {
+		text += " on " + p.Address
+	}
+
+	return Message{Event: e, Hostname: n.hostname, Group: group, Title: title, Text: text}
+}
+
+// limiter allows at most limit calls per window. A limit of zero allows
+// everything.
+type limiter struct {
+	limit  int
+	window time.Duration
+	now    func() time.Time
+
+	mu   sync.Mutex
+	sent []time.Time
+}
+
+func newLimiter(limit int, window time.Duration) *limiter {
+	return &limiter{limit: limit, window: window, now: time.Now}
+}
+
+func (l *limiter) allow() bool {
+	if l.limit <= 0 {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	recent := l.sent[:0]
+	for _, t := range l.sent {
+		if now.Sub(t) < l.window {
+			recent = append(recent, t)
+		}
+	}
+	l.sent = recent
+	if len(l.sent) >= l.limit {
+		return false
+	}
+	l.sent = append(l.sent, now)
+	return true
+}
@@ -0,0 +1,207 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/events"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+func openEvent(port int, proc string) events.Event {
+	return events.Event{
+		Kind: events.Open,
+		Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Port: scanner.PortInfo{Port: port, Protocol: "TCP", Address: "0.0.0.0", PID: 812, ProcessName: proc, User: "mike"},
+	}
+}
+
+type request struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookServer records the requests it receives and answers with status.
+func webhookServer(t *testing.T, status int) (*httptest.Server, func() []request) {
+	t.Helper()
+	var (
+		mu   sync.Mutex
+		reqs []request
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		reqs = append(reqs, request{r.Header.Clone(), body})
+		mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request(nil), reqs...)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	srv, received := webhookServer(t, http.StatusNoContent)
+	n, err := New([]config.Notifier{{
+		Name: "hook", Type: config.NotifierWebhook, URL: srv.URL,
+		Headers: map[string]string{"Authorization": "Bearer s3cret"},
+	}}, func(port int) string { return "database" })
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := n.Notify(context.Background(), openEvent(5432, "postgres")); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+
+	reqs := received()
+	if len(reqs) != 1 {
+		t.Fatalf("got %d requests, want 1", len(reqs))
+	}
+	if got := reqs[0].header.Get("Authorization"); got != "Bearer s3cret" {
+		t.Errorf("Authorization header: got %q", got)
+	}
+	if got := reqs[0].header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type: got %q", got)
+	}
+
+	var body struct {
+		Kind  string           `json:"kind"`
+		Time  time.Time        `json:"time"`
+		Port  scanner.PortInfo `json:"port"`
+		Group string           `json:"group"`
+		Title string           `json:"title"`
+		Text  string           `json:"text"`
+	}
+	if err := json.Unmarshal(reqs[0].body, &body); err != nil {
+		t.Fatalf("decoding body %s: %v", reqs[0].body, err)
+	}
+	if body.Kind != "open" || body.Port.Port != 5432 || body.Group != "database" || body.Time.IsZero() {
+		t.Errorf("body: %+v", body)
+	}
+	if !strings.HasPrefix(body.Title, "Port 5432/TCP opened") {
+		t.Errorf("title: got %q", body.Title)
+	}
+	if body.Text != "postgres (PID 812, user mike, group database) on 0.0.0.0" {
+		t.Errorf("text: got %q", body.Text)
+	}
+}
+
+func TestSlackSink(t *testing.T) {
+	srv, received := webhookServer(t, http.StatusOK)
+	sink := &WebhookSink{URL: srv.URL, Slack: true}
+	m := Message{Event: openEvent(3000, "node"), Title: "Port 3000/TCP opened", Text: "node (PID 812)"}
+	if err := sink.Send(context.Background(), m); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var body map[string]any
+	if err := json.Unmarshal(received()[0].body, &body); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	if len(body) != 1 || body["text"] != "*Port 3000/TCP opened*\nnode (PID 812)" {
+		t.Errorf("slack payload: %v", body)
+	}
+}
+
+func TestWebhookSinkError(t *testing.T) {
+	srv, _ := webhookServer(t, http.StatusInternalServerError)
+	sink := &WebhookSink{URL: srv.URL}
+	err := sink.Send(context.Background(), Message{Event: openEvent(3000, "node")})
+	if err == nil || !strings.Contains(err.Error(), "500") {
+		t.Errorf("expected status error, got %v", err)
+	}
+}
+
+func TestNotifierRouting(t *testing.T) {
+	srv, received := webhookServer(t, http.StatusOK)
+	n, err := New([]config.Notifier{{
+		Name: "db", Type: config.NotifierWebhook, URL: srv.URL,
+		Events: []string{"open"}, Filter: "port:5432",
+	}}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	closeEv := openEvent(5432, "postgres")
+	closeEv.Kind = events.Close
+	for _, e := range []events.Event{openEvent(3000, "node"), closeEv, openEvent(5432, "postgres")} {
+		if err := n.Notify(context.Background(), e); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+	if got := len(received()); got != 1 {
+		t.Errorf("got %d requests, want only the matching open event", got)
+	}
+}
+
+func TestNotifierRateLimit(t *testing.T) {
+	srv, received := webhookServer(t, http.StatusOK)
+	n, err := New([]config.Notifier{{Name: "hook", Type: config.NotifierWebhook, URL: srv.URL, RateLimit: 2}}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := n.Notify(context.Background(), openEvent(3000+i, "node")); err != nil {
+			t.Fatalf("Notify: %v", err)
+		}
+	}
+	if got := len(received()); got != 2 {
+		t.Errorf("got %d requests, want 2", got)
+	}
+}
+
+func TestLimiterWindow(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	l := newLimiter(1, time.Minute)
+	l.now = func() time.Time { return now }
+
+	if !l.allow() || l.allow() {
+		t.Fatal("expected one call per window")
+	}
+	now = now.Add(61 * time.Second)
+	if !l.allow() {
+		t.Error("expected the limit to reset after the window")
+	}
+	if unlimited := newLimiter(0, time.Minute); !unlimited.allow() || !unlimited.allow() {
+		t.Error("zero limit should allow everything")
+	}
+}
+
+func TestExecSink(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	sink := &ExecSink{Command: `echo "$PORTPILOT_EVENT {port} {process}" > ` + out + `; cat >> ` + out}
+	m := Message{Event: openEvent(3000, "node"), Title: "t", Text: "x"}
+	if err := sink.Send(context.Background(), m); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, rest, _ := strings.Cut(string(data), "\n")
+	if first != "open 3000 node" {
+		t.Errorf("command output: got %q", first)
+	}
+	if !strings.Contains(rest, `"process_name":"node"`) {
+		t.Errorf("stdin JSON: got %q", rest)
+	}
+
+	if err := (&ExecSink{Command: "echo oops >&2; exit 3"}).Send(context.Background(), m); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("expected failure with output, got %v", err)
+	}
+}
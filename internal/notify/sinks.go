@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/AbdullahTarakji/portpilot/internal/action"
+)
+
+// WebhookSink POSTs each message as JSON. With Slack set the body is a
+// Slack-compatible {"text": ...} payload instead, which also suits
+// Mattermost and Discord's Slack endpoint.
+type WebhookSink struct {
+	URL     string
+	Headers map[string]string
+	Slack   bool
+	// Client defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+type slackPayload struct {
+	Text string `json:"text"`
+}
+
+// Send posts the message.
+func (s *WebhookSink) Send(ctx context.Context, m Message) error {
+	var body any = m
+	if s.Slack {
+		body = slackPayload{Text: fmt.Sprintf("*%s*\n%s", m.Title, m.Text)}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "portpilot")
+	for k, v := range s.Headers {
+		req.Header.Set(k, v)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// ExecSink runs a shell command for each message. Command is an action
+// template, so placeholders such as {port} and {process} are filled in from
+// the event's port. The command also gets the event kind in
+// $PORTPILOT_EVENT, the summary in $PORTPILOT_MESSAGE and the message as
+// JSON on stdin.
+type ExecSink struct {
+	Command string
+}
+
+// Send runs the command.
+func (s *ExecSink) Send(ctx context.Context, m Message) error {
+	expanded, err := action.Expand(s.Command, m.Port, m.Group)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", expanded)
+	cmd.Env = append(os.Environ(),
+		"PORTPILOT_EVENT="+string(m.Kind),
+		"PORTPILOT_MESSAGE="+m.Title+": "+m.Text,
+	)
+	cmd.Stdin = bytes.NewReader(data)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("running %q: %w: %s", expanded, err, msg)
+		}
+		return fmt.Errorf("running %q: %w", expanded, err)
+	}
+	return nil
+}
+
+// DesktopSink shows a desktop notification, through notify-send (D-Bus) on
+// Linux and osascript on macOS.
+type DesktopSink struct{}
+
+// Send shows the notification.
+func (DesktopSink) Send(ctx context.Context, m Message) error {
+	cmd := desktopCommand(ctx, m.Title, m.Text)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("showing notification: %w: %s", err, msg)
+		}
+		return fmt.Errorf("showing notification: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package output
+
+import (
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// SchemaVersion is the version of the JSON and YAML output envelope. It is
+// bumped whenever a field is removed, renamed or changes type; adding a
+// field is not a breaking change.
+const SchemaVersion = 1
+
+// Meta describes the scan a listing came from.
+type Meta struct {
+	Hostname  string
+	ScannedAt time.Time
+	Backend   string
+	Warnings  []string
+}
+
+// Envelope is the top-level object of JSON and YAML output.
+type Envelope struct {
+	SchemaVersion int                `json:"schema_version" yaml:"schema_version"`
+	Hostname      string             `json:"hostname" yaml:"hostname"`
+	ScannedAt     time.Time          `json:"scanned_at" yaml:"scanned_at"`
+	Backend       string             `json:"backend" yaml:"backend"`
+	Warnings      []string           `json:"warnings" yaml:"warnings"`
+	Ports         []scanner.PortInfo `json:"ports" yaml:"ports"`
+}
+
+// NewEnvelope wraps ports in an envelope. Nil slices become empty ones so
+// consumers always see arrays.
+func NewEnvelope(ports []scanner.PortInfo, meta Meta) Envelope {
+	if ports == nil {
+		ports = []scanner.PortInfo{}
+	}
+	warnings := meta.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	return Envelope{
+		SchemaVersion: SchemaVersion,
+		Hostname:      meta.Hostname,
+		ScannedAt:     meta.ScannedAt,
+		Backend:       meta.Backend,
+		Warnings:      warnings,
+		Ports:         ports,
+	}
+}
+
+// Meta returns the scan metadata recorded in the envelope.
+func (e Envelope) Meta() Meta {
+	return Meta{
+		Hostname:  e.Hostname,
+		ScannedAt: e.ScannedAt,
+		Backend:   e.Backend,
+		Warnings:  e.Warnings,
+	}
+}
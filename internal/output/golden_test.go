@@ -0,0 +1,126 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// schemaPath is the published schema document, kept in sync with the types
+// by TestJSONSchemaGolden.
+const schemaPath = "../../docs/schema/portpilot.schema.json"
+
+func goldenMeta() Meta {
+	return Meta{
+		Hostname:  "devbox",
+		ScannedAt: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Backend:   "ss",
+	}
+}
+
+func goldenPorts() []scanner.PortInfo {
+	return []scanner.PortInfo{
+		{
+			Port: 3000, Protocol: "TCP", Address: "127.0.0.1", PID: 4242,
+			ProcessName: "node", User: "mike", State: "LISTEN",
+			Command: "node server.js", CPU: 2.5, Mem: 1.25,
+			StartTime: time.Date(2026, 3, 1, 9, 30, 0, 0, time.UTC),
+			RSS:       52428800, Threads: 11,
+		},
+		{
+			// No process details: the zero StartTime must be left out.
+			Port: 5353, Protocol: "UDP", Address: "*", State: "LISTEN",
+		},
+	}
+}
+
+// checkGolden compares got with testdata/name, or rewrites the file when
+// the tests run with -update.
+func checkGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("updating %s: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run go test -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s; if the change is intended, bump SchemaVersion when it breaks consumers and run go test ./internal/output -update\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestEnvelopeGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		ports  []scanner.PortInfo
+	}{
+		{"list.json", JSON, goldenPorts()},
+		{"empty.json", JSON, nil},
+		{"list.yaml", YAML, goldenPorts()},
+		{"list.jsonl", JSONL, goldenPorts()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			opts := Options{Format: tt.format, Meta: goldenMeta()}
+			if err := Write(&buf, tt.ports, opts); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			checkGolden(t, filepath.Join("testdata", tt.name+".golden"), buf.Bytes())
+		})
+	}
+}
+
+func TestJSONSchemaGolden(t *testing.T) {
+	data, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema: %v", err)
+	}
+	checkGolden(t, schemaPath, data)
+}
+
+func TestJSONSchemaRequiredFields(t *testing.T) {
+	data, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema: %v", err)
+	}
+	var schema struct {
+		Required   []string `json:"required"`
+		Properties struct {
+			Ports struct {
+				Items struct {
+					Required   []string                   `json:"required"`
+					Properties map[string]json.RawMessage `json:"properties"`
+				} `json:"items"`
+			} `json:"ports"`
+		} `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("decoding schema: %v", err)
+	}
+	if len(schema.Required) != 6 {
+		t.Errorf("envelope required: got %v", schema.Required)
+	}
+	item := schema.Properties.Ports.Items
+	for _, name := range item.Required {
+		if name == "start_time" || name == "address" {
+			t.Errorf("%s is omitted when empty and must not be required", name)
+		}
+	}
+	if _, ok := item.Properties["start_time"]; !ok {
+		t.Error("start_time missing from port properties")
+	}
+}
@@ -0,0 +1,269 @@
+// Package output renders port listings for the CLI in the formats selected
+// with --output.
+package output
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/AbdullahTarakji/portpilot/internal/columns"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// Format is an output format name as given to --output.
+type Format string
+
+// Supported output formats.
+const (
+	Table    Format = "table"
+	JSON     Format = "json"
+	JSONL    Format = "jsonl"
+	CSV      Format = "csv"
+	TSV      Format = "tsv"
+	YAML     Format = "yaml"
+	Markdown Format = "markdown"
+	Template Format = "template"
+)
+
+// Formats lists every supported format.
+var Formats = []Format{Table, JSON, JSONL, CSV, TSV, YAML, Markdown, Template}
+
+// ParseFormat validates a format name. "md" is accepted for markdown.
+func ParseFormat(s string) (Format, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "md" {
+		return Markdown, nil
+	}
+	for _, f := range Formats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return "", fmt.Errorf("unknown output format %q (available: %s)", s, strings.Join(names, ", "))
+}
+
+// Options controls how ports are written.
+type Options struct {
+	Format Format
+	// Meta is reported in the envelope of JSON and YAML output.
+	Meta Meta
+	// Template is a text/template executed once per port, with the
+	// scanner.PortInfo as data. Used by the template format.
+	Template string
+	// Columns and Env select and fill the columns of the tabular formats.
+	Columns []columns.Column
+	Env     columns.Env
+	// NoHeaders leaves out the header of table, csv, tsv and markdown output.
+	NoHeaders bool
+	// Width is the terminal width table output should fit by truncating the
+	// command column. Zero means unlimited.
+	Width int
+}
+
+// Write renders ports to w.
+func Write(w io.Writer, ports []scanner.PortInfo, opts Options) error {
+	if len(opts.Columns) == 0 {
+		opts.Columns = columns.Default()
+	}
+	if ports == nil {
+		ports = []scanner.PortInfo{}
+	}
+
+	switch opts.Format {
+	case Table, "":
+		return writeTable(w, ports, opts)
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(NewEnvelope(ports, opts.Meta))
+	case JSONL:
+		enc := json.NewEncoder(w)
+		for _, p := range ports {
+			if err := enc.Encode(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	case CSV, TSV:
+		return writeDelimited(w, ports, opts)
+	case YAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(NewEnvelope(ports, opts.Meta)); err != nil {
+			return err
+		}
+		return enc.Close()
+	case Markdown:
+		return writeMarkdown(w, ports, opts)
+	case Template:
+		return writeTemplate(w, ports, opts.Template)
+	}
+	return fmt.Errorf("unknown output format %q", opts.Format)
+}
+
+// rows returns the column values of each port.
+func rows(ports []scanner.PortInfo, opts Options) [][]string {
+	out := make([][]string, len(ports))
+	for i, p := range ports {
+		values := make([]string, len(opts.Columns))
+		for j, c := range opts.Columns {
+			values[j] = c.Value(p, opts.Env)
+		}
+		out[i] = values
+	}
+	return out
+}
+
+func writeTable(w io.Writer, ports []scanner.PortInfo, opts Options) error {
+	data := rows(ports, opts)
+	fitCommand(data, opts)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if !opts.NoHeaders {
+		titles := make([]string, len(opts.Columns))
+		rules := make([]string, len(opts.Columns))
+		for i, c := range opts.Columns {
+			titles[i] = strings.ToUpper(c.Title)
+			rules[i] = strings.Repeat("-", len(c.Title))
+		}
+		fmt.Fprintln(tw, strings.Join(titles, "\t"))
+		fmt.Fprintln(tw, strings.Join(rules, "\t"))
+	}
+	for _, values := range data {
+		fmt.Fprintln(tw, strings.Join(values, "\t"))
+	}
+	return tw.Flush()
+}
+
+// tableGap is the padding tabwriter puts between table columns.
+const tableGap = 2
+
+// minCommandWidth keeps a truncated command column readable.
+const minCommandWidth = 20
+
+// fitCommand truncates the command column so table rows fit in opts.Width.
+func fitCommand(data [][]string, opts Options) {
+	if opts.Width <= 0 {
+		return
+	}
+	cmdIdx := -1
+	widths := make([]int, len(opts.Columns))
+	for i, c := range opts.Columns {
+		if c.Key == "command" {
+			cmdIdx = i
+		}
+		widths[i] = len(c.Title)
+		for _, values := range data {
+			widths[i] = max(widths[i], len(values[i]))
+		}
+	}
+	if cmdIdx < 0 {
+		return
+	}
+
+	used := 0
+	for i, wd := range widths {
+		if i != cmdIdx {
+			used += wd + tableGap
+		}
+	}
+	avail := max(minCommandWidth, opts.Width-used)
+	for _, values := range data {
+		values[cmdIdx] = truncate(values[cmdIdx], avail)
+	}
+}
+
+func truncate(s string, maxLen int) string {
+	if len(s) <= maxLen {
+		return s
+	}
+	if maxLen <= 3 {
+		return s[:maxLen]
+	}
+	return s[:maxLen-3] + "..."
+}
+
+func writeDelimited(w io.Writer, ports []scanner.PortInfo, opts Options) error {
+	cw := csv.NewWriter(w)
+	if opts.Format == TSV {
+		cw.Comma = '\t'
+	}
+	if !opts.NoHeaders {
+		keys := make([]string, len(opts.Columns))
+		for i, c := range opts.Columns {
+			keys[i] = c.Key
+		}
+		if err := cw.Write(keys); err != nil {
+			return err
+		}
+	}
+	if err := cw.WriteAll(rows(ports, opts)); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func writeMarkdown(w io.Writer, ports []scanner.PortInfo, opts Options) error {
+	bw := bufio.NewWriter(w)
+	line := func(cells []string) {
+		bw.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	if !opts.NoHeaders {
+		titles := make([]string, len(opts.Columns))
+		rules := make([]string, len(opts.Columns))
+		for i, c := range opts.Columns {
+			titles[i] = c.Title
+			rules[i] = "---"
+		}
+		line(titles)
+		line(rules)
+	}
+	for _, values := range rows(ports, opts) {
+		for i, v := range values {
+			values[i] = strings.ReplaceAll(v, "|", `\|`)
+		}
+		line(values)
+	}
+	return bw.Flush()
+}
+
+func writeTemplate(w io.Writer, ports []scanner.PortInfo, text string) error {
+	if text == "" {
+		return fmt.Errorf("the template format needs --template")
+	}
+	tmpl, err := ParseTemplate(text)
+	if err != nil {
+		return err
+	}
+	for _, p := range ports {
+		if err := tmpl.Execute(w, p); err != nil {
+			return fmt.Errorf("executing template: %w", err)
+		}
+		if !strings.HasSuffix(text, "\n") {
+			fmt.Fprintln(w)
+		}
+	}
+	return nil
+}
+
+// ParseTemplate parses a --template value, so errors can be reported before
+// scanning.
+func ParseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("output").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("parsing template: %w", err)
+	}
+	return tmpl, nil
+}
@@ -0,0 +1,118 @@
+package output
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/AbdullahTarakji/portpilot/internal/columns"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+func testPorts() []scanner.PortInfo {
+	return []scanner.PortInfo{
+		{Port: 3000, Protocol: "TCP", PID: 100, ProcessName: "node", User: "mike", State: "LISTEN",
+			Command: "node server.js --port 3000 --watch --inspect=0.0.0.0:9229"},
+		{Port: 5432, Protocol: "TCP", PID: 200, ProcessName: "postgres", User: "pg", State: "LISTEN",
+			Command: "postgres -D /var/lib/postgres"},
+	}
+}
+
+func render(t *testing.T, ports []scanner.PortInfo, opts Options) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, ports, opts); err != nil {
+		t.Fatalf("Write(%s): %v", opts.Format, err)
+	}
+	return buf.String()
+}
+
+func TestParseFormat(t *testing.T) {
+	for _, name := range []string{"table", "JSON", "jsonl", "csv", "tsv", "yaml", "markdown", "md", "template"} {
+		if _, err := ParseFormat(name); err != nil {
+			t.Errorf("ParseFormat(%q): %v", name, err)
+		}
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestWriteFormats(t *testing.T) {
+	cols, _ := columns.Select([]string{"port", "proc", "user"})
+
+	tests := []struct {
+		format    Format
+		noHeaders bool
+		want      string
+	}{
+		{Table, false, "PORT  PROCESS   USER\n----  -------   ----\n3000  node      mike\n5432  postgres  pg\n"},
+		{Table, true, "3000  node      mike\n5432  postgres  pg\n"},
+		{CSV, false, "port,proc,user\n3000,node,mike\n5432,postgres,pg\n"},
+		{TSV, true, "3000\tnode\tmike\n5432\tpostgres\tpg\n"},
+		{Markdown, false, "| Port | Process | User |\n| --- | --- | --- |\n| 3000 | node | mike |\n| 5432 | postgres | pg |\n"},
+	}
+	for _, tt := range tests {
+		got := render(t, testPorts(), Options{Format: tt.format, Columns: cols, NoHeaders: tt.noHeaders})
+		if got != tt.want {
+			t.Errorf("%s (no headers %v):\ngot:\n%s\nwant:\n%s", tt.format, tt.noHeaders, got, tt.want)
+		}
+	}
+}
+
+func TestWriteJSONFormats(t *testing.T) {
+	got := render(t, testPorts(), Options{Format: JSONL})
+	lines := strings.Split(strings.TrimSpace(got), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], `{"port":3000,`) {
+		t.Errorf("jsonl: got %q", got)
+	}
+
+	got = render(t, testPorts()[:1], Options{Format: YAML})
+	if !strings.Contains(got, "schema_version: 1\n") || !strings.Contains(got, "    process_name: node\n") {
+		t.Errorf("yaml: got %q", got)
+	}
+}
+
+func TestWriteTemplate(t *testing.T) {
+	got := render(t, testPorts(), Options{Format: Template, Template: "{{.Port}} {{.ProcessName}}"})
+	if got != "3000 node\n5432 postgres\n" {
+		t.Errorf("template: got %q", got)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, testPorts(), Options{Format: Template}); err == nil {
+		t.Error("expected error for template format without a template")
+	}
+	if _, err := ParseTemplate("{{.Port"); err == nil {
+		t.Error("expected error for malformed template")
+	}
+}
+
+func TestMarkdownEscapesPipes(t *testing.T) {
+	cols, _ := columns.Select([]string{"command"})
+	ports := []scanner.PortInfo{{Command: "sh -c 'a | b'"}}
+	got := render(t, ports, Options{Format: Markdown, Columns: cols, NoHeaders: true})
+	if got != "| sh -c 'a \\| b' |\n" {
+		t.Errorf("markdown: got %q", got)
+	}
+}
+
+func TestTableTruncatesCommandToWidth(t *testing.T) {
+	cols, _ := columns.Select([]string{"port", "proc", "command"})
+	opts := Options{Format: Table, Columns: cols, Width: 50}
+
+	got := render(t, testPorts(), opts)
+	for _, line := range strings.Split(strings.TrimRight(got, "\n"), "\n") {
+		if len(line) > 50 {
+			t.Errorf("line exceeds width 50 (%d): %q", len(line), line)
+		}
+	}
+	if !strings.Contains(got, "...") {
+		t.Error("long command should be truncated with an ellipsis")
+	}
+
+	opts.Width = 0
+	if got := render(t, testPorts(), opts); !strings.Contains(got, "--inspect=0.0.0.0:9229") {
+		t.Error("without a width the command should not be truncated")
+	}
+}
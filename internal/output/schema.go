@@ -0,0 +1,75 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaID is the $id of the published schema document.
+const schemaID = "https://github.com/AbdullahTarakji/portpilot/schema/portpilot.schema.json"
+
+// JSONSchema returns a JSON Schema document for the output envelope,
+// generated from the Go types so it can't drift from what is printed.
+func JSONSchema() ([]byte, error) {
+	schema := typeSchema(reflect.TypeOf(Envelope{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["$id"] = schemaID
+	schema["title"] = "portpilot port listing"
+	schema["description"] = fmt.Sprintf("Output of portpilot --output json, schema version %d.", SchemaVersion)
+
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func typeSchema(t reflect.Type) map[string]any {
+	switch {
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		return structSchema(t)
+	case t.Kind() == reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	panic(fmt.Sprintf("output: no JSON Schema for %s", t))
+}
+
+func structSchema(t reflect.Type) map[string]any {
+	props := make(map[string]any)
+	required := []string{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = typeSchema(f.Type)
+		if !strings.Contains(opts, "omitempty") && !strings.Contains(opts, "omitzero") {
+			required = append(required, name)
+		}
+	}
+	return map[string]any{
+		"type":       "object",
+		"properties": props,
+		"required":   required,
+	}
+}
@@ -0,0 +1,80 @@
+// Package probe checks whether listening ports actually accept connections.
+package probe
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the dial timeout used when none is given.
+const DefaultTimeout = 500 * time.Millisecond
+
+// Result holds the outcome of probing a single port.
+type Result struct {
+	Port     int           `json:"port"`
+	Protocol string        `json:"protocol"`
+	OK       bool          `json:"ok"`
+	Skipped  bool          `json:"skipped"`
+	Latency  time.Duration `json:"latency"`
+	Err      error         `json:"-"`
+}
+
+// TCP dials the port on the loopback interface and reports how long the
+// connection took to establish.
+func TCP(port int, timeout time.Duration) Result {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	r := Result{Port: port, Protocol: "TCP"}
+	start := time.Now()
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)), timeout)
+	r.Latency = time.Since(start)
+	if err != nil {
+		r.Err = fmt.Errorf("dialing port %d: %w", port, err)
+		return r
+	}
+	_ = conn.Close()
+	r.OK = true
+	return r
+}
+
+// Port probes a port using the given protocol. UDP listeners cannot be
+// probed without speaking their protocol, so they are reported as skipped.
+func Port(port int, protocol string, timeout time.Duration) Result {
+	if strings.EqualFold(protocol, "UDP") {
+		return Result{Port: port, Protocol: "UDP", Skipped: true}
+	}
+	return TCP(port, timeout)
+}
+
+// Status describes the result in a word: "up", "down", or "n/a" when the
+// port was skipped.
+func (r Result) Status() string {
+	switch {
+	case r.Skipped:
+		return "n/a"
+	case r.OK:
+		return "up"
+	default:
+		return "down"
+	}
+}
+
+// Summary counts reachable, failed and skipped results.
+func Summary(results []Result) (ok, failed, skipped int) {
+	for _, r := range results {
+		switch {
+		case r.Skipped:
+			skipped++
+		case r.OK:
+			ok++
+		default:
+			failed++
+		}
+	}
+	return ok, failed, skipped
+}
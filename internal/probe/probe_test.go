@@ -0,0 +1,80 @@
+package probe
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func listen(t *testing.T) (int, func()) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port, func() { _ = ln.Close() }
+}
+
+func TestTCPReachable(t *testing.T) {
+	port, stop := listen(t)
+	defer stop()
+
+	r := TCP(port, time.Second)
+	if !r.OK {
+		t.Fatalf("expected port %d to be reachable: %v", port, r.Err)
+	}
+}
+
+func TestTCPRefused(t *testing.T) {
+	port, stop := listen(t)
+	stop()
+
+	r := TCP(port, time.Second)
+	if r.OK {
+		t.Fatal("expected closed port to be unreachable")
+	}
+	if r.Err == nil {
+		t.Error("expected error for closed port")
+	}
+}
+
+func TestPortSkipsUDP(t *testing.T) {
+	r := Port(5353, "udp", time.Second)
+	if !r.Skipped {
+		t.Error("expected UDP probe to be skipped")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	ok, failed, skipped := Summary([]Result{
+		{OK: true},
+		{OK: true},
+		{Skipped: true},
+		{},
+	})
+	if ok != 2 || failed != 1 || skipped != 1 {
+		t.Errorf("Summary: got %d/%d/%d, want 2/1/1", ok, failed, skipped)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	tests := map[string]Result{
+		"up":   {OK: true},
+		"down": {},
+		"n/a":  {Skipped: true},
+	}
+	for want, r := range tests {
+		if got := r.Status(); got != want {
+			t.Errorf("Status(%+v): got %q, want %q", r, got, want)
+		}
+	}
+}
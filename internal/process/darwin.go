@@ -0,0 +1,141 @@
+package process
+
+import (
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// inspectDarwin fills in the sockets and descriptors of a process from
+// lsof, and its environment from ps.
+func inspectDarwin(sys scanner.System, d *Details) {
+	lsofPath := "lsof"
+	if _, err := sys.LookPath("lsof"); err != nil {
+		lsofPath = "/usr/sbin/lsof"
+	}
+	pid := strconv.Itoa(d.PID)
+
+	// -T qs adds the queue lengths, which lsof reports for TCP on macOS.
+	if out, err := sys.Run(lsofPath, "-a", "-p", pid, "-i", "-nP", "-T", "qs", "-F", "fPnT"); err == nil || len(out) > 0 {
+		d.Sockets = parseLsofSockets(string(out))
+	}
+	if out, err := sys.Run(lsofPath, "-p", pid, "-F", "f"); err == nil || len(out) > 0 {
+		d.OpenFDs = countLsofFDs(string(out))
+	}
+	// macOS has no way to read another process's limits.
+	if sys == scanner.Local && runtime.GOOS == "darwin" && d.PID == os.Getpid() {
+		var rl unix.Rlimit
+		if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rl); err == nil && rl.Cur != unix.RLIM_INFINITY {
+			d.FDLimit = rl.Cur
+		}
+	}
+	if out, err := sys.Run("ps", "-wwE", "-p", pid, "-o", "command="); err == nil {
+		d.Env = parsePsEnv(string(out), d.Command)
+	}
+}
+
+// parseLsofSockets parses the field output of
+// `lsof -a -p PID -i -nP -T qs -F fPnT`, one field per line:
+// p812
+// f6
+// PTCP
+// n*:5432
+// TST=LISTEN
+// TQR=0
+// TQS=0
+func parseLsofSockets(output string) []Socket {
+	var sockets []Socket
+	var cur *Socket
+	for _, line := range strings.Split(output, "\n") {
+		if line == "" {
+			continue
+		}
+		value := line[1:]
+		switch line[0] {
+		case 'f':
+			sockets = append(sockets, Socket{})
+			cur = &sockets[len(sockets)-1]
+		case 'P':
+			if cur != nil {
+				cur.Protocol = value
+			}
+		case 'n':
+			if cur != nil {
+				cur.Local, cur.Peer, _ = strings.Cut(value, "->")
+			}
+		case 'T':
+			if cur == nil {
+				continue
+			}
+			key, v, _ := strings.Cut(value, "=")
+			switch key {
+			case "ST":
+				cur.State = v
+			case "QR":
+				cur.RecvQ, _ = strconv.Atoi(v)
+			case "QS":
+				cur.SendQ, _ = strconv.Atoi(v)
+			}
+		}
+	}
+	for i := range sockets {
+		// lsof gives UDP sockets no state; match what ss calls them.
+		if sockets[i].State == "" && sockets[i].Peer == "" {
+			sockets[i].State = "UNCONN"
+		}
+	}
+	return sockets
+}
+
+// countLsofFDs counts the numbered descriptors in `lsof -p PID -F f`,
+// leaving out entries such as cwd, txt and mapped files.
+func countLsofFDs(output string) int {
+	n := 0
+	for _, line := range strings.Split(output, "\n") {
+		if fd, ok := strings.CutPrefix(line, "f"); ok {
+			if _, err := strconv.Atoi(fd); err == nil {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// parsePsEnv extracts the environment from `ps -wwE -o command=`, which
+// prints it after the command line. Values containing spaces can't be told
+// apart from the next entry and are cut short.
+func parsePsEnv(output, command string) []string {
+	rest, ok := strings.CutPrefix(strings.TrimSpace(output), command)
+	if !ok {
+		return nil
+	}
+	var env []string
+	for _, f := range strings.Fields(rest) {
+		if key, _, ok := strings.Cut(f, "="); ok && isEnvName(key) {
+			env = append(env, f)
+		}
+	}
+	sort.Strings(env)
+	return env
+}
+
+func isEnvName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
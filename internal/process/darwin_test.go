@@ -0,0 +1,54 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLsofSockets(t *testing.T) {
+	input := `p812
+f6
+PTCP
+n*:5432
+TST=LISTEN
+TQR=2
+TQS=128
+f9
+PTCP
+n127.0.0.1:5432->127.0.0.1:51234
+TST=ESTABLISHED
+TQR=0
+TQS=0
+f11
+PUDP
+n*:5353
+`
+	got := parseLsofSockets(input)
+	want := []Socket{
+		{Protocol: "TCP", State: "LISTEN", Local: "*:5432", RecvQ: 2, SendQ: 128},
+		{Protocol: "TCP", State: "ESTABLISHED", Local: "127.0.0.1:5432", Peer: "127.0.0.1:51234"},
+		{Protocol: "UDP", State: "UNCONN", Local: "*:5353"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\n got %+v\nwant %+v", got, want)
+	}
+}
+
+func TestCountLsofFDs(t *testing.T) {
+	input := "p812\nfcwd\nftxt\nf0\nf1\nf2\nf6\n"
+	if got := countLsofFDs(input); got != 4 {
+		t.Errorf("got %d, want 4", got)
+	}
+}
+
+func TestParsePsEnv(t *testing.T) {
+	output := "node server.js --port 3000 PORT=3000 HOST=127.0.0.1 TERM=xterm-256color\n"
+	got := parsePsEnv(output, "node server.js --port 3000")
+	want := []string{"HOST=127.0.0.1", "PORT=3000", "TERM=xterm-256color"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := parsePsEnv("other", "node"); got != nil {
+		t.Errorf("mismatched command: got %v", got)
+	}
+}
@@ -0,0 +1,115 @@
+package process
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// inspectLinux fills in the sockets of a process from ss, and its
+// descriptors and environment from /proc.
+func inspectLinux(sys scanner.System, d *Details) {
+	// -a includes connections, -e and -o the extended socket details, and
+	// -O keeps each socket on one line.
+	if out, err := sys.Run("ss", "-tuanpeoO"); err == nil || len(out) > 0 {
+		d.Sockets = parseSSSockets(string(out), d.PID)
+	}
+	if fds, err := sys.ReadDir(fmt.Sprintf("/proc/%d/fd", d.PID)); err == nil {
+		d.OpenFDs = len(fds)
+	}
+	if data, err := sys.ReadFile(fmt.Sprintf("/proc/%d/limits", d.PID)); err == nil {
+		d.FDLimit = parseFDLimit(string(data))
+	}
+	if data, err := sys.ReadFile(fmt.Sprintf("/proc/%d/environ", d.PID)); err == nil {
+		d.Env = parseEnviron(data)
+	}
+}
+
+// parseSSSockets returns the sockets of pid in the output of
+// `ss -tuanpeoO`.
+// Example line:
+// tcp LISTEN 0 511 0.0.0.0:80 0.0.0.0:* users:(("nginx",pid=812,fd=6),("nginx",pid=813,fd=6)) ino:20411 sk:3 cgroup:/system.slice/nginx.service <->
+func parseSSSockets(output string, pid int) []Socket {
+	owner := fmt.Sprintf("pid=%d,", pid)
+	var sockets []Socket
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 6 {
+			continue
+		}
+		proto := strings.ToUpper(fields[0])
+		if proto != "TCP" && proto != "UDP" {
+			continue
+		}
+
+		var owned bool
+		var options []string
+		for _, f := range fields[6:] {
+			switch {
+			case strings.HasPrefix(f, "users:"):
+				owned = strings.Contains(f, owner)
+			case strings.HasPrefix(f, "sk:"), strings.HasPrefix(f, "cgroup:"), strings.HasPrefix(f, "uid:"):
+				// Kernel bookkeeping rather than anything set on the socket.
+			case strings.HasPrefix(f, "<") || strings.HasPrefix(f, "-"):
+				// The shutdown state, e.g. <->.
+			default:
+				options = append(options, f)
+			}
+		}
+		if !owned {
+			continue
+		}
+
+		recvQ, _ := strconv.Atoi(fields[2])
+		sendQ, _ := strconv.Atoi(fields[3])
+		s := Socket{
+			Protocol: proto,
+			State:    fields[1],
+			Local:    fields[4],
+			RecvQ:    recvQ,
+			SendQ:    sendQ,
+			Options:  options,
+		}
+		if peer := fields[5]; !strings.HasSuffix(peer, ":*") {
+			s.Peer = peer
+		}
+		sockets = append(sockets, s)
+	}
+	return sockets
+}
+
+// parseFDLimit returns the soft "Max open files" limit of
+// /proc/<pid>/limits, or zero if it is unlimited or missing.
+// Example line:
+// Max open files            1024                 524288               files
+func parseFDLimit(limits string) uint64 {
+	for _, line := range strings.Split(limits, "\n") {
+		rest, ok := strings.CutPrefix(line, "Max open files")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return 0
+		}
+		n, _ := strconv.ParseUint(fields[0], 10, 64)
+		return n
+	}
+	return 0
+}
+
+// parseEnviron splits the NUL-separated contents of /proc/<pid>/environ
+// into sorted KEY=VALUE entries.
+func parseEnviron(data []byte) []string {
+	var env []string
+	for _, kv := range strings.Split(string(data), "\x00") {
+		if strings.Contains(kv, "=") {
+			env = append(env, kv)
+		}
+	}
+	sort.Strings(env)
+	return env
+}
@@ -0,0 +1,76 @@
+package process
+
+import (
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+func TestParseSSSockets(t *testing.T) {
+	input := `Netid State  Recv-Q Send-Q Local Address:Port Peer Address:Port Process
+tcp   LISTEN 3      511          0.0.0.0:80        0.0.0.0:*    users:(("nginx",pid=812,fd=6),("nginx",pid=813,fd=6)) ino:20411 sk:3 cgroup:/system.slice/nginx.service <->
+tcp   LISTEN 0      511             [::]:80           [::]:*    users:(("nginx",pid=812,fd=7)) ino:20412 sk:4 cgroup:/system.slice/nginx.service v6only:1 <->
+tcp   ESTAB  0      36         10.0.0.5:80     10.0.0.9:51234 users:(("nginx",pid=813,fd=12)) timer:(on,200ms,0) uid:33 ino:30100 sk:9 <->
+udp   UNCONN 0      0          127.0.0.1:8125      0.0.0.0:*    users:(("nginx",pid=812,fd=9)) ino:20500 sk:5 <->
+tcp   LISTEN 0      128          0.0.0.0:22        0.0.0.0:*    users:(("sshd",pid=8120,fd=3)) ino:1100 sk:1 <->
+`
+	got := parseSSSockets(input, 812)
+	want := []Socket{
+		{Protocol: "TCP", State: "LISTEN", Local: "0.0.0.0:80", RecvQ: 3, SendQ: 511, Options: []string{"ino:20411"}},
+		{Protocol: "TCP", State: "LISTEN", Local: "[::]:80", SendQ: 511, Options: []string{"ino:20412", "v6only:1"}},
+		{Protocol: "UDP", State: "UNCONN", Local: "127.0.0.1:8125", Options: []string{"ino:20500"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("pid 812:\n got %+v\nwant %+v", got, want)
+	}
+
+	got = parseSSSockets(input, 813)
+	if len(got) != 2 || got[1].Peer != "10.0.0.9:51234" || got[1].SendQ != 36 {
+		t.Errorf("pid 813: got %+v", got)
+	}
+	if !got[0].Listening() || got[1].Listening() {
+		t.Error("Listening() should hold for LISTEN only")
+	}
+}
+
+func TestParseFDLimit(t *testing.T) {
+	limits := `Limit                     Soft Limit           Hard Limit           Units
+Max cpu time              unlimited            unlimited            seconds
+Max open files            1024                 524288               files
+Max locked memory         8388608              8388608              bytes
+`
+	if got := parseFDLimit(limits); got != 1024 {
+		t.Errorf("got %d, want 1024", got)
+	}
+	if got := parseFDLimit("Max open files  unlimited  unlimited  files\n"); got != 0 {
+		t.Errorf("unlimited: got %d, want 0", got)
+	}
+}
+
+func TestParseEnviron(t *testing.T) {
+	got := parseEnviron([]byte("PORT=3000\x00HOST=0.0.0.0\x00EMPTY=\x00junk\x00"))
+	want := []string{"EMPTY=", "HOST=0.0.0.0", "PORT=3000"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestGetDetailsInspectsSelf(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("reads /proc")
+	}
+	d, err := GetDetails(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.OpenFDs == 0 {
+		t.Error("expected open descriptors")
+	}
+	if d.FDLimit == 0 {
+		t.Error("expected a descriptor limit")
+	}
+	if len(d.Env) == 0 {
+		t.Error("expected an environment")
+	}
+}
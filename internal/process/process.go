@@ -2,13 +2,20 @@
 package process
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"os"
-	"os/exec"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
 	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
 )
 
 // Details holds extended information about a running process.
@@ -22,6 +29,33 @@ type Details struct {
 	StartTime  time.Time `json:"start_time"`
 	ParentPID  int       `json:"parent_pid"`
 	NumThreads int       `json:"num_threads"`
+
+	// Sockets, descriptors and environment are read on a best-effort basis:
+	// the system hides them for processes of other users unless running as
+	// root.
+	Sockets []Socket `json:"sockets,omitempty"`
+	OpenFDs int      `json:"open_fds,omitempty"`
+	FDLimit uint64   `json:"fd_limit,omitempty"` // soft RLIMIT_NOFILE; zero if unknown
+	Env     []string `json:"env,omitempty"`      // KEY=VALUE, sorted
+}
+
+// Socket is a TCP or UDP socket held by a process. For a listening TCP
+// socket, RecvQ is the number of connections waiting to be accepted and
+// SendQ the listen backlog.
+type Socket struct {
+	Protocol string   `json:"protocol"`
+	State    string   `json:"state"`
+	Local    string   `json:"local"`
+	Peer     string   `json:"peer,omitempty"`
+	RecvQ    int      `json:"recv_q"`
+	SendQ    int      `json:"send_q"`
+	Options  []string `json:"options,omitempty"`
+}
+
+// Listening reports whether the socket accepts connections or, for UDP,
+// datagrams from anyone.
+func (s Socket) Listening() bool {
+	return s.State == "LISTEN" || s.State == "UNCONN"
 }
 
 // Kill sends the specified signal to a process.
@@ -38,24 +72,186 @@ func Kill(pid int, signal os.Signal) error {
 	return nil
 }
 
-// KillByPort finds and kills the process listening on the given port.
-// Returns the PID that was killed.
-func KillByPort(port int, signal os.Signal) (int, error) {
-	pid, err := findPIDByPort(port)
+// Terminate sends signal to a process and, if it is still running once
+// grace has passed, kills it with SIGKILL. It reports whether SIGKILL was
+// needed. A zero grace only sends the signal.
+func Terminate(pid int, signal os.Signal, grace time.Duration) (bool, error) {
+	if err := Kill(pid, signal); err != nil {
+		return false, err
+	}
+	if grace <= 0 || signal == syscall.SIGKILL {
+		return false, nil
+	}
+
+	deadline := time.Now().Add(grace)
+	for time.Now().Before(deadline) {
+		if !IsRunning(pid) {
+			return false, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if !IsRunning(pid) {
+		return false, nil
+	}
+	return true, Kill(pid, syscall.SIGKILL)
+}
+
+// ErrPortFree is returned when no process listens on a port.
+var ErrPortFree = errors.New("no process found")
+
+// ErrPermission is returned when the processes on a port are hidden from
+// this user or can't be signalled by it. It is scanner.ErrPermission and
+// os.ErrPermission, so the errors of signalling another user's process
+// match it too.
+var ErrPermission = scanner.ErrPermission
+
+// PortFilter narrows the listeners on a port to one protocol or bind
+// address. Empty fields match anything.
+type PortFilter struct {
+	Protocol string // "tcp" or "udp", in any case
+	// Address is a bind address such as 127.0.0.1 or ::1. Listeners on all
+	// addresses hold the port on every one of them, so they always match,
+	// and an address of 0.0.0.0, :: or * matches every listener.
+	Address string
+}
+
+func (f PortFilter) String() string {
+	var parts []string
+	if f.Protocol != "" {
+		parts = append(parts, strings.ToUpper(f.Protocol))
+	}
+	if f.Address != "" {
+		parts = append(parts, "on "+f.Address)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f PortFilter) match(p scanner.PortInfo) bool {
+	if f.Protocol != "" && !strings.EqualFold(f.Protocol, p.Protocol) {
+		return false
+	}
+	if f.Address == "" || anyAddress(f.Address) || anyAddress(p.Address) {
+		return true
+	}
+	if a, b := net.ParseIP(f.Address), net.ParseIP(p.Address); a != nil && b != nil {
+		return a.Equal(b)
+	}
+	return f.Address == p.Address
+}
+
+// anyAddress reports whether addr stands for all addresses, the way ss and
+// lsof show a wildcard bind.
+func anyAddress(addr string) bool {
+	if addr == "" || addr == "*" {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	return ip != nil && ip.IsUnspecified()
+}
+
+// OnPort returns the listeners in ports on port that match f, one for each
+// process and socket: a port held by both IPv4 and IPv6 sockets, by several
+// SO_REUSEPORT listeners or by forked workers sharing a socket lists every
+// process. It fails with ErrPortFree if there are none and with
+// ErrPermission if any of them has no known owner, which is how ss and lsof
+// show the sockets of other users' processes.
+func OnPort(ports []scanner.PortInfo, port int, f PortFilter) ([]scanner.PortInfo, error) {
+	if f.Protocol != "" && !strings.EqualFold(f.Protocol, "tcp") && !strings.EqualFold(f.Protocol, "udp") {
+		return nil, fmt.Errorf("unknown protocol %q (available: tcp, udp)", f.Protocol)
+	}
+
+	var found []scanner.PortInfo
+	hidden := 0
+	for _, p := range ports {
+		if p.Port != port || !f.match(p) {
+			continue
+		}
+		if p.PID <= 0 {
+			hidden++
+			continue
+		}
+		found = append(found, p)
+	}
+	sort.SliceStable(found, func(i, j int) bool { return found[i].PID < found[j].PID })
+
+	switch {
+	case len(found) == 0 && hidden == 0:
+		if f != (PortFilter{}) {
+			return nil, fmt.Errorf("%w on port %d (%s)", ErrPortFree, port, f)
+		}
+		return nil, fmt.Errorf("%w on port %d", ErrPortFree, port)
+	case len(found) == 0:
+		return nil, fmt.Errorf("the process on port %d is hidden from this user: %w", port, ErrPermission)
+	case hidden > 0:
+		return found, fmt.Errorf("%d of the sockets on port %d belong to processes hidden from this user: %w", hidden, port, ErrPermission)
+	}
+	return found, nil
+}
+
+// FindByPort scans with s and returns the listeners on port that match f,
+// as OnPort does.
+func FindByPort(s scanner.Scanner, port int, f PortFilter) ([]scanner.PortInfo, error) {
+	ports, err := s.Scan()
 	if err != nil {
-		return 0, err
+		return nil, fmt.Errorf("scanning: %w", err)
 	}
+	return OnPort(ports, port, f)
+}
 
-	if err := Kill(pid, signal); err != nil {
-		return pid, err
+// PIDs returns the processes of ports, each once, in order.
+func PIDs(ports []scanner.PortInfo) []int {
+	var pids []int
+	seen := make(map[int]bool)
+	for _, p := range ports {
+		if p.PID > 0 && !seen[p.PID] {
+			seen[p.PID] = true
+			pids = append(pids, p.PID)
+		}
+	}
+	return pids
+}
+
+// KillByPort sends signal to every process listening on port that matches
+// f, found with s. Nothing is signalled when some of them are hidden from
+// this user, since the port wouldn't be freed. It returns the PIDs that
+// were signalled.
+func KillByPort(s scanner.Scanner, port int, f PortFilter, signal os.Signal) ([]int, error) {
+	ports, err := FindByPort(s, port, f)
+	if err != nil {
+		return nil, err
 	}
 
-	return pid, nil
+	var killed []int
+	var errs []error
+	for _, pid := range PIDs(ports) {
+		if err := Kill(pid, signal); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		killed = append(killed, pid)
+	}
+	return killed, errors.Join(errs...)
 }
 
 // GetDetails returns detailed information about a process.
 func GetDetails(pid int) (*Details, error) {
-	out, err := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "pid,ppid,%cpu,%mem,user,lstart,command").Output()
+	return GetDetailsWith(scanner.Local, runtime.GOOS, pid)
+}
+
+// GetDetailsWith returns detailed information about a process on sys, a
+// machine running goos, "linux" or "darwin".
+func GetDetailsWith(sys scanner.System, goos string, pid int) (*Details, error) {
+	var inspect func(scanner.System, *Details)
+	switch goos {
+	case "linux":
+		inspect = inspectLinux
+	case "darwin":
+		inspect = inspectDarwin
+	default:
+		return nil, fmt.Errorf("unsupported OS %q (available: linux, darwin)", goos)
+	}
+
+	out, err := sys.Run("ps", "-p", strconv.Itoa(pid), "-o", "pid,ppid,%cpu,%mem,user,lstart,command")
 	if err != nil {
 		return nil, fmt.Errorf("getting details for pid %d: %w", pid, err)
 	}
@@ -65,7 +261,12 @@ func GetDetails(pid int) (*Details, error) {
 		return nil, fmt.Errorf("process %d not found", pid)
 	}
 
-	return parseDetails(lines[1])
+	d, err := parseDetails(lines[1])
+	if err != nil {
+		return nil, err
+	}
+	inspect(sys, d)
+	return d, nil
 }
 
 func parseDetails(line string) (*Details, error) {
@@ -139,22 +340,52 @@ func IsRunning(pid int) bool {
 	return err == nil
 }
 
-func findPIDByPort(port int) (int, error) {
-	out, err := exec.Command("lsof", "-iTCP:"+strconv.Itoa(port), "-iUDP:"+strconv.Itoa(port), "-nP", "-sTCP:LISTEN", "-t").Output()
-	if err != nil {
-		return 0, fmt.Errorf("no process found on port %d: %w", port, err)
+// Signal pairs a signal with its conventional name.
+type Signal struct {
+	Name string
+	Num  syscall.Signal
+}
+
+// maxSignal bounds the search for named signals; realtime signals above the
+// classic set have no names and are left out.
+const maxSignal = 64
+
+// Signals returns every named signal on this platform, ordered by number.
+func Signals() []Signal {
+	var sigs []Signal
+	for i := 1; i <= maxSignal; i++ {
+		sig := syscall.Signal(i)
+		if name := unix.SignalName(sig); name != "" {
+			sigs = append(sigs, Signal{Name: name, Num: sig})
+		}
 	}
+	return sigs
+}
 
-	pidStr := strings.TrimSpace(strings.Split(string(out), "\n")[0])
-	pid, err := strconv.Atoi(pidStr)
-	if err != nil {
-		return 0, fmt.Errorf("parsing pid for port %d: %w", port, err)
+// SignalName returns the conventional name of a signal, such as "SIGTERM",
+// falling back to its description for signals without one.
+func SignalName(sig os.Signal) string {
+	if s, ok := sig.(syscall.Signal); ok {
+		if name := unix.SignalName(s); name != "" {
+			return name
+		}
 	}
+	return sig.String()
+}
+
+// Suspend stops a process with SIGSTOP.
+func Suspend(pid int) error {
+	return Kill(pid, syscall.SIGSTOP)
+}
 
-	return pid, nil
+// Resume continues a stopped process with SIGCONT.
+func Resume(pid int) error {
+	return Kill(pid, syscall.SIGCONT)
 }
 
 // ParseSignal converts a signal name or number string to an os.Signal.
+// Besides the common aliases it accepts any signal name the platform knows,
+// with or without the SIG prefix.
 func ParseSignal(s string) (os.Signal, error) {
 	signals := map[string]syscall.Signal{
 		"SIGTERM": syscall.SIGTERM,
@@ -176,6 +407,10 @@ func ParseSignal(s string) (os.Signal, error) {
 		return sig, nil
 	}
 
+	if sig := unix.SignalNum("SIG" + strings.TrimPrefix(upper, "SIG")); sig != 0 {
+		return sig, nil
+	}
+
 	// Try parsing as a number
 	num, err := strconv.Atoi(s)
 	if err == nil {
@@ -1,9 +1,17 @@
 package process
 
 import (
+	"errors"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
 	"syscall"
 	"testing"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
 )
 
 func TestKillInvalidPID(t *testing.T) {
@@ -40,6 +48,45 @@ func TestGetDetailsSelf(t *testing.T) {
 	}
 }
 
+// TestGetDetailsReplay reads process details from the scans recorded in
+// the scanner's testdata.
+func TestGetDetailsReplay(t *testing.T) {
+	tests := []struct {
+		recording string
+		pid       int
+		want      Details
+	}{
+		{"linux.json", 10296, Details{PID: 10296, ParentPID: 10294, Name: "shop-web", User: "root", OpenFDs: 9, FDLimit: 20000}},
+		{"darwin.json", 4821, Details{PID: 4821, ParentPID: 4790, Name: "node", User: "mike", OpenFDs: 11}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.recording, func(t *testing.T) {
+			rec, err := scanner.LoadRecording(filepath.Join("..", "scanner", "testdata", "replay", tt.recording))
+			if err != nil {
+				t.Fatal(err)
+			}
+			replay := scanner.NewReplay(rec)
+			d, err := GetDetailsWith(replay, rec.OS, tt.pid)
+			if err != nil {
+				t.Fatalf("GetDetailsWith: %v", err)
+			}
+			if missed := replay.Missed(); len(missed) > 0 {
+				t.Errorf("commands not in the recording: %q", missed)
+			}
+			got := Details{PID: d.PID, ParentPID: d.ParentPID, Name: d.Name, User: d.User, OpenFDs: d.OpenFDs, FDLimit: d.FDLimit}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if len(d.Sockets) == 0 || !d.Sockets[0].Listening() {
+				t.Errorf("sockets: got %+v", d.Sockets)
+			}
+			if !slices.Contains(d.Env, "PORT=3000") && !slices.Contains(d.Env, "PORT=8080") {
+				t.Errorf("env: got %q", d.Env)
+			}
+		})
+	}
+}
+
 func TestIsRunning(t *testing.T) {
 	if !IsRunning(os.Getpid()) {
 		t.Error("current process should be running")
@@ -49,6 +96,149 @@ func TestIsRunning(t *testing.T) {
 	}
 }
 
+// startChild starts a shell script and reaps it in the background, so it
+// stops counting as running once it exits.
+func startChild(t *testing.T, script string) int {
+	t.Helper()
+	cmd := exec.Command("sh", "-c", script)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting child: %v", err)
+	}
+	go func() { _ = cmd.Wait() }()
+	t.Cleanup(func() { _ = cmd.Process.Kill() })
+	// Give the shell time to install its traps.
+	time.Sleep(200 * time.Millisecond)
+	return cmd.Process.Pid
+}
+
+func TestTerminate(t *testing.T) {
+	pid := startChild(t, "sleep 30")
+	forced, err := Terminate(pid, syscall.SIGTERM, 2*time.Second)
+	if err != nil || forced {
+		t.Errorf("got forced=%v err=%v, want a clean exit", forced, err)
+	}
+}
+
+func TestTerminateForcesAfterGrace(t *testing.T) {
+	pid := startChild(t, `trap "" TERM; while :; do sleep 0.1; done`)
+	forced, err := Terminate(pid, syscall.SIGTERM, 300*time.Millisecond)
+	if err != nil || !forced {
+		t.Errorf("got forced=%v err=%v, want SIGKILL after the grace period", forced, err)
+	}
+}
+
+func TestSuspendResume(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("starting child: %v", err)
+	}
+	pid := cmd.Process.Pid
+	t.Cleanup(func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	})
+
+	var ws syscall.WaitStatus
+	if err := Suspend(pid); err != nil {
+		t.Fatalf("Suspend: %v", err)
+	}
+	if _, err := syscall.Wait4(pid, &ws, syscall.WUNTRACED, nil); err != nil || !ws.Stopped() {
+		t.Fatalf("after Suspend got status %v err=%v, want stopped", ws, err)
+	}
+	if err := Resume(pid); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	if _, err := syscall.Wait4(pid, &ws, syscall.WCONTINUED, nil); err != nil || !ws.Continued() {
+		t.Fatalf("after Resume got status %v err=%v, want continued", ws, err)
+	}
+}
+
+// scannedPorts is a scanner returning fixed ports.
+type scannedPorts []scanner.PortInfo
+
+func (s scannedPorts) Scan() ([]scanner.PortInfo, error) { return s, nil }
+
+func TestOnPort(t *testing.T) {
+	ports := []scanner.PortInfo{
+		{Port: 80, Protocol: "TCP", Address: "0.0.0.0", PID: 813, ProcessName: "nginx"},
+		{Port: 80, Protocol: "TCP", Address: "0.0.0.0", PID: 812, ProcessName: "nginx"},
+		{Port: 80, Protocol: "TCP", Address: "::", PID: 900, ProcessName: "envoy"},
+		{Port: 53, Protocol: "UDP", Address: "127.0.0.53", PID: 700, ProcessName: "systemd-resolve"},
+		{Port: 53, Protocol: "TCP", Address: "127.0.0.53", PID: 700, ProcessName: "systemd-resolve"},
+		{Port: 53, Protocol: "UDP", Address: "10.0.0.5", PID: 710, ProcessName: "dnsmasq"},
+		{Port: 8443, Protocol: "TCP", Address: "10.0.0.5", PID: 4000, ProcessName: "api"},
+		{Port: 8443, Protocol: "TCP", Address: "192.168.1.5", PID: 4000, ProcessName: "api"},
+		{Port: 5432, Protocol: "TCP", Address: "127.0.0.1", ProcessName: ""},
+		{Port: 6379, Protocol: "TCP", Address: "127.0.0.1", PID: 2882, ProcessName: "redis-server"},
+		{Port: 6379, Protocol: "TCP", Address: "::1"},
+	}
+
+	tests := []struct {
+		name   string
+		port   int
+		filter PortFilter
+		pids   []int
+		err    error
+	}{
+		{"workers and dual-stack", 80, PortFilter{}, []int{812, 813, 900}, nil},
+		{"protocol", 53, PortFilter{Protocol: "udp"}, []int{700, 710}, nil},
+		{"address", 53, PortFilter{Address: "10.0.0.5"}, []int{710}, nil},
+		{"wildcard listener holds every address", 80, PortFilter{Address: "127.0.0.1"}, []int{812, 813, 900}, nil},
+		{"wildcard filter matches everything", 53, PortFilter{Address: "::"}, []int{700, 710}, nil},
+		{"second address of a process", 8443, PortFilter{Address: "192.168.1.5"}, []int{4000}, nil},
+		{"free", 8080, PortFilter{}, nil, ErrPortFree},
+		{"free for the filter", 53, PortFilter{Protocol: "TCP", Address: "10.0.0.5"}, nil, ErrPortFree},
+		{"hidden", 5432, PortFilter{}, nil, ErrPermission},
+		{"partly hidden", 6379, PortFilter{}, []int{2882}, ErrPermission},
+		{"partly hidden, filtered", 6379, PortFilter{Address: "127.0.0.1"}, []int{2882}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found, err := FindByPort(scannedPorts(ports), tt.port, tt.filter)
+			if !errors.Is(err, tt.err) || (tt.err == nil && err != nil) {
+				t.Errorf("error: got %v, want %v", err, tt.err)
+			}
+			if got := PIDs(found); !slices.Equal(got, tt.pids) {
+				t.Errorf("pids: got %v, want %v", got, tt.pids)
+			}
+		})
+	}
+
+	if _, err := OnPort(ports, 80, PortFilter{Protocol: "sctp"}); err == nil {
+		t.Error("an unknown protocol should fail")
+	}
+}
+
+func TestKillByPort(t *testing.T) {
+	first := startChild(t, "sleep 30")
+	second := startChild(t, "sleep 30")
+	ports := scannedPorts{
+		{Port: 8080, Protocol: "TCP", Address: "0.0.0.0", PID: second},
+		{Port: 8080, Protocol: "TCP", Address: "::", PID: second},
+		{Port: 8080, Protocol: "TCP", Address: "0.0.0.0", PID: first},
+	}
+
+	killed, err := KillByPort(ports, 8080, PortFilter{}, syscall.SIGKILL)
+	if err != nil {
+		t.Fatalf("KillByPort: %v", err)
+	}
+	want := []int{first, second}
+	slices.Sort(want)
+	if !slices.Equal(killed, want) {
+		t.Errorf("killed %v, want %v", killed, want)
+	}
+
+	if _, err := KillByPort(ports, 9090, PortFilter{}, syscall.SIGKILL); !errors.Is(err, ErrPortFree) {
+		t.Errorf("free port: got %v, want ErrPortFree", err)
+	}
+	if os.Getuid() != 0 {
+		pid1 := scannedPorts{{Port: 1, Protocol: "TCP", PID: 1}}
+		if _, err := KillByPort(pid1, 1, PortFilter{}, syscall.Signal(0)); !errors.Is(err, ErrPermission) {
+			t.Errorf("signalling init: got %v, want ErrPermission", err)
+		}
+	}
+}
+
 func TestParseSignal(t *testing.T) {
 	tests := []struct {
 		input string
@@ -60,6 +250,9 @@ func TestParseSignal(t *testing.T) {
 		{"SIGKILL", syscall.SIGKILL, false},
 		{"kill", syscall.SIGKILL, false},
 		{"15", syscall.Signal(15), false},
+		{"SIGSTOP", syscall.SIGSTOP, false},
+		{"cont", syscall.SIGCONT, false},
+		{"SIGWINCH", syscall.SIGWINCH, false},
 		{"bogus", 0, true},
 	}
 
@@ -97,3 +290,32 @@ func TestExtractProcessName(t *testing.T) {
 		}
 	}
 }
+
+func TestSignals(t *testing.T) {
+	sigs := Signals()
+	if len(sigs) < 20 {
+		t.Fatalf("expected the full signal list, got %d signals", len(sigs))
+	}
+
+	names := make(map[string]syscall.Signal)
+	for i, s := range sigs {
+		if i > 0 && s.Num <= sigs[i-1].Num {
+			t.Errorf("signals not ordered by number at %s", s.Name)
+		}
+		names[s.Name] = s.Num
+	}
+	for _, want := range []string{"SIGTERM", "SIGKILL", "SIGSTOP", "SIGCONT", "SIGWINCH"} {
+		if _, ok := names[want]; !ok {
+			t.Errorf("Signals() missing %s", want)
+		}
+	}
+}
+
+func TestSignalName(t *testing.T) {
+	if got := SignalName(syscall.SIGTERM); got != "SIGTERM" {
+		t.Errorf("SignalName(SIGTERM): got %q", got)
+	}
+	if got := SignalName(os.Interrupt); got != "SIGINT" {
+		t.Errorf("SignalName(os.Interrupt): got %q", got)
+	}
+}
@@ -0,0 +1,610 @@
+// Package query implements the filter language shared by the TUI filter bar
+// and the CLI's --filter flag.
+//
+// A query is a list of terms that must all match. Terms are either bare
+// words, matched against the process name, user and command, or field
+// predicates:
+//
+//	port:80  port:3000-3999  port:80,443  proc:node  user=root
+//	cpu>20  mem<=1.5  proto:udp  state:listen  group:backend  cmd:/--inspect/
+//	project:shop  branch:main  dir:src/shop  unit:nginx  queue>=80  rx>1000000
+//
+// A bare number matches the port exactly, so "80" does not match 8080.
+// Terms can be negated with a leading "-", "!" or NOT, combined with AND
+// (implicit) and OR, and grouped with parentheses. A value wrapped in
+// slashes is a regular expression; values containing spaces can be quoted.
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// Error describes a syntax error in a query.
+type Error struct {
+	Pos int // byte offset in the query where the problem was found
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("col %d: %s", e.Pos+1, e.Msg)
+}
+
+// Query is a parsed filter expression.
+type Query struct {
+	src  string
+	root node
+}
+
+// Parse parses a filter expression. An empty string yields a query that
+// matches everything.
+func Parse(s string) (*Query, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{toks: toks, src: s}
+	if len(toks) == 0 {
+		return &Query{src: s}, nil
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, &Error{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	return &Query{src: s, root: root}, nil
+}
+
+// String returns the source text of the query.
+func (q *Query) String() string {
+	return q.src
+}
+
+// Match reports whether a port satisfies the query. group is the port's
+// service group from the config, or empty if it has none.
+func (q *Query) Match(p scanner.PortInfo, group string) bool {
+	if q == nil || q.root == nil {
+		return true
+	}
+	return q.root.match(record{port: p, group: group})
+}
+
+// Filter returns the ports that satisfy the query, in their original order.
+// groupFor maps a port number to its group and may be nil.
+func (q *Query) Filter(ports []scanner.PortInfo, groupFor func(int) string) []scanner.PortInfo {
+	if q == nil || q.root == nil {
+		return ports
+	}
+	var result []scanner.PortInfo
+	for _, p := range ports {
+		var group string
+		if groupFor != nil {
+			group = groupFor(p.Port)
+		}
+		if q.Match(p, group) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// Fields returns the canonical field names a predicate may use.
+func Fields() []string {
+	return []string{"port", "pid", "proc", "user", "cmd", "proto", "state", "cpu", "mem", "group", "project", "branch", "dir", "unit", "queue", "rx", "tx", "host"}
+}
+
+// record is what a query is evaluated against.
+type record struct {
+	port  scanner.PortInfo
+	group string
+}
+
+type fieldKind int
+
+const (
+	textField fieldKind = iota
+	numberField
+)
+
+type field struct {
+	name  string
+	kind  fieldKind
+	text  func(record) string
+	value func(record) float64
+}
+
+var fields = map[string]field{}
+
+func init() {
+	defs := []struct {
+		f       field
+		aliases []string
+	}{
+		{field{name: "port", kind: numberField, value: func(r record) float64 { return float64(r.port.Port) }}, []string{"p"}},
+		{field{name: "pid", kind: numberField, value: func(r record) float64 { return float64(r.port.PID) }}, nil},
+		{field{name: "cpu", kind: numberField, value: func(r record) float64 { return r.port.CPU }}, nil},
+		{field{name: "mem", kind: numberField, value: func(r record) float64 { return r.port.Mem }}, nil},
+		{field{name: "proc", text: func(r record) string { return r.port.ProcessName }}, []string{"process", "name"}},
+		{field{name: "user", text: func(r record) string { return r.port.User }}, []string{"u"}},
+		{field{name: "cmd", text: func(r record) string { return r.port.Command }}, []string{"command"}},
+		{field{name: "proto", text: func(r record) string { return r.port.Protocol }}, []string{"protocol"}},
+		{field{name: "state", text: func(r record) string { return r.port.State }}, nil},
+		{field{name: "group", text: func(r record) string { return r.group }}, []string{"g"}},
+		{field{name: "project", text: func(r record) string { return r.port.Project }}, []string{"proj"}},
+		{field{name: "branch", text: func(r record) string { return r.port.Branch }}, nil},
+		{field{name: "dir", text: func(r record) string { return r.port.WorkingDir }}, []string{"cwd"}},
+		{field{name: "unit", text: func(r record) string { return r.port.Unit }}, nil},
+		{field{name: "queue", kind: numberField, value: func(r record) float64 { return r.port.Saturation() * 100 }}, nil},
+		{field{name: "rx", kind: numberField, value: func(r record) float64 { return r.port.RxBytes }}, nil},
+		{field{name: "tx", kind: numberField, value: func(r record) float64 { return r.port.TxBytes }}, nil},
+		{field{name: "host", text: func(r record) string {
+			if r.port.Host == "" {
+				return "local"
+			}
+			return r.port.Host
+		}}, []string{"h"}},
+	}
+	for _, d := range defs {
+		fields[d.f.name] = d.f
+		for _, a := range d.aliases {
+			fields[a] = d.f
+		}
+	}
+}
+
+type node interface {
+	match(r record) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) match(r record) bool { return n.left.match(r) && n.right.match(r) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) match(r record) bool { return n.left.match(r) || n.right.match(r) }
+
+type notNode struct{ inner node }
+
+func (n notNode) match(r record) bool { return !n.inner.match(r) }
+
+// wordNode is a bare term. Numbers match the port exactly; anything else is
+// a case-insensitive substring of the process name, user or command.
+type wordNode struct {
+	lower string
+	port  int
+	re    *regexp.Regexp
+}
+
+func (n wordNode) match(r record) bool {
+	texts := []string{r.port.ProcessName, r.port.User, r.port.Command}
+	if n.re != nil {
+		for _, t := range texts {
+			if n.re.MatchString(t) {
+				return true
+			}
+		}
+		return false
+	}
+	if n.port > 0 {
+		return r.port.Port == n.port
+	}
+	for _, t := range texts {
+		if strings.Contains(strings.ToLower(t), n.lower) {
+			return true
+		}
+	}
+	return false
+}
+
+// textNode matches a text field by substring, exact value or regex.
+type textNode struct {
+	field  field
+	op     string
+	values []string // lower-cased
+	re     *regexp.Regexp
+}
+
+func (n textNode) match(r record) bool {
+	v := n.field.text(r)
+	if n.re != nil {
+		return n.re.MatchString(v)
+	}
+	lower := strings.ToLower(v)
+	for _, want := range n.values {
+		switch n.op {
+		case ":":
+			if strings.Contains(lower, want) {
+				return true
+			}
+		default: // "=" and "!=" share the exact comparison
+			if lower == want {
+				return n.op == "="
+			}
+		}
+	}
+	return n.op == "!="
+}
+
+// numberNode matches a numeric field against values, ranges or a bound.
+type numberNode struct {
+	field  field
+	op     string
+	ranges [][2]float64
+}
+
+func (n numberNode) match(r record) bool {
+	v := n.field.value(r)
+	switch n.op {
+	case ">":
+		return v > n.ranges[0][0]
+	case ">=":
+		return v >= n.ranges[0][0]
+	case "<":
+		return v < n.ranges[0][0]
+	case "<=":
+		return v <= n.ranges[0][0]
+	}
+
+	in := false
+	for _, rg := range n.ranges {
+		if v >= rg[0] && v <= rg[1] {
+			in = true
+			break
+		}
+	}
+	if n.op == "!=" {
+		return !in
+	}
+	return in
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokTerm
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func lex(s string) ([]token, error) {
+	var toks []token
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(':
+			toks = append(toks, token{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			toks = append(toks, token{kind: tokRParen, text: ")", pos: i})
+			i++
+		case strings.HasPrefix(s[i:], "||"):
+			toks = append(toks, token{kind: tokOr, text: "||", pos: i})
+			i += 2
+		case strings.HasPrefix(s[i:], "&&"):
+			toks = append(toks, token{kind: tokAnd, text: "&&", pos: i})
+			i += 2
+		case (c == '-' || c == '!') && i+1 < len(s) && s[i+1] != ' ' && s[i+1] != '=':
+			toks = append(toks, token{kind: tokNot, text: string(c), pos: i})
+			i++
+		default:
+			start := i
+			end, err := scanTerm(s, i)
+			if err != nil {
+				return nil, err
+			}
+			text := s[start:end]
+			switch strings.ToUpper(text) {
+			case "AND":
+				toks = append(toks, token{kind: tokAnd, text: text, pos: start})
+			case "OR":
+				toks = append(toks, token{kind: tokOr, text: text, pos: start})
+			case "NOT":
+				toks = append(toks, token{kind: tokNot, text: text, pos: start})
+			default:
+				toks = append(toks, token{kind: tokTerm, text: text, pos: start})
+			}
+			i = end
+		}
+	}
+	return toks, nil
+}
+
+// scanTerm returns the end of the term starting at i. Quoted strings and
+// /regex/ values may contain spaces and parentheses.
+func scanTerm(s string, i int) (int, error) {
+	valueStart := true // a regex may only open at the start of a value
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '(' || c == ')':
+			return i, nil
+		case c == '"' || (c == '/' && valueStart):
+			end := closing(s, i+1, c)
+			if end < 0 {
+				what := "quote"
+				if c == '/' {
+					what = "regex"
+				}
+				return 0, &Error{Pos: i, Msg: "unterminated " + what}
+			}
+			i = end + 1
+			valueStart = false
+		case c == ':' || c == '=' || c == '>' || c == '<' || c == '!':
+			i++
+			valueStart = true
+		default:
+			i++
+			valueStart = false
+		}
+	}
+	return i, nil
+}
+
+// closing finds the next unescaped delim at or after i.
+func closing(s string, i int, delim byte) int {
+	for ; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == delim {
+			return i
+		}
+	}
+	return -1
+}
+
+type parser struct {
+	toks []token
+	pos  int
+	src  string
+}
+
+func (p *parser) peek() token {
+	if p.pos >= len(p.toks) {
+		return token{kind: tokEOF, pos: len(p.src)}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if p.pos < len(p.toks) {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().kind == tokOr {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.peek().kind {
+		case tokAnd:
+			p.next()
+		case tokTerm, tokNot, tokLParen:
+			// implicit AND
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	if p.peek().kind == tokNot {
+		p.next()
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, &Error{Pos: closing.pos, Msg: "missing )"}
+		}
+		return inner, nil
+	case tokTerm:
+		return parseTerm(tok)
+	case tokEOF:
+		return nil, &Error{Pos: tok.pos, Msg: "unexpected end of query"}
+	default:
+		return nil, &Error{Pos: tok.pos, Msg: fmt.Sprintf("unexpected %q", tok.text)}
+	}
+}
+
+var ops = []string{">=", "<=", "!=", ":", "=", ">", "<"}
+
+// parseTerm turns a single term into a predicate.
+func parseTerm(tok token) (node, error) {
+	text := tok.text
+
+	name, op, value, ok := splitPredicate(text)
+	if !ok {
+		return parseWord(tok)
+	}
+
+	f, known := fields[strings.ToLower(name)]
+	if !known {
+		return nil, &Error{Pos: tok.pos, Msg: fmt.Sprintf("unknown field %q", name)}
+	}
+	valuePos := tok.pos + len(name) + len(op)
+	if value == "" {
+		return nil, &Error{Pos: valuePos, Msg: fmt.Sprintf("missing value for %s", name)}
+	}
+
+	if f.kind == numberField {
+		return parseNumber(f, op, value, valuePos)
+	}
+
+	if op != ":" && op != "=" && op != "!=" {
+		return nil, &Error{Pos: tok.pos + len(name), Msg: fmt.Sprintf("%s is not numeric; use : or =", f.name)}
+	}
+	if isRegex(value) {
+		re, err := compileRegex(value, valuePos)
+		if err != nil {
+			return nil, err
+		}
+		n := textNode{field: f, op: op, re: re}
+		if op == "!=" {
+			return notNode{textNode{field: f, op: ":", re: re}}, nil
+		}
+		return n, nil
+	}
+
+	var values []string
+	for _, v := range strings.Split(unquote(value), ",") {
+		values = append(values, strings.ToLower(v))
+	}
+	return textNode{field: f, op: op, values: values}, nil
+}
+
+// splitPredicate splits "name<op>value". It reports false for bare words.
+func splitPredicate(text string) (name, op, value string, ok bool) {
+	end := 0
+	for end < len(text) && (text[end] >= 'a' && text[end] <= 'z' || text[end] >= 'A' && text[end] <= 'Z') {
+		end++
+	}
+	if end == 0 || end == len(text) {
+		return "", "", "", false
+	}
+	for _, o := range ops {
+		if strings.HasPrefix(text[end:], o) {
+			return text[:end], o, text[end+len(o):], true
+		}
+	}
+	return "", "", "", false
+}
+
+func parseNumber(f field, op, value string, pos int) (node, error) {
+	parse := func(s string) (float64, error) {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, &Error{Pos: pos, Msg: fmt.Sprintf("%s needs a number, got %q", f.name, s)}
+		}
+		return v, nil
+	}
+
+	switch op {
+	case ">", ">=", "<", "<=":
+		v, err := parse(value)
+		if err != nil {
+			return nil, err
+		}
+		return numberNode{field: f, op: op, ranges: [][2]float64{{v, v}}}, nil
+	}
+
+	var ranges [][2]float64
+	for _, part := range strings.Split(value, ",") {
+		lo, hi, isRange := strings.Cut(part, "-")
+		a, err := parse(lo)
+		if err != nil {
+			return nil, err
+		}
+		b := a
+		if isRange {
+			if b, err = parse(hi); err != nil {
+				return nil, err
+			}
+			if b < a {
+				return nil, &Error{Pos: pos, Msg: fmt.Sprintf("empty range %s", part)}
+			}
+		}
+		ranges = append(ranges, [2]float64{a, b})
+	}
+	if op == ":" {
+		op = "="
+	}
+	return numberNode{field: f, op: op, ranges: ranges}, nil
+}
+
+func parseWord(tok token) (node, error) {
+	text := tok.text
+	if isRegex(text) {
+		re, err := compileRegex(text, tok.pos)
+		if err != nil {
+			return nil, err
+		}
+		return wordNode{re: re}, nil
+	}
+	text = unquote(text)
+	n := wordNode{lower: strings.ToLower(text)}
+	if port, err := strconv.Atoi(text); err == nil {
+		n.port = port
+	}
+	return n, nil
+}
+
+func isRegex(s string) bool {
+	return len(s) >= 2 && s[0] == '/' && s[len(s)-1] == '/'
+}
+
+func compileRegex(s string, pos int) (*regexp.Regexp, error) {
+	re, err := regexp.Compile(s[1 : len(s)-1])
+	if err != nil {
+		return nil, &Error{Pos: pos, Msg: fmt.Sprintf("bad regex: %v", err)}
+	}
+	return re, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return strings.ReplaceAll(s[1:len(s)-1], `\"`, `"`)
+	}
+	return s
+}
@@ -0,0 +1,166 @@
+package query
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+func testPorts() []scanner.PortInfo {
+	return []scanner.PortInfo{
+		{Port: 80, Protocol: "TCP", PID: 10, ProcessName: "nginx", User: "root", State: "LISTEN", Command: "nginx: master process", CPU: 0.5, Mem: 0.2, Unit: "nginx.service"},
+		{Port: 3000, Protocol: "TCP", PID: 100, ProcessName: "node", User: "mike", State: "LISTEN", Command: "node server.js --port 3000", CPU: 25, Mem: 1.3, Project: "shop", Branch: "main", WorkingDir: "/home/mike/src/shop", RxBytes: 4096, TxBytes: 65536},
+		{Port: 3001, Protocol: "TCP", PID: 101, ProcessName: "node", User: "mike", State: "LISTEN", Command: "node --inspect api.js", CPU: 2, Mem: 0.9, Project: "shop-api", Branch: "feature/cart", WorkingDir: "/home/mike/src/shop/api"},
+		{Port: 5353, Protocol: "UDP", PID: 200, ProcessName: "avahi-daemon", User: "avahi", State: "LISTEN", Command: "avahi-daemon: running", CPU: 0, Mem: 0.1},
+		{Port: 5432, Protocol: "TCP", PID: 300, ProcessName: "postgres", User: "postgres", State: "LISTEN", Command: "postgres -D /var/lib/pg", CPU: 1, Mem: 4.5, RecvQ: 2, SendQ: 200},
+		{Port: 8080, Protocol: "TCP", PID: 400, ProcessName: "java", User: "mike", State: "LISTEN", Command: "java -jar app.jar --server.port=8080", CPU: 55, Mem: 12, RecvQ: 95, SendQ: 100},
+		{Port: 18080, Protocol: "TCP", PID: 500, ProcessName: "python3", User: "mike", State: "LISTEN", Command: "python3 -m http.server 18080", CPU: 0, Mem: 0.3, Host: "vm1"},
+	}
+}
+
+func groupFor(port int) string {
+	switch port {
+	case 3000, 3001, 8080:
+		return "backend"
+	case 5432:
+		return "database"
+	}
+	return ""
+}
+
+func ports(ps []scanner.PortInfo) string {
+	var out []string
+	for _, p := range ps {
+		out = append(out, strconv.Itoa(p.Port))
+	}
+	return strings.Join(out, ",")
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "80,3000,3001,5353,5432,8080,18080"},
+		{"80", "80"},
+		{"port:80", "80"},
+		{"p:8080", "8080"},
+		{"port:3000-3999", "3000,3001"},
+		{"port:80,443,5432", "80,5432"},
+		{"port>=8080", "8080,18080"},
+		{"port!=80 port<3001", "3000"},
+		{"proc:node", "3000,3001"},
+		{"process:NODE", "3000,3001"},
+		{"proc=post", ""},
+		{"proc=postgres", "5432"},
+		{"user:root", "80"},
+		{"user!=mike", "80,5353,5432"},
+		{"cpu>20", "3000,8080"},
+		{"mem<=0.3", "80,5353,18080"},
+		{"proto:udp", "5353"},
+		{"state:listen proto:tcp port<100", "80"},
+		{"group:backend", "3000,3001,8080"},
+		{"group:database OR group:backend", "3000,3001,5432,8080"},
+		{"-proc:node user:mike", "8080,18080"},
+		{"!proto:tcp", "5353"},
+		{"NOT user:mike", "80,5353,5432"},
+		{"proc:node AND cpu>10", "3000"},
+		{"proc:node && cpu>10", "3000"},
+		{"proc:java || proc:python3", "8080,18080"},
+		{"(proc:node OR proc:java) cpu>20", "3000,8080"},
+		{"-(proc:node OR proc:java)", "80,5353,5432,18080"},
+		{"proc:/^p/", "5432,18080"},
+		{"cmd:/--inspect|--port/", "3000,3001"},
+		{"/daemon$/", "5353"},
+		{`cmd:"http.server 18080"`, "18080"},
+		{"project:shop", "3000,3001"},
+		{"proj=shop", "3000"},
+		{"branch:feature/", "3001"},
+		{"cwd:src/shop", "3000,3001"},
+		{"-dir:/api$/ user:mike", "3000,8080,18080"},
+		{"unit:nginx", "80"},
+		{"-unit:/./ user:root", ""},
+		{"queue>=80", "8080"},
+		{"queue>0", "5432,8080"},
+		{"rx>1000", "3000"},
+		{"tx>0 port<5000", "3000"},
+		{"host:vm1", "18080"},
+		{"h=local port>8000", "8080"},
+		{"node", "3000,3001"},
+		{"avahi", "5353"},
+	}
+
+	for _, tt := range tests {
+		q, err := Parse(tt.query)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", tt.query, err)
+			continue
+		}
+		got := ports(q.Filter(testPorts(), groupFor))
+		if got != tt.want {
+			t.Errorf("Parse(%q): got [%s], want [%s]", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		{"port:", 5, "missing value"},
+		{"bogus:1", 0, "unknown field"},
+		{"cpu>lots", 4, "needs a number"},
+		{"port:90-80", 5, "empty range"},
+		{"proc>3", 4, "not numeric"},
+		{"(proc:node", 10, "missing )"},
+		{"proc:node)", 9, "unexpected"},
+		{"proc:/[/", 5, "bad regex"},
+		{"cmd:/abc", 4, "unterminated regex"},
+		{`cmd:"abc`, 4, "unterminated quote"},
+		{"proc:node OR", 12, "unexpected end"},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.query)
+		if err == nil {
+			t.Errorf("Parse(%q): expected error", tt.query)
+			continue
+		}
+		var qe *Error
+		if !errors.As(err, &qe) {
+			t.Errorf("Parse(%q): error %v is not a *query.Error", tt.query, err)
+			continue
+		}
+		if qe.Pos != tt.pos {
+			t.Errorf("Parse(%q): pos got %d, want %d (%v)", tt.query, qe.Pos, tt.pos, err)
+		}
+		if !strings.Contains(qe.Msg, tt.msg) {
+			t.Errorf("Parse(%q): message %q does not contain %q", tt.query, qe.Msg, tt.msg)
+		}
+	}
+}
+
+func TestNilQueryMatchesEverything(t *testing.T) {
+	var q *Query
+	if !q.Match(testPorts()[0], "") {
+		t.Error("nil query should match")
+	}
+	if got := len(q.Filter(testPorts(), nil)); got != len(testPorts()) {
+		t.Errorf("nil query filter: got %d ports", got)
+	}
+}
+
+func TestString(t *testing.T) {
+	q, err := Parse("port:80 proc:nginx")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q.String() != "port:80 proc:nginx" {
+		t.Errorf("String: got %q", q.String())
+	}
+}
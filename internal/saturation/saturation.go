@@ -0,0 +1,115 @@
+// Package saturation follows the accept queues of TCP listeners across
+// scans and flags the ones that stay close to their backlog, the classic
+// sign of a service that has stopped accepting connections.
+package saturation
+
+import (
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/events"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// Rule flags a listener whose accept queue is at least Threshold of its
+// backlog in Scans consecutive scans.
+type Rule struct {
+	Threshold float64
+	Scans     int
+}
+
+// DefaultRule flags a queue at 80% of its backlog for three scans in a row.
+var DefaultRule = Rule{Threshold: 0.8, Scans: 3}
+
+// Sample is the accept queue of a listener at one scan.
+type Sample struct {
+	Time    time.Time
+	Depth   int
+	Backlog int
+}
+
+// Ratio returns the queue depth as a share of the backlog.
+func (s Sample) Ratio() float64 {
+	if s.Backlog <= 0 {
+		return 0
+	}
+	return float64(s.Depth) / float64(s.Backlog)
+}
+
+// Tracker keeps the recent accept-queue samples of each TCP listener. It is
+// not safe for concurrent use.
+type Tracker struct {
+	rule   Rule
+	size   int
+	series map[string][]Sample
+}
+
+// NewTracker returns a Tracker applying rule that keeps up to size samples
+// per listener, at least as many as the rule needs.
+func NewTracker(rule Rule, size int) *Tracker {
+	if rule.Scans < 1 {
+		rule.Scans = 1
+	}
+	return &Tracker{rule: rule, size: max(size, rule.Scans), series: make(map[string][]Sample)}
+}
+
+// Rule returns the rule the tracker applies.
+func (t *Tracker) Rule() Rule {
+	return t.rule
+}
+
+// Observe records the queues of a scan. Listeners without a known backlog
+// are skipped, and those missing from the scan are forgotten.
+func (t *Tracker) Observe(ports []scanner.PortInfo, at time.Time) {
+	seen := make(map[string]bool, len(ports))
+	for _, p := range ports {
+		if p.Protocol != "TCP" || p.SendQ <= 0 {
+			continue
+		}
+		key := events.Key(p)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		s := append(t.series[key], Sample{Time: at, Depth: p.RecvQ, Backlog: p.SendQ})
+		if len(s) > t.size {
+			s = s[len(s)-t.size:]
+		}
+		t.series[key] = s
+	}
+	for key := range t.series {
+		if !seen[key] {
+			delete(t.series, key)
+		}
+	}
+}
+
+// Samples returns the recorded samples of a listener, oldest first.
+func (t *Tracker) Samples(p scanner.PortInfo) []Sample {
+	return t.series[events.Key(p)]
+}
+
+// Saturated reports whether the listener's queue has been at or above the
+// rule's threshold in each of the last Scans samples.
+func (t *Tracker) Saturated(p scanner.PortInfo) bool {
+	s := t.series[events.Key(p)]
+	if len(s) < t.rule.Scans {
+		return false
+	}
+	for _, sample := range s[len(s)-t.rule.Scans:] {
+		if sample.Ratio() < t.rule.Threshold {
+			return false
+		}
+	}
+	return true
+}
+
+// Flagged returns the listeners of ports that are saturated, in order.
+func (t *Tracker) Flagged(ports []scanner.PortInfo) []scanner.PortInfo {
+	var flagged []scanner.PortInfo
+	for _, p := range ports {
+		if t.Saturated(p) {
+			flagged = append(flagged, p)
+		}
+	}
+	return flagged
+}
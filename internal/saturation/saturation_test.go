@@ -0,0 +1,68 @@
+package saturation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+func listener(depth, backlog int) scanner.PortInfo {
+	return scanner.PortInfo{Port: 8080, Protocol: "TCP", PID: 42, State: "LISTEN", RecvQ: depth, SendQ: backlog}
+}
+
+func TestSaturatedNeedsSustainedQueue(t *testing.T) {
+	tr := NewTracker(Rule{Threshold: 0.8, Scans: 3}, 10)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	scan := func(i, depth int) {
+		tr.Observe([]scanner.PortInfo{listener(depth, 100)}, start.Add(time.Duration(i)*time.Second))
+	}
+
+	scan(0, 95)
+	scan(1, 90)
+	if tr.Saturated(listener(0, 100)) {
+		t.Error("two full scans should not be enough")
+	}
+	scan(2, 80)
+	if !tr.Saturated(listener(0, 100)) {
+		t.Error("three scans at or above 80% should be flagged")
+	}
+	scan(3, 10)
+	if tr.Saturated(listener(0, 100)) {
+		t.Error("a drained queue should clear the flag")
+	}
+	if got := len(tr.Samples(listener(0, 100))); got != 4 {
+		t.Errorf("samples: got %d, want 4", got)
+	}
+}
+
+func TestObserveBoundsAndForgets(t *testing.T) {
+	tr := NewTracker(DefaultRule, 5)
+	p := listener(1, 128)
+	for i := range 8 {
+		tr.Observe([]scanner.PortInfo{p}, time.Unix(int64(i), 0))
+	}
+	s := tr.Samples(p)
+	if len(s) != 5 || s[0].Time.Unix() != 3 {
+		t.Errorf("expected the last 5 samples, got %+v", s)
+	}
+
+	tr.Observe(nil, time.Unix(9, 0))
+	if tr.Samples(p) != nil {
+		t.Error("a listener that went away should be forgotten")
+	}
+}
+
+func TestObserveSkipsUnknownBacklog(t *testing.T) {
+	tr := NewTracker(Rule{Threshold: 0.5, Scans: 1}, 5)
+	udp := scanner.PortInfo{Port: 53, Protocol: "UDP", RecvQ: 10}
+	full := listener(100, 100)
+	tr.Observe([]scanner.PortInfo{udp, listener(0, 0), full}, time.Unix(0, 0))
+	if tr.Samples(udp) != nil {
+		t.Error("UDP sockets have no accept queue")
+	}
+	flagged := tr.Flagged([]scanner.PortInfo{udp, full})
+	if len(flagged) != 1 || flagged[0].RecvQ != 100 {
+		t.Errorf("flagged: got %+v", flagged)
+	}
+}
@@ -1,120 +1,226 @@
-//go:build darwin
-
 package scanner
 
 import (
-	"bufio"
 	"fmt"
-	"os/exec"
+	"sort"
 	"strconv"
 	"strings"
 )
 
-type darwinScanner struct{}
+// darwinScanner reads ports from lsof and processes from ps.
+type darwinScanner struct {
+	sys System
+}
+
+// Backend reports that ports are read from lsof.
+func (d *darwinScanner) Backend() string {
+	return "lsof"
+}
 
-// New creates a macOS scanner using lsof.
-func New() (Scanner, error) {
-	return &darwinScanner{}, nil
+// lsof returns the path of lsof. It is at /usr/sbin/lsof on macOS, which
+// may not be in the PATH.
+func (d *darwinScanner) lsof() string {
+	if _, err := d.sys.LookPath("lsof"); err != nil {
+		return "/usr/sbin/lsof"
+	}
+	return "lsof"
 }
 
 // Scan uses lsof to discover listening TCP and UDP ports on macOS.
 func (d *darwinScanner) Scan() ([]PortInfo, error) {
-	lsofPath := "lsof"
-	if _, err := exec.LookPath("lsof"); err != nil {
-		// lsof is commonly at /usr/sbin/lsof on macOS but may not be in PATH
-		lsofPath = "/usr/sbin/lsof"
-	}
-	out, err := exec.Command(lsofPath, "-iTCP", "-iUDP", "-nP", "-sTCP:LISTEN").Output()
+	res, err := d.ScanResult()
+	return res.Ports, err
+}
+
+// ScanResult scans like Scan and reports netstat or ps failing.
+func (d *darwinScanner) ScanResult() (Result, error) {
+	out, err := d.sys.Run(d.lsof(), "-iTCP", "-iUDP", "-nP", "-sTCP:LISTEN")
 	if err != nil {
 		// lsof may exit non-zero if some files can't be accessed (permission)
 		if out == nil || len(out) == 0 {
-			return nil, fmt.Errorf("running lsof: %w", err)
+			return Result{}, backendError("lsof", err)
 		}
 	}
 
 	ports, err := parseLsofOutput(string(out))
 	if err != nil {
-		return nil, fmt.Errorf("parsing lsof output: %w", err)
+		return Result{}, fmt.Errorf("parsing lsof output: %w", err)
+	}
+
+	// Queue sizes are optional; leave them out if netstat fails. netstat
+	// also lists the TCP listeners of other users' processes, which lsof
+	// leaves out unless run as root.
+	var failed []Failure
+	if out, err := d.sys.Run("netstat", "-Lan", "-p", "tcp"); err == nil {
+		queues := parseNetstatQueues(string(out))
+		applyListenQueues(ports, queues)
+		ports = append(ports, unownedListeners(ports, queues)...)
+	} else {
+		failed = append(failed, Failure{Backend: "netstat", Err: classify(err)})
 	}
 
-	enrichWithProcessStats(ports)
-	return ports, nil
+	failed = append(failed, enrichWithProcessStats(d.sys, d, ports)...)
+	return Result{Ports: ports, Failed: failed}, nil
 }
 
-// parseLsofOutput parses the output of `lsof -iTCP -iUDP -nP -sTCP:LISTEN`.
-// Example line:
-// rapportd    496 mike   4u  IPv4 0x1234   0t0  TCP *:49153 (LISTEN)
-// rapportd    496 mike   5u  IPv6 0x5678   0t0  UDP *:5353
-func parseLsofOutput(output string) ([]PortInfo, error) {
-	var ports []PortInfo
-	seen := make(map[string]bool) // deduplicate by port+proto+pid
-
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	for scanner.Scan() {
-		line := scanner.Text()
+// listenQueue is the accept queue length and backlog of a TCP listener.
+type listenQueue struct {
+	depth, backlog int
+}
+
+// parseNetstatQueues parses `netstat -Lan -p tcp` into listen queues keyed
+// by "address:port", with * for the wildcard address.
+// Example lines:
+// Current listen queue sizes (qlen/incqlen/maxqlen)
+// Listen         Local Address
+// 0/0/128        *.8080
+// 3/0/511        127.0.0.1.5432
+func parseNetstatQueues(output string) map[string]listenQueue {
+	queues := make(map[string]listenQueue)
+	for _, line := range strings.Split(output, "\n") {
 		fields := strings.Fields(line)
-		if len(fields) < 9 {
+		if len(fields) < 2 {
 			continue
 		}
-
-		// Skip header
-		if fields[0] == "COMMAND" {
+		sizes := strings.Split(fields[0], "/")
+		if len(sizes) != 3 {
 			continue
 		}
-
-		processName := fields[0]
-		pid, err := strconv.Atoi(fields[1])
-		if err != nil {
+		depth, err1 := strconv.Atoi(sizes[0])
+		backlog, err2 := strconv.Atoi(sizes[2])
+		i := strings.LastIndex(fields[1], ".")
+		if err1 != nil || err2 != nil || i < 0 {
 			continue
 		}
-		user := fields[2]
-		proto := fields[7] // TCP or UDP
-
-		// Parse the address field (e.g., "*:49153", "127.0.0.1:8080", "[::1]:3000")
-		addrField := fields[8]
-		port, err := parsePortFromAddr(addrField)
-		if err != nil {
-			continue
+		host, port := fields[1][:i], fields[1][i+1:]
+		if j := strings.Index(host, "%"); j >= 0 {
+			host = host[:j]
 		}
+		queues[host+":"+port] = listenQueue{depth: depth, backlog: backlog}
+	}
+	return queues
+}
 
-		state := "LISTEN"
-		if len(fields) > 9 {
-			state = strings.Trim(fields[9], "()")
+// applyListenQueues fills in the queue sizes of the TCP listeners found in
+// queues.
+func applyListenQueues(ports []PortInfo, queues map[string]listenQueue) {
+	for i := range ports {
+		if ports[i].Protocol != "TCP" {
+			continue
 		}
-		if proto == "UDP" {
-			state = "LISTEN" // UDP doesn't have LISTEN state but we show it as listening
+		q, ok := queues[fmt.Sprintf("%s:%d", ports[i].Address, ports[i].Port)]
+		if !ok {
+			continue
 		}
+		ports[i].RecvQ, ports[i].SendQ = q.depth, q.backlog
+	}
+}
 
-		key := fmt.Sprintf("%d:%s:%d", port, proto, pid)
-		if seen[key] {
+// unownedListeners returns the TCP listeners in queues on ports that none of
+// ports is on, without a process.
+func unownedListeners(ports []PortInfo, queues map[string]listenQueue) []PortInfo {
+	owned := make(map[int]bool)
+	for _, p := range ports {
+		if p.Protocol == "TCP" {
+			owned[p.Port] = true
+		}
+	}
+	var unowned []PortInfo
+	for key, q := range queues {
+		i := strings.LastIndex(key, ":")
+		port, err := strconv.Atoi(key[i+1:])
+		if err != nil || owned[port] {
 			continue
 		}
-		seen[key] = true
-
-		ports = append(ports, PortInfo{
-			Port:        port,
-			Protocol:    proto,
-			PID:         pid,
-			ProcessName: processName,
-			User:        user,
-			State:       state,
+		unowned = append(unowned, PortInfo{
+			Port:     port,
+			Protocol: "TCP",
+			Address:  key[:i],
+			State:    "LISTEN",
+			RecvQ:    q.depth,
+			SendQ:    q.backlog,
 		})
 	}
+	sort.Slice(unowned, func(i, j int) bool {
+		if unowned[i].Port != unowned[j].Port {
+			return unowned[i].Port < unowned[j].Port
+		}
+		return unowned[i].Address < unowned[j].Address
+	})
+	return unowned
+}
 
-	return ports, scanner.Err()
+// Connections counts established TCP connections per local port with lsof.
+func (d *darwinScanner) Connections() (map[int]int, error) {
+	out, err := d.sys.Run(d.lsof(), "-iTCP", "-nP", "-sTCP:ESTABLISHED")
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("running lsof: %w", err)
+	}
+	return parseLsofConnections(string(out)), nil
 }
 
-// parsePortFromAddr extracts the port number from lsof address field.
-// Handles formats like: *:8080, 127.0.0.1:3000, [::1]:443, [::]:80
-func parsePortFromAddr(addr string) (int, error) {
-	idx := strings.LastIndex(addr, ":")
-	if idx < 0 {
-		return 0, fmt.Errorf("no port in address: %s", addr)
+// parseLsofConnections counts connections by local port in the output of
+// `lsof -iTCP -nP -sTCP:ESTABLISHED`. A socket shared by several processes
+// is counted once.
+// Example line:
+// postgres  812 mike  9u  IPv4 0x1234  0t0  TCP 127.0.0.1:5432->127.0.0.1:51234 (ESTABLISHED)
+func parseLsofConnections(output string) map[int]int {
+	counts := make(map[int]int)
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 9 || fields[7] != "TCP" {
+			continue
+		}
+		name := fields[8]
+		local, _, ok := strings.Cut(name, "->")
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if port, err := parsePortFromAddr(local); err == nil {
+			counts[port]++
+		}
 	}
-	portStr := addr[idx+1:]
-	port, err := strconv.Atoi(portStr)
-	if err != nil {
-		return 0, fmt.Errorf("parsing port %q: %w", portStr, err)
+	return counts
+}
+
+// platformProcessStats is a no-op on macOS: there is no cheap way to read a
+// process's thread count, containers run inside a VM rather than on the
+// host, and there is no systemd.
+func (d *darwinScanner) platformProcessStats(pid int, s *processStats) {}
+
+// workingDirs looks up the working directory of each process with a
+// single lsof call.
+func (d *darwinScanner) workingDirs(pids []int) map[int]string {
+	if len(pids) == 0 {
+		return nil
+	}
+	list := make([]string, len(pids))
+	for i, pid := range pids {
+		list[i] = strconv.Itoa(pid)
+	}
+	out, _ := d.sys.Run(d.lsof(), "-a", "-d", "cwd", "-Fpn", "-p", strings.Join(list, ","))
+	return parseLsofCwd(string(out))
+}
+
+// parseLsofCwd parses the output of `lsof -a -d cwd -Fpn -p PIDS`: a
+// "p<pid>" line for each process followed by "n<path>" for its cwd.
+func parseLsofCwd(output string) map[int]string {
+	dirs := make(map[int]string)
+	pid := 0
+	for _, line := range strings.Split(output, "\n") {
+		if len(line) < 2 {
+			continue
+		}
+		switch line[0] {
+		case 'p':
+			pid, _ = strconv.Atoi(line[1:])
+		case 'n':
+			if pid > 0 {
+				dirs[pid] = line[1:]
+			}
+		}
 	}
-	return port, nil
+	return dirs
 }
@@ -1,113 +1,61 @@
-//go:build darwin
-
 package scanner
 
 import (
 	"testing"
 )
 
-func TestParseLsofOutput(t *testing.T) {
+func TestParseLsofConnections(t *testing.T) {
 	input := `COMMAND     PID   USER   FD   TYPE             DEVICE SIZE/OFF NODE NAME
-rapportd    496   mike    4u  IPv4 0x1234      0t0  TCP *:49153 (LISTEN)
-node      12345   mike    5u  IPv6 0x5678      0t0  TCP [::1]:3000 (LISTEN)
-postgres  54321   mike    6u  IPv4 0xabcd      0t0  TCP 127.0.0.1:5432 (LISTEN)
-mDNSRespo   100   _mdns   7u  IPv4 0x9999      0t0  UDP *:5353
+postgres    812   mike    9u  IPv4 0x1234      0t0  TCP 127.0.0.1:5432->127.0.0.1:51234 (ESTABLISHED)
+postgres    813   mike    9u  IPv4 0x1234      0t0  TCP 127.0.0.1:5432->127.0.0.1:51234 (ESTABLISHED)
+node      12345   mike   21u  IPv4 0x5678      0t0  TCP 127.0.0.1:51234->127.0.0.1:5432 (ESTABLISHED)
+node      12345   mike   22u  IPv6 0x9999      0t0  TCP [::1]:3000->[::1]:60000 (ESTABLISHED)
 `
-
-	ports, err := parseLsofOutput(input)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-
-	if len(ports) != 4 {
-		t.Fatalf("expected 4 ports, got %d", len(ports))
-	}
-
-	tests := []struct {
-		idx         int
-		port        int
-		proto       string
-		pid         int
-		processName string
-		user        string
-		state       string
-	}{
-		{0, 49153, "TCP", 496, "rapportd", "mike", "LISTEN"},
-		{1, 3000, "TCP", 12345, "node", "mike", "LISTEN"},
-		{2, 5432, "TCP", 54321, "postgres", "mike", "LISTEN"},
-		{3, 5353, "UDP", 100, "mDNSRespo", "_mdns", "LISTEN"},
-	}
-
-	for _, tt := range tests {
-		p := ports[tt.idx]
-		if p.Port != tt.port {
-			t.Errorf("[%d] port: got %d, want %d", tt.idx, p.Port, tt.port)
-		}
-		if p.Protocol != tt.proto {
-			t.Errorf("[%d] proto: got %s, want %s", tt.idx, p.Protocol, tt.proto)
-		}
-		if p.PID != tt.pid {
-			t.Errorf("[%d] pid: got %d, want %d", tt.idx, p.PID, tt.pid)
-		}
-		if p.ProcessName != tt.processName {
-			t.Errorf("[%d] name: got %s, want %s", tt.idx, p.ProcessName, tt.processName)
-		}
-		if p.User != tt.user {
-			t.Errorf("[%d] user: got %s, want %s", tt.idx, p.User, tt.user)
-		}
-		if p.State != tt.state {
-			t.Errorf("[%d] state: got %s, want %s", tt.idx, p.State, tt.state)
-		}
+	counts := parseLsofConnections(input)
+	if counts[5432] != 1 || counts[3000] != 1 || counts[51234] != 1 {
+		t.Errorf("counts: got %v", counts)
 	}
 }
 
-func TestParseLsofOutputEmpty(t *testing.T) {
-	ports, err := parseLsofOutput("")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-	if len(ports) != 0 {
-		t.Fatalf("expected 0 ports, got %d", len(ports))
+func TestParseLsofCwd(t *testing.T) {
+	output := "p812\nfcwd\nn/Users/mike/src/shop\np913\nfcwd\nn/\n"
+	dirs := parseLsofCwd(output)
+	if len(dirs) != 2 || dirs[812] != "/Users/mike/src/shop" || dirs[913] != "/" {
+		t.Errorf("got %v", dirs)
 	}
 }
 
-func TestParseLsofOutputDedup(t *testing.T) {
-	input := `COMMAND  PID USER FD TYPE DEVICE SIZE/OFF NODE NAME
-node   123 mike 4u IPv4 0x1 0t0 TCP *:3000 (LISTEN)
-node   123 mike 5u IPv6 0x2 0t0 TCP *:3000 (LISTEN)
+func TestParseNetstatQueues(t *testing.T) {
+	output := `Current listen queue sizes (qlen/incqlen/maxqlen)
+Listen         Local Address
+0/0/128        *.8080
+3/0/511        127.0.0.1.5432
+0/0/128        ::1.3000
+0/0/5          fe80::1%lo0.7000
 `
-	ports, err := parseLsofOutput(input)
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+	queues := parseNetstatQueues(output)
+	want := map[string]listenQueue{
+		"*:8080":         {0, 128},
+		"127.0.0.1:5432": {3, 511},
+		"::1:3000":       {0, 128},
+		"fe80::1:7000":   {0, 5},
 	}
-	if len(ports) != 1 {
-		t.Fatalf("expected 1 port (deduped), got %d", len(ports))
+	if len(queues) != len(want) {
+		t.Fatalf("got %v", queues)
 	}
-}
-
-func TestParsePortFromAddr(t *testing.T) {
-	tests := []struct {
-		addr string
-		port int
-		err  bool
-	}{
-		{"*:8080", 8080, false},
-		{"127.0.0.1:3000", 3000, false},
-		{"[::1]:443", 443, false},
-		{"[::]:80", 80, false},
-		{"noport", 0, true},
+	for k, q := range want {
+		if queues[k] != q {
+			t.Errorf("%s: got %v, want %v", k, queues[k], q)
+		}
 	}
 
-	for _, tt := range tests {
-		port, err := parsePortFromAddr(tt.addr)
-		if tt.err && err == nil {
-			t.Errorf("parsePortFromAddr(%q): expected error", tt.addr)
-		}
-		if !tt.err && err != nil {
-			t.Errorf("parsePortFromAddr(%q): unexpected error: %v", tt.addr, err)
-		}
-		if port != tt.port {
-			t.Errorf("parsePortFromAddr(%q): got %d, want %d", tt.addr, port, tt.port)
-		}
+	ports := []PortInfo{
+		{Port: 5432, Protocol: "TCP", Address: "127.0.0.1"},
+		{Port: 8080, Protocol: "TCP", Address: "*"},
+		{Port: 5353, Protocol: "UDP", Address: "*"},
+	}
+	applyListenQueues(ports, queues)
+	if ports[0].RecvQ != 3 || ports[0].SendQ != 511 || ports[1].SendQ != 128 || ports[2].SendQ != 0 {
+		t.Errorf("got %+v", ports)
 	}
 }
@@ -1,160 +1,177 @@
-//go:build linux
-
 package scanner
 
 import (
-	"bufio"
 	"fmt"
-	"os/exec"
 	"strconv"
 	"strings"
 )
 
-type linuxScanner struct{}
+// linuxScanner reads ports from ss and processes from ps and /proc.
+type linuxScanner struct {
+	sys System
+}
 
-// New creates a Linux scanner using ss.
-func New() (Scanner, error) {
-	return &linuxScanner{}, nil
+// Backend reports that ports are read from ss.
+func (l *linuxScanner) Backend() string {
+	return "ss"
 }
 
 // Scan uses ss to discover listening TCP and UDP ports on Linux.
 func (l *linuxScanner) Scan() ([]PortInfo, error) {
-	out, err := exec.Command("ss", "-tulnp").Output()
+	res, err := l.ScanResult()
+	return res.Ports, err
+}
+
+// ScanResult scans like Scan and reports ps failing.
+func (l *linuxScanner) ScanResult() (Result, error) {
+	out, err := l.sys.Run("ss", "-tulnp")
 	if err != nil {
 		if len(out) == 0 {
-			return nil, fmt.Errorf("running ss: %w", err)
+			return Result{}, backendError("ss", err)
 		}
 	}
 
 	ports, err := parseSSOutput(string(out))
 	if err != nil {
-		return nil, fmt.Errorf("parsing ss output: %w", err)
+		return Result{}, fmt.Errorf("parsing ss output: %w", err)
+	}
+	for i := range ports {
+		if ports[i].PID > 0 {
+			ports[i].User, _ = getProcessUser(l.sys, ports[i].PID)
+		}
 	}
 
-	enrichWithProcessStats(ports)
-	return ports, nil
+	failed := enrichWithProcessStats(l.sys, l, ports)
+	return Result{Ports: ports, Failed: failed}, nil
 }
 
-// parseSSOutput parses the output of `ss -tulnp`.
-// Example lines:
-// Netid  State   Recv-Q  Send-Q   Local Address:Port   Peer Address:Port  Process
-// tcp    LISTEN  0       128      0.0.0.0:22            0.0.0.0:*          users:(("sshd",pid=1234,fd=3))
-// udp    UNCONN  0       0        0.0.0.0:5353          0.0.0.0:*          users:(("avahi-daemon",pid=567,fd=12))
-func parseSSOutput(output string) ([]PortInfo, error) {
-	var ports []PortInfo
-	seen := make(map[string]bool)
+// Connections counts established TCP connections per local port with ss.
+func (l *linuxScanner) Connections() (map[int]int, error) {
+	out, err := l.sys.Run("ss", "-tn")
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("running ss: %w", err)
+	}
+	return parseSSConnections(string(out)), nil
+}
 
-	scanner := bufio.NewScanner(strings.NewReader(output))
-	for scanner.Scan() {
-		line := scanner.Text()
-		fields := strings.Fields(line)
-		if len(fields) < 5 {
-			continue
-		}
+// Traffic reads the counters of established TCP connections from the
+// tcp_info that ss -i reports.
+func (l *linuxScanner) Traffic() ([]ConnTraffic, error) {
+	out, err := l.sys.Run("ss", "-tinO", "state", "established")
+	if err != nil && len(out) == 0 {
+		return nil, fmt.Errorf("running ss: %w", err)
+	}
+	return parseSSTraffic(string(out)), nil
+}
 
-		// Skip header
-		proto := strings.ToUpper(fields[0])
-		if proto != "TCP" && proto != "UDP" {
+// parseSSTraffic parses `ss -tinO state established`, which drops the State
+// column and appends the tcp_info of each connection to its line.
+// Example line:
+// 0  0  127.0.0.1:5432  127.0.0.1:51234 cubic ... bytes_sent:1850 bytes_acked:1850 bytes_received:15045 segs_out:79 segs_in:80 ...
+func parseSSTraffic(output string) []ConnTraffic {
+	var conns []ConnTraffic
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 {
 			continue
 		}
-
-		state := fields[1]
-		if proto == "UDP" && state == "UNCONN" {
-			state = "LISTEN"
-		}
-
-		// Local address is field 4
-		localAddr := fields[4]
-		port, err := parsePortFromAddr(localAddr)
+		port, err := parsePortFromAddr(fields[2])
 		if err != nil {
-			continue
+			continue // the header
 		}
-
-		// Parse process info from the last field if it contains users:(...)
-		var pid int
-		var processName string
-		for _, f := range fields[5:] {
-			if strings.HasPrefix(f, "users:") || strings.Contains(f, "pid=") {
-				pid, processName = parseSSProcess(f)
+		c := ConnTraffic{LocalPort: port, Local: fields[2], Peer: fields[3]}
+		var sent, acked uint64
+		for _, f := range fields[4:] {
+			key, value, ok := strings.Cut(f, ":")
+			if !ok {
+				continue
+			}
+			n, err := strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			switch key {
+			case "bytes_received":
+				c.BytesIn = n
+			case "bytes_sent":
+				sent = n
+			case "bytes_acked":
+				acked = n
+			case "segs_in":
+				c.PacketsIn = n
+			case "segs_out":
+				c.PacketsOut = n
 			}
 		}
-
-		key := fmt.Sprintf("%d:%s:%d", port, proto, pid)
-		if seen[key] {
-			continue
+		// bytes_acked leaves out retransmissions but is missing on old
+		// kernels.
+		c.BytesOut = acked
+		if acked == 0 {
+			c.BytesOut = sent
 		}
-		seen[key] = true
+		conns = append(conns, c)
+	}
+	return conns
+}
 
-		info := PortInfo{
-			Port:        port,
-			Protocol:    proto,
-			PID:         pid,
-			ProcessName: processName,
-			State:       state,
+// parseSSConnections counts the ESTAB lines of `ss -tn` by local port.
+// Example line:
+// State  Recv-Q  Send-Q  Local Address:Port  Peer Address:Port  Process
+// ESTAB  0       0       127.0.0.1:5432      127.0.0.1:51234
+func parseSSConnections(output string) map[int]int {
+	counts := make(map[int]int)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || fields[0] != "ESTAB" {
+			continue
 		}
-
-		// Try to get user from /proc if we have a PID
-		if pid > 0 {
-			if user, err := getProcessUser(pid); err == nil {
-				info.User = user
-			}
+		if port, err := parsePortFromAddr(fields[3]); err == nil {
+			counts[port]++
 		}
-
-		ports = append(ports, info)
 	}
+	return counts
+}
 
-	return ports, scanner.Err()
+// platformProcessStats reads the thread count, container ID and systemd
+// unit of a process from /proc.
+func (l *linuxScanner) platformProcessStats(pid int, s *processStats) {
+	if data, err := l.sys.ReadFile(fmt.Sprintf("/proc/%d/status", pid)); err == nil {
+		s.threads = parseStatusThreads(string(data))
+	}
+	if data, err := l.sys.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid)); err == nil {
+		s.container = parseContainerID(string(data))
+		s.unit, s.userUnit = parseSystemdUnit(string(data))
+	}
 }
 
-// parseSSProcess extracts PID and process name from ss process field.
-// Input format: users:(("sshd",pid=1234,fd=3))
-func parseSSProcess(field string) (int, string) {
-	// Extract process name
-	var name string
-	if start := strings.Index(field, "((\""); start >= 0 {
-		rest := field[start+3:]
-		if end := strings.Index(rest, "\""); end >= 0 {
-			name = rest[:end]
+// workingDirs reads the working directory of each process from /proc.
+// Processes of other users are left out unless running as root.
+func (l *linuxScanner) workingDirs(pids []int) map[int]string {
+	dirs := make(map[int]string, len(pids))
+	for _, pid := range pids {
+		if dir, err := l.sys.Readlink(fmt.Sprintf("/proc/%d/cwd", pid)); err == nil {
+			dirs[pid] = dir
 		}
 	}
+	return dirs
+}
 
-	// Extract PID
-	var pid int
-	if pidIdx := strings.Index(field, "pid="); pidIdx >= 0 {
-		rest := field[pidIdx+4:]
-		if end := strings.IndexAny(rest, ",)"); end >= 0 {
-			rest = rest[:end]
+// parseStatusThreads returns the Threads: value of /proc/<pid>/status.
+func parseStatusThreads(status string) int {
+	for _, line := range strings.Split(status, "\n") {
+		if v, ok := strings.CutPrefix(line, "Threads:"); ok {
+			n, _ := strconv.Atoi(strings.TrimSpace(v))
+			return n
 		}
-		pid, _ = strconv.Atoi(rest)
 	}
-
-	return pid, name
+	return 0
 }
 
-// getProcessUser reads the owner of a process from /proc.
-func getProcessUser(pid int) (string, error) {
-	out, err := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "user=").Output()
+// getProcessUser reads the owner of a process with ps.
+func getProcessUser(sys System, pid int) (string, error) {
+	out, err := sys.Run("ps", "-p", strconv.Itoa(pid), "-o", "user=")
 	if err != nil {
 		return "", fmt.Errorf("getting user for pid %d: %w", pid, err)
 	}
 	return strings.TrimSpace(string(out)), nil
 }
-
-// parsePortFromAddr extracts the port number from an ss address field.
-// Handles formats like: 0.0.0.0:22, [::]:80, *:5353
-func parsePortFromAddr(addr string) (int, error) {
-	idx := strings.LastIndex(addr, ":")
-	if idx < 0 {
-		return 0, fmt.Errorf("no port in address: %s", addr)
-	}
-	portStr := addr[idx+1:]
-	// ss may show * for wildcard
-	if portStr == "*" {
-		return 0, fmt.Errorf("wildcard port")
-	}
-	port, err := strconv.Atoi(portStr)
-	if err != nil {
-		return 0, fmt.Errorf("parsing port %q: %w", portStr, err)
-	}
-	return port, nil
-}
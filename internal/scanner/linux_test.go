@@ -0,0 +1,45 @@
+package scanner
+
+import "testing"
+
+func TestParseStatusThreads(t *testing.T) {
+	status := "Name:\tnode\nState:\tS (sleeping)\nThreads:\t11\nSigQ:\t0/63448\n"
+	if got := parseStatusThreads(status); got != 11 {
+		t.Errorf("threads: got %d, want 11", got)
+	}
+	if got := parseStatusThreads("Name:\tnode\n"); got != 0 {
+		t.Errorf("missing Threads line: got %d, want 0", got)
+	}
+}
+
+func TestParseSSConnections(t *testing.T) {
+	input := `State  Recv-Q Send-Q Local Address:Port   Peer Address:Port Process
+ESTAB  0      0      127.0.0.1:5432         127.0.0.1:51234
+ESTAB  0      0      127.0.0.1:51234        127.0.0.1:5432
+ESTAB  0      36     [::ffff:10.0.0.2]:22   [::ffff:10.0.0.9]:60211
+ESTAB  0      0      127.0.0.1:5432         127.0.0.1:51240
+TIME-WAIT 0   0      127.0.0.1:5432         127.0.0.1:51200
+`
+	counts := parseSSConnections(input)
+	if counts[5432] != 2 || counts[22] != 1 || counts[51234] != 1 {
+		t.Errorf("counts: got %v", counts)
+	}
+}
+
+func TestParseSSTraffic(t *testing.T) {
+	input := `Recv-Q Send-Q Local Address:Port  Peer Address:Port Process
+0      0          127.0.0.1:5432    127.0.0.1:59706 cubic wscale:10,10 rto:204 mss:65483 cwnd:24 bytes_sent:1900 bytes_acked:1850 bytes_received:15045 segs_out:792 segs_in:793 data_segs_out:528 send 242868705882bps lastsnd:124
+0      0   [::ffff:10.0.0.2]:22   [::ffff:10.0.0.9]:60211 cubic bytes_sent:400 bytes_received:96 segs_out:6 segs_in:5
+`
+	conns := parseSSTraffic(input)
+	if len(conns) != 2 {
+		t.Fatalf("expected 2 connections, got %d", len(conns))
+	}
+	want := ConnTraffic{LocalPort: 5432, Local: "127.0.0.1:5432", Peer: "127.0.0.1:59706", BytesIn: 15045, BytesOut: 1850, PacketsIn: 793, PacketsOut: 792}
+	if conns[0] != want {
+		t.Errorf("got %+v, want %+v", conns[0], want)
+	}
+	if conns[1].LocalPort != 22 || conns[1].BytesOut != 400 {
+		t.Errorf("without bytes_acked: got %+v", conns[1])
+	}
+}
@@ -0,0 +1,90 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseLsofOutput parses the output of `lsof -iTCP -iUDP -nP -sTCP:LISTEN`.
+// Example line:
+// rapportd    496 mike   4u  IPv4 0x1234   0t0  TCP *:49153 (LISTEN)
+// rapportd    496 mike   5u  IPv6 0x5678   0t0  UDP *:5353
+func parseLsofOutput(output string) ([]PortInfo, error) {
+	var ports []PortInfo
+	seen := make(map[string]bool) // deduplicate by address+port+proto+pid
+
+	// Output without a header or a single socket isn't from lsof.
+	recognized := false
+	firstLine := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if firstLine == "" {
+			firstLine = strings.TrimSpace(line)
+		}
+		if len(fields) < 9 {
+			continue
+		}
+
+		// Skip header
+		if fields[0] == "COMMAND" {
+			recognized = true
+			continue
+		}
+
+		processName := fields[0]
+		pid, err := strconv.Atoi(fields[1])
+		if err != nil {
+			continue
+		}
+		user := fields[2]
+		proto := fields[7] // TCP or UDP
+
+		// Parse the address field (e.g., "*:49153", "127.0.0.1:8080", "[::1]:3000")
+		addrField := fields[8]
+		port, err := parsePortFromAddr(addrField)
+		if err != nil {
+			continue
+		}
+
+		state := "LISTEN"
+		if len(fields) > 9 {
+			state = strings.Trim(fields[9], "()")
+		}
+		if proto == "UDP" {
+			state = "LISTEN" // UDP doesn't have LISTEN state but we show it as listening
+		}
+
+		recognized = true
+		// A process listening on several addresses has a socket on each;
+		// lsof shows the IPv4 and IPv6 wildcards alike, as *.
+		address := parseHostFromAddr(addrField)
+		key := fmt.Sprintf("%s:%d:%s:%d", address, port, proto, pid)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		ports = append(ports, PortInfo{
+			Port:        port,
+			Protocol:    proto,
+			Address:     address,
+			PID:         pid,
+			ProcessName: processName,
+			User:        user,
+			State:       state,
+		})
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !recognized && firstLine != "" {
+		return nil, fmt.Errorf("%w: %q", ErrParse, firstLine)
+	}
+	return ports, nil
+}
@@ -0,0 +1,180 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// project is the code project a process runs in, found from its working
+// directory.
+type project struct {
+	name   string
+	branch string
+}
+
+// manifests are the files that mark the root of a project, each with a
+// function that reads the project name from it.
+var manifests = []struct {
+	file string
+	name func(data []byte) string
+}{
+	{"package.json", packageJSONName},
+	{"go.mod", goModName},
+	{"pyproject.toml", tomlName("project", "tool.poetry")},
+	{"Cargo.toml", tomlName("package")},
+}
+
+// detectProject walks up from dir to the nearest project manifest and the
+// enclosing git repository. The project is named after the manifest, or
+// after the directory it is in, or else after the repository root; the
+// branch comes from the repository. Manifests above the repository root
+// are ignored. A directory outside any project yields the zero project.
+func detectProject(sys System, dir string) project {
+	if dir == "" || dir == "/" {
+		return project{}
+	}
+
+	var p project
+	var root string
+	for d := filepath.Clean(dir); ; d = filepath.Dir(d) {
+		if p.name == "" {
+			for _, m := range manifests {
+				data, err := sys.ReadFile(filepath.Join(d, m.file))
+				if err != nil {
+					continue
+				}
+				p.name = m.name(data)
+				if p.name == "" {
+					p.name = filepath.Base(d)
+				}
+				break
+			}
+		}
+		if gitDir := findGitDir(sys, d); gitDir != "" {
+			root = d
+			p.branch = gitBranch(sys, gitDir)
+			break
+		}
+		if parent := filepath.Dir(d); parent == d {
+			break
+		}
+	}
+	if p.name == "" && root != "" {
+		p.name = filepath.Base(root)
+	}
+	return p
+}
+
+// findGitDir returns the git directory of a repository rooted at dir. A
+// .git file, as in worktrees and submodules, points to it.
+func findGitDir(sys System, dir string) string {
+	gitPath := filepath.Join(dir, ".git")
+	info, err := sys.Stat(gitPath)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return gitPath
+	}
+	data, err := sys.ReadFile(gitPath)
+	if err != nil {
+		return ""
+	}
+	target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir:")
+	if !ok {
+		return ""
+	}
+	target = strings.TrimSpace(target)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	return target
+}
+
+// gitBranch reads the checked-out branch from a git directory, or the
+// short commit hash when HEAD is detached.
+func gitBranch(sys System, gitDir string) string {
+	data, err := sys.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	return parseGitHead(string(data))
+}
+
+// parseGitHead parses the contents of .git/HEAD.
+func parseGitHead(head string) string {
+	head = strings.TrimSpace(head)
+	if ref, ok := strings.CutPrefix(head, "ref:"); ok {
+		ref = strings.TrimSpace(ref)
+		if branch, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+			return branch
+		}
+		return ref
+	}
+	if len(head) >= 7 {
+		return head[:7]
+	}
+	return head
+}
+
+func packageJSONName(data []byte) string {
+	var pkg struct {
+		Name string `json:"name"`
+	}
+	if json.Unmarshal(data, &pkg) != nil {
+		return ""
+	}
+	return pkg.Name
+}
+
+// goModName returns the last element of the module path, skipping a major
+// version suffix such as /v2.
+func goModName(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		mod, ok := strings.CutPrefix(strings.TrimSpace(line), "module ")
+		if !ok {
+			continue
+		}
+		mod = strings.Trim(strings.TrimSpace(mod), `"`)
+		name := path.Base(mod)
+		if majorVersion.MatchString(name) && path.Dir(mod) != "." {
+			name = path.Base(path.Dir(mod))
+		}
+		return name
+	}
+	return ""
+}
+
+var majorVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+var tomlNameRe = regexp.MustCompile(`^name\s*=\s*["']([^"']+)["']`)
+
+// tomlName returns a function that reads the name key from any of the
+// given TOML tables.
+func tomlName(tables ...string) func(data []byte) string {
+	return func(data []byte) string {
+		var table string
+		sc := bufio.NewScanner(strings.NewReader(string(data)))
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if strings.HasPrefix(line, "[") {
+				table = strings.Trim(line, "[] ")
+				continue
+			}
+			m := tomlNameRe.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			for _, t := range tables {
+				if table == t {
+					return m[1]
+				}
+			}
+		}
+		return ""
+	}
+}
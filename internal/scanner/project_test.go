@@ -0,0 +1,79 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDetectProject(t *testing.T) {
+	root := t.TempDir()
+
+	// A monorepo on a feature branch with a Node app and a Go service.
+	repo := filepath.Join(root, "shop")
+	writeFile(t, filepath.Join(repo, ".git", "HEAD"), "ref: refs/heads/feature/cart\n")
+	writeFile(t, filepath.Join(repo, "apps", "web", "package.json"), `{"name": "@shop/web", "version": "1.0.0"}`)
+	writeFile(t, filepath.Join(repo, "services", "api", "go.mod"), "module github.com/acme/shop-api/v2\n\ngo 1.24\n")
+	writeFile(t, filepath.Join(repo, "tools", "README.md"), "")
+
+	// A Python project that isn't a git repository, under a directory
+	// with a manifest of its own.
+	writeFile(t, filepath.Join(root, "package.json"), `{"name": "outer"}`)
+	writeFile(t, filepath.Join(root, "ml", "pyproject.toml"), "[build-system]\nname = \"wrong\"\n\n[project]\nname = \"trainer\"\n")
+
+	// A worktree, whose .git is a file pointing at the git directory.
+	writeFile(t, filepath.Join(root, "wt", ".git"), "gitdir: ../shop/.git/worktrees/wt\n")
+	writeFile(t, filepath.Join(repo, ".git", "worktrees", "wt", "HEAD"), "4b825dc642cb6eb9a060e54bf8d69288fbee4904\n")
+
+	tests := []struct {
+		dir  string
+		want project
+	}{
+		{filepath.Join(repo, "apps", "web", "src"), project{"@shop/web", "feature/cart"}},
+		{filepath.Join(repo, "services", "api"), project{"shop-api", "feature/cart"}},
+		{filepath.Join(repo, "tools"), project{"shop", "feature/cart"}},
+		{filepath.Join(root, "ml"), project{"trainer", ""}},
+		{filepath.Join(root, "wt"), project{"wt", "4b825dc"}},
+		{"/", project{}},
+		{"", project{}},
+	}
+	for _, tt := range tests {
+		if got := detectProject(Local, tt.dir); got != tt.want {
+			t.Errorf("detectProject(%q): got %+v, want %+v", tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestDetectProjectStopsAtRepoRoot(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "go.mod"), "module example.com/outer\n")
+	writeFile(t, filepath.Join(root, "inner", ".git", "HEAD"), "ref: refs/heads/main\n")
+
+	got := detectProject(Local, filepath.Join(root, "inner", "cmd"))
+	if want := (project{"inner", "main"}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseGitHead(t *testing.T) {
+	tests := map[string]string{
+		"ref: refs/heads/main\n": "main",
+		"ref: refs/remotes/x":    "refs/remotes/x",
+		"0123456789abcdef\n":     "0123456",
+	}
+	for in, want := range tests {
+		if got := parseGitHead(in); got != want {
+			t.Errorf("parseGitHead(%q): got %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,273 @@
+package scanner
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recording is everything a scan read from a System: the output of each
+// command it ran and the files it looked at. Saved as JSON it becomes a
+// fixture, and a Replay of it answers the same questions the same way, so
+// whole scans can be tested without the machine they ran on.
+type Recording struct {
+	// OS is the GOOS of the recorded machine.
+	OS       string              `json:"os"`
+	Commands []RecordedCommand   `json:"commands"`
+	Files    map[string]string   `json:"files,omitempty"` // ReadFile
+	Dirs     map[string][]string `json:"dirs,omitempty"`  // ReadDir, the entry names
+	Links    map[string]string   `json:"links,omitempty"` // Readlink
+	Stats    map[string]bool     `json:"stats,omitempty"` // Stat, whether a directory
+	Paths    map[string]string   `json:"paths,omitempty"` // LookPath
+}
+
+// RecordedCommand is one command and what it printed. Err is the error it
+// failed with, if it did; commands can print something and still fail.
+type RecordedCommand struct {
+	Args   []string `json:"args"`
+	Output string   `json:"output"`
+	Err    string   `json:"error,omitempty"`
+}
+
+// LoadRecording reads a recording saved with Save.
+func LoadRecording(path string) (*Recording, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rec Recording
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("parsing recording %s: %w", path, err)
+	}
+	return &rec, nil
+}
+
+// Save writes the recording to path as indented JSON.
+func (r *Recording) Save(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Recorder passes everything through to a System and records it. Only the
+// first run of each command is kept. Files that can't be read are left out,
+// which a Replay reports as missing.
+type Recorder struct {
+	sys System
+
+	mu  sync.Mutex
+	rec Recording
+}
+
+// NewRecorder returns a Recorder over sys, a machine running goos.
+func NewRecorder(sys System, goos string) *Recorder {
+	return &Recorder{sys: sys, rec: Recording{
+		OS:    goos,
+		Files: make(map[string]string),
+		Dirs:  make(map[string][]string),
+		Links: make(map[string]string),
+		Stats: make(map[string]bool),
+		Paths: make(map[string]string),
+	}}
+}
+
+// Recording returns what has been recorded so far.
+func (r *Recorder) Recording() *Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec := r.rec
+	rec.Commands = append([]RecordedCommand(nil), r.rec.Commands...)
+	return &rec
+}
+
+func (r *Recorder) Run(name string, args ...string) ([]byte, error) {
+	out, err := r.sys.Run(name, args...)
+	argv := append([]string{name}, args...)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, c := range r.rec.Commands {
+		if slices.Equal(c.Args, argv) {
+			return out, err
+		}
+	}
+	c := RecordedCommand{Args: argv, Output: string(out)}
+	if err != nil {
+		c.Err = err.Error()
+	}
+	r.rec.Commands = append(r.rec.Commands, c)
+	return out, err
+}
+
+func (r *Recorder) ReadFile(name string) ([]byte, error) {
+	data, err := r.sys.ReadFile(name)
+	if err == nil {
+		r.mu.Lock()
+		r.rec.Files[name] = string(data)
+		r.mu.Unlock()
+	}
+	return data, err
+}
+
+func (r *Recorder) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := r.sys.ReadDir(name)
+	if err == nil {
+		names := make([]string, len(entries))
+		for i, e := range entries {
+			names[i] = e.Name()
+		}
+		r.mu.Lock()
+		r.rec.Dirs[name] = names
+		r.mu.Unlock()
+	}
+	return entries, err
+}
+
+func (r *Recorder) Readlink(name string) (string, error) {
+	target, err := r.sys.Readlink(name)
+	if err == nil {
+		r.mu.Lock()
+		r.rec.Links[name] = target
+		r.mu.Unlock()
+	}
+	return target, err
+}
+
+func (r *Recorder) Stat(name string) (fs.FileInfo, error) {
+	info, err := r.sys.Stat(name)
+	if err == nil {
+		r.mu.Lock()
+		r.rec.Stats[name] = info.IsDir()
+		r.mu.Unlock()
+	}
+	return info, err
+}
+
+func (r *Recorder) LookPath(file string) (string, error) {
+	p, err := r.sys.LookPath(file)
+	if err == nil {
+		r.mu.Lock()
+		r.rec.Paths[file] = p
+		r.mu.Unlock()
+	}
+	return p, err
+}
+
+// Replay answers from a recording. Commands and files that weren't
+// recorded fail as if missing, and the commands are listed by Missed.
+type Replay struct {
+	rec *Recording
+
+	mu     sync.Mutex
+	missed []string
+}
+
+// NewReplay returns a System replaying rec.
+func NewReplay(rec *Recording) *Replay {
+	return &Replay{rec: rec}
+}
+
+// Missed returns the commands run that weren't recorded, sorted. A scan
+// that runs something new since the recording was made shows up here. Files
+// aren't listed: looking for files that aren't there, such as project
+// manifests, is part of a scan.
+func (r *Replay) Missed() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	missed := append([]string(nil), r.missed...)
+	sort.Strings(missed)
+	return missed
+}
+
+func (r *Replay) miss(what string) {
+	r.mu.Lock()
+	r.missed = append(r.missed, what)
+	r.mu.Unlock()
+}
+
+func (r *Replay) Run(name string, args ...string) ([]byte, error) {
+	argv := append([]string{name}, args...)
+	for _, c := range r.rec.Commands {
+		if !slices.Equal(c.Args, argv) {
+			continue
+		}
+		if c.Err != "" {
+			return []byte(c.Output), errors.New(c.Err)
+		}
+		return []byte(c.Output), nil
+	}
+	r.miss(strings.Join(argv, " "))
+	return nil, &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+func (r *Replay) ReadFile(name string) ([]byte, error) {
+	data, ok := r.rec.Files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return []byte(data), nil
+}
+
+func (r *Replay) ReadDir(name string) ([]fs.DirEntry, error) {
+	names, ok := r.rec.Dirs[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	entries := make([]fs.DirEntry, len(names))
+	for i, n := range names {
+		entries[i] = fs.FileInfoToDirEntry(replayedFile{name: n})
+	}
+	return entries, nil
+}
+
+func (r *Replay) Readlink(name string) (string, error) {
+	target, ok := r.rec.Links[name]
+	if !ok {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrNotExist}
+	}
+	return target, nil
+}
+
+func (r *Replay) Stat(name string) (fs.FileInfo, error) {
+	dir, ok := r.rec.Stats[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return replayedFile{name: path.Base(name), dir: dir}, nil
+}
+
+func (r *Replay) LookPath(file string) (string, error) {
+	p, ok := r.rec.Paths[file]
+	if !ok {
+		return "", &exec.Error{Name: file, Err: exec.ErrNotFound}
+	}
+	return p, nil
+}
+
+// replayedFile is the little a Replay knows about a file.
+type replayedFile struct {
+	name string
+	dir  bool
+}
+
+func (f replayedFile) Name() string { return f.name }
+func (f replayedFile) Size() int64  { return 0 }
+func (f replayedFile) Mode() fs.FileMode {
+	if f.dir {
+		return fs.ModeDir | 0o755
+	}
+	return 0o644
+}
+func (f replayedFile) ModTime() time.Time { return time.Time{} }
+func (f replayedFile) IsDir() bool        { return f.dir }
+func (f replayedFile) Sys() any           { return nil }
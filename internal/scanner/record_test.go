@@ -0,0 +1,128 @@
+package scanner
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+// TestReplay runs whole scans against the recordings in testdata/replay and
+// compares them with the golden files next to them. linux.json was recorded
+// with "portpilot record --details" in a network namespace holding only the
+// test listeners, with the cgroup paths replaced by systemd units;
+// darwin.json was written to match the output of the macOS tools.
+func TestReplay(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "replay", "*.json"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no recordings found: %v", err)
+	}
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			rec, err := LoadRecording(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			replay := NewReplay(rec)
+			s, err := NewWith(replay, rec.OS)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := ScanResult(s)
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			result := struct {
+				Ports       []PortInfo  `json:"ports"`
+				Warnings    []string    `json:"warnings"`
+				Connections map[int]int `json:"connections"`
+			}{Ports: res.Ports, Warnings: res.Warnings()}
+			if cc, ok := s.(ConnectionCounter); ok {
+				if result.Connections, err = cc.Connections(); err != nil {
+					t.Fatalf("Connections: %v", err)
+				}
+			}
+			if missed := replay.Missed(); len(missed) > 0 {
+				t.Errorf("commands not in the recording: %q", missed)
+			}
+
+			got, err := json.MarshalIndent(result, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkGolden(t, filepath.Join("testdata", "replay", name+".scan.golden"), append(got, '\n'))
+		})
+	}
+}
+
+// checkGolden compares got with the golden file at path, or rewrites it
+// when the tests run with -update.
+func checkGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("updating %s: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run go test -update to create it): %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("scan differs from %s; if the change is intended, run go test ./internal/scanner -update\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+func TestRecorder(t *testing.T) {
+	rec := &Recording{
+		OS:       "linux",
+		Commands: []RecordedCommand{{Args: []string{"ss", "-tulnp"}, Output: "out"}, {Args: []string{"ps", "-p", "1"}, Output: "partial", Err: "exit status 1"}},
+		Files:    map[string]string{"/proc/1/status": "Threads:\t2\n"},
+		Links:    map[string]string{"/proc/1/cwd": "/srv"},
+		Stats:    map[string]bool{"/srv/.git": true},
+	}
+	r := NewRecorder(NewReplay(rec), "linux")
+	_, _ = r.Run("ss", "-tulnp")
+	_, _ = r.Run("ss", "-tulnp")
+	if out, err := r.Run("ps", "-p", "1"); string(out) != "partial" || err == nil {
+		t.Errorf("a failing command should keep its output and error, got %q, %v", out, err)
+	}
+	_, _ = r.ReadFile("/proc/1/status")
+	_, _ = r.ReadFile("/proc/2/status")
+	_, _ = r.Readlink("/proc/1/cwd")
+	_, _ = r.Stat("/srv/.git")
+
+	path := filepath.Join(t.TempDir(), "rec.json")
+	if err := r.Recording().Save(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadRecording(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Commands) != 2 || got.Commands[1].Err != "exit status 1" {
+		t.Errorf("commands: got %+v", got.Commands)
+	}
+	if len(got.Files) != 1 || got.Links["/proc/1/cwd"] != "/srv" || !got.Stats["/srv/.git"] {
+		t.Errorf("files: got %+v", got)
+	}
+
+	replay := NewReplay(got)
+	if _, err := replay.Run("lsof", "-t"); err == nil {
+		t.Error("an unrecorded command should fail")
+	}
+	if missed := replay.Missed(); len(missed) != 1 || missed[0] != "lsof -t" {
+		t.Errorf("missed: got %q", missed)
+	}
+	if _, err := replay.ReadFile("/proc/2/status"); !os.IsNotExist(err) {
+		t.Errorf("an unrecorded file should be missing, got %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Runner runs a command and returns its standard output. A Runner may run
+// it somewhere else than on this machine.
+type Runner interface {
+	Run(name string, args ...string) ([]byte, error)
+}
+
+// Remote scans another machine by running ss or lsof, and ps, on it
+// through a Runner. It implements Scanner.
+type Remote struct {
+	run Runner
+	os  string
+}
+
+// NewRemote returns a scanner for a machine running goos, "linux" or
+// "darwin", reached through run.
+func NewRemote(run Runner, goos string) (*Remote, error) {
+	switch goos {
+	case "linux", "darwin":
+	default:
+		return nil, fmt.Errorf("unsupported remote OS %q (available: linux, darwin)", goos)
+	}
+	return &Remote{run: run, os: goos}, nil
+}
+
+// Backend reports the tool the remote ports are read from.
+func (r *Remote) Backend() string {
+	if r.os == "darwin" {
+		return "lsof"
+	}
+	return "ss"
+}
+
+// Scan lists the remote machine's listeners and fills in the user, CPU,
+// memory, command and start time of their processes from a single ps.
+// Working directories, projects, threads and units are left out, since
+// they would take a command per process.
+func (r *Remote) Scan() ([]PortInfo, error) {
+	res, err := r.ScanResult()
+	return res.Ports, err
+}
+
+// ScanResult scans like Scan and reports ps failing.
+func (r *Remote) ScanResult() (Result, error) {
+	var ports []PortInfo
+	var err error
+	if r.os == "darwin" {
+		// lsof isn't always in the PATH of a non-interactive shell, and
+		// exits non-zero when it can't read some files.
+		out, runErr := r.run.Run("/usr/sbin/lsof", "-iTCP", "-iUDP", "-nP", "-sTCP:LISTEN")
+		if runErr != nil && len(out) == 0 {
+			return Result{}, backendError("lsof", runErr)
+		}
+		if ports, err = parseLsofOutput(string(out)); err != nil {
+			return Result{}, fmt.Errorf("parsing lsof output: %w", err)
+		}
+	} else {
+		out, runErr := r.run.Run("ss", "-tulnp")
+		if runErr != nil && len(out) == 0 {
+			return Result{}, backendError("ss", runErr)
+		}
+		if ports, err = parseSSOutput(string(out)); err != nil {
+			return Result{}, fmt.Errorf("parsing ss output: %w", err)
+		}
+	}
+
+	var pids []string
+	seen := make(map[int]bool)
+	for _, p := range ports {
+		if p.PID > 0 && !seen[p.PID] {
+			seen[p.PID] = true
+			pids = append(pids, strconv.Itoa(p.PID))
+		}
+	}
+	if len(pids) == 0 {
+		return Result{Ports: ports}, nil
+	}
+	// Process details are optional, like for a local scan. ps exits
+	// non-zero when some of the processes are gone, but still lists the
+	// others.
+	var failed []Failure
+	out, err := r.run.Run("ps", "-o", "pid=,user=,%cpu=,%mem=,rss=,lstart=,command=", "-p", strings.Join(pids, ","))
+	if err != nil && len(out) == 0 {
+		failed = append(failed, Failure{Backend: "ps", Err: classify(err)})
+	}
+	stats := parseBatchPS(string(out))
+	for i := range ports {
+		s, ok := stats[ports[i].PID]
+		if !ok {
+			continue
+		}
+		if ports[i].User == "" {
+			ports[i].User = s.user
+		}
+		ports[i].CPU = s.cpu
+		ports[i].Mem = s.mem
+		ports[i].RSS = s.rss
+		ports[i].Command = s.command
+		ports[i].StartTime = s.startTime
+	}
+	return Result{Ports: ports, Failed: failed}, nil
+}
+
+// batchStats is one process in the output of a batched ps.
+type batchStats struct {
+	processStats
+	user string
+}
+
+// parseBatchPS parses the output of
+// `ps -o pid=,user=,%cpu=,%mem=,rss=,lstart=,command= -p PIDS`, one process
+// per line:
+//
+//	812 postgres  0.0  0.4  10240 Thu Jan  2 15:04:05 2025 /usr/lib/postgresql/16/bin/postgres
+func parseBatchPS(output string) map[int]batchStats {
+	stats := make(map[int]batchStats)
+	sc := bufio.NewScanner(strings.NewReader(output))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		pid, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		s, err := parseProcessStats(strings.Join(fields[2:], " "))
+		if err != nil {
+			continue
+		}
+		stats[pid] = batchStats{processStats: s, user: fields[1]}
+	}
+	return stats
+}
+
+// SSHRunner runs commands on a host through the ssh client, so hosts,
+// users, keys and jump hosts from ~/.ssh/config apply as usual. ssh runs
+// in batch mode: hosts must be reachable without a password prompt.
+type SSHRunner struct {
+	// Host is the destination, [user@]hostname or an alias from
+	// ~/.ssh/config.
+	Host string
+	// Port and User override the destination's, if set.
+	Port int
+	User string
+	// Jump lists the jump hosts to go through, in order, as for ssh -J.
+	Jump []string
+	// Identity is a private key file to use.
+	Identity string
+	// Timeout bounds each command, including connecting. Zero means
+	// DefaultSSHTimeout.
+	Timeout time.Duration
+}
+
+// DefaultSSHTimeout is how long an SSHRunner waits for a command.
+const DefaultSSHTimeout = 15 * time.Second
+
+// Run runs a command on the host.
+func (s SSHRunner) Run(name string, args ...string) ([]byte, error) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSSHTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "ssh", s.args(timeout, name, args...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err == nil {
+		return out, nil
+	}
+	var exitErr *exec.ExitError
+	msg := strings.TrimSpace(stderr.String())
+	switch {
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == sshFailed:
+		// ssh itself failed, such as to connect or log in; its "Permission
+		// denied" is no failure of the command.
+		return out, fmt.Errorf("ssh %s: %s: %w", s.Host, msg, errSSH)
+	case msg != "":
+		// Keep the exit status, which tells a command that wasn't found.
+		return out, fmt.Errorf("ssh %s: %s (%w)", s.Host, msg, err)
+	}
+	return out, fmt.Errorf("ssh %s: %w", s.Host, err)
+}
+
+// sshFailed is the exit status of ssh when it fails itself rather than the
+// command it ran.
+const sshFailed = 255
+
+// errSSH marks the errors of ssh failing itself, which say nothing of the
+// command.
+var errSSH = errors.New("connection failed")
+
+// args returns the arguments of the ssh command running name with args on
+// the host. The remote shell sees the command as one quoted string.
+func (s SSHRunner) args(timeout time.Duration, name string, args ...string) []string {
+	argv := []string{
+		"-o", "BatchMode=yes",
+		"-o", "ConnectTimeout=" + strconv.Itoa(max(1, int(timeout.Seconds()))),
+	}
+	if s.Port > 0 {
+		argv = append(argv, "-p", strconv.Itoa(s.Port))
+	}
+	if s.User != "" {
+		argv = append(argv, "-l", s.User)
+	}
+	if len(s.Jump) > 0 {
+		argv = append(argv, "-J", strings.Join(s.Jump, ","))
+	}
+	if s.Identity != "" {
+		argv = append(argv, "-i", s.Identity)
+	}
+
+	words := []string{shellQuote(name)}
+	for _, a := range args {
+		words = append(words, shellQuote(a))
+	}
+	// LC_ALL=C keeps ps dates and ss columns in the format the parsers
+	// expect, and TZ=UTC makes the start times ps prints unambiguous.
+	return append(argv, "--", s.Host, "LC_ALL=C TZ=UTC "+strings.Join(words, " "))
+}
+
+// shellQuote quotes s for a POSIX shell, leaving plain words alone.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./,=:%+@", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
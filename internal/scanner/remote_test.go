@@ -0,0 +1,181 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRunner answers commands from canned output keyed by the command
+// name, and records the commands it was asked to run.
+type fakeRunner struct {
+	out   map[string]string
+	calls []string
+}
+
+func (f *fakeRunner) Run(name string, args ...string) ([]byte, error) {
+	f.calls = append(f.calls, name+" "+strings.Join(args, " "))
+	out, ok := f.out[name]
+	if !ok {
+		return nil, errors.New("command not found")
+	}
+	return []byte(out), nil
+}
+
+func TestRemoteScanLinux(t *testing.T) {
+	run := &fakeRunner{out: map[string]string{
+		"ss": `Netid State  Recv-Q Send-Q Local Address:Port  Peer Address:Port Process
+tcp   LISTEN 0      244    0.0.0.0:5432          0.0.0.0:*     users:(("postgres",pid=812,fd=6))
+tcp   LISTEN 0      4096   [::]:5432             [::]:*        users:(("postgres",pid=812,fd=7))
+tcp   LISTEN 0      511    0.0.0.0:80            0.0.0.0:*     users:(("nginx",pid=901,fd=6))
+`,
+		"ps": `  812 postgres  0.3  1.2  52000 Thu Jan  2 15:04:05 2025 /usr/lib/postgresql/16/bin/postgres -D /var/lib/postgresql
+`,
+	}}
+	r, err := NewRemote(run, "linux")
+	if err != nil {
+		t.Fatalf("NewRemote: %v", err)
+	}
+	ports, err := r.Scan()
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(ports) != 3 {
+		t.Fatalf("expected 3 listeners, got %+v", ports)
+	}
+
+	pg := ports[0]
+	if pg.User != "postgres" || pg.CPU != 0.3 || pg.RSS != 52000*1024 || !strings.HasPrefix(pg.Command, "/usr/lib/postgresql") {
+		t.Errorf("postgres: got %+v", pg)
+	}
+	if want := time.Date(2025, 1, 2, 15, 4, 5, 0, time.UTC); !pg.StartTime.Equal(want) {
+		t.Errorf("start time: got %v, want %v", pg.StartTime, want)
+	}
+	if ports[1].Address != "::" || ports[1].User != "postgres" {
+		t.Errorf("postgres on IPv6: got %+v", ports[1])
+	}
+	if ports[2].ProcessName != "nginx" || ports[2].User != "" {
+		t.Errorf("a process missing from ps keeps what ss knows, got %+v", ports[2])
+	}
+
+	if len(run.calls) != 2 || run.calls[1] != "ps -o pid=,user=,%cpu=,%mem=,rss=,lstart=,command= -p 812,901" {
+		t.Errorf("expected one batched ps, got %q", run.calls)
+	}
+}
+
+func TestRemoteScanDarwin(t *testing.T) {
+	run := &fakeRunner{out: map[string]string{
+		"/usr/sbin/lsof": `COMMAND     PID   USER   FD   TYPE             DEVICE SIZE/OFF NODE NAME
+node      12345   mike    5u  IPv6 0x5678      0t0  TCP [::1]:3000 (LISTEN)
+`,
+	}}
+	r, _ := NewRemote(run, "darwin")
+	ports, err := r.Scan()
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(ports) != 1 || ports[0].Port != 3000 || ports[0].User != "mike" {
+		t.Errorf("got %+v", ports)
+	}
+	if r.Backend() != "lsof" {
+		t.Errorf("backend: got %q", r.Backend())
+	}
+}
+
+func TestRemoteScanFailure(t *testing.T) {
+	r, _ := NewRemote(&fakeRunner{}, "linux")
+	if _, err := r.Scan(); err == nil || !strings.Contains(err.Error(), "running ss") {
+		t.Errorf("got %v", err)
+	}
+	if _, err := NewRemote(&fakeRunner{}, "windows"); err == nil {
+		t.Error("expected an unsupported OS error")
+	}
+}
+
+func TestRemoteScanResult(t *testing.T) {
+	// A regular user on a host without ps: ss hides root's sshd.
+	run := &fakeRunner{out: map[string]string{
+		"ss": `Netid State  Recv-Q Send-Q Local Address:Port  Peer Address:Port Process
+tcp   LISTEN 0      128    0.0.0.0:22            0.0.0.0:*
+tcp   LISTEN 0      511    127.0.0.1:3000        0.0.0.0:*     users:(("node",pid=4821,fd=23))
+`,
+	}}
+	r, _ := NewRemote(run, "linux")
+	res, err := ScanResult(r)
+	if err != nil {
+		t.Fatalf("ScanResult: %v", err)
+	}
+	if len(res.Ports) != 2 || res.Unowned() != 1 {
+		t.Errorf("got %+v", res.Ports)
+	}
+	if len(res.Failed) != 1 || res.Failed[0].Backend != "ps" || !errors.Is(res.Failed[0].Err, ErrBackendMissing) {
+		t.Errorf("failed: got %+v", res.Failed)
+	}
+}
+
+// fakeSSH puts an ssh on the PATH that prints stderr and exits with code,
+// like the real one does for a failing command or connection.
+func fakeSSH(t *testing.T, stderr string, code int) {
+	t.Helper()
+	dir := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\necho %s >&2\nexit %d\n", shellQuote(stderr), code)
+	if err := os.WriteFile(filepath.Join(dir, "ssh"), []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+}
+
+func TestRemoteScanErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		code   int
+		want   error
+	}{
+		{"ss not installed", "bash: line 1: ss: command not found", 127, ErrBackendMissing},
+		{"ss not allowed", "Cannot open netlink socket: Operation not permitted", 1, ErrPermission},
+		{"login refused", "ops@db1: Permission denied (publickey).", 255, errSSH},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fakeSSH(t, tt.stderr, tt.code)
+			r, _ := NewRemote(SSHRunner{Host: "db1"}, "linux")
+			_, err := r.Scan()
+			if !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+			if tt.want == errSSH && (errors.Is(err, ErrPermission) || !strings.Contains(err.Error(), "publickey")) {
+				t.Errorf("a failed login should keep ssh's message and not read as a permission error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSSHRunnerArgs(t *testing.T) {
+	s := SSHRunner{Host: "db1", Port: 2222, User: "ops", Jump: []string{"bastion", "ops@inner"}, Identity: "/keys/id_ed25519"}
+	got := strings.Join(s.args(10*time.Second, "ps", "-o", "pid=,user=", "-p", "1,2"), " ")
+	want := "-o BatchMode=yes -o ConnectTimeout=10 -p 2222 -l ops -J bastion,ops@inner -i /keys/id_ed25519 -- db1 LC_ALL=C TZ=UTC ps -o pid=,user= -p 1,2"
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	for in, want := range map[string]string{
+		"-tulnp":   "-tulnp",
+		"%cpu=":    "%cpu=",
+		"":         "''",
+		"a b":      "'a b'",
+		"it's":     `'it'\''s'`,
+		"$(id)":    "'$(id)'",
+		"sTCP:LIS": "sTCP:LIS",
+	} {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q): got %s, want %s", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package scanner
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// The errors a scan fails with, wrapped in the details. Check for them with
+// errors.Is.
+var (
+	// ErrPermission is returned when the system won't let a backend run or
+	// read what it needs. It is os.ErrPermission, so the system's own
+	// errors match it too.
+	ErrPermission = os.ErrPermission
+	// ErrBackendMissing is returned when a tool a scan runs, such as ss,
+	// lsof or ps, isn't installed.
+	ErrBackendMissing = errors.New("not installed")
+	// ErrParse is returned when a backend prints something other than
+	// the output it is known for, such as an unfamiliar version would.
+	ErrParse = errors.New("unrecognized output")
+)
+
+// backendError describes the failure of running a backend.
+func backendError(name string, err error) error {
+	return fmt.Errorf("running %s: %w", name, classify(err))
+}
+
+// classify returns ErrBackendMissing or ErrPermission when a backend
+// failed for either reason, and err otherwise.
+func classify(err error) error {
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, errSSH):
+		return err
+	case errors.Is(err, exec.ErrNotFound):
+		return ErrBackendMissing
+	case errors.Is(err, ErrPermission):
+		return ErrPermission
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 127:
+		// A shell, such as the one ssh runs commands in, couldn't find it.
+		return ErrBackendMissing
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 126:
+		// The shell found it but couldn't run it.
+		return ErrPermission
+	}
+	msg := strings.ToLower(err.Error())
+	if errors.As(err, &exitErr) {
+		msg += " " + strings.ToLower(string(exitErr.Stderr))
+	}
+	switch {
+	case strings.Contains(msg, "command not found"):
+		return ErrBackendMissing
+	case strings.Contains(msg, "permission denied"), strings.Contains(msg, "operation not permitted"):
+		return ErrPermission
+	}
+	return err
+}
+
+// Result is what a scan found, and what it couldn't find out.
+type Result struct {
+	Ports []PortInfo
+	// Failed lists the backends that failed without failing the scan,
+	// leaving out the details they read, such as ps.
+	Failed []Failure
+	// Reported lists warnings a scanner passed on already worded, such as
+	// the ones an agent sends with its scan.
+	Reported []string
+}
+
+// Failure is a backend that failed during a scan.
+type Failure struct {
+	Backend string
+	Err     error
+}
+
+// Unowned counts the sockets whose process is unknown: ss and lsof leave
+// out the processes of other users unless run as root.
+func (r Result) Unowned() int {
+	n := 0
+	for _, p := range r.Ports {
+		if p.PID <= 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// Warnings describes what the scan left out, a sentence each, for the
+// envelope and the TUI. Reported warnings are returned in place of the ones
+// worked out from Ports and Failed: they describe the same scan.
+func (r Result) Warnings() []string {
+	if len(r.Reported) > 0 {
+		return r.Reported
+	}
+	var warnings []string
+	if n := r.Unowned(); n == 1 {
+		warnings = append(warnings, "1 socket has no owner information; rerun with sudo to see other users' processes")
+	} else if n > 1 {
+		warnings = append(warnings, fmt.Sprintf("%d sockets have no owner information; rerun with sudo to see other users' processes", n))
+	}
+	for _, f := range r.Failed {
+		w := fmt.Sprintf("%s failed: %v", f.Backend, f.Err)
+		if errors.Is(f.Err, ErrPermission) {
+			w += "; rerun with sudo"
+		}
+		warnings = append(warnings, w)
+	}
+	return warnings
+}
+
+// ResultScanner is implemented by scanners that report the backends that
+// failed during a scan.
+type ResultScanner interface {
+	// ScanResult scans like Scan and tells what went missing.
+	ScanResult() (Result, error)
+}
+
+// ScanResult scans with s. The result of scanners that don't report their
+// failures only has the ports.
+func ScanResult(s Scanner) (Result, error) {
+	if rs, ok := s.(ResultScanner); ok {
+		return rs.ScanResult()
+	}
+	ports, err := s.Scan()
+	return Result{Ports: ports}, err
+}
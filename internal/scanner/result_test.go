@@ -0,0 +1,89 @@
+package scanner
+
+import (
+	"errors"
+	"os/exec"
+	"slices"
+	"testing"
+)
+
+func TestScanErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rec  Recording
+		want error
+	}{
+		{"ss missing", Recording{OS: "linux"}, ErrBackendMissing},
+		{"ss denied", Recording{OS: "linux", Commands: []RecordedCommand{
+			{Args: []string{"ss", "-tulnp"}, Err: "Cannot open netlink socket: Operation not permitted"},
+		}}, ErrPermission},
+		{"not ss", Recording{OS: "linux", Commands: []RecordedCommand{
+			{Args: []string{"ss", "-tulnp"}, Output: "Usage: ss [ OPTIONS ]\n"},
+		}}, ErrParse},
+		{"not lsof", Recording{OS: "darwin", Commands: []RecordedCommand{
+			{Args: []string{"/usr/sbin/lsof", "-iTCP", "-iUDP", "-nP", "-sTCP:LISTEN"}, Output: "lsof: unsupported option\n"},
+		}}, ErrParse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := NewWith(NewReplay(&tt.rec), tt.rec.OS)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Scan(); !errors.Is(err, tt.want) {
+				t.Errorf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanResultWarnings(t *testing.T) {
+	// Run as a regular user: the first socket is someone else's, and ps
+	// isn't installed.
+	rec := &Recording{OS: "linux", Commands: []RecordedCommand{{
+		Args: []string{"ss", "-tulnp"},
+		Output: "Netid State  Recv-Q Send-Q Local Address:Port Peer Address:Port Process\n" +
+			"tcp   LISTEN 0      128        0.0.0.0:22        0.0.0.0:*\n" +
+			"tcp   LISTEN 0      511      127.0.0.1:3000      0.0.0.0:*    users:((\"node\",pid=4821,fd=23))\n",
+	}}}
+	s, err := NewWith(NewReplay(rec), "linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := ScanResult(s)
+	if err != nil {
+		t.Fatalf("ScanResult: %v", err)
+	}
+	if res.Unowned() != 1 {
+		t.Errorf("unowned: got %d, want 1", res.Unowned())
+	}
+	want := []string{
+		"1 socket has no owner information; rerun with sudo to see other users' processes",
+		"ps failed: not installed",
+	}
+	if got := res.Warnings(); !slices.Equal(got, want) {
+		t.Errorf("warnings:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{&exec.Error{Name: "ss", Err: exec.ErrNotFound}, ErrBackendMissing},
+		{errors.New("ssh vm1: bash: line 1: ss: command not found"), ErrBackendMissing},
+		{errors.New("lsof: Permission denied"), ErrPermission},
+		{errors.New("exit status 2"), nil},
+	}
+	for _, tt := range tests {
+		got := classify(tt.err)
+		if tt.want == nil {
+			if got != tt.err {
+				t.Errorf("%v: got %v, want it unchanged", tt.err, got)
+			}
+		} else if !errors.Is(got, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
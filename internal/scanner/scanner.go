@@ -1,8 +1,10 @@
 package scanner
 
 import (
+	"errors"
 	"fmt"
-	"os/exec"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -14,11 +16,101 @@ type Scanner interface {
 	Scan() ([]PortInfo, error)
 }
 
-// New creates a platform-appropriate Scanner.
-// Implemented in platform-specific files (darwin.go, linux.go).
+// New creates a Scanner for this machine.
+func New() (Scanner, error) {
+	return NewWith(Local, runtime.GOOS)
+}
+
+// NewWith creates a Scanner reading sys, a machine running goos: ss and
+// /proc on "linux", lsof on "darwin".
+func NewWith(sys System, goos string) (Scanner, error) {
+	switch goos {
+	case "linux":
+		return &linuxScanner{sys: sys}, nil
+	case "darwin":
+		return &darwinScanner{sys: sys}, nil
+	}
+	return nil, fmt.Errorf("unsupported OS %q (available: linux, darwin)", goos)
+}
+
+// platform reads the process details that are found differently on each
+// operating system.
+type platform interface {
+	// platformProcessStats fills in what ps doesn't report.
+	platformProcessStats(pid int, s *processStats)
+	// workingDirs returns the working directory of each process it can.
+	workingDirs(pids []int) map[int]string
+}
+
+// Backend returns the name of the tool a scanner reads ports from, such as
+// "ss" or "lsof", or "unknown" if the scanner doesn't say.
+func Backend(s Scanner) string {
+	if b, ok := s.(interface{ Backend() string }); ok {
+		return b.Backend()
+	}
+	return "unknown"
+}
+
+// ConnectionCounter is implemented by scanners that can count established
+// TCP connections.
+type ConnectionCounter interface {
+	// Connections returns the number of established connections per local
+	// port.
+	Connections() (map[int]int, error)
+}
+
+// TrafficCounter is implemented by scanners that can read the byte and
+// packet counters of established TCP connections.
+type TrafficCounter interface {
+	// Traffic returns the counters of every established TCP connection.
+	Traffic() ([]ConnTraffic, error)
+}
+
+// ConnTraffic holds the counters of one TCP connection since it opened,
+// from the point of view of its local end.
+type ConnTraffic struct {
+	LocalPort  int
+	Local      string // local address:port
+	Peer       string // peer address:port
+	BytesIn    uint64
+	BytesOut   uint64
+	PacketsIn  uint64
+	PacketsOut uint64
+}
 
-// enrichWithProcessStats augments port entries with CPU, memory, and command info from ps.
-func enrichWithProcessStats(ports []PortInfo) {
+// HostPort identifies a port on one host; the local machine has an empty
+// Host.
+type HostPort struct {
+	Host string
+	Port int
+}
+
+// Conflicts reports the ports that more than one process on the same host
+// is bound to.
+func Conflicts(ports []PortInfo) map[HostPort]bool {
+	portPIDs := make(map[HostPort]map[int]bool)
+	for _, p := range ports {
+		key := HostPort{p.Host, p.Port}
+		if _, ok := portPIDs[key]; !ok {
+			portPIDs[key] = make(map[int]bool)
+		}
+		portPIDs[key][p.PID] = true
+	}
+
+	conflicts := make(map[HostPort]bool)
+	for key, pids := range portPIDs {
+		if len(pids) > 1 {
+			conflicts[key] = true
+		}
+	}
+	return conflicts
+}
+
+// enrichWithProcessStats augments port entries with CPU, memory, and command
+// info from ps, and with the working directory and project of each process.
+// It reports ps failing when it isn't installed or isn't allowed to run;
+// processes that exit before ps gets to them aren't a failure.
+func enrichWithProcessStats(sys System, plat platform, ports []PortInfo) []Failure {
 	pids := make(map[int]bool)
 	for _, p := range ports {
 		if p.PID > 0 {
@@ -26,18 +118,42 @@ func enrichWithProcessStats(ports []PortInfo) {
 		}
 	}
 	if len(pids) == 0 {
-		return
+		return nil
 	}
 
-	stats := make(map[int]processStats)
+	pidList := make([]int, 0, len(pids))
 	for pid := range pids {
-		s, err := getProcessStats(pid)
+		pidList = append(pidList, pid)
+	}
+	sort.Ints(pidList)
+
+	var failed []Failure
+	stats := make(map[int]processStats)
+	for _, pid := range pidList {
+		s, err := getProcessStats(sys, pid)
 		if err == nil {
+			plat.platformProcessStats(pid, &s)
 			stats[pid] = s
+			continue
+		}
+		if err := classify(err); failed == nil && (errors.Is(err, ErrBackendMissing) || errors.Is(err, ErrPermission)) {
+			failed = append(failed, Failure{Backend: "ps", Err: err})
 		}
 	}
+	dirs := plat.workingDirs(pidList)
+	projects := make(map[string]project)
 
 	for i := range ports {
+		if dir := dirs[ports[i].PID]; dir != "" {
+			proj, ok := projects[dir]
+			if !ok {
+				proj = detectProject(sys, dir)
+				projects[dir] = proj
+			}
+			ports[i].WorkingDir = dir
+			ports[i].Project = proj.name
+			ports[i].Branch = proj.branch
+		}
 		if s, ok := stats[ports[i].PID]; ok {
 			ports[i].CPU = s.cpu
 			ports[i].Mem = s.mem
@@ -45,19 +161,30 @@ func enrichWithProcessStats(ports []PortInfo) {
 				ports[i].Command = s.command
 			}
 			ports[i].StartTime = s.startTime
+			ports[i].RSS = s.rss
+			ports[i].Threads = s.threads
+			ports[i].Container = s.container
+			ports[i].Unit = s.unit
+			ports[i].UserUnit = s.userUnit
 		}
 	}
+	return failed
 }
 
 type processStats struct {
 	cpu       float64
 	mem       float64
+	rss       int64
 	command   string
 	startTime time.Time
+	threads   int
+	container string
+	unit      string
+	userUnit  bool
 }
 
-func getProcessStats(pid int) (processStats, error) {
-	out, err := exec.Command("ps", "-p", strconv.Itoa(pid), "-o", "%cpu,%mem,lstart,command").Output()
+func getProcessStats(sys System, pid int) (processStats, error) {
+	out, err := sys.Run("ps", "-p", strconv.Itoa(pid), "-o", "%cpu,%mem,rss,lstart,command")
 	if err != nil {
 		return processStats{}, fmt.Errorf("ps for pid %d: %w", pid, err)
 	}
@@ -68,17 +195,17 @@ func getProcessStats(pid int) (processStats, error) {
 	}
 
 	// The output line looks like:
-	//  0.0  0.1 Thu Jan  2 15:04:05 2025 /usr/bin/some-command --flag
+	//  0.0  0.1  10240 Thu Jan  2 15:04:05 2025 /usr/bin/some-command --flag
 	line := strings.TrimSpace(lines[1])
 	return parseProcessStats(line)
 }
 
-// parseProcessStats parses a single line of `ps -o %cpu,%mem,lstart,command` output.
+// parseProcessStats parses a single line of `ps -o %cpu,%mem,rss,lstart,command` output.
 func parseProcessStats(line string) (processStats, error) {
 	var s processStats
 
 	fields := strings.Fields(line)
-	if len(fields) < 7 {
+	if len(fields) < 8 {
 		return s, fmt.Errorf("too few fields in ps output: %q", line)
 	}
 
@@ -94,18 +221,94 @@ func parseProcessStats(line string) (processStats, error) {
 	}
 	s.mem = mem
 
-	// lstart format: "Day Mon DD HH:MM:SS YYYY" (5 fields starting at index 2)
-	if len(fields) >= 7 {
-		timeStr := strings.Join(fields[2:7], " ")
-		t, err := time.Parse("Mon Jan 2 15:04:05 2006", timeStr)
-		if err == nil {
-			s.startTime = t
-		}
+	// rss is reported in KiB
+	rss, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return s, fmt.Errorf("parsing rss: %w", err)
+	}
+	s.rss = rss * 1024
+
+	// lstart format: "Day Mon DD HH:MM:SS YYYY" (5 fields starting at index 3)
+	timeStr := strings.Join(fields[3:8], " ")
+	if t, err := time.Parse("Mon Jan 2 15:04:05 2006", timeStr); err == nil {
+		s.startTime = t
 	}
 
-	if len(fields) > 7 {
-		s.command = strings.Join(fields[7:], " ")
+	if len(fields) > 8 {
+		s.command = strings.Join(fields[8:], " ")
 	}
 
 	return s, nil
 }
+
+// parseHostFromAddr extracts the bind address from a "host:port" field as
+// printed by ss or lsof, e.g. "127.0.0.1:80" → "127.0.0.1", "[::1]:3000" →
+// "::1" and "127.0.0.53%lo:53" → "127.0.0.53".
+func parseHostFromAddr(addr string) string {
+	idx := strings.LastIndex(addr, ":")
+	if idx < 0 {
+		return ""
+	}
+	host := strings.Trim(addr[:idx], "[]")
+	if i := strings.Index(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+	return host
+}
+
+// parseContainerID finds a container ID in the contents of
+// /proc/<pid>/cgroup and returns it shortened to 12 characters, the way
+// docker ps shows it. Docker, containerd, CRI-O and podman all name the
+// cgroup after the 64-character hex ID.
+func parseContainerID(cgroup string) string {
+	for _, line := range strings.Split(cgroup, "\n") {
+		for _, seg := range strings.FieldsFunc(line, func(r rune) bool {
+			return r == '/' || r == '-' || r == ':' || r == '.'
+		}) {
+			if len(seg) == 64 && isHex(seg) {
+				return seg[:12]
+			}
+		}
+	}
+	return ""
+}
+
+// parseSystemdUnit finds the systemd service a process belongs to in the
+// contents of /proc/<pid>/cgroup, e.g. "nginx.service" for
+// 0::/system.slice/nginx.service. user reports a unit of a user's service
+// manager (systemctl --user). Processes in scopes, such as login sessions,
+// containers and apps started from a desktop, have no unit.
+func parseSystemdUnit(cgroup string) (unit string, user bool) {
+	for _, line := range strings.Split(cgroup, "\n") {
+		// Prefer the unified hierarchy, or the systemd one on cgroup v1.
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 || (parts[0] != "0" && parts[1] != "name=systemd") {
+			continue
+		}
+		segs := strings.Split(parts[2], "/")
+		last := segs[len(segs)-1]
+		if !strings.HasSuffix(last, ".service") {
+			return "", false
+		}
+		if strings.HasPrefix(last, "user@") {
+			// The user's service manager itself.
+			return "", false
+		}
+		for _, seg := range segs {
+			if strings.HasPrefix(seg, "user@") && strings.HasSuffix(seg, ".service") {
+				return last, true
+			}
+		}
+		return last, false
+	}
+	return "", false
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
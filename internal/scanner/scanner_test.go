@@ -1,12 +1,15 @@
 package scanner
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
 
 func TestParseProcessStats(t *testing.T) {
-	line := "1.5 0.8 Thu Feb 19 04:00:00 2026 /usr/local/bin/node server.js"
+	line := "1.5 0.8 20480 Thu Feb 19 04:00:00 2026 /usr/local/bin/node server.js"
 	s, err := parseProcessStats(line)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
@@ -17,6 +20,9 @@ func TestParseProcessStats(t *testing.T) {
 	if s.mem != 0.8 {
 		t.Errorf("mem: got %f, want 0.8", s.mem)
 	}
+	if s.rss != 20480*1024 {
+		t.Errorf("rss: got %d, want %d", s.rss, 20480*1024)
+	}
 	expected := time.Date(2026, 2, 19, 4, 0, 0, 0, time.UTC)
 	if !s.startTime.Equal(expected) {
 		t.Errorf("startTime: got %v, want %v", s.startTime, expected)
@@ -32,3 +38,121 @@ func TestParseProcessStatsTooFewFields(t *testing.T) {
 		t.Error("expected error for too few fields")
 	}
 }
+
+func TestParseHostFromAddr(t *testing.T) {
+	tests := map[string]string{
+		"0.0.0.0:22":        "0.0.0.0",
+		"127.0.0.1:5432":    "127.0.0.1",
+		"[::1]:3000":        "::1",
+		"[::]:80":           "::",
+		"*:5353":            "*",
+		"127.0.0.53%lo:53":  "127.0.0.53",
+		"[fe80::1%eth0]:67": "fe80::1",
+		"noport":            "",
+	}
+	for addr, want := range tests {
+		if got := parseHostFromAddr(addr); got != want {
+			t.Errorf("parseHostFromAddr(%q): got %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestParseContainerID(t *testing.T) {
+	id := strings.Repeat("0123456789abcdef", 4)
+	tests := []struct {
+		name   string
+		cgroup string
+		want   string
+	}{
+		{"docker v1", "12:pids:/docker/" + id + "\n", id[:12]},
+		{"systemd scope", "0::/system.slice/docker-" + id + ".scope\n", id[:12]},
+		{"kubepods", "0::/kubepods.slice/kubepods-pod1.slice/cri-containerd-" + id + ".scope", id[:12]},
+		{"host process", "0::/user.slice/user-1000.slice/session-2.scope\n", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := parseContainerID(tt.cgroup); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseSystemdUnit(t *testing.T) {
+	tests := []struct {
+		fixture string
+		unit    string
+		user    bool
+	}{
+		{"system-service", "nginx.service", false},
+		{"template-service", "postgresql@16-main.service", false},
+		{"user-service", "vite-dev.service", true},
+		{"v1-hybrid", "redis-server.service", false},
+		{"session", "", false},
+		{"user-app-scope", "", false},
+		{"docker", "", false},
+		{"init", "", false},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(filepath.Join("testdata", "cgroup", tt.fixture))
+		if err != nil {
+			t.Fatal(err)
+		}
+		unit, user := parseSystemdUnit(string(data))
+		if unit != tt.unit || user != tt.user {
+			t.Errorf("%s: got %q (user %v), want %q (user %v)", tt.fixture, unit, user, tt.unit, tt.user)
+		}
+	}
+}
+
+func TestParseServices(t *testing.T) {
+	input := `# Network services
+ssh             22/tcp                          # SSH Remote Login Protocol
+http            80/tcp          www             # WorldWideWeb HTTP
+www-alt         80/tcp
+domain          53/udp
+`
+	svc := parseServices(strings.NewReader(input))
+	if svc["22/tcp"] != "ssh" || svc["53/udp"] != "domain" {
+		t.Errorf("parseServices: got %v", svc)
+	}
+	if svc["80/tcp"] != "http" {
+		t.Errorf("first name should win for 80/tcp, got %q", svc["80/tcp"])
+	}
+	if _, ok := svc["53/tcp"]; ok {
+		t.Error("unexpected entry for 53/tcp")
+	}
+}
+
+func TestConflicts(t *testing.T) {
+	ports := []PortInfo{
+		{Port: 3000, PID: 1},
+		{Port: 3000, PID: 2},
+		{Port: 5432, PID: 3},
+		{Port: 5432, PID: 3, Protocol: "UDP"},
+		{Port: 8080, PID: 4},
+		{Port: 8080, PID: 5, Host: "vm1"}, // same port, another machine
+		{Port: 9090, PID: 6, Host: "vm1"},
+		{Port: 9090, PID: 7, Host: "vm1"},
+	}
+	got := Conflicts(ports)
+	if !got[HostPort{"", 3000}] || !got[HostPort{"vm1", 9090}] || got[HostPort{"", 9090}] || len(got) != 2 {
+		t.Errorf("Conflicts: got %v, want 3000 and 9090 on vm1", got)
+	}
+}
+
+type fakeScanner struct{ backend string }
+
+func (fakeScanner) Scan() ([]PortInfo, error) { return nil, nil }
+
+type namedScanner struct{ fakeScanner }
+
+func (n namedScanner) Backend() string { return n.backend }
+
+func TestBackend(t *testing.T) {
+	if got := Backend(fakeScanner{}); got != "unknown" {
+		t.Errorf("scanner without Backend: got %q, want unknown", got)
+	}
+	if got := Backend(namedScanner{fakeScanner{backend: "ss"}}); got != "ss" {
+		t.Errorf("got %q, want ss", got)
+	}
+}
@@ -0,0 +1,68 @@
+package scanner
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// commonServices covers well-known ports when /etc/services is missing.
+var commonServices = map[string]string{
+	"22/tcp":    "ssh",
+	"53/tcp":    "domain",
+	"53/udp":    "domain",
+	"80/tcp":    "http",
+	"443/tcp":   "https",
+	"3306/tcp":  "mysql",
+	"5353/udp":  "mdns",
+	"5432/tcp":  "postgresql",
+	"6379/tcp":  "redis",
+	"27017/tcp": "mongodb",
+}
+
+var (
+	servicesOnce sync.Once
+	services     map[string]string
+)
+
+// ServiceName returns the well-known service name for a port, such as
+// "https" for 443/TCP, or "" if there is none. Names come from
+// /etc/services, falling back to a short built-in list.
+func ServiceName(port int, protocol string) string {
+	servicesOnce.Do(func() {
+		services = commonServices
+		f, err := os.Open("/etc/services")
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		if parsed := parseServices(f); len(parsed) > 0 {
+			services = parsed
+		}
+	})
+	return services[strconv.Itoa(port)+"/"+strings.ToLower(protocol)]
+}
+
+// parseServices parses an /etc/services file into a map keyed by
+// "port/protocol". The first name listed for a port wins.
+// Example line:
+// http            80/tcp          www             # WorldWideWeb HTTP
+func parseServices(r io.Reader) map[string]string {
+	result := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		key := strings.ToLower(fields[1])
+		if _, ok := result[key]; !ok {
+			result[key] = fields[0]
+		}
+	}
+	return result
+}
@@ -0,0 +1,166 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseSSOutput parses the output of `ss -tulnp`.
+// Example lines:
+// Netid  State   Recv-Q  Send-Q   Local Address:Port   Peer Address:Port  Process
+// tcp    LISTEN  0       128      0.0.0.0:22            0.0.0.0:*          users:(("sshd",pid=1234,fd=3))
+// udp    UNCONN  0       0        0.0.0.0:5353          0.0.0.0:*          users:(("avahi-daemon",pid=567,fd=12))
+func parseSSOutput(output string) ([]PortInfo, error) {
+	var ports []PortInfo
+	seen := make(map[string]bool)
+
+	// Output without a header or a single socket isn't from ss.
+	recognized := false
+	firstLine := ""
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if firstLine == "" {
+			firstLine = strings.TrimSpace(line)
+		}
+		if len(fields) > 0 && fields[0] == "Netid" {
+			recognized = true
+		}
+		if len(fields) < 5 {
+			continue
+		}
+
+		// Skip header
+		proto := strings.ToUpper(fields[0])
+		if proto != "TCP" && proto != "UDP" {
+			continue
+		}
+		recognized = true
+
+		state := fields[1]
+		if proto == "UDP" && state == "UNCONN" {
+			state = "LISTEN"
+		}
+
+		// Local address is field 4
+		localAddr := fields[4]
+		port, err := parsePortFromAddr(localAddr)
+		if err != nil {
+			continue
+		}
+
+		// Parse process info from the last field if it contains
+		// users:(...). A socket shared by forked workers lists each of
+		// them.
+		owners := []ssProcess{{}}
+		for _, f := range fields[5:] {
+			if strings.HasPrefix(f, "users:") || strings.Contains(f, "pid=") {
+				if procs := parseSSProcesses(f); len(procs) > 0 {
+					owners = procs
+				}
+			}
+		}
+
+		address := parseHostFromAddr(localAddr)
+		for _, owner := range owners {
+			key := fmt.Sprintf("%s:%d:%s:%d", address, port, proto, owner.pid)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			info := PortInfo{
+				Port:        port,
+				Protocol:    proto,
+				Address:     address,
+				PID:         owner.pid,
+				ProcessName: owner.name,
+				State:       state,
+			}
+			// For TCP listeners Recv-Q is the accept queue and Send-Q the
+			// backlog; for UDP they count bytes, which say nothing of load.
+			if proto == "TCP" {
+				info.RecvQ, _ = strconv.Atoi(fields[2])
+				info.SendQ, _ = strconv.Atoi(fields[3])
+			}
+
+			ports = append(ports, info)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !recognized && firstLine != "" {
+		return nil, fmt.Errorf("%w: %q", ErrParse, firstLine)
+	}
+	return ports, nil
+}
+
+// ssProcess is a process holding a socket, as ss shows it.
+type ssProcess struct {
+	pid  int
+	name string
+}
+
+// parseSSProcesses extracts the PIDs and process names from an ss process
+// field.
+// Input format: users:(("nginx",pid=1235,fd=6),("nginx",pid=1234,fd=6))
+func parseSSProcesses(field string) []ssProcess {
+	var procs []ssProcess
+	for _, entry := range strings.Split(field, "),(") {
+		pid, name := parseSSProcess(entry)
+		if pid > 0 || name != "" {
+			procs = append(procs, ssProcess{pid: pid, name: name})
+		}
+	}
+	return procs
+}
+
+// parseSSProcess extracts PID and process name from one process of an ss
+// process field, such as users:(("sshd",pid=1234,fd=3)).
+func parseSSProcess(field string) (int, string) {
+	// Extract process name
+	var name string
+	if start := strings.Index(field, "\""); start >= 0 {
+		rest := field[start+1:]
+		if end := strings.Index(rest, "\""); end >= 0 {
+			name = rest[:end]
+		}
+	}
+
+	// Extract PID
+	var pid int
+	if pidIdx := strings.Index(field, "pid="); pidIdx >= 0 {
+		rest := field[pidIdx+4:]
+		if end := strings.IndexAny(rest, ",)"); end >= 0 {
+			rest = rest[:end]
+		}
+		pid, _ = strconv.Atoi(rest)
+	}
+
+	return pid, name
+}
+
+// parsePortFromAddr extracts the port number from an ss or lsof address
+// field. Handles formats like: 0.0.0.0:22, [::]:80, *:5353, [::1]:443
+func parsePortFromAddr(addr string) (int, error) {
+	idx := strings.LastIndex(addr, ":")
+	if idx < 0 {
+		return 0, fmt.Errorf("no port in address: %s", addr)
+	}
+	portStr := addr[idx+1:]
+	// ss may show * for wildcard
+	if portStr == "*" {
+		return 0, fmt.Errorf("wildcard port")
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return 0, fmt.Errorf("parsing port %q: %w", portStr, err)
+	}
+	return port, nil
+}
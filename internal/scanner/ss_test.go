@@ -0,0 +1,81 @@
+package scanner
+
+import "testing"
+
+func TestParseSSOutputAddress(t *testing.T) {
+	input := `Netid State  Recv-Q Send-Q Local Address:Port  Peer Address:Port Process
+tcp   LISTEN 0      4096   127.0.0.53%lo:53      0.0.0.0:*
+tcp   LISTEN 0      128    [::]:8080             [::]:*
+`
+	ports, err := parseSSOutput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ports) != 2 {
+		t.Fatalf("expected 2 ports, got %d", len(ports))
+	}
+	if ports[0].Address != "127.0.0.53" || ports[1].Address != "::" {
+		t.Errorf("addresses: got %q, %q", ports[0].Address, ports[1].Address)
+	}
+}
+
+func TestParseSSOutputQueues(t *testing.T) {
+	input := `Netid State  Recv-Q Send-Q Local Address:Port  Peer Address:Port Process
+tcp   LISTEN 97     100    0.0.0.0:8080          0.0.0.0:*
+udp   UNCONN 2048   0      0.0.0.0:5353          0.0.0.0:*
+`
+	ports, err := parseSSOutput(input)
+	if err != nil || len(ports) != 2 {
+		t.Fatalf("got %v, %v", ports, err)
+	}
+	if ports[0].RecvQ != 97 || ports[0].SendQ != 100 {
+		t.Errorf("tcp queues: got %d/%d, want 97/100", ports[0].RecvQ, ports[0].SendQ)
+	}
+	if got := ports[0].Saturation(); got != 0.97 {
+		t.Errorf("saturation: got %v, want 0.97", got)
+	}
+	if ports[1].RecvQ != 0 || ports[1].Saturation() != 0 {
+		t.Errorf("udp byte counts should be left out, got %+v", ports[1])
+	}
+}
+
+func TestParseSSOutputWorkers(t *testing.T) {
+	input := `Netid State  Recv-Q Send-Q Local Address:Port  Peer Address:Port Process
+tcp   LISTEN 0      511    0.0.0.0:80            0.0.0.0:*    users:(("nginx",pid=813,fd=6),("nginx",pid=812,fd=6))
+tcp   LISTEN 0      511    [::]:80               [::]:*       users:(("nginx",pid=813,fd=7),("nginx",pid=812,fd=7))
+tcp   LISTEN 0      128    127.0.0.1:5432        0.0.0.0:*
+`
+	ports, err := parseSSOutput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ports) != 5 {
+		t.Fatalf("expected a port for each worker on each address and one without an owner, got %+v", ports)
+	}
+	want := []struct {
+		address string
+		pid     int
+	}{{"0.0.0.0", 813}, {"0.0.0.0", 812}, {"::", 813}, {"::", 812}, {"127.0.0.1", 0}}
+	for i, w := range want {
+		if ports[i].Address != w.address || ports[i].PID != w.pid {
+			t.Errorf("[%d] got %s PID %d, want %s PID %d", i, ports[i].Address, ports[i].PID, w.address, w.pid)
+		}
+	}
+	if ports[1].ProcessName != "nginx" || ports[4].ProcessName != "" {
+		t.Errorf("process names: got %q, %q", ports[1].ProcessName, ports[4].ProcessName)
+	}
+}
+
+func TestParseSSOutputAddresses(t *testing.T) {
+	input := `Netid State  Recv-Q Send-Q Local Address:Port  Peer Address:Port Process
+tcp   LISTEN 0      128    10.0.0.5:8080         0.0.0.0:*    users:(("api",pid=4000,fd=6))
+tcp   LISTEN 0      128    192.168.1.5:8080      0.0.0.0:*    users:(("api",pid=4000,fd=7))
+`
+	ports, err := parseSSOutput(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ports) != 2 || ports[0].Address != "10.0.0.5" || ports[1].Address != "192.168.1.5" {
+		t.Fatalf("expected a port for each address of the process, got %+v", ports)
+	}
+}
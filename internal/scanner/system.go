@@ -0,0 +1,43 @@
+package scanner
+
+import (
+	"io/fs"
+	"os"
+	"os/exec"
+)
+
+// System is the machine a scanner reads: the commands it runs and the files
+// it looks at. Scanners read this one, Local, unless given another, such as
+// a Recorder capturing a scan or a Replay of a recorded one.
+type System interface {
+	Runner
+	// ReadFile, ReadDir, Readlink and Stat are as in package os, and
+	// LookPath as in os/exec.
+	ReadFile(name string) ([]byte, error)
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Readlink(name string) (string, error)
+	Stat(name string) (fs.FileInfo, error)
+	LookPath(file string) (string, error)
+}
+
+// Local is this machine.
+var Local System = localSystem{}
+
+type localSystem struct{}
+
+// Run runs a command and returns its standard output. Commands that exit
+// non-zero may still have printed something useful, so the output is
+// returned along with the error.
+func (localSystem) Run(name string, args ...string) ([]byte, error) {
+	return exec.Command(name, args...).Output()
+}
+
+func (localSystem) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+
+func (localSystem) ReadDir(name string) ([]fs.DirEntry, error) { return os.ReadDir(name) }
+
+func (localSystem) Readlink(name string) (string, error) { return os.Readlink(name) }
+
+func (localSystem) Stat(name string) (fs.FileInfo, error) { return os.Stat(name) }
+
+func (localSystem) LookPath(file string) (string, error) { return exec.LookPath(file) }
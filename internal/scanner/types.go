@@ -4,28 +4,46 @@ import "time"
 
 // PortInfo holds information about a listening port and its associated process.
 type PortInfo struct {
-	Port        int       `json:"port"`
-	Protocol    string    `json:"protocol"`
-	PID         int       `json:"pid"`
-	ProcessName string    `json:"process_name"`
-	User        string    `json:"user"`
-	State       string    `json:"state"`
-	Command     string    `json:"command"`
-	CPU         float64   `json:"cpu_percent"`
-	Mem         float64   `json:"mem_percent"`
-	StartTime   time.Time `json:"start_time"`
+	Port        int       `json:"port" yaml:"port"`
+	Protocol    string    `json:"protocol" yaml:"protocol"`
+	Address     string    `json:"address,omitempty" yaml:"address,omitempty"`
+	PID         int       `json:"pid" yaml:"pid"`
+	ProcessName string    `json:"process_name" yaml:"process_name"`
+	User        string    `json:"user" yaml:"user"`
+	State       string    `json:"state" yaml:"state"`
+	Command     string    `json:"command" yaml:"command"`
+	CPU         float64   `json:"cpu_percent" yaml:"cpu_percent"`
+	Mem         float64   `json:"mem_percent" yaml:"mem_percent"`
+	StartTime   time.Time `json:"start_time,omitzero" yaml:"start_time,omitempty"`
+	RSS         int64     `json:"rss_bytes,omitempty" yaml:"rss_bytes,omitempty"`
+	Threads     int       `json:"threads,omitempty" yaml:"threads,omitempty"`
+	Container   string    `json:"container,omitempty" yaml:"container,omitempty"`
+	WorkingDir  string    `json:"working_dir,omitempty" yaml:"working_dir,omitempty"`
+	Project     string    `json:"project,omitempty" yaml:"project,omitempty"`
+	Branch      string    `json:"branch,omitempty" yaml:"branch,omitempty"`
+	Unit        string    `json:"unit,omitempty" yaml:"unit,omitempty"`
+	UserUnit    bool      `json:"user_unit,omitempty" yaml:"user_unit,omitempty"`
+	// RecvQ and SendQ are the accept queue length and the backlog of a TCP
+	// listener.
+	RecvQ int `json:"recv_q,omitempty" yaml:"recv_q,omitempty"`
+	SendQ int `json:"send_q,omitempty" yaml:"send_q,omitempty"`
+	// The traffic rates of the connections to a TCP listener's port, per
+	// second. A single scan can't measure them; they are filled in by
+	// callers that compare scans, such as the TUI and the metrics exporter.
+	RxBytes   float64 `json:"rx_bytes_per_sec,omitempty" yaml:"rx_bytes_per_sec,omitempty"`
+	TxBytes   float64 `json:"tx_bytes_per_sec,omitempty" yaml:"tx_bytes_per_sec,omitempty"`
+	RxPackets float64 `json:"rx_packets_per_sec,omitempty" yaml:"rx_packets_per_sec,omitempty"`
+	TxPackets float64 `json:"tx_packets_per_sec,omitempty" yaml:"tx_packets_per_sec,omitempty"`
+	// Host names the machine a listener was scanned on when ports from
+	// several machines are combined. It is empty for the local machine.
+	Host string `json:"host,omitempty" yaml:"host,omitempty"`
 }
 
-// ProcessInfo holds detailed information about a process.
-type ProcessInfo struct {
-	PID         int       `json:"pid"`
-	Name        string    `json:"name"`
-	User        string    `json:"user"`
-	Command     string    `json:"command"`
-	CPU         float64   `json:"cpu_percent"`
-	Mem         float64   `json:"mem_percent"`
-	StartTime   time.Time `json:"start_time"`
-	ParentPID   int       `json:"parent_pid"`
-	NumThreads  int       `json:"num_threads"`
-	WorkingDir  string    `json:"working_dir"`
+// Saturation returns how full the accept queue of a TCP listener is, as a
+// share of its backlog, or zero when the backlog is unknown.
+func (p PortInfo) Saturation() float64 {
+	if p.Protocol != "TCP" || p.SendQ <= 0 {
+		return 0
+	}
+	return float64(p.RecvQ) / float64(p.SendQ)
 }
@@ -0,0 +1,97 @@
+// Package systemd controls the systemd units that listeners belong to, so
+// stopping a service doesn't just get its process restarted by systemd.
+package systemd
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// Verbs are the systemctl commands portpilot runs on units.
+const (
+	Stop    = "stop"
+	Restart = "restart"
+	Status  = "status"
+)
+
+// ErrNotManaged is returned for a listener that isn't part of a systemd
+// service.
+var ErrNotManaged = errors.New("not managed by systemd")
+
+// Unit is a systemd service and the listeners that belong to it.
+type Unit struct {
+	Name  string
+	User  bool // a unit of the user's service manager (systemctl --user)
+	Ports []scanner.PortInfo
+}
+
+// String returns the unit name, marked when it is a user unit.
+func (u Unit) String() string {
+	if u.User {
+		return u.Name + " (user)"
+	}
+	return u.Name
+}
+
+// Units collects the units of the given listeners, in name order, and
+// returns the listeners that don't belong to one separately.
+func Units(ports []scanner.PortInfo) (units []Unit, unmanaged []scanner.PortInfo) {
+	index := make(map[string]int)
+	for _, p := range ports {
+		if p.Unit == "" {
+			unmanaged = append(unmanaged, p)
+			continue
+		}
+		key := fmt.Sprintf("%s/%v", p.Unit, p.UserUnit)
+		i, ok := index[key]
+		if !ok {
+			i = len(units)
+			index[key] = i
+			units = append(units, Unit{Name: p.Unit, User: p.UserUnit})
+		}
+		units[i].Ports = append(units[i].Ports, p)
+	}
+	sort.SliceStable(units, func(i, j int) bool { return units[i].Name < units[j].Name })
+	return units, unmanaged
+}
+
+// Command returns the systemctl command that runs verb on the unit.
+func Command(verb string, u Unit) *exec.Cmd {
+	return exec.Command("systemctl", Args(verb, u)...)
+}
+
+// Args returns the systemctl arguments that run verb on the unit.
+func Args(verb string, u Unit) []string {
+	var args []string
+	if u.User {
+		args = append(args, "--user")
+	}
+	if verb == Status {
+		args = append(args, "--no-pager")
+	}
+	return append(args, verb, u.Name)
+}
+
+// Run runs verb on the unit and returns systemctl's combined output. A
+// failure includes the output, which says why.
+func Run(verb string, u Unit) (string, error) {
+	out, err := Command(verb, u).CombinedOutput()
+	output := strings.TrimSpace(string(out))
+	if err != nil {
+		if output != "" {
+			return output, fmt.Errorf("systemctl %s %s: %s", verb, u.Name, firstLine(output))
+		}
+		return output, fmt.Errorf("systemctl %s %s: %w", verb, u.Name, err)
+	}
+	return output, nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -0,0 +1,50 @@
+package systemd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+func TestUnits(t *testing.T) {
+	ports := []scanner.PortInfo{
+		{Port: 80, PID: 10, Unit: "nginx.service"},
+		{Port: 443, PID: 10, Unit: "nginx.service"},
+		{Port: 3000, PID: 100, ProcessName: "node"},
+		{Port: 5173, PID: 200, Unit: "vite-dev.service", UserUnit: true},
+		{Port: 5432, PID: 300, Unit: "postgresql@16-main.service"},
+	}
+	units, unmanaged := Units(ports)
+
+	var names []string
+	for _, u := range units {
+		names = append(names, u.String())
+	}
+	if got := strings.Join(names, ","); got != "nginx.service,postgresql@16-main.service,vite-dev.service (user)" {
+		t.Errorf("units: got %s", got)
+	}
+	if len(units[0].Ports) != 2 {
+		t.Errorf("nginx should own both of its ports, got %v", units[0].Ports)
+	}
+	if len(unmanaged) != 1 || unmanaged[0].Port != 3000 {
+		t.Errorf("unmanaged: got %v", unmanaged)
+	}
+}
+
+func TestArgs(t *testing.T) {
+	tests := []struct {
+		verb string
+		unit Unit
+		want string
+	}{
+		{Stop, Unit{Name: "nginx.service"}, "stop nginx.service"},
+		{Restart, Unit{Name: "vite-dev.service", User: true}, "--user restart vite-dev.service"},
+		{Status, Unit{Name: "nginx.service"}, "--no-pager status nginx.service"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(Args(tt.verb, tt.unit), " "); got != tt.want {
+			t.Errorf("Args(%s, %v): got %q, want %q", tt.verb, tt.unit, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Package traffic turns the byte and packet counters of TCP connections,
+// read at successive scans, into per-listener rates.
+package traffic
+
+import (
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// Rate is the traffic of a port per second.
+type Rate struct {
+	BytesIn    float64
+	BytesOut   float64
+	PacketsIn  float64
+	PacketsOut float64
+}
+
+// Meter remembers the counters of the previous sample. It is not safe for
+// concurrent use.
+type Meter struct {
+	prev map[string]scanner.ConnTraffic
+	at   time.Time
+}
+
+// NewMeter returns a Meter with no previous sample.
+func NewMeter() *Meter {
+	return &Meter{}
+}
+
+// Update records the counters read at a scan and returns the rates per
+// local port since the previous one, or nil for the first sample.
+//
+// Counters only exist while a connection is open, so a connection that
+// opened since the previous sample counts in full and the last traffic
+// of one that closed is missed.
+func (m *Meter) Update(conns []scanner.ConnTraffic, at time.Time) map[int]Rate {
+	next := make(map[string]scanner.ConnTraffic, len(conns))
+	for _, c := range conns {
+		next[c.Local+"/"+c.Peer] = c
+	}
+	prev, prevAt := m.prev, m.at
+	m.prev, m.at = next, at
+
+	elapsed := at.Sub(prevAt).Seconds()
+	if prev == nil || elapsed <= 0 {
+		return nil
+	}
+
+	totals := make(map[int]scanner.ConnTraffic)
+	for key, c := range next {
+		d := c
+		if p, ok := prev[key]; ok && c.BytesIn >= p.BytesIn && c.BytesOut >= p.BytesOut {
+			d.BytesIn -= p.BytesIn
+			d.BytesOut -= p.BytesOut
+			d.PacketsIn -= min(p.PacketsIn, c.PacketsIn)
+			d.PacketsOut -= min(p.PacketsOut, c.PacketsOut)
+		}
+		t := totals[c.LocalPort]
+		t.BytesIn += d.BytesIn
+		t.BytesOut += d.BytesOut
+		t.PacketsIn += d.PacketsIn
+		t.PacketsOut += d.PacketsOut
+		totals[c.LocalPort] = t
+	}
+
+	rates := make(map[int]Rate, len(totals))
+	for port, t := range totals {
+		rates[port] = Rate{
+			BytesIn:    float64(t.BytesIn) / elapsed,
+			BytesOut:   float64(t.BytesOut) / elapsed,
+			PacketsIn:  float64(t.PacketsIn) / elapsed,
+			PacketsOut: float64(t.PacketsOut) / elapsed,
+		}
+	}
+	return rates
+}
+
+// Apply sets the traffic rates of the TCP listeners among ports. Ports
+// without traffic are reset to zero.
+func Apply(ports []scanner.PortInfo, rates map[int]Rate) {
+	for i := range ports {
+		p := &ports[i]
+		if p.Protocol != "TCP" {
+			continue
+		}
+		r := rates[p.Port]
+		p.RxBytes, p.TxBytes = r.BytesIn, r.BytesOut
+		p.RxPackets, p.TxPackets = r.PacketsIn, r.PacketsOut
+	}
+}
@@ -0,0 +1,66 @@
+package traffic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+func conn(peer string, in, out, pktsIn, pktsOut uint64) scanner.ConnTraffic {
+	return scanner.ConnTraffic{LocalPort: 5432, Local: "127.0.0.1:5432", Peer: peer,
+		BytesIn: in, BytesOut: out, PacketsIn: pktsIn, PacketsOut: pktsOut}
+}
+
+func TestMeterRates(t *testing.T) {
+	m := NewMeter()
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	if rates := m.Update([]scanner.ConnTraffic{conn("10.0.0.9:1", 1000, 5000, 10, 20)}, start); rates != nil {
+		t.Fatalf("first sample should have no rates, got %v", rates)
+	}
+
+	rates := m.Update([]scanner.ConnTraffic{
+		conn("10.0.0.9:1", 3000, 9000, 30, 40), // +2000/+4000 over 2s
+		conn("10.0.0.9:2", 400, 0, 4, 0),       // opened since, counted in full
+	}, start.Add(2*time.Second))
+	want := Rate{BytesIn: 1200, BytesOut: 2000, PacketsIn: 12, PacketsOut: 10}
+	if rates[5432] != want {
+		t.Errorf("got %+v, want %+v", rates[5432], want)
+	}
+
+	// The first connection closed; the second sent nothing more.
+	rates = m.Update([]scanner.ConnTraffic{conn("10.0.0.9:2", 400, 0, 4, 0)}, start.Add(4*time.Second))
+	if rates[5432] != (Rate{}) {
+		t.Errorf("idle port: got %+v", rates[5432])
+	}
+}
+
+func TestMeterReusedTuple(t *testing.T) {
+	m := NewMeter()
+	start := time.Unix(0, 0)
+	m.Update([]scanner.ConnTraffic{conn("10.0.0.9:1", 9000, 9000, 90, 90)}, start)
+	// Same address pair, new connection with smaller counters.
+	rates := m.Update([]scanner.ConnTraffic{conn("10.0.0.9:1", 100, 50, 1, 1)}, start.Add(time.Second))
+	if got := rates[5432]; got.BytesIn != 100 || got.BytesOut != 50 {
+		t.Errorf("a new connection should count from zero, got %+v", got)
+	}
+}
+
+func TestApply(t *testing.T) {
+	ports := []scanner.PortInfo{
+		{Port: 5432, Protocol: "TCP", RxBytes: 7},
+		{Port: 5353, Protocol: "UDP"},
+		{Port: 80, Protocol: "TCP", RxBytes: 99},
+	}
+	Apply(ports, map[int]Rate{5432: {BytesIn: 10, BytesOut: 20, PacketsIn: 1, PacketsOut: 2}, 5353: {BytesIn: 5}})
+	if p := ports[0]; p.RxBytes != 10 || p.TxBytes != 20 || p.RxPackets != 1 || p.TxPackets != 2 {
+		t.Errorf("tcp: got %+v", p)
+	}
+	if ports[1].RxBytes != 0 {
+		t.Error("UDP ports have no connections to measure")
+	}
+	if ports[2].RxBytes != 0 {
+		t.Error("a port without traffic should be reset")
+	}
+}
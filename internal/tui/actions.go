@@ -0,0 +1,291 @@
+package tui
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/AbdullahTarakji/portpilot/internal/output"
+	"github.com/AbdullahTarakji/portpilot/internal/probe"
+	"github.com/AbdullahTarakji/portpilot/internal/process"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+	"github.com/AbdullahTarakji/portpilot/internal/systemd"
+)
+
+// killSignals are the signals offered by the kill confirmation dialog, in
+// the order Tab cycles through them.
+var killSignals = []string{"SIGTERM", "SIGINT", "SIGHUP", "SIGKILL"}
+
+// cycleSignal steps through killSignals from the current signal. A signal
+// picked from the full list that isn't in killSignals steps to either end.
+func cycleSignal(current string, step int) string {
+	idx := -1
+	for i, s := range killSignals {
+		if s == current {
+			idx = i
+			break
+		}
+	}
+	if idx < 0 {
+		if step > 0 {
+			return killSignals[0]
+		}
+		return killSignals[len(killSignals)-1]
+	}
+	return killSignals[(idx+step+len(killSignals))%len(killSignals)]
+}
+
+// portKey identifies a listener across rescans.
+type portKey struct {
+	host     string
+	address  string
+	port     int
+	protocol string
+	pid      int
+}
+
+func keyOf(p scanner.PortInfo) portKey {
+	return portKey{host: p.Host, address: p.Address, port: p.Port, protocol: p.Protocol, pid: p.PID}
+}
+
+// remoteOnlyMsg explains why an action did nothing: the processes behind
+// the listeners of other hosts are out of reach from here.
+const remoteOnlyMsg = "Listeners on other hosts can only be viewed"
+
+// hasRemote reports whether any of ports is on another host.
+func hasRemote(ports []scanner.PortInfo) bool {
+	for _, p := range ports {
+		if p.Host != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// actionResult records the outcome of a bulk action for one target.
+type actionResult struct {
+	port scanner.PortInfo
+	err  error
+}
+
+// rowTargets returns the ports an action on the given row applies to. For a
+// group header that is every port in the bucket, labelled with the group.
+func rowTargets(row tableRow) ([]scanner.PortInfo, string) {
+	if row.isHeader() {
+		return row.bucket.ports, "group " + row.group
+	}
+	return []scanner.PortInfo{row.port}, ""
+}
+
+// killTargets sends sig to every distinct process among the targets.
+func killTargets(targets []scanner.PortInfo, sig string) []actionResult {
+	s, err := process.ParseSignal(sig)
+	if err != nil {
+		results := make([]actionResult, len(targets))
+		for i, p := range targets {
+			results[i] = actionResult{port: p, err: err}
+		}
+		return results
+	}
+	return signalTargets(targets, func(pid int) error { return process.Kill(pid, s) })
+}
+
+// signalTargets calls send once for every distinct process among the
+// targets. Ports sharing a PID all report that single outcome.
+func signalTargets(targets []scanner.PortInfo, send func(pid int) error) []actionResult {
+	sent := make(map[int]error)
+	results := make([]actionResult, 0, len(targets))
+	for _, p := range targets {
+		if p.PID <= 0 {
+			results = append(results, actionResult{port: p, err: fmt.Errorf("no PID for port %d", p.Port)})
+			continue
+		}
+		err, ok := sent[p.PID]
+		if !ok {
+			err = send(p.PID)
+			sent[p.PID] = err
+		}
+		results = append(results, actionResult{port: p, err: err})
+	}
+	return results
+}
+
+// killStatus summarizes kill results for the status bar, listing the outcome
+// for each target.
+func killStatus(results []actionResult, sig string) string {
+	if len(results) == 1 {
+		r := results[0]
+		if r.err != nil {
+			return fmt.Sprintf("Failed to kill PID %d: %v", r.port.PID, r.err)
+		}
+		if sig == killSignals[0] {
+			return fmt.Sprintf("Killed PID %d (%s) on port %d", r.port.PID, r.port.ProcessName, r.port.Port)
+		}
+		return fmt.Sprintf("Sent %s to PID %d (%s) on port %d", sig, r.port.PID, r.port.ProcessName, r.port.Port)
+	}
+
+	return summarizeResults(sig, results)
+}
+
+// summarizeResults reports how many targets succeeded followed by the
+// outcome for each one.
+func summarizeResults(name string, results []actionResult) string {
+	var ok int
+	parts := make([]string, 0, len(results))
+	for _, r := range results {
+		if r.err != nil {
+			parts = append(parts, fmt.Sprintf("%d ✗ (%v)", r.port.Port, r.err))
+			continue
+		}
+		ok++
+		parts = append(parts, fmt.Sprintf("%d ✓", r.port.Port))
+	}
+	return fmt.Sprintf("%s %d/%d: %s", name, ok, len(results), strings.Join(parts, "  "))
+}
+
+// confirmKillText builds the body of the kill confirmation dialog, listing
+// every process that will be signalled.
+func confirmKillText(targets []scanner.PortInfo, label, sig string) string {
+	footer := fmt.Sprintf("Signal: %s  [tab] change\n\n  [y] Yes   [n] No", sig)
+
+	if units, _ := systemd.Units(targets); len(units) > 0 {
+		footer = fmt.Sprintf("systemd may restart %s; stop it from the action menu (a) instead.\n\n%s", units[0], footer)
+		if len(units) > 1 {
+			footer = fmt.Sprintf("systemd may restart %d units; stop them from the action menu (a) instead.\n\n%s", len(units), footer)
+		}
+	}
+
+	if len(targets) == 1 && label == "" {
+		p := targets[0]
+		return fmt.Sprintf("Kill process %q (PID %d) on port %d?\n\n%s", p.ProcessName, p.PID, p.Port, footer)
+	}
+
+	lines := []string{fmt.Sprintf("Kill %d processes (%s)?", len(targets), label), ""}
+	for _, p := range targets {
+		lines = append(lines, fmt.Sprintf("  • %q (PID %d) on port %d/%s", p.ProcessName, p.PID, p.Port, p.Protocol))
+	}
+	lines = append(lines, "", footer)
+	return strings.Join(lines, "\n")
+}
+
+// probeStatus summarizes probe results for the status bar.
+func probeStatus(label string, results []probe.Result) string {
+	if len(results) == 1 {
+		r := results[0]
+		switch {
+		case r.Skipped:
+			return fmt.Sprintf("Probe %s: skipped (%s)", label, r.Protocol)
+		case r.OK:
+			return fmt.Sprintf("Probe %s: reachable in %s", label, r.Latency.Round(time.Millisecond))
+		default:
+			return fmt.Sprintf("Probe %s: %v", label, r.Err)
+		}
+	}
+
+	ok, failed, skipped := probe.Summary(results)
+	status := fmt.Sprintf("Probe %s: %d/%d reachable", label, ok, ok+failed)
+	if skipped > 0 {
+		status += fmt.Sprintf(", %d skipped", skipped)
+	}
+	return status
+}
+
+// portList joins the distinct port numbers of the targets with commas.
+func portList(targets []scanner.PortInfo) string {
+	seen := make(map[int]bool)
+	var ports []string
+	for _, p := range targets {
+		if seen[p.Port] {
+			continue
+		}
+		seen[p.Port] = true
+		ports = append(ports, strconv.Itoa(p.Port))
+	}
+	return strings.Join(ports, ",")
+}
+
+// copiedMsg reports the outcome of copying port numbers to the clipboard.
+type copiedMsg struct {
+	text string
+	err  error
+}
+
+// exportedMsg reports the outcome of exporting ports to a file.
+type exportedMsg struct {
+	count int
+	path  string
+	err   error
+}
+
+// doCopy copies text to the clipboard in the background, since the
+// clipboard tools may take a while to start.
+func doCopy(text string) tea.Cmd {
+	return func() tea.Msg {
+		return copiedMsg{text: text, err: copyToClipboard(text)}
+	}
+}
+
+// doExport writes the targets to a file in dir in the background.
+func doExport(dir string, targets []scanner.PortInfo, meta output.Meta, now time.Time) tea.Cmd {
+	return func() tea.Msg {
+		path, err := exportPorts(dir, targets, meta, now)
+		return exportedMsg{count: len(targets), path: path, err: err}
+	}
+}
+
+// clipboardTools are tried in order by copyToClipboard.
+var clipboardTools = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+}
+
+// copyToClipboard places text on the system clipboard. It prefers a native
+// clipboard tool and falls back to an OSC 52 escape sequence, which most
+// terminals understand even over SSH.
+func copyToClipboard(text string) error {
+	for _, tool := range clipboardTools {
+		if _, err := exec.LookPath(tool[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(tool[0], tool[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		if err := cmd.Run(); err == nil {
+			return nil
+		}
+	}
+
+	if _, err := osc52.New(text).WriteTo(os.Stderr); err != nil {
+		return fmt.Errorf("writing OSC 52 sequence: %w", err)
+	}
+	return nil
+}
+
+// exportPorts writes the targets as an indented JSON envelope, the same as
+// "list --output json", to a timestamped file in dir and returns its
+// absolute path.
+func exportPorts(dir string, targets []scanner.PortInfo, meta output.Meta, now time.Time) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("resolving export directory: %w", err)
+	}
+	data, err := json.MarshalIndent(output.NewEnvelope(targets, meta), "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encoding ports: %w", err)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("portpilot-export-%s.json", now.Format("20060102-150405")))
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return "", fmt.Errorf("writing export: %w", err)
+	}
+	return path, nil
+}
@@ -2,18 +2,31 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
+	"net"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
-	"syscall"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/AbdullahTarakji/portpilot/internal/cgroup"
+	"github.com/AbdullahTarakji/portpilot/internal/columns"
 	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/events"
+	"github.com/AbdullahTarakji/portpilot/internal/fleet"
+	"github.com/AbdullahTarakji/portpilot/internal/history"
+	"github.com/AbdullahTarakji/portpilot/internal/output"
+	"github.com/AbdullahTarakji/portpilot/internal/probe"
 	"github.com/AbdullahTarakji/portpilot/internal/process"
+	"github.com/AbdullahTarakji/portpilot/internal/query"
+	"github.com/AbdullahTarakji/portpilot/internal/saturation"
 	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+	"github.com/AbdullahTarakji/portpilot/internal/traffic"
 )
 
 // viewMode tracks the current UI state.
@@ -24,48 +37,129 @@ const (
 	viewDetail
 	viewHelp
 	viewConfirmKill
+	viewMenu
+	viewColumns
+	viewHistory
 )
 
 // Model is the main bubbletea model for the TUI.
 type Model struct {
-	ports       []scanner.PortInfo
-	scanner     scanner.Scanner
-	config      *config.Config
-	width       int
-	height      int
-	cursor      int
-	sortCol     sortOrder
-	filter      string
-	filterMode  bool
-	view        viewMode
-	showGroups  bool
-	lastRefresh time.Time
-	statusMsg   string
-	err         error
-	hostname    string
+	ports         []scanner.PortInfo
+	scanner       scanner.Scanner
+	config        *config.Config
+	width         int
+	height        int
+	cursor        int
+	offset        int
+	sortCol       sortOrder
+	cols          []columns.Column
+	chooser       []chooserItem
+	chooserPos    int
+	health        map[healthKey]probe.Result
+	viewName      string
+	statePath     string
+	history       *history.Store
+	cgroups       cgroup.Reader
+	queues        *saturation.Tracker
+	meter         *traffic.Meter
+	rates         map[int]traffic.Rate
+	historyPort   int
+	historyEvents []events.Event
+	historyErr    error
+	detailPort    scanner.PortInfo
+	details       *process.Details
+	detailErr     error
+	detailTab     int
+	filter        string
+	filterMode    bool
+	view          viewMode
+	showGroups    bool
+	collapsed     map[string]bool
+	marked        map[portKey]bool
+	anchor        int
+	targets       []scanner.PortInfo
+	targetLabel   string
+	signal        string
+	menu          []menuItem
+	menuTitle     string
+	menuCursor    int
+	lastRefresh   time.Time
+	statusMsg     string
+	err           error
+	hostname      string
+	hosts         []string // the hosts of a fleet scanner, "" for this one
+	hostPick      int      // 0 shows every host, i shows hosts[i-1]
+	hostStatus    []fleet.Status
+	failed        []scanner.Failure // backends that failed in the latest scan
+	reported      []string          // warnings the latest scan passed on, such as agents'
+	awaiting      bool              // whether an awaitHosts is outstanding
 }
 
 type tickMsg time.Time
 
 type scanResultMsg struct {
-	ports []scanner.PortInfo
+	ports    []scanner.PortInfo
+	failed   []scanner.Failure
+	reported []string
+	err      error
+	statuses []fleet.Status  // per host, for a fleet scanner
+	updated  <-chan struct{} // closed when a host still scanning answers
+	awaited  bool            // sent by awaitHosts
+}
+
+type trafficMsg struct {
+	conns []scanner.ConnTraffic
+	at    time.Time
 	err   error
 }
 
+type probeResultMsg struct {
+	label   string
+	results []probe.Result
+	quiet   bool // a background health check; don't touch the status bar
+}
+
 // New creates a new TUI model.
 func New(s scanner.Scanner, cfg *config.Config) Model {
 	hostname, _ := os.Hostname()
-	return Model{
-		scanner:  s,
-		config:   cfg,
-		sortCol:  sortOrder{column: 0, asc: true},
-		hostname: hostname,
+	m := Model{
+		scanner:   s,
+		config:    cfg,
+		sortCol:   sortOrder{key: "port", asc: true},
+		cols:      defaultColumns(cfg),
+		health:    make(map[healthKey]probe.Result),
+		collapsed: make(map[string]bool),
+		marked:    make(map[portKey]bool),
+		hostname:  hostname,
+		cgroups:   cgroup.New(),
+		queues:    saturation.NewTracker(cfg.Saturation.Rule(), queueSamples),
+		meter:     traffic.NewMeter(),
+	}
+	if f, ok := s.(*fleet.Fleet); ok {
+		m.hosts = f.Hosts()
+		if host, ok := columns.Lookup("host"); ok && !columns.Has(m.cols, "host") {
+			m.cols = append([]columns.Column{host}, m.cols...)
+		}
 	}
+	return m
 }
 
 // Run starts the TUI application.
 func Run(s scanner.Scanner, cfg *config.Config) error {
 	m := New(s, cfg)
+	if path, err := config.StatePath(); err == nil {
+		m.statePath = path
+		if st, err := config.LoadState(path); err == nil {
+			if v, ok := cfg.View(st.LastView); ok {
+				m = m.applyView(v)
+			}
+		}
+	}
+	if !cfg.History.Disabled {
+		if path, err := config.HistoryPath(); err == nil {
+			m.history = history.Open(path, cfg.History.Retention())
+		}
+	}
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	_, err := p.Run()
 	return err
@@ -73,11 +167,63 @@ func Run(s scanner.Scanner, cfg *config.Config) error {
 
 func doScan(s scanner.Scanner) tea.Cmd {
 	return func() tea.Msg {
-		ports, err := s.Scan()
-		return scanResultMsg{ports: ports, err: err}
+		res, err := scanner.ScanResult(s)
+		msg := scanResultMsg{ports: res.Ports, failed: res.Failed, reported: res.Reported, err: err}
+		if f, ok := s.(*fleet.Fleet); ok {
+			msg.updated = f.Updated()
+			msg.statuses = f.Status()
+		}
+		return msg
+	}
+}
+
+// awaitHosts waits until a host of f that was still scanning answers and
+// reports what the hosts have found, so slow hosts fill in before the next
+// tick.
+func awaitHosts(f *fleet.Fleet, updated <-chan struct{}) tea.Cmd {
+	return func() tea.Msg {
+		<-updated
+		res, err := f.Latest()
+		return scanResultMsg{ports: res.Ports, failed: res.Failed, reported: res.Reported, err: err, updated: f.Updated(), statuses: f.Status(), awaited: true}
+	}
+}
+
+func doProbe(label string, ports []scanner.PortInfo) tea.Cmd {
+	return func() tea.Msg {
+		return probeResultMsg{label: label, results: probePorts(ports)}
+	}
+}
+
+// doHealthCheck probes every port in the background to fill the health
+// column.
+func doHealthCheck(ports []scanner.PortInfo) tea.Cmd {
+	return func() tea.Msg {
+		return probeResultMsg{results: probePorts(ports), quiet: true}
+	}
+}
+
+// readTraffic reads connection counters in the background to fill the
+// traffic columns.
+func readTraffic(tc scanner.TrafficCounter) tea.Cmd {
+	return func() tea.Msg {
+		conns, err := tc.Traffic()
+		return trafficMsg{conns: conns, at: time.Now(), err: err}
 	}
 }
 
+// probePorts probes the local ports among ports; those on other hosts
+// aren't reachable at the addresses the probe uses.
+func probePorts(ports []scanner.PortInfo) []probe.Result {
+	results := make([]probe.Result, 0, len(ports))
+	for _, p := range ports {
+		if p.Host != "" {
+			continue
+		}
+		results = append(results, probe.Port(p.Port, p.Protocol, probe.DefaultTimeout))
+	}
+	return results
+}
+
 func tickCmd(interval time.Duration) tea.Cmd {
 	return tea.Tick(interval, func(t time.Time) tea.Msg {
 		return tickMsg(t)
@@ -98,7 +244,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
-		return m, nil
+		return m.scrollToCursor(), nil
 
 	case tickMsg:
 		return m, tea.Batch(
@@ -107,23 +253,113 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		)
 
 	case scanResultMsg:
-		if msg.err != nil {
+		m.hostStatus = msg.statuses
+		// One waiter at a time: every tick's scan finds the slow hosts
+		// still scanning.
+		if msg.awaited {
+			m.awaiting = false
+		}
+		var await tea.Cmd
+		if f, ok := m.scanner.(*fleet.Fleet); ok && !m.awaiting && slices.ContainsFunc(msg.statuses, func(st fleet.Status) bool { return st.Scanning }) {
+			await = awaitHosts(f, msg.updated)
+			m.awaiting = true
+		}
+		var partial *fleet.Error
+		switch {
+		case msg.err == nil:
+		case errors.As(msg.err, &partial) && msg.ports != nil:
+			// Show what the hosts have; the header marks the stale ones.
+		default:
 			m.err = msg.err
 			m.statusMsg = fmt.Sprintf("Scan error: %v", msg.err)
+			return m.scrollToCursor(), await
+		}
+		m.ports = msg.ports
+		m.failed = msg.failed
+		m.reported = msg.reported
+		m.lastRefresh = time.Now()
+		m.err = nil
+		m.queues.Observe(m.ports, m.lastRefresh)
+		// Keep showing the last rates until the next sample is in.
+		traffic.Apply(m.ports, m.rates)
+		// Ensure cursor is in bounds
+		rows := m.rows()
+		if m.cursor >= len(rows) {
+			m.cursor = max(0, len(rows)-1)
+		}
+		cmds := []tea.Cmd{await}
+		// A partial scan would misrecord the unreachable hosts' listeners.
+		if m.history != nil && partial == nil {
+			cmds = append(cmds, recordHistory(m.history, m.ports, m.lastRefresh))
+		}
+		if columns.Has(m.cols, "health") {
+			cmds = append(cmds, doHealthCheck(m.ports))
+		}
+		if tc, ok := m.scanner.(scanner.TrafficCounter); ok && m.showsTraffic() {
+			cmds = append(cmds, readTraffic(tc))
+		}
+		return m.scrollToCursor(), tea.Batch(cmds...)
+
+	case trafficMsg:
+		if msg.err != nil {
+			// Rates are optional; drop them rather than show stale ones.
+			m.rates = nil
 		} else {
-			m.ports = msg.ports
-			m.lastRefresh = time.Now()
-			m.err = nil
-			// Ensure cursor is in bounds
-			filtered := filterPorts(m.ports, m.filter)
-			if m.cursor >= len(filtered) {
-				m.cursor = max(0, len(filtered)-1)
-			}
+			m.rates = m.meter.Update(msg.conns, msg.at)
+		}
+		traffic.Apply(m.ports, m.rates)
+		return m, nil
+
+	case historyRecordedMsg:
+		if msg.err != nil {
+			m.statusMsg = fmt.Sprintf("History error: %v", msg.err)
+		}
+		return m, nil
+
+	case detailMsg:
+		if m.view == viewDetail && msg.pid == m.detailPort.PID {
+			m.details, m.detailErr = msg.details, msg.err
+		}
+		return m, nil
+
+	case historyMsg:
+		if m.view == viewHistory && msg.port == m.historyPort {
+			m.historyEvents, m.historyErr = msg.events, msg.err
+		}
+		return m, nil
+
+	case probeResultMsg:
+		for _, r := range msg.results {
+			m.health[healthKey{r.Port, r.Protocol}] = r
+		}
+		if !msg.quiet {
+			m.statusMsg = probeStatus(msg.label, msg.results)
+		}
+		return m, nil
+
+	case userActionMsg:
+		m.statusMsg = userActionStatus(msg)
+		return m, doScan(m.scanner)
+
+	case copiedMsg:
+		if msg.err != nil {
+			m.statusMsg = fmt.Sprintf("Copy failed: %v", msg.err)
+		} else {
+			m.statusMsg = fmt.Sprintf("Copied %s", msg.text)
+		}
+		return m, nil
+
+	case exportedMsg:
+		if msg.err != nil {
+			m.statusMsg = fmt.Sprintf("Export failed: %v", msg.err)
+		} else {
+			m.statusMsg = fmt.Sprintf("Exported %d ports to %s", msg.count, msg.path)
 		}
 		return m, nil
 
 	case tea.KeyMsg:
-		return m.handleKey(msg)
+		updated, cmd := m.handleKey(msg)
+		return updated.(Model).scrollToCursor(), cmd
 	}
 
 	return m, nil
@@ -143,6 +379,12 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		return m.handleHelpKey(msg)
 	case viewDetail:
 		return m.handleDetailKey(msg)
+	case viewMenu:
+		return m.handleMenuKey(msg)
+	case viewColumns:
+		return m.handleChooserKey(msg)
+	case viewHistory:
+		return m.handleHistoryKey(msg)
 	default:
 		if m.filterMode {
 			return m.handleFilterKey(msg)
@@ -152,7 +394,7 @@ func (m Model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) handleTableKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	filtered := filterPorts(m.ports, m.filter)
+	rows := m.rows()
 
 	switch msg.String() {
 	case "q":
@@ -166,39 +408,169 @@ func (m Model) handleTableKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	case "r":
 		m.statusMsg = "Refreshing..."
 		return m, doScan(m.scanner)
-	case "g":
+	case "t":
 		m.showGroups = !m.showGroups
+		m.cursor = 0
 		return m, nil
-	case "k":
-		if len(filtered) > 0 && m.cursor < len(filtered) {
+	case "H":
+		if len(m.hosts) > 0 {
+			m.hostPick = (m.hostPick + 1) % (len(m.hosts) + 1)
+			m.cursor = 0
+		}
+		return m, nil
+	case "x":
+		if targets, label := m.actionTargets(); len(targets) > 0 {
+			if hasRemote(targets) {
+				m.statusMsg = remoteOnlyMsg
+				return m, nil
+			}
+			m.targets, m.targetLabel = targets, label
+			m.signal = killSignals[0]
 			m.view = viewConfirmKill
 		}
 		return m, nil
+	case "v":
+		return m.openMenu("Views", viewPicker(m.config)), nil
+	case "C":
+		return m.openChooser(), nil
+	case "h":
+		return m.openHistory()
+	case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		i := int(msg.String()[0] - '1')
+		if i < len(m.config.Views) {
+			return m.switchView(m.config.Views[i]), nil
+		}
+		return m, nil
+	case "a":
+		if targets, _ := m.actionTargets(); len(targets) > 0 {
+			if hasRemote(targets) {
+				m.statusMsg = remoteOnlyMsg
+				return m, nil
+			}
+			return m.openMenu("Actions", actionMenu(m.config, targets)), nil
+		}
+		return m, nil
+	case "p":
+		if targets, label := m.actionTargets(); len(targets) > 0 {
+			if hasRemote(targets) {
+				m.statusMsg = remoteOnlyMsg
+				return m, nil
+			}
+			if label == "" {
+				label = fmt.Sprintf("port %d", targets[0].Port)
+			}
+			m.statusMsg = fmt.Sprintf("Probing %s...", label)
+			return m, doProbe(label, targets)
+		}
+		return m, nil
+	case "c":
+		if targets, _ := m.actionTargets(); len(targets) > 0 {
+			m.statusMsg = "Copying..."
+			return m, doCopy(portList(targets))
+		}
+		return m, nil
+	case "e":
+		if targets, _ := m.actionTargets(); len(targets) > 0 {
+			meta := output.Meta{Hostname: m.hostname, ScannedAt: m.lastRefresh, Backend: scanner.Backend(m.scanner), Warnings: m.scanResult().Warnings()}
+			m.statusMsg = fmt.Sprintf("Exporting %d ports...", len(targets))
+			return m, doExport(".", targets, meta, time.Now())
+		}
+		return m, nil
 	case "enter":
-		if len(filtered) > 0 && m.cursor < len(filtered) {
-			m.view = viewDetail
+		row, ok := m.selectedRow()
+		if !ok {
+			return m, nil
+		}
+		if row.isHeader() {
+			m.collapsed[row.group] = !m.collapsed[row.group]
+			return m, nil
+		}
+		if row.port.Host != "" {
+			m.statusMsg = remoteOnlyMsg
+			return m, nil
+		}
+		return m.openDetail(row.port)
+	case " ":
+		row, ok := m.selectedRow()
+		if !ok {
+			return m, nil
+		}
+		if row.isHeader() {
+			m.collapsed[row.group] = !m.collapsed[row.group]
+			return m, nil
+		}
+		key := keyOf(row.port)
+		if m.marked[key] {
+			delete(m.marked, key)
+		} else {
+			m.marked[key] = true
+		}
+		m.anchor = m.cursor
+		return m, nil
+	case "V":
+		lo, hi := min(m.anchor, m.cursor), max(m.anchor, m.cursor)
+		for i := lo; i <= hi && i < len(rows); i++ {
+			if !rows[i].isHeader() {
+				m.marked[keyOf(rows[i].port)] = true
+			}
+		}
+		m.anchor = m.cursor
+		return m, nil
+	case "*":
+		filtered := filterPorts(m.hostPorts(), m.filter, m.config)
+		all := len(filtered) > 0
+		for _, p := range filtered {
+			if !m.marked[keyOf(p)] {
+				all = false
+				break
+			}
+		}
+		for _, p := range filtered {
+			if all {
+				delete(m.marked, keyOf(p))
+			} else {
+				m.marked[keyOf(p)] = true
+			}
 		}
 		return m, nil
-	case "up":
+	case "up", "k":
 		if m.cursor > 0 {
 			m.cursor--
 		}
 		return m, nil
 	case "down", "j":
-		if m.cursor < len(filtered)-1 {
+		if m.cursor < len(rows)-1 {
 			m.cursor++
 		}
 		return m, nil
-	case "1", "2", "3", "4", "5", "6", "7", "8":
-		col := int(msg.String()[0] - '1')
-		if m.sortCol.column == col {
+	case "pgup":
+		m.cursor = max(0, m.cursor-m.pageSize())
+		return m, nil
+	case "pgdown":
+		m.cursor = max(0, min(len(rows)-1, m.cursor+m.pageSize()))
+		return m, nil
+	case "home", "g":
+		m.cursor = 0
+		return m, nil
+	case "end", "G":
+		m.cursor = max(0, len(rows)-1)
+		return m, nil
+	case "alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6", "alt+7", "alt+8", "alt+9":
+		col := int(msg.String()[len("alt+")] - '1')
+		if col >= len(m.cols) {
+			return m, nil
+		}
+		if key := m.cols[col].Key; m.sortCol.key == key {
 			m.sortCol.asc = !m.sortCol.asc
 		} else {
-			m.sortCol.column = col
-			m.sortCol.asc = true
+			m.sortCol = sortOrder{key: key, asc: true}
 		}
 		return m, nil
 	case "esc":
+		if len(m.marked) > 0 {
+			m.marked = make(map[portKey]bool)
+			return m, nil
+		}
 		if m.filter != "" {
 			m.filter = ""
 			m.cursor = 0
@@ -233,14 +605,6 @@ func (m Model) handleFilterKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	}
 }
 
-func (m Model) handleDetailKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	switch msg.String() {
-	case "esc", "enter", "q":
-		m.view = viewTable
-	}
-	return m, nil
-}
-
 func (m Model) handleHelpKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	switch msg.String() {
 	case "?", "esc", "q":
@@ -250,28 +614,138 @@ func (m Model) handleHelpKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 }
 
 func (m Model) handleConfirmKillKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
-	filtered := filterPorts(m.ports, m.filter)
-	sorted := sortPorts(filtered, m.sortCol)
-
 	switch msg.String() {
 	case "y", "Y":
-		if m.cursor < len(sorted) {
-			p := sorted[m.cursor]
-			if err := process.Kill(p.PID, syscall.SIGTERM); err != nil {
-				m.statusMsg = fmt.Sprintf("Failed to kill PID %d: %v", p.PID, err)
-			} else {
-				m.statusMsg = fmt.Sprintf("Killed PID %d (%s) on port %d", p.PID, p.ProcessName, p.Port)
-			}
+		m.statusMsg = killStatus(killTargets(m.targets, m.signal), m.signal)
+		if m.targetLabel != "" {
+			m.marked = make(map[portKey]bool)
 		}
+		m.targets, m.targetLabel = nil, ""
 		m.view = viewTable
 		return m, doScan(m.scanner)
+	case "tab":
+		m.signal = cycleSignal(m.signal, 1)
+	case "shift+tab":
+		m.signal = cycleSignal(m.signal, -1)
 	case "n", "N", "esc":
+		m.targets, m.targetLabel = nil, ""
 		m.view = viewTable
 		m.statusMsg = "Kill cancelled"
 	}
 	return m, nil
 }
 
+// tableChrome is the number of lines around the table body besides the
+// header: the table's column header and separator, the scroll indicator and
+// the status bar.
+const tableChrome = 4
+
+// tableHeight returns how many rows fit in the table viewport, or zero if
+// the terminal size isn't known yet.
+func (m Model) tableHeight() int {
+	if m.height == 0 {
+		return 0
+	}
+	h := m.height - m.headerHeight() - tableChrome
+	if m.filterMode || m.filter != "" {
+		h--
+	}
+	return max(1, h)
+}
+
+// pageSize is how far PgUp and PgDn move the cursor.
+func (m Model) pageSize() int {
+	return max(1, m.tableHeight())
+}
+
+// scrollToCursor moves the viewport so the cursor row is visible.
+func (m Model) scrollToCursor() Model {
+	m.offset = scrollOffset(m.offset, m.cursor, m.tableHeight(), len(m.rows()))
+	return m
+}
+
+// tableView returns the table state to render for the current model.
+func (m Model) tableView() tableView {
+	return tableView{
+		rows:      m.rows(),
+		cursor:    m.cursor,
+		offset:    m.offset,
+		height:    m.tableHeight(),
+		sortCol:   m.sortCol,
+		cols:      m.cols,
+		env:       m.env(),
+		filter:    m.filter,
+		collapsed: m.collapsed,
+		marked:    m.marked,
+		cfg:       m.config,
+		width:     m.width,
+	}
+}
+
+// rows returns the table rows for the current filter, sort and grouping.
+func (m Model) rows() []tableRow {
+	return buildRows(m.hostPorts(), m.filter, m.sortCol, m.env(), m.showGroups, m.collapsed, m.config)
+}
+
+// hostPorts returns the ports on the host picked with the host switcher.
+func (m Model) hostPorts() []scanner.PortInfo {
+	if m.hostPick == 0 {
+		return m.ports
+	}
+	host := m.hosts[m.hostPick-1]
+	var result []scanner.PortInfo
+	for _, p := range m.ports {
+		if p.Host == host {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// hostLabel names a host for display.
+func hostLabel(host string) string {
+	if host == "" {
+		return "local"
+	}
+	return host
+}
+
+// markedPorts returns the marked ports that are still present, in table order.
+func (m Model) markedPorts() []scanner.PortInfo {
+	if len(m.marked) == 0 {
+		return nil
+	}
+	var result []scanner.PortInfo
+	for _, p := range columns.Sort(m.ports, m.sortCol.key, m.sortCol.asc, m.env()) {
+		if m.marked[keyOf(p)] {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+// actionTargets returns the ports a bulk action applies to: the marked set
+// if there is one, otherwise the row under the cursor. The label describes
+// the set and is empty for a single selected port.
+func (m Model) actionTargets() ([]scanner.PortInfo, string) {
+	if marked := m.markedPorts(); len(marked) > 0 {
+		return marked, fmt.Sprintf("%d marked", len(marked))
+	}
+	if row, ok := m.selectedRow(); ok {
+		return rowTargets(row)
+	}
+	return nil, ""
+}
+
+// selectedRow returns the row under the cursor.
+func (m Model) selectedRow() (tableRow, bool) {
+	rows := m.rows()
+	if m.cursor < 0 || m.cursor >= len(rows) {
+		return tableRow{}, false
+	}
+	return rows[m.cursor], true
+}
+
 // View renders the UI.
 func (m Model) View() string {
 	if m.width == 0 {
@@ -286,23 +760,25 @@ func (m Model) View() string {
 	switch m.view {
 	case viewHelp:
 		sections = append(sections, renderHelp(m.width))
+	case viewMenu:
+		sections = append(sections, renderMenu(m.menuTitle, m.menu, m.menuCursor, m.width))
+	case viewColumns:
+		sections = append(sections, renderChooser(m.chooser, m.chooserPos, m.width))
 	case viewDetail:
-		filtered := filterPorts(m.ports, m.filter)
-		sorted := sortPorts(filtered, m.sortCol)
-		if m.cursor < len(sorted) {
-			sections = append(sections, renderDetail(sorted[m.cursor].PID, m.width))
-		}
+		queue := queueHistory{samples: m.queues.Samples(m.detailPort), rule: m.queues.Rule(), saturated: m.queues.Saturated(m.detailPort)}
+		sections = append(sections, renderDetail(m.detailPort, m.details, m.detailErr, m.cgroups, queue, m.detailTab, m.width, m.height-m.headerHeight()-1))
+	case viewHistory:
+		sections = append(sections, renderHistory(m.historyPort, m.historyEvents, m.historyErr, m.width, m.height-m.headerHeight()-1))
 	case viewConfirmKill:
-		sections = append(sections, renderTable(m.ports, m.cursor, m.sortCol, m.filter, m.showGroups, m.config, m.width))
-		filtered := filterPorts(m.ports, m.filter)
-		sorted := sortPorts(filtered, m.sortCol)
-		if m.cursor < len(sorted) {
-			p := sorted[m.cursor]
-			confirm := confirmStyle.Render(fmt.Sprintf(
-				"Kill process %q (PID %d) on port %d?\n\n  [y] Yes   [n] No",
-				p.ProcessName, p.PID, p.Port,
-			))
-			sections = append(sections, confirm)
+		dialog := confirmStyle.Render(confirmKillText(m.targets, m.targetLabel, m.signal))
+		tv := m.tableView()
+		if tv.height > 0 {
+			tv.height = max(1, tv.height-lipgloss.Height(dialog))
+			tv.offset = scrollOffset(tv.offset, tv.cursor, tv.height, len(tv.rows))
+		}
+		sections = append(sections, renderTable(tv))
+		if len(m.targets) > 0 {
+			sections = append(sections, dialog)
 		}
 	default:
 		// Search bar
@@ -311,10 +787,13 @@ func (m Model) View() string {
 			if m.filterMode {
 				search += "█"
 			}
+			if _, err := query.Parse(m.filter); err != nil {
+				search += searchErrorStyle.Render("✗ " + err.Error())
+			}
 			sections = append(sections, search)
 		}
 
-		sections = append(sections, renderTable(m.ports, m.cursor, m.sortCol, m.filter, m.showGroups, m.config, m.width))
+		sections = append(sections, renderTable(m.tableView()))
 	}
 
 	// Status bar
@@ -326,13 +805,84 @@ func (m Model) View() string {
 }
 
 func (m Model) renderHeader() string {
-	filtered := filterPorts(m.ports, m.filter)
+	filtered := filterPorts(m.hostPorts(), m.filter, m.config)
 	title := titleStyle.Render("PortPilot")
-	stats := headerStyle.Render(fmt.Sprintf(
-		"%s │ %d ports │ %d shown",
-		m.hostname, len(m.ports), len(filtered),
-	))
-	return lipgloss.JoinHorizontal(lipgloss.Top, title, stats)
+	summary := fmt.Sprintf("%s │ %d ports │ %d shown", m.hostname, len(m.ports), len(filtered))
+	if len(m.hosts) > 0 {
+		host := "all hosts"
+		if m.hostPick > 0 {
+			host = "host: " + hostLabel(m.hosts[m.hostPick-1])
+		}
+		summary += " │ " + host
+	}
+	if clashes := fleet.Clashes(m.ports); len(clashes) > 0 {
+		addrs := make([]string, len(clashes))
+		for i, c := range clashes {
+			addrs[i] = net.JoinHostPort(c.Address, strconv.Itoa(c.Port)) + "/" + c.Protocol
+		}
+		summary += " │ ⚠ clash: " + strings.Join(addrs, ", ")
+	}
+	if m.viewName != "" {
+		summary += " │ view: " + m.viewName
+	}
+	if n := len(m.markedPorts()); n > 0 {
+		summary += fmt.Sprintf(" │ %d marked", n)
+	}
+	if n := len(m.queues.Flagged(m.ports)); n > 0 {
+		summary += fmt.Sprintf(" │ ⚠ %d saturated", n)
+	}
+	res := m.scanResult()
+	if n := res.Unowned(); n > 0 {
+		summary += fmt.Sprintf(" │ ⚠ %d without owner (rerun with sudo)", n)
+	}
+	for _, f := range res.Failed {
+		summary += fmt.Sprintf(" │ ⚠ %s: %v", f.Backend, f.Err)
+	}
+	stats := headerStyle.Render(summary)
+	header := lipgloss.JoinHorizontal(lipgloss.Top, title, stats)
+	if len(m.hosts) > 0 {
+		header = lipgloss.JoinVertical(lipgloss.Left, header, m.renderHostStatus())
+	}
+	return header
+}
+
+// scanResult returns the latest scan, with what it left out.
+func (m Model) scanResult() scanner.Result {
+	return scanner.Result{Ports: m.ports, Failed: m.failed, Reported: m.reported}
+}
+
+// headerHeight is the number of lines the header takes: one more for the
+// host status line when remote hosts are configured.
+func (m Model) headerHeight() int {
+	if len(m.hosts) > 0 {
+		return 2
+	}
+	return 1
+}
+
+// renderHostStatus renders how each host fared in the latest scan, with
+// its scan time, or how old its listeners are if it didn't answer.
+func (m Model) renderHostStatus() string {
+	var parts []string
+	for i, h := range m.hosts {
+		if i >= len(m.hostStatus) {
+			parts = append(parts, dimStyle.Render(hostLabel(h)+" …"))
+			continue
+		}
+		st := m.hostStatus[i]
+		switch {
+		case st.Err == nil && st.Scanning && st.Scanned.IsZero():
+			parts = append(parts, dimStyle.Render(hostLabel(h)+" …"))
+		case st.Err == nil:
+			parts = append(parts, healthyStyle.Render(fmt.Sprintf("%s ✓ %s", hostLabel(h), st.Latency.Round(time.Millisecond))))
+		case st.Stale():
+			age := columns.FormatDuration(time.Since(st.Scanned))
+			parts = append(parts, warningStyle.Render(fmt.Sprintf("%s ✗ stale %s: %s", hostLabel(h), age, truncate(st.Err.Error(), 40))))
+		default:
+			parts = append(parts, downStyle.Render(fmt.Sprintf("%s ✗ %s", hostLabel(h), truncate(st.Err.Error(), 40))))
+		}
+	}
+	return hostStatusStyle.Render(strings.Join(parts, dimStyle.Render(" │ ")))
 }
 
 func (m Model) renderStatusBar() string {
@@ -348,7 +898,7 @@ func (m Model) renderStatusBar() string {
 	hints := []string{
 		statusKeyStyle.Render("?") + " help",
 		statusKeyStyle.Render("/") + " filter",
-		statusKeyStyle.Render("k") + " kill",
+		statusKeyStyle.Render("x") + " kill",
 		statusKeyStyle.Render("q") + " quit",
 	}
 	right := strings.Join(hints, "  ")
@@ -1,13 +1,29 @@
 package tui
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"testing"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 
+	"github.com/AbdullahTarakji/portpilot/internal/cgroup"
+	"github.com/AbdullahTarakji/portpilot/internal/columns"
 	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/fleet"
+	"github.com/AbdullahTarakji/portpilot/internal/history"
+	"github.com/AbdullahTarakji/portpilot/internal/output"
+	"github.com/AbdullahTarakji/portpilot/internal/probe"
+	"github.com/AbdullahTarakji/portpilot/internal/process"
 	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+	"github.com/AbdullahTarakji/portpilot/internal/systemd"
 )
 
 // mockScanner returns canned port data for testing.
@@ -57,8 +73,8 @@ func TestNewModelInitialization(t *testing.T) {
 	if m.showGroups {
 		t.Error("showGroups should be false initially")
 	}
-	if m.sortCol.column != 0 {
-		t.Errorf("sortCol: got %d, want 0", m.sortCol.column)
+	if m.sortCol.key != "port" {
+		t.Errorf("sortCol: got %q, want port", m.sortCol.key)
 	}
 	if !m.sortCol.asc {
 		t.Error("sort should be ascending initially")
@@ -196,18 +212,18 @@ func TestCursorNavigation(t *testing.T) {
 func TestGroupToggle(t *testing.T) {
 	m := newTestModel()
 
-	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("g")})
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")})
 	m = updated.(Model)
 
 	if !m.showGroups {
-		t.Error("expected showGroups=true after pressing 'g'")
+		t.Error("expected showGroups=true after pressing 't'")
 	}
 
-	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("g")})
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("t")})
 	m = updated.(Model)
 
 	if m.showGroups {
-		t.Error("expected showGroups=false after pressing 'g' again")
+		t.Error("expected showGroups=false after pressing 't' again")
 	}
 }
 
@@ -234,8 +250,8 @@ func TestDetailViewToggle(t *testing.T) {
 func TestConfirmKillView(t *testing.T) {
 	m := newTestModel()
 
-	// Press 'k' to open kill confirmation
-	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("k")})
+	// Press 'x' to open kill confirmation
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
 	m = updated.(Model)
 
 	if m.view != viewConfirmKill {
@@ -257,24 +273,24 @@ func TestConfirmKillView(t *testing.T) {
 func TestSortColumnToggle(t *testing.T) {
 	m := newTestModel()
 
-	// Press '1' to sort by first column
-	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("1")})
+	// Press Alt+1 to sort by first column
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("1"), Alt: true})
 	m = updated.(Model)
 
-	if m.sortCol.column != 0 {
-		t.Errorf("sortCol: got %d, want 0", m.sortCol.column)
+	if m.sortCol.key != "port" {
+		t.Errorf("sortCol: got %q, want port", m.sortCol.key)
 	}
 	// It was already col 0 asc, so should flip to desc
 	if m.sortCol.asc {
 		t.Error("expected sort desc after pressing same column")
 	}
 
-	// Press '3' to sort by third column
-	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("3")})
+	// Press Alt+3 to sort by third column
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("3"), Alt: true})
 	m = updated.(Model)
 
-	if m.sortCol.column != 2 {
-		t.Errorf("sortCol: got %d, want 2", m.sortCol.column)
+	if m.sortCol.key != "pid" {
+		t.Errorf("sortCol: got %q, want pid", m.sortCol.key)
 	}
 	if !m.sortCol.asc {
 		t.Error("expected sort asc for new column")
@@ -375,9 +391,1292 @@ func TestEmptyPortsList(t *testing.T) {
 	}
 
 	// Kill on empty should not crash
-	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("k")})
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
 	m = updated.(Model)
 	if m.view != viewTable {
 		t.Error("kill on empty list should stay on table view")
 	}
 }
+
+func newGroupedTestModel() Model {
+	m := newTestModel()
+	m.config.Groups = map[string]config.Group{
+		"frontend": {Ports: []int{3000, 8080}, Color: "blue"},
+		"database": {Ports: []int{5432}, Color: "yellow"},
+	}
+	m.showGroups = true
+	return m
+}
+
+func TestGroupedRows(t *testing.T) {
+	m := newGroupedTestModel()
+	rows := m.rows()
+
+	// database header + 5432, frontend header + 3000 + 8080, Other header + 6379
+	if len(rows) != 7 {
+		t.Fatalf("rows: got %d, want 7", len(rows))
+	}
+
+	var headers []string
+	for _, r := range rows {
+		if r.isHeader() {
+			headers = append(headers, r.group)
+		}
+	}
+	want := []string{"database", "frontend", otherGroup}
+	if strings.Join(headers, ",") != strings.Join(want, ",") {
+		t.Errorf("group order: got %v, want %v", headers, want)
+	}
+
+	fe := rows[2].bucket
+	if fe == nil || fe.name != "frontend" {
+		t.Fatalf("rows[2]: expected frontend header, got %+v", rows[2])
+	}
+	if len(fe.ports) != 2 {
+		t.Errorf("frontend count: got %d, want 2", len(fe.ports))
+	}
+	if fe.cpu != 57.1 {
+		t.Errorf("frontend cpu: got %.1f, want 57.1", fe.cpu)
+	}
+}
+
+func TestGroupedRowsByProject(t *testing.T) {
+	m := newGroupedTestModel()
+	m.ports[0].Project = "shop"  // 3000, in the frontend group
+	m.ports[2].Project = "cache" // 6379
+	m.ports[3].Project = "shop"  // 8080, in the frontend group
+	rows := m.rows()
+
+	var headers []string
+	for _, r := range rows {
+		if r.isHeader() {
+			headers = append(headers, r.group)
+		}
+	}
+	// Configured groups win; ungrouped ports fall back to their project.
+	if got := strings.Join(headers, ","); got != "cache,database,frontend" {
+		t.Errorf("buckets: got %s", got)
+	}
+}
+
+func TestGroupedRowsSortWithinGroup(t *testing.T) {
+	m := newGroupedTestModel()
+	m.sortCol = sortOrder{key: "port", asc: false}
+	rows := m.rows()
+
+	// frontend bucket should list 8080 before 3000 when sorting port desc
+	if rows[3].port.Port != 8080 || rows[4].port.Port != 3000 {
+		t.Errorf("frontend order: got %d, %d, want 8080, 3000", rows[3].port.Port, rows[4].port.Port)
+	}
+	if rows[0].group != "database" {
+		t.Errorf("group order should not follow port sort, got %q first", rows[0].group)
+	}
+}
+
+func TestGroupCollapse(t *testing.T) {
+	m := newGroupedTestModel()
+	m.cursor = 2 // frontend header
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(Model)
+
+	if !m.collapsed["frontend"] {
+		t.Fatal("expected frontend to be collapsed after Enter on its header")
+	}
+	if m.view != viewTable {
+		t.Errorf("Enter on a header should not open details, got view %d", m.view)
+	}
+	if n := len(m.rows()); n != 5 {
+		t.Errorf("rows after collapse: got %d, want 5", n)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")})
+	m = updated.(Model)
+
+	if m.collapsed["frontend"] {
+		t.Error("expected frontend to expand after Space on its header")
+	}
+}
+
+func TestGroupKillTargetsBucket(t *testing.T) {
+	m := newGroupedTestModel()
+	m.cursor = 2 // frontend header
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x")})
+	m = updated.(Model)
+
+	if m.view != viewConfirmKill {
+		t.Fatalf("view: got %d, want viewConfirmKill(%d)", m.view, viewConfirmKill)
+	}
+	if len(m.targets) != 2 || m.targetLabel != "group frontend" {
+		t.Errorf("targets: got %d in %q, want 2 in group frontend", len(m.targets), m.targetLabel)
+	}
+
+	text := confirmKillText(m.targets, m.targetLabel, m.signal)
+	for _, want := range []string{"node", "Python", "group frontend"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("confirm dialog missing %q:\n%s", want, text)
+		}
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n")})
+	m = updated.(Model)
+	if m.targets != nil {
+		t.Error("targets should be cleared after cancelling")
+	}
+}
+
+func TestGroupedViewRenders(t *testing.T) {
+	m := newGroupedTestModel()
+	m.collapsed["database"] = true
+
+	output := m.View()
+	for _, want := range []string{"▶ database", "▼ frontend", "Other"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("grouped view missing %q", want)
+		}
+	}
+}
+
+func pressKey(m Model, key string) Model {
+	var msg tea.KeyMsg
+	switch key {
+	case "esc":
+		msg = tea.KeyMsg{Type: tea.KeyEsc}
+	case "enter":
+		msg = tea.KeyMsg{Type: tea.KeyEnter}
+	case "tab":
+		msg = tea.KeyMsg{Type: tea.KeyTab}
+	case "shift+tab":
+		msg = tea.KeyMsg{Type: tea.KeyShiftTab}
+	case "down":
+		msg = tea.KeyMsg{Type: tea.KeyDown}
+	case "up":
+		msg = tea.KeyMsg{Type: tea.KeyUp}
+	case " ":
+		msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}
+	case "alt+1", "alt+2", "alt+3", "alt+4", "alt+5", "alt+6":
+		msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key[len("alt+"):]), Alt: true}
+	default:
+		msg = tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+	}
+	updated, _ := m.Update(msg)
+	return updated.(Model)
+}
+
+func TestMarkToggle(t *testing.T) {
+	m := newTestModel()
+
+	m = pressKey(m, " ")
+	if len(m.markedPorts()) != 1 {
+		t.Fatalf("marked: got %d, want 1", len(m.markedPorts()))
+	}
+
+	m = pressKey(m, " ")
+	if len(m.markedPorts()) != 0 {
+		t.Errorf("marked after second space: got %d, want 0", len(m.markedPorts()))
+	}
+}
+
+func TestMarkDualStack(t *testing.T) {
+	m := newTestModel()
+	m.ports = []scanner.PortInfo{
+		{Address: "0.0.0.0", Port: 80, Protocol: "TCP", PID: 50, ProcessName: "nginx", State: "LISTEN"},
+		{Address: "::", Port: 80, Protocol: "TCP", PID: 50, ProcessName: "nginx", State: "LISTEN"},
+	}
+
+	m = pressKey(m, " ")
+	if marked := m.markedPorts(); len(marked) != 1 {
+		t.Errorf("marking one socket of a dual-stack listener: got %d marked, want 1", len(marked))
+	}
+}
+
+func TestMarkRange(t *testing.T) {
+	m := newTestModel()
+
+	m = pressKey(m, " ") // mark row 0, anchor at 0
+	m = pressKey(m, "down")
+	m = pressKey(m, "down")
+	m = pressKey(m, "V")
+
+	marked := m.markedPorts()
+	if len(marked) != 3 {
+		t.Fatalf("marked: got %d, want 3", len(marked))
+	}
+	if marked[2].Port != 6379 {
+		t.Errorf("last marked port: got %d, want 6379", marked[2].Port)
+	}
+}
+
+func TestMarkAllFiltered(t *testing.T) {
+	m := newTestModel()
+	m.filter = "node"
+
+	m = pressKey(m, "*")
+	if n := len(m.markedPorts()); n != 1 {
+		t.Fatalf("marked: got %d, want 1 (only filtered rows)", n)
+	}
+
+	m.filter = ""
+	m = pressKey(m, "*")
+	if n := len(m.markedPorts()); n != 4 {
+		t.Fatalf("marked: got %d, want 4", n)
+	}
+
+	m = pressKey(m, "*")
+	if n := len(m.markedPorts()); n != 0 {
+		t.Errorf("pressing * with everything marked should clear, got %d", n)
+	}
+}
+
+func TestEscClearsMarksBeforeFilter(t *testing.T) {
+	m := newTestModel()
+	m.filter = "o"
+	m = pressKey(m, "*")
+
+	m = pressKey(m, "esc")
+	if len(m.marked) != 0 {
+		t.Error("expected Esc to clear marks")
+	}
+	if m.filter != "o" {
+		t.Error("first Esc should keep the filter")
+	}
+
+	m = pressKey(m, "esc")
+	if m.filter != "" {
+		t.Error("second Esc should clear the filter")
+	}
+}
+
+func TestBulkKillConfirmListsMarked(t *testing.T) {
+	m := newTestModel()
+	m = pressKey(m, "*")
+	m = pressKey(m, "x")
+
+	if m.view != viewConfirmKill {
+		t.Fatalf("view: got %d, want viewConfirmKill(%d)", m.view, viewConfirmKill)
+	}
+	if len(m.targets) != 4 {
+		t.Fatalf("targets: got %d, want 4", len(m.targets))
+	}
+
+	m = pressKey(m, "tab")
+	if m.signal != "SIGINT" {
+		t.Errorf("signal after tab: got %s, want SIGINT", m.signal)
+	}
+
+	text := confirmKillText(m.targets, m.targetLabel, m.signal)
+	for _, want := range []string{"4 marked", "node", "postgres", "redis-ser", "Python", "SIGINT"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("confirm dialog missing %q:\n%s", want, text)
+		}
+	}
+
+	m = pressKey(m, "n")
+	if len(m.markedPorts()) != 4 {
+		t.Error("cancelling should keep the marks")
+	}
+}
+
+func TestKillStatusPerTarget(t *testing.T) {
+	ports := testPorts()
+	status := killStatus([]actionResult{
+		{port: ports[0]},
+		{port: ports[1], err: syscall.EPERM},
+	}, "SIGTERM")
+
+	for _, want := range []string{"1/2", "3000 ✓", "5432 ✗", "operation not permitted"} {
+		if !strings.Contains(status, want) {
+			t.Errorf("status %q missing %q", status, want)
+		}
+	}
+}
+
+func TestPortList(t *testing.T) {
+	ports := testPorts()
+	ports = append(ports, ports[0])
+	if got := portList(ports); got != "3000,5432,6379,8080" {
+		t.Errorf("portList: got %q", got)
+	}
+}
+
+func TestExportPorts(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	path, err := exportPorts(dir, testPorts()[:2], output.Meta{Hostname: "devbox", Backend: "ss"}, now)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filepath.Base(path) != "portpilot-export-20260301-120000.json" {
+		t.Errorf("path: got %s", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading export: %v", err)
+	}
+	var got output.Envelope
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("decoding export: %v", err)
+	}
+	if got.SchemaVersion != output.SchemaVersion || got.Hostname != "devbox" {
+		t.Errorf("export envelope: got version %d host %q", got.SchemaVersion, got.Hostname)
+	}
+	if len(got.Ports) != 2 || got.Ports[1].Port != 5432 {
+		t.Errorf("exported ports: got %+v", got.Ports)
+	}
+}
+
+func TestExportKeyShowsFullPath(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	m := newTestModel()
+
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")})
+	m = updated.(Model)
+	if cmd == nil || m.statusMsg != "Exporting 1 ports..." {
+		t.Fatalf("export should run in the background, got status %q", m.statusMsg)
+	}
+
+	updated, _ = m.Update(cmd())
+	m = updated.(Model)
+	matches, _ := filepath.Glob(filepath.Join(dir, "portpilot-export-*.json"))
+	if len(matches) != 1 {
+		t.Fatalf("expected one export in %s, got %v", dir, matches)
+	}
+	if want := "Exported 1 ports to " + matches[0]; m.statusMsg != want {
+		t.Errorf("status: got %q, want %q", m.statusMsg, want)
+	}
+}
+
+func TestCopiedStatus(t *testing.T) {
+	m := newTestModel()
+
+	updated, _ := m.Update(copiedMsg{text: "3000,5432"})
+	if got := updated.(Model).statusMsg; got != "Copied 3000,5432" {
+		t.Errorf("status: got %q", got)
+	}
+	updated, _ = m.Update(copiedMsg{err: errors.New("no display")})
+	if got := updated.(Model).statusMsg; got != "Copy failed: no display" {
+		t.Errorf("status: got %q", got)
+	}
+}
+
+func TestActionMenu(t *testing.T) {
+	m := newTestModel()
+	m.config.Actions = []config.Action{{Name: "Open", Command: "open http://localhost:{port}"}}
+
+	m = pressKey(m, "a")
+	if m.view != viewMenu {
+		t.Fatalf("view: got %d, want viewMenu(%d)", m.view, viewMenu)
+	}
+	if len(m.menu) != 4 || m.menu[3].label != "Run: Open" {
+		t.Errorf("menu: got %+v", m.menu)
+	}
+
+	m = pressKey(m, "esc")
+	if m.view != viewTable {
+		t.Errorf("view after esc: got %d, want viewTable", m.view)
+	}
+}
+
+func TestActionMenuUnitItems(t *testing.T) {
+	m := newTestModel()
+	m.ports[0].Unit = "node-app.service"
+
+	m = pressKey(m, "a")
+	var verbs []string
+	for _, item := range m.menu {
+		if item.kind == menuUnit {
+			verbs = append(verbs, item.verb)
+		}
+	}
+	if strings.Join(verbs, ",") != "stop,restart" {
+		t.Errorf("unit items: got %v", verbs)
+	}
+
+	m = pressKey(m, "esc")
+	m = pressKey(m, "j")
+	m = pressKey(m, "a")
+	for _, item := range m.menu {
+		if item.kind == menuUnit {
+			t.Errorf("a port outside systemd should get no unit items, got %q", item.label)
+		}
+	}
+}
+
+func TestConfirmKillWarnsAboutUnits(t *testing.T) {
+	ports := testPorts()[:1]
+	ports[0].Unit = "node-app.service"
+	if text := confirmKillText(ports, "", "SIGTERM"); !strings.Contains(text, "systemd may restart node-app.service") {
+		t.Errorf("dialog: got %q", text)
+	}
+	if text := confirmKillText(testPorts()[1:2], "", "SIGTERM"); strings.Contains(text, "systemd") {
+		t.Errorf("unmanaged dialog mentions systemd: %q", text)
+	}
+}
+
+func TestRunUnitActionUnmanaged(t *testing.T) {
+	msg := runUnitAction(systemd.Stop, testPorts()[:1])().(userActionMsg)
+	if status := userActionStatus(msg); !strings.Contains(status, "not managed by systemd") {
+		t.Errorf("status: got %q", status)
+	}
+}
+
+func TestSignalPicker(t *testing.T) {
+	m := newTestModel()
+	m = pressKey(m, "a")
+	m = pressKey(m, "enter") // Send signal…
+
+	if m.menuTitle != "Send signal" {
+		t.Fatalf("expected signal picker, got menu %q", m.menuTitle)
+	}
+	if len(m.menu) <= len(killSignals) {
+		t.Errorf("signal picker should list every signal, got %d", len(m.menu))
+	}
+
+	// SIGHUP is signal 1, the first entry
+	m = pressKey(m, "enter")
+	if m.view != viewConfirmKill {
+		t.Fatalf("view: got %d, want viewConfirmKill(%d)", m.view, viewConfirmKill)
+	}
+	if m.signal != "SIGHUP" {
+		t.Errorf("signal: got %s, want SIGHUP", m.signal)
+	}
+	if len(m.targets) != 1 || m.targets[0].Port != 3000 {
+		t.Errorf("targets: got %+v", m.targets)
+	}
+
+	m = pressKey(m, "n")
+	if m.view != viewTable {
+		t.Errorf("view after cancel: got %d, want viewTable", m.view)
+	}
+}
+
+func TestCycleSignal(t *testing.T) {
+	if got := cycleSignal("SIGKILL", 1); got != "SIGTERM" {
+		t.Errorf("cycleSignal(SIGKILL, 1): got %s", got)
+	}
+	if got := cycleSignal("SIGTERM", -1); got != "SIGKILL" {
+		t.Errorf("cycleSignal(SIGTERM, -1): got %s", got)
+	}
+	if got := cycleSignal("SIGWINCH", 1); got != "SIGTERM" {
+		t.Errorf("cycleSignal(SIGWINCH, 1): got %s", got)
+	}
+}
+
+func TestRunUserAction(t *testing.T) {
+	a := config.Action{Name: "Echo", Command: "echo port={port}"}
+	cmd := runUserAction(a, testPorts()[:1], config.DefaultConfig())
+
+	msg, ok := cmd().(userActionMsg)
+	if !ok {
+		t.Fatalf("expected userActionMsg")
+	}
+	if got := userActionStatus(msg); got != "Echo: port=3000" {
+		t.Errorf("status: got %q", got)
+	}
+}
+
+func TestRunUserActionBadTemplate(t *testing.T) {
+	a := config.Action{Name: "Logs", Command: "docker logs {nope}"}
+	msg := runUserAction(a, testPorts()[:2], config.DefaultConfig())().(userActionMsg)
+
+	status := userActionStatus(msg)
+	if !strings.Contains(status, "0/2") || !strings.Contains(status, "unknown placeholder") {
+		t.Errorf("status: got %q", status)
+	}
+}
+
+func manyPorts(n int) []scanner.PortInfo {
+	ports := make([]scanner.PortInfo, n)
+	for i := range ports {
+		ports[i] = scanner.PortInfo{Port: 3000 + i, Protocol: "TCP", PID: 1000 + i, ProcessName: "node", User: "mike", State: "LISTEN"}
+	}
+	return ports
+}
+
+func newScrollTestModel() Model {
+	m := newTestModel()
+	m.ports = manyPorts(60)
+	m.height = 25 // 20 table rows
+	return m
+}
+
+func TestVimNavigation(t *testing.T) {
+	m := newTestModel()
+
+	m = pressKey(m, "j")
+	m = pressKey(m, "j")
+	if m.cursor != 2 {
+		t.Fatalf("cursor after jj: got %d, want 2", m.cursor)
+	}
+
+	m = pressKey(m, "k")
+	if m.cursor != 1 {
+		t.Errorf("cursor after k: got %d, want 1", m.cursor)
+	}
+	if m.view != viewTable {
+		t.Errorf("k should move up, not open a dialog (view %d)", m.view)
+	}
+}
+
+func TestViewportFollowsCursor(t *testing.T) {
+	m := newScrollTestModel()
+	height := m.tableHeight()
+	if height != 20 {
+		t.Fatalf("tableHeight: got %d, want 20", height)
+	}
+
+	for i := 0; i < height; i++ {
+		m = pressKey(m, "down")
+	}
+	if m.cursor != height {
+		t.Fatalf("cursor: got %d, want %d", m.cursor, height)
+	}
+	if m.offset != 1 {
+		t.Errorf("offset after moving past the bottom: got %d, want 1", m.offset)
+	}
+
+	for i := 0; i < height; i++ {
+		m = pressKey(m, "up")
+	}
+	if m.offset != 0 {
+		t.Errorf("offset after moving back up: got %d, want 0", m.offset)
+	}
+}
+
+func TestPageUpDown(t *testing.T) {
+	m := newScrollTestModel()
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyPgDown})
+	m = updated.(Model)
+	if m.cursor != 20 {
+		t.Errorf("cursor after PgDn: got %d, want 20", m.cursor)
+	}
+	if m.cursor < m.offset || m.cursor >= m.offset+m.tableHeight() {
+		t.Errorf("cursor %d outside viewport at offset %d", m.cursor, m.offset)
+	}
+
+	for i := 0; i < 5; i++ {
+		updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyPgDown})
+		m = updated.(Model)
+	}
+	if m.cursor != 59 {
+		t.Errorf("PgDn should stop at the last row, got %d", m.cursor)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyPgUp})
+	m = updated.(Model)
+	if m.cursor != 39 {
+		t.Errorf("cursor after PgUp: got %d, want 39", m.cursor)
+	}
+}
+
+func TestHomeEndKeys(t *testing.T) {
+	m := newScrollTestModel()
+
+	m = pressKey(m, "G")
+	if m.cursor != 59 || m.offset != 40 {
+		t.Errorf("after G: cursor=%d offset=%d, want 59/40", m.cursor, m.offset)
+	}
+
+	m = pressKey(m, "g")
+	if m.cursor != 0 || m.offset != 0 {
+		t.Errorf("after g: cursor=%d offset=%d, want 0/0", m.cursor, m.offset)
+	}
+
+	updated, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnd})
+	m = updated.(Model)
+	if m.cursor != 59 {
+		t.Errorf("after End: cursor=%d, want 59", m.cursor)
+	}
+
+	updated, _ = m.Update(tea.KeyMsg{Type: tea.KeyHome})
+	m = updated.(Model)
+	if m.cursor != 0 {
+		t.Errorf("after Home: cursor=%d, want 0", m.cursor)
+	}
+}
+
+func TestViewportKeepsHeaderAndFitsScreen(t *testing.T) {
+	m := newScrollTestModel()
+	m = pressKey(m, "G")
+
+	output := m.View()
+	lines := strings.Split(output, "\n")
+	if len(lines) > m.height {
+		t.Errorf("view is %d lines, taller than the %d-line terminal", len(lines), m.height)
+	}
+	if !strings.Contains(output, "Port") {
+		t.Error("column header should stay visible when scrolled")
+	}
+	if !strings.Contains(output, "rows 41–60 of 60") {
+		t.Error("missing scroll position indicator")
+	}
+	if strings.Contains(output, "3000 ") {
+		t.Error("first row should be scrolled out of view")
+	}
+}
+
+func TestResizeKeepsCursorVisible(t *testing.T) {
+	m := newScrollTestModel()
+	m = pressKey(m, "G")
+
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 120, Height: 15})
+	m = updated.(Model)
+	if m.cursor < m.offset || m.cursor >= m.offset+m.tableHeight() {
+		t.Errorf("cursor %d outside viewport [%d, %d)", m.cursor, m.offset, m.offset+m.tableHeight())
+	}
+}
+
+func TestFilterQuery(t *testing.T) {
+	m := newTestModel()
+	m.filter = "port:3000-6000 -proc:redis"
+
+	rows := m.rows()
+	if len(rows) != 2 || rows[0].port.Port != 3000 || rows[1].port.Port != 5432 {
+		t.Errorf("rows: got %+v", rows)
+	}
+}
+
+func TestFilterBareNumberMatchesPortExactly(t *testing.T) {
+	m := newTestModel()
+	m.ports = append(m.ports, scanner.PortInfo{Port: 18080, Protocol: "TCP", PID: 500, ProcessName: "python3"})
+	m.filter = "8080"
+
+	rows := m.rows()
+	if len(rows) != 1 || rows[0].port.Port != 8080 {
+		t.Errorf("rows: got %+v", rows)
+	}
+}
+
+func TestFilterParseErrorShownInline(t *testing.T) {
+	m := newTestModel()
+	m = pressKey(m, "/")
+	for _, ch := range "cpu>" {
+		m = pressKey(m, string(ch))
+	}
+
+	output := m.View()
+	if !strings.Contains(output, "missing value for cpu") {
+		t.Error("filter bar should show the parse error")
+	}
+	if n := len(m.rows()); n != 4 {
+		t.Errorf("invalid query should not hide rows, got %d", n)
+	}
+}
+
+func newViewsTestModel(t *testing.T) Model {
+	t.Helper()
+	m := newTestModel()
+	m.config.Views = []config.View{
+		{Name: "hot", Filter: "cpu>1", Sort: "-cpu", Columns: []string{"port", "proc", "cpu"}},
+		{Name: "grouped", Grouped: true},
+	}
+	m.statePath = filepath.Join(t.TempDir(), "state.yaml")
+	return m
+}
+
+func TestApplyView(t *testing.T) {
+	m := newViewsTestModel(t)
+	m = m.applyView(m.config.Views[0])
+
+	if len(m.cols) != 3 || m.cols[2].Key != "cpu" {
+		t.Fatalf("cols: got %+v", m.cols)
+	}
+	if m.sortCol.key != "cpu" || m.sortCol.asc {
+		t.Errorf("sortCol: got %+v, want cpu descending", m.sortCol)
+	}
+	rows := m.rows()
+	if len(rows) != 2 || rows[0].port.Port != 8080 || rows[1].port.Port != 3000 {
+		t.Errorf("rows: got %+v", rows)
+	}
+
+	output := m.View()
+	if strings.Contains(output, "Proto") || !strings.Contains(output, "view: hot") {
+		t.Error("view should hide unlisted columns and show its name in the header")
+	}
+}
+
+func TestViewPickerSwitchesAndSavesState(t *testing.T) {
+	m := newViewsTestModel(t)
+
+	m = pressKey(m, "v")
+	if m.view != viewMenu || len(m.menu) != 3 {
+		t.Fatalf("expected view picker with 3 entries, got view %d with %d", m.view, len(m.menu))
+	}
+
+	m = pressKey(m, "2")
+	if m.view != viewTable || m.viewName != "grouped" || !m.showGroups {
+		t.Errorf("expected grouped view, got %q (grouped=%v)", m.viewName, m.showGroups)
+	}
+
+	st, err := config.LoadState(m.statePath)
+	if err != nil {
+		t.Fatalf("LoadState: %v", err)
+	}
+	if st.LastView != "grouped" {
+		t.Errorf("LastView: got %q, want grouped", st.LastView)
+	}
+
+	m = pressKey(m, "v")
+	m = pressKey(m, "0")
+	if m.viewName != "" || m.showGroups || len(m.cols) != 8 {
+		t.Errorf("default view should reset, got %q with %d columns", m.viewName, len(m.cols))
+	}
+}
+
+func TestNumberSwitchesView(t *testing.T) {
+	m := newViewsTestModel(t)
+
+	m = pressKey(m, "1")
+	if m.viewName != "hot" || m.filter != "cpu>1" {
+		t.Errorf("1: got view %q filter %q", m.viewName, m.filter)
+	}
+
+	m = pressKey(m, "3")
+	if m.viewName != "hot" {
+		t.Errorf("3 with two views should do nothing, got %q", m.viewName)
+	}
+
+	m = pressKey(m, "/")
+	m = pressKey(m, "2")
+	if m.viewName != "hot" || !strings.HasSuffix(m.filter, "2") {
+		t.Errorf("2 while filtering should be typed, got view %q filter %q", m.viewName, m.filter)
+	}
+}
+
+func TestSortKeysFollowVisibleColumns(t *testing.T) {
+	m := newViewsTestModel(t)
+	m = m.applyView(m.config.Views[0])
+
+	m = pressKey(m, "alt+2")
+	if m.sortCol.key != "proc" {
+		t.Errorf("alt+2 should sort by the second visible column, got %+v", m.sortCol)
+	}
+	m = pressKey(m, "alt+5")
+	if m.sortCol.key != "proc" {
+		t.Errorf("alt+5 is past the last visible column and should be ignored, got %+v", m.sortCol)
+	}
+}
+
+func TestConfiguredColumns(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Columns = []string{"port", "proc", "addr"}
+	m := New(&mockScanner{}, cfg)
+	m.ports = []scanner.PortInfo{{Port: 3000, Protocol: "TCP", ProcessName: "node", Address: "127.0.0.1"}}
+	m.width, m.height = 120, 40
+
+	output := m.View()
+	if !strings.Contains(output, "Address") || !strings.Contains(output, "127.0.0.1") {
+		t.Error("configured address column should be shown")
+	}
+	if strings.Contains(output, "Proto") {
+		t.Error("unconfigured columns should be hidden")
+	}
+}
+
+func TestColumnChooser(t *testing.T) {
+	m := newTestModel()
+
+	m = pressKey(m, "C")
+	if m.view != viewColumns {
+		t.Fatalf("expected column chooser, got view %d", m.view)
+	}
+	if len(m.chooser) != len(columns.All()) {
+		t.Fatalf("chooser: got %d entries, want %d", len(m.chooser), len(columns.All()))
+	}
+
+	// Hide proto, then move pid above port.
+	m = pressKey(m, "down")
+	m = pressKey(m, " ")
+	m = pressKey(m, "down")
+	m = pressKey(m, "K")
+	m = pressKey(m, "K")
+	if m.chooserPos != 0 || m.chooser[0].col.Key != "pid" {
+		t.Fatalf("pid should have moved to the top, got %q at %d", m.chooser[0].col.Key, m.chooserPos)
+	}
+
+	m = pressKey(m, "enter")
+	if m.view != viewTable {
+		t.Fatalf("enter should apply and close, got view %d", m.view)
+	}
+	if len(m.cols) != 7 || m.cols[0].Key != "pid" || m.cols[1].Key != "port" || columns.Has(m.cols, "proto") {
+		var keys []string
+		for _, c := range m.cols {
+			keys = append(keys, c.Key)
+		}
+		t.Errorf("cols: got %v", keys)
+	}
+}
+
+func TestColumnChooserNeedsOneColumn(t *testing.T) {
+	m := newTestModel()
+	m = pressKey(m, "C")
+	for i := range m.chooser {
+		m.chooser[i].on = false
+	}
+	m = pressKey(m, "enter")
+	if m.view != viewColumns || len(m.cols) != 8 {
+		t.Error("applying with no columns should be refused")
+	}
+
+	m = pressKey(m, "esc")
+	if m.view != viewTable || len(m.cols) != 8 {
+		t.Error("esc should close the chooser without changes")
+	}
+}
+
+func TestSortFollowsColumnAfterReorder(t *testing.T) {
+	m := newTestModel()
+	m = pressKey(m, "alt+6") // CPU%
+	if m.sortCol.key != "cpu" {
+		t.Fatalf("sortCol: got %q, want cpu", m.sortCol.key)
+	}
+
+	m.cols, _ = columns.Select([]string{"cpu", "port"})
+	rows := m.rows()
+	if rows[0].port.Port != 6379 || rows[3].port.Port != 8080 {
+		t.Errorf("rows should still be sorted by cpu, got %d first", rows[0].port.Port)
+	}
+}
+
+func TestHealthColumnFromProbeResults(t *testing.T) {
+	m := newTestModel()
+	m.cols, _ = columns.Select([]string{"port", "health"})
+
+	updated, _ := m.Update(probeResultMsg{
+		results: []probe.Result{{Port: 3000, Protocol: "TCP", OK: true}, {Port: 5432, Protocol: "TCP"}},
+		quiet:   true,
+	})
+	m = updated.(Model)
+	if m.statusMsg != "" {
+		t.Errorf("quiet probe should not set the status, got %q", m.statusMsg)
+	}
+
+	output := m.View()
+	if !strings.Contains(output, "up") || !strings.Contains(output, "down") {
+		t.Error("health column should show probe results")
+	}
+
+	_, cmd := m.Update(scanResultMsg{ports: testPorts()})
+	if cmd == nil {
+		t.Error("a visible health column should trigger a background health check after each scan")
+	}
+}
+
+func TestHistoryPanel(t *testing.T) {
+	m := newTestModel()
+	m = pressKey(m, "h")
+	if m.view != viewTable || !strings.Contains(m.statusMsg, "disabled") {
+		t.Fatalf("without a store: view %d, status %q", m.view, m.statusMsg)
+	}
+
+	m.history = history.Open(filepath.Join(t.TempDir(), "history.jsonl"), history.Retention{})
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := m.history.Record(testPorts(), t0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.history.Record(testPorts()[1:], t0.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, cmd := m.handleKey(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("h")})
+	m = updated.(Model)
+	if m.view != viewHistory || m.historyPort != 3000 || cmd == nil {
+		t.Fatalf("view %d, port %d", m.view, m.historyPort)
+	}
+	updated, _ = m.Update(cmd())
+	m = updated.(Model)
+	if len(m.historyEvents) != 2 {
+		t.Fatalf("got %d events, want 2", len(m.historyEvents))
+	}
+
+	output := m.View()
+	if !strings.Contains(output, "History of port 3000") {
+		t.Error("panel title missing")
+	}
+	if strings.Index(output, "close") > strings.Index(output, "open ") {
+		t.Error("events should be listed newest first")
+	}
+
+	m = pressKey(m, "h")
+	if m.view != viewTable {
+		t.Errorf("h should close the panel, view %d", m.view)
+	}
+}
+
+func TestScanRecordsHistory(t *testing.T) {
+	m := newTestModel()
+	m.history = history.Open(filepath.Join(t.TempDir(), "history.jsonl"), history.Retention{})
+
+	_, cmd := m.Update(scanResultMsg{ports: testPorts()})
+	if cmd == nil {
+		t.Fatal("a scan should be recorded in the history")
+	}
+	if msg, ok := cmd().(historyRecordedMsg); !ok || msg.err != nil {
+		t.Fatalf("got %#v", msg)
+	}
+	evs, err := m.history.Query(history.Filter{})
+	if err != nil || len(evs) != len(testPorts()) {
+		t.Errorf("got %d events, err %v", len(evs), err)
+	}
+}
+
+type fakeCgroups map[int]cgroup.Stats
+
+func (f fakeCgroups) Read(pid int) (cgroup.Stats, error) {
+	if s, ok := f[pid]; ok {
+		return s, nil
+	}
+	return cgroup.Stats{}, cgroup.ErrNotV2
+}
+
+func TestDetailShowsCgroup(t *testing.T) {
+	m := newTestModel()
+	self, parent := m.ports[0], m.ports[1]
+	m.cgroups = fakeCgroups{
+		self.PID:   {Path: "/system.slice/node-app.service", MemoryCurrent: 480 << 20, MemoryMax: 512 << 20, PidsCurrent: 7, PidsMax: 64},
+		parent.PID: {Path: "/user.slice", MemoryCurrent: 100 << 20},
+	}
+	m.view, m.detailTab = viewDetail, tabResources
+	m.detailPort, m.details = self, &process.Details{PID: self.PID}
+
+	output := m.View()
+	for _, want := range []string{"/system.slice/node-app.service", "(94%)", "near memory limit", "7 / 64"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("detail panel missing %q", want)
+		}
+	}
+
+	m.detailPort, m.details = parent, &process.Details{PID: parent.PID}
+	output = m.View()
+	if !strings.Contains(output, "no limit") || strings.Contains(output, "near memory limit") {
+		t.Error("an unlimited cgroup should show no limit and no warning")
+	}
+
+	m.cgroups = fakeCgroups{}
+	if strings.Contains(m.View(), "cgroup") {
+		t.Error("processes outside cgroup v2 should have no cgroup section")
+	}
+}
+
+func TestDetailLoadsInBackground(t *testing.T) {
+	m := newTestModel()
+	updated, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = updated.(Model)
+	if cmd == nil || m.detailPort.PID != m.ports[0].PID {
+		t.Fatal("Enter should start loading the details of the selected process")
+	}
+	if !strings.Contains(m.View(), "Inspecting PID") {
+		t.Error("expected a loading line before the details arrive")
+	}
+
+	// Details of a process that is no longer shown are dropped.
+	updated, _ = m.Update(detailMsg{pid: 4242, details: &process.Details{PID: 4242}})
+	m = updated.(Model)
+	if m.details != nil {
+		t.Error("details for another PID should be ignored")
+	}
+
+	updated, _ = m.Update(detailMsg{pid: m.detailPort.PID, details: &process.Details{PID: m.detailPort.PID, Name: "node-inspected"}})
+	m = updated.(Model)
+	if !strings.Contains(m.View(), "node-inspected") {
+		t.Error("expected the loaded details in the panel")
+	}
+}
+
+func TestDetailTabs(t *testing.T) {
+	m := newTestModel()
+	m.view = viewDetail
+	m.detailPort = m.ports[0]
+	m.details = &process.Details{
+		PID: m.ports[0].PID,
+		Sockets: []process.Socket{
+			{Protocol: "TCP", State: "ESTAB", Local: "127.0.0.1:3000", Peer: "127.0.0.1:50000"},
+			{Protocol: "TCP", State: "LISTEN", Local: "0.0.0.0:3000", RecvQ: 5, SendQ: 511, Options: []string{"v6only:0"}},
+		},
+		OpenFDs: 1000,
+		FDLimit: 1024,
+		Env:     []string{"API_TOKEN=hunter2", "NODE_ENV=production", "PORT=3000"},
+	}
+
+	m = pressKey(m, "tab")
+	if m.detailTab != tabSockets {
+		t.Fatalf("tab: got %d, want sockets", m.detailTab)
+	}
+	output := m.View()
+	listen, estab := strings.Index(output, "0.0.0.0:3000"), strings.Index(output, "127.0.0.1:50000")
+	if listen < 0 || estab < 0 || listen > estab {
+		t.Error("expected both sockets, listeners first")
+	}
+	if !strings.Contains(output, "511") || !strings.Contains(output, "v6only:0") {
+		t.Error("expected the backlog and socket options")
+	}
+
+	m = pressKey(m, "tab")
+	if m.detailTab != tabQueue {
+		t.Fatalf("tab: got %d, want queue", m.detailTab)
+	}
+
+	m = pressKey(m, "tab")
+	if output := m.View(); !strings.Contains(output, "1000 / 1024 (98%)") || !strings.Contains(output, "near descriptor limit") {
+		t.Error("expected descriptor use against the limit, with a warning")
+	}
+
+	m = pressKey(m, "tab")
+	output = m.View()
+	if strings.Index(output, "PORT") > strings.Index(output, "NODE_ENV") {
+		t.Error("expected listen variables first")
+	}
+	if strings.Contains(output, "hunter2") {
+		t.Error("credential values should be hidden")
+	}
+
+	m = pressKey(m, "tab")
+	if m.detailTab != tabProcess {
+		t.Errorf("tab should wrap around, got %d", m.detailTab)
+	}
+	m = pressKey(m, "shift+tab")
+	if m.detailTab != tabEnvironment {
+		t.Errorf("shift+tab should go back, got %d", m.detailTab)
+	}
+}
+
+func TestScanWarnings(t *testing.T) {
+	m := newTestModel()
+	ports := append(testPorts(), scanner.PortInfo{Port: 22, Protocol: "TCP", State: "LISTEN"})
+	updated, _ := m.Update(scanResultMsg{
+		ports:  ports,
+		failed: []scanner.Failure{{Backend: "ps", Err: scanner.ErrBackendMissing}},
+	})
+	m = updated.(Model)
+
+	header := m.renderHeader()
+	for _, want := range []string{"1 without owner (rerun with sudo)", "ps: not installed"} {
+		if !strings.Contains(header, want) {
+			t.Errorf("header should show %q: %q", want, header)
+		}
+	}
+	if got := m.scanResult().Warnings(); len(got) != 2 {
+		t.Errorf("exports should carry both warnings, got %q", got)
+	}
+
+	updated, _ = m.Update(scanResultMsg{ports: testPorts()})
+	if header := updated.(Model).renderHeader(); strings.Contains(header, "⚠") {
+		t.Errorf("warnings should clear with the next scan: %q", header)
+	}
+}
+
+func TestSaturatedListenerFlagged(t *testing.T) {
+	m := newTestModel()
+	s := m.scanner.(*mockScanner)
+	for i := range s.ports {
+		s.ports[i].SendQ = 100
+	}
+	s.ports[0].RecvQ = 99
+	for range m.queues.Rule().Scans {
+		updated, _ := m.Update(scanResultMsg{ports: s.ports})
+		m = updated.(Model)
+	}
+
+	if !strings.Contains(m.renderHeader(), "1 saturated") {
+		t.Errorf("header should count saturated listeners: %q", m.renderHeader())
+	}
+	m.cols, _ = columns.Select([]string{"port", "queue"})
+	if !strings.Contains(m.View(), "99/100 ⚠") {
+		t.Error("queue column should flag the saturated listener")
+	}
+}
+
+func TestQueueTab(t *testing.T) {
+	m := newTestModel()
+	p := m.ports[0]
+	p.RecvQ, p.SendQ = 90, 100
+	m.view, m.detailTab, m.detailPort = viewDetail, tabQueue, p
+
+	if !strings.Contains(m.View(), "Collecting samples") {
+		t.Error("expected a placeholder before there is history")
+	}
+
+	start := time.Now()
+	for i, depth := range []int{0, 40, 85, 90, 95} {
+		q := p
+		q.RecvQ = depth
+		m.queues.Observe([]scanner.PortInfo{q}, start.Add(time.Duration(i)*2*time.Second))
+	}
+	output := m.View()
+	for _, want := range []string{"90 waiting / backlog 100 (90%)", "⚠ at 80% of the backlog", "100 ┤", "█", "last 5 scans, 8s"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("queue tab missing %q", want)
+		}
+	}
+
+	udp := m.ports[0]
+	udp.Protocol = "UDP"
+	m.detailPort = udp
+	if !strings.Contains(m.View(), "Only TCP listeners") {
+		t.Error("UDP listeners have no accept queue")
+	}
+}
+
+func TestTrafficColumns(t *testing.T) {
+	m := newTestModel()
+	m.cols, _ = columns.Select([]string{"port", "rx", "tx"})
+	port := m.ports[0].Port
+	sample := func(in, out uint64, at time.Time) tea.Msg {
+		return trafficMsg{at: at, conns: []scanner.ConnTraffic{
+			{LocalPort: port, Local: "127.0.0.1:1", Peer: "127.0.0.1:2", BytesIn: in, BytesOut: out},
+		}}
+	}
+
+	start := time.Now()
+	updated, _ := m.Update(sample(0, 0, start))
+	m = updated.(Model)
+	updated, _ = m.Update(sample(4096, 2<<20, start.Add(2*time.Second)))
+	m = updated.(Model)
+	if p := m.ports[0]; p.RxBytes != 2048 || p.TxBytes != 1<<20 {
+		t.Fatalf("rates: got rx %v tx %v", p.RxBytes, p.TxBytes)
+	}
+	output := m.View()
+	if !strings.Contains(output, "2.0K/s") || !strings.Contains(output, "1.0M/s") {
+		t.Error("expected the rates in the table")
+	}
+
+	// A rescan keeps the last rates until the next sample.
+	updated, _ = m.Update(scanResultMsg{ports: testPorts()})
+	m = updated.(Model)
+	if m.ports[0].RxBytes != 2048 {
+		t.Errorf("rates should carry over a rescan, got %v", m.ports[0].RxBytes)
+	}
+}
+
+// scanFleet scans f and applies the results until every host has answered.
+func scanFleet(m Model, f *fleet.Fleet) Model {
+	msg := doScan(f)().(scanResultMsg)
+	for {
+		updated, _ := m.Update(msg)
+		m = updated.(Model)
+		if !slices.ContainsFunc(msg.statuses, func(st fleet.Status) bool { return st.Scanning }) {
+			return m
+		}
+		msg = awaitHosts(f, msg.updated)().(scanResultMsg)
+	}
+}
+
+func TestHostSwitcher(t *testing.T) {
+	remote := &mockScanner{ports: []scanner.PortInfo{{Port: 9090, Protocol: "TCP", PID: 7, ProcessName: "prometheus", State: "LISTEN"}}}
+	local := &mockScanner{ports: testPorts()}
+	f := fleet.New(fleet.Source{Scanner: local}, fleet.Source{Name: "vm1", Scanner: remote})
+	m := New(f, config.DefaultConfig())
+	m.width, m.height = 120, 40
+	if m.cols[0].Key != "host" {
+		t.Fatalf("a fleet should add the host column, got %q first", m.cols[0].Key)
+	}
+
+	m = scanFleet(m, f)
+	if len(m.rows()) != 5 || !strings.Contains(m.View(), "all hosts") {
+		t.Fatalf("expected every host's ports, got %d rows", len(m.rows()))
+	}
+
+	m = pressKey(m, "H") // local
+	if len(m.rows()) != 4 || !strings.Contains(m.View(), "host: local") {
+		t.Errorf("local: got %d rows", len(m.rows()))
+	}
+	m = pressKey(m, "H") // vm1
+	rows := m.rows()
+	if len(rows) != 1 || rows[0].port.Host != "vm1" {
+		t.Fatalf("vm1: got %+v", rows)
+	}
+
+	m = pressKey(m, "x")
+	if m.view != viewTable || m.statusMsg != remoteOnlyMsg {
+		t.Errorf("remote listeners can't be killed: view %v, status %q", m.view, m.statusMsg)
+	}
+	m = pressKey(m, "enter")
+	if m.view != viewTable {
+		t.Error("remote listeners have no local process details")
+	}
+
+	m = pressKey(m, "H")
+	if len(m.rows()) != 5 {
+		t.Errorf("back to all hosts: got %d rows", len(m.rows()))
+	}
+
+	remote.err = fmt.Errorf("connection refused")
+	m = scanFleet(m, f)
+	view := m.View()
+	if len(m.ports) != 5 || !strings.Contains(view, "vm1 ✗ stale") || !strings.Contains(view, "vm1 (stale)") {
+		t.Errorf("an unreachable host's last ports should be kept and marked stale, got %d ports", len(m.ports))
+	}
+	if !strings.Contains(view, "local ✓") {
+		t.Error("the hosts that answered should be shown as up")
+	}
+
+	// A virtual IP held by both hosts at once.
+	vip := scanner.PortInfo{Port: 443, Protocol: "TCP", Address: "10.0.0.100", PID: 8, ProcessName: "nginx", State: "LISTEN"}
+	remote.err = nil
+	remote.ports = []scanner.PortInfo{vip}
+	local.ports = append(testPorts(), vip)
+	m = scanFleet(m, f)
+	if !strings.Contains(m.View(), "clash: 10.0.0.100:443/TCP") {
+		t.Error("the shared address should be flagged in the header")
+	}
+	conflicts := conflictingPorts(m.ports)
+	if !conflicts[scanner.HostPort{Port: 443}] || !conflicts[scanner.HostPort{Host: "vm1", Port: 443}] || conflicts[scanner.HostPort{Port: 3000}] {
+		t.Errorf("conflicts: got %v", conflicts)
+	}
+}
+
+// blockingScanner answers with ports once release is closed.
+type blockingScanner struct {
+	ports   []scanner.PortInfo
+	release chan struct{}
+}
+
+func (b blockingScanner) Scan() ([]scanner.PortInfo, error) {
+	<-b.release
+	return b.ports, nil
+}
+
+func TestSlowHostFillsIn(t *testing.T) {
+	release := make(chan struct{})
+	remote := blockingScanner{ports: []scanner.PortInfo{{Port: 9090, Protocol: "TCP", PID: 7, ProcessName: "prometheus", State: "LISTEN"}}, release: release}
+	f := fleet.New(fleet.Source{Scanner: &mockScanner{ports: testPorts()}}, fleet.Source{Name: "vm1", Scanner: remote})
+	m := New(f, config.DefaultConfig())
+	m.width, m.height = 120, 40
+
+	msg := doScan(f)().(scanResultMsg)
+	updated, cmd := m.Update(msg)
+	m = updated.(Model)
+	if len(m.ports) != 4 || !strings.Contains(m.View(), "vm1 …") {
+		t.Fatalf("the local ports should show while vm1 is scanning, got %d ports", len(m.ports))
+	}
+	if cmd == nil {
+		t.Fatal("expected a command waiting for vm1")
+	}
+
+	// Ticks while vm1 is still scanning don't start more waiters.
+	for range 3 {
+		updated, again := m.Update(doScan(f)())
+		m = updated.(Model)
+		if again != nil {
+			t.Fatal("a second waiter was started for vm1")
+		}
+	}
+
+	// The waiter fires on any answer, and waits again while vm1 is slow.
+	close(release)
+	for cmd != nil {
+		updated, cmd = m.Update(cmd())
+		m = updated.(Model)
+	}
+	if len(m.ports) != 5 || !strings.Contains(m.View(), "vm1 ✓") {
+		t.Errorf("vm1's ports should fill in once it answers, got %d ports", len(m.ports))
+	}
+	if m.awaiting {
+		t.Error("the waiter should be cleared once it fires")
+	}
+}
@@ -0,0 +1,158 @@
+package tui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/AbdullahTarakji/portpilot/internal/columns"
+	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// healthKey identifies a port in the results of health probes, which don't
+// carry a PID.
+type healthKey struct {
+	port     int
+	protocol string
+}
+
+// chooserItem is one line of the column chooser.
+type chooserItem struct {
+	col columns.Column
+	on  bool
+}
+
+// defaultColumns returns the columns configured in cfg, or the built-in
+// defaults if there are none.
+func defaultColumns(cfg *config.Config) []columns.Column {
+	cols, err := columns.Select(cfg.Columns)
+	if err != nil {
+		return columns.Default()
+	}
+	return cols
+}
+
+// env supplies the group, health, queue saturation and host staleness
+// values to the table columns.
+func (m Model) env() columns.Env {
+	return columns.Env{
+		GroupFor:  m.config.GroupForPort,
+		Saturated: m.queues.Saturated,
+		Stale: func(host string) bool {
+			for _, st := range m.hostStatus {
+				if st.Name == host {
+					return st.Stale()
+				}
+			}
+			return false
+		},
+		Health: func(p scanner.PortInfo) string {
+			if p.Host != "" {
+				return ""
+			}
+			if r, ok := m.health[healthKey{p.Port, p.Protocol}]; ok {
+				return r.Status()
+			}
+			return ""
+		},
+	}
+}
+
+// showsTraffic reports whether any traffic column is visible, which is
+// when connection counters are worth reading.
+func (m Model) showsTraffic() bool {
+	for _, key := range []string{"rx", "tx", "rxpkts", "txpkts"} {
+		if columns.Has(m.cols, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// openChooser lists the visible columns in their current order, followed by
+// the hidden ones.
+func (m Model) openChooser() Model {
+	m.chooser = nil
+	for _, c := range m.cols {
+		m.chooser = append(m.chooser, chooserItem{col: c, on: true})
+	}
+	for _, c := range columns.All() {
+		if !columns.Has(m.cols, c.Key) {
+			m.chooser = append(m.chooser, chooserItem{col: c})
+		}
+	}
+	m.chooserPos = 0
+	m.view = viewColumns
+	return m
+}
+
+func (m Model) handleChooserKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q", "C":
+		m.chooser = nil
+		m.view = viewTable
+	case "up", "k":
+		if m.chooserPos > 0 {
+			m.chooserPos--
+		}
+	case "down", "j":
+		if m.chooserPos < len(m.chooser)-1 {
+			m.chooserPos++
+		}
+	case " ", "x":
+		m.chooser = append([]chooserItem(nil), m.chooser...)
+		m.chooser[m.chooserPos].on = !m.chooser[m.chooserPos].on
+	case "K", "shift+up":
+		if i := m.chooserPos; i > 0 {
+			m.chooser = append([]chooserItem(nil), m.chooser...)
+			m.chooser[i-1], m.chooser[i] = m.chooser[i], m.chooser[i-1]
+			m.chooserPos--
+		}
+	case "J", "shift+down":
+		if i := m.chooserPos; i < len(m.chooser)-1 {
+			m.chooser = append([]chooserItem(nil), m.chooser...)
+			m.chooser[i], m.chooser[i+1] = m.chooser[i+1], m.chooser[i]
+			m.chooserPos++
+		}
+	case "enter":
+		var cols []columns.Column
+		for _, it := range m.chooser {
+			if it.on {
+				cols = append(cols, it.col)
+			}
+		}
+		if len(cols) == 0 {
+			m.statusMsg = "Select at least one column"
+			return m, nil
+		}
+		m.cols = cols
+		m.chooser = nil
+		m.view = viewTable
+		if columns.Has(cols, "health") {
+			return m, doHealthCheck(m.ports)
+		}
+	}
+	return m, nil
+}
+
+// renderChooser draws the column chooser overlay.
+func renderChooser(items []chooserItem, cursor int, width int) string {
+	lines := []string{titleStyle.Render("Columns"), ""}
+	for i, it := range items {
+		box := "[ ]"
+		if it.on {
+			box = "[x]"
+		}
+		label := fmt.Sprintf("%s %-10s %s", box, it.col.Key, it.col.Title)
+		if i == cursor {
+			lines = append(lines, selectedRowStyle.Render("▸ "+label))
+		} else {
+			lines = append(lines, "  "+label)
+		}
+	}
+	lines = append(lines, "", dimStyle.Render("Space toggle  J/K move  Enter apply  Esc cancel"))
+
+	return helpStyle.Width(min(width-4, 50)).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
@@ -1,50 +1,306 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
 
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/AbdullahTarakji/portpilot/internal/cgroup"
+	"github.com/AbdullahTarakji/portpilot/internal/columns"
 	"github.com/AbdullahTarakji/portpilot/internal/process"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
 )
 
-func renderDetail(pid int, width int) string {
-	details, err := process.GetDetails(pid)
-	if err != nil {
-		return detailBorderStyle.Width(width - 4).Render(
-			fmt.Sprintf("Error getting details for PID %d: %v", pid, err),
-		)
-	}
+// detailRow is one labelled line of the detail panel.
+type detailRow struct {
+	key   string
+	value string
+}
+
+// detailTabs are the sections of the detail panel, switched with Tab.
+var detailTabs = []string{"Process", "Sockets", "Queue", "Resources", "Environment"}
+
+const (
+	tabProcess = iota
+	tabSockets
+	tabQueue
+	tabResources
+	tabEnvironment
+)
+
+// detailChrome is the number of panel lines around a tab's content: the
+// border and padding, title, tab bar, spacing and footer.
+const detailChrome = 9
+
+// detailPadding is the panel width taken by the border and padding.
+const detailPadding = 8
+
+type detailMsg struct {
+	pid     int
+	details *process.Details
+	err     error
+}
 
-	rows := []struct {
-		key   string
-		value string
-	}{
-		{"PID", fmt.Sprintf("%d", details.PID)},
-		{"Parent PID", fmt.Sprintf("%d", details.ParentPID)},
-		{"Name", details.Name},
-		{"User", details.User},
-		{"CPU", fmt.Sprintf("%.1f%%", details.CPU)},
-		{"Memory", fmt.Sprintf("%.1f%%", details.Mem)},
-		{"Started", details.StartTime.Format("2006-01-02 15:04:05")},
-		{"Command", details.Command},
+// loadDetails inspects a process in the background; reading its sockets
+// and environment can take a moment on a busy host.
+func loadDetails(pid int) tea.Cmd {
+	return func() tea.Msg {
+		d, err := process.GetDetails(pid)
+		return detailMsg{pid: pid, details: d, err: err}
 	}
+}
 
-	var lines []string
-	lines = append(lines, titleStyle.Render("Process Details"))
-	lines = append(lines, "")
+func (m Model) openDetail(p scanner.PortInfo) (tea.Model, tea.Cmd) {
+	m.view = viewDetail
+	m.detailPort = p
+	m.details, m.detailErr = nil, nil
+	return m, loadDetails(p.PID)
+}
 
-	for _, r := range rows {
-		line := lipgloss.JoinHorizontal(lipgloss.Top,
-			detailKeyStyle.Render(r.key+":"),
-			detailValueStyle.Render(r.value),
-		)
-		lines = append(lines, line)
+func (m Model) handleDetailKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "enter", "q":
+		m.view = viewTable
+	case "tab", "right", "l":
+		m.detailTab = (m.detailTab + 1) % len(detailTabs)
+	case "shift+tab", "left", "h":
+		m.detailTab = (m.detailTab + len(detailTabs) - 1) % len(detailTabs)
+	}
+	return m, nil
+}
+
+// renderDetail shows one tab of the details of the process behind p, which
+// stays put while rescans reorder the table. d is nil until the details
+// have loaded.
+func renderDetail(p scanner.PortInfo, d *process.Details, err error, cg cgroup.Reader, queue queueHistory, tab, width, height int) string {
+	var body []string
+	switch {
+	case tab == tabQueue:
+		// Queue samples come from the scans, not from the process.
+		body = renderQueue(p, queue, width-detailPadding, max(1, height-detailChrome))
+	case err != nil:
+		body = []string{fmt.Sprintf("Error getting details for PID %d: %v", p.PID, err)}
+	case d == nil:
+		body = []string{dimStyle.Render(fmt.Sprintf("Inspecting PID %d…", p.PID))}
+	default:
+		limit := 0
+		if height > 0 {
+			limit = max(1, height-detailChrome)
+		}
+		switch tab {
+		case tabSockets:
+			body = renderSockets(d.Sockets, limit)
+		case tabResources:
+			body = renderResources(p, d, cg)
+		case tabEnvironment:
+			body = renderEnv(d.Env, limit)
+		default:
+			body = renderProcess(p, d)
+		}
 	}
 
-	lines = append(lines, "")
-	lines = append(lines, dimStyle.Render("Press Esc or Enter to close"))
+	lines := []string{titleStyle.Render("Process Details"), renderTabs(tab), ""}
+	lines = append(lines, body...)
+	lines = append(lines, "", dimStyle.Render("Tab/←/→ switch section · Esc or Enter to close"))
 
 	content := lipgloss.JoinVertical(lipgloss.Left, lines...)
 	return detailBorderStyle.Width(width - 4).Render(content)
 }
+
+func renderTabs(active int) string {
+	tabs := make([]string, len(detailTabs))
+	for i, name := range detailTabs {
+		if i == active {
+			tabs[i] = selectedRowStyle.Render(" " + name + " ")
+		} else {
+			tabs[i] = dimStyle.Render(" " + name + " ")
+		}
+	}
+	return strings.Join(tabs, " ")
+}
+
+func detailLine(key, value string) string {
+	return lipgloss.JoinHorizontal(lipgloss.Top, detailKeyStyle.Render(key+":"), value)
+}
+
+func renderProcess(p scanner.PortInfo, d *process.Details) []string {
+	rows := []detailRow{
+		{"PID", fmt.Sprintf("%d", d.PID)},
+		{"Parent PID", fmt.Sprintf("%d", d.ParentPID)},
+		{"Name", d.Name},
+		{"User", d.User},
+		{"CPU", fmt.Sprintf("%.1f%%", d.CPU)},
+		{"Memory", fmt.Sprintf("%.1f%%", d.Mem)},
+		{"Started", d.StartTime.Format("2006-01-02 15:04:05")},
+		{"Command", d.Command},
+	}
+	if p.Unit != "" {
+		unit := p.Unit
+		if p.UserUnit {
+			unit += " (user)"
+		}
+		rows = append(rows, detailRow{"Unit", unit})
+	}
+
+	lines := make([]string, 0, len(rows))
+	for _, r := range rows {
+		lines = append(lines, detailLine(r.key, detailValueStyle.Render(r.value)))
+	}
+	return lines
+}
+
+// renderSockets lists the sockets of the process, listeners first, as many
+// as fit in limit lines; zero means no limit.
+func renderSockets(sockets []process.Socket, limit int) []string {
+	if len(sockets) == 0 {
+		return []string{dimStyle.Render("No sockets visible (run with sudo to inspect other users' processes)")}
+	}
+	sorted := append([]process.Socket(nil), sockets...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Listening() && !sorted[j].Listening()
+	})
+
+	lines := []string{
+		dimStyle.Render("For listeners, Recv-Q is the accept queue and Send-Q the backlog."),
+		tableHeaderStyle.Render(fmt.Sprintf("%-5s  %-11s  %-22s  %-22s  %6s  %6s  %s",
+			"PROTO", "STATE", "LOCAL", "PEER", "RECV-Q", "SEND-Q", "OPTIONS")),
+	}
+	shown := len(sorted)
+	if limit > 0 {
+		shown = min(shown, max(1, limit-len(lines)-1))
+	}
+	for _, s := range sorted[:shown] {
+		lines = append(lines, fmt.Sprintf("%-5s  %-11s  %-22s  %-22s  %6d  %6d  %s",
+			s.Protocol, truncate(s.State, 11), truncate(s.Local, 22), truncate(s.Peer, 22),
+			s.RecvQ, s.SendQ, strings.Join(s.Options, " ")))
+	}
+	if shown < len(sorted) {
+		lines = append(lines, dimStyle.Render(fmt.Sprintf("… %d more sockets", len(sorted)-shown)))
+	}
+	return lines
+}
+
+// fdNearLimit is the share of the descriptor limit above which a process
+// risks failing to accept connections.
+const fdNearLimit = 0.9
+
+// renderResources shows descriptor use against its limit and the cgroup
+// accounting of the process.
+func renderResources(p scanner.PortInfo, d *process.Details, cg cgroup.Reader) []string {
+	fds := fmt.Sprintf("%d", d.OpenFDs)
+	fdValue := detailValueStyle.Render(fds + " (limit unknown)")
+	if d.FDLimit > 0 {
+		ratio := float64(d.OpenFDs) / float64(d.FDLimit)
+		fds = fmt.Sprintf("%s / %d (%.0f%%)", fds, d.FDLimit, ratio*100)
+		fdValue = detailValueStyle.Render(fds)
+		if ratio >= fdNearLimit {
+			fdValue = warningStyle.Render(fds + "  ⚠ near descriptor limit")
+		}
+	}
+	if d.OpenFDs == 0 {
+		fdValue = dimStyle.Render("unknown (run with sudo to inspect other users' processes)")
+	}
+	lines := []string{detailLine("Open files", fdValue)}
+
+	if cg != nil {
+		// The root cgroup has no accounting of its own.
+		if stats, err := cg.Read(p.PID); err == nil && stats.Path != "/" {
+			lines = append(lines, "", titleStyle.Render("cgroup"), "")
+			lines = append(lines, renderCgroup(stats)...)
+		} else if err != nil && !errors.Is(err, cgroup.ErrNotV2) {
+			lines = append(lines, "", dimStyle.Render(fmt.Sprintf("cgroup: %v", err)))
+		}
+	}
+	return lines
+}
+
+// renderCgroup shows a cgroup's usage against its limits, warning when
+// memory is close to the limit.
+func renderCgroup(s cgroup.Stats) []string {
+	memory := columns.FormatBytes(s.MemoryCurrent)
+	if memory == "" {
+		memory = "0"
+	}
+	if s.MemoryMax > 0 {
+		memory = fmt.Sprintf("%s / %s (%.0f%%)", memory, columns.FormatBytes(s.MemoryMax), s.MemoryRatio()*100)
+	} else {
+		memory += " (no limit)"
+	}
+	memoryValue := detailValueStyle.Render(memory)
+	if s.NearMemoryLimit() {
+		memoryValue = warningStyle.Render(memory + "  ⚠ near memory limit")
+	}
+
+	cpu := columns.FormatDuration(s.CPUUsage)
+	if s.NrThrottled > 0 {
+		cpu += fmt.Sprintf(", throttled %d times for %s", s.NrThrottled, columns.FormatDuration(s.CPUThrottled))
+	}
+	tasks := fmt.Sprintf("%d", s.PidsCurrent)
+	if s.PidsMax > 0 {
+		tasks += fmt.Sprintf(" / %d", s.PidsMax)
+	}
+
+	return []string{
+		detailLine("Path", detailValueStyle.Render(s.Path)),
+		detailLine("Memory", memoryValue),
+		detailLine("CPU time", detailValueStyle.Render(cpu)),
+		detailLine("Tasks", detailValueStyle.Render(tasks)),
+	}
+}
+
+// renderEnv lists the environment of the process, the variables that
+// usually decide where it listens first. Values of variables that look
+// like credentials are hidden.
+func renderEnv(env []string, limit int) []string {
+	if len(env) == 0 {
+		return []string{dimStyle.Render("Environment not readable (run with sudo to inspect other users' processes)")}
+	}
+	sorted := append([]string(nil), env...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return isListenVar(sorted[i]) && !isListenVar(sorted[j])
+	})
+
+	shown := len(sorted)
+	if limit > 0 {
+		shown = min(shown, max(1, limit-1))
+	}
+	lines := make([]string, 0, shown+1)
+	for _, kv := range sorted[:shown] {
+		key, value, _ := strings.Cut(kv, "=")
+		if isSecretVar(key) && value != "" {
+			value = "••••••"
+		}
+		lines = append(lines, envKeyStyle.Render(key)+"="+value)
+	}
+	if shown < len(sorted) {
+		lines = append(lines, dimStyle.Render(fmt.Sprintf("… %d more variables", len(sorted)-shown)))
+	}
+	return lines
+}
+
+// isListenVar reports whether an environment entry is one that commonly
+// sets a listen address, such as PORT, HOST or DB_PORT.
+func isListenVar(kv string) bool {
+	key, _, _ := strings.Cut(kv, "=")
+	key = strings.ToUpper(key)
+	for _, name := range []string{"PORT", "HOST", "BIND", "LISTEN", "ADDR"} {
+		if key == name || strings.HasPrefix(key, name+"_") || strings.HasSuffix(key, "_"+name) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSecretVar(key string) bool {
+	key = strings.ToUpper(key)
+	for _, s := range []string{"SECRET", "TOKEN", "PASSWORD", "PASSWD", "KEY", "CREDENTIAL", "AUTH"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
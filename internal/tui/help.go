@@ -8,17 +8,30 @@ type helpEntry struct {
 }
 
 var helpEntries = []helpEntry{
-	{"1-8", "Sort by column (toggle asc/desc)"},
-	{"/", "Search / filter by port or process"},
-	{"Esc", "Clear search / close panel"},
-	{"Enter", "View process details"},
-	{"k", "Kill selected process"},
+	{"1-9", "Switch to saved view N"},
+	{"v", "Pick a saved view"},
+	{"C", "Choose and reorder columns"},
+	{"Alt+1-9", "Sort by visible column (toggle asc/desc)"},
+	{"/", "Filter with a query (port:80 proc:node cpu>20)"},
+	{"Esc", "Clear marks / search, close panel"},
+	{"Enter", "View process details (Tab: next section) / collapse group"},
+	{"h", "Open / close history of the selected port"},
+	{"Space", "Mark row / collapse or expand group"},
+	{"V", "Mark rows from last mark to cursor"},
+	{"*", "Mark / unmark all filtered rows"},
+	{"x", "Kill marked, selected or grouped processes"},
+	{"a", "Action menu: signals, suspend/resume, custom"},
+	{"p", "Probe marked, selected or grouped ports"},
+	{"c", "Copy port numbers to clipboard"},
+	{"e", "Export ports to a JSON file"},
 	{"r", "Manual refresh"},
-	{"g", "Toggle group view"},
+	{"t", "Toggle grouped view"},
+	{"H", "Cycle through hosts when remote hosts are configured"},
 	{"?", "Toggle this help"},
 	{"q", "Quit"},
-	{"Up/Down", "Navigate rows"},
-	{"j/k nav", "Vim-style navigation (when not killing)"},
+	{"↑/↓ j/k", "Navigate rows"},
+	{"PgUp/PgDn", "Scroll a page"},
+	{"g/G", "Jump to first / last row (also Home/End)"},
 }
 
 func renderHelp(width int) string {
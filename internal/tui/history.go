@@ -0,0 +1,101 @@
+package tui
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/AbdullahTarakji/portpilot/internal/events"
+	"github.com/AbdullahTarakji/portpilot/internal/history"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+type historyMsg struct {
+	port   int
+	events []events.Event
+	err    error
+}
+
+type historyRecordedMsg struct {
+	err error
+}
+
+// recordHistory logs the open and close events of a scan.
+func recordHistory(store *history.Store, ports []scanner.PortInfo, at time.Time) tea.Cmd {
+	return func() tea.Msg {
+		_, err := store.Record(ports, at)
+		return historyRecordedMsg{err: err}
+	}
+}
+
+func loadHistory(store *history.Store, port int) tea.Cmd {
+	return func() tea.Msg {
+		evs, err := store.Query(history.Filter{Port: port})
+		return historyMsg{port: port, events: evs, err: err}
+	}
+}
+
+func (m Model) openHistory() (tea.Model, tea.Cmd) {
+	if m.history == nil {
+		m.statusMsg = "History is disabled in the config"
+		return m, nil
+	}
+	row, ok := m.selectedRow()
+	if !ok || row.isHeader() {
+		return m, nil
+	}
+	m.view = viewHistory
+	m.historyPort = row.port.Port
+	m.historyEvents, m.historyErr = nil, nil
+	return m, loadHistory(m.history, row.port.Port)
+}
+
+func (m Model) handleHistoryKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q", "h":
+		m.view = viewTable
+	}
+	return m, nil
+}
+
+// historyChrome is the number of panel lines around the event list: the
+// border, title, column header, spacing and footer.
+const historyChrome = 7
+
+// renderHistory shows the events on a port, newest first, as many as fit
+// in height.
+func renderHistory(port int, evs []events.Event, err error, width, height int) string {
+	lines := []string{titleStyle.Render(fmt.Sprintf("History of port %d", port)), ""}
+
+	switch {
+	case err != nil:
+		lines = append(lines, fmt.Sprintf("Error reading history: %v", err))
+	case len(evs) == 0:
+		lines = append(lines, dimStyle.Render("No events recorded for this port"))
+	default:
+		lines = append(lines, tableHeaderStyle.Render(fmt.Sprintf("%-19s  %-5s  %-5s  %-7s  %-15s  %s",
+			"TIME", "EVENT", "PROTO", "PID", "PROCESS", "USER")))
+		limit := len(evs)
+		if height > 0 {
+			limit = min(limit, max(1, height-historyChrome))
+		}
+		for i := len(evs) - 1; i >= len(evs)-limit; i-- {
+			e := evs[i]
+			line := fmt.Sprintf("%-19s  %-5s  %-5s  %-7d  %-15s  %s",
+				e.Time.Local().Format("2006-01-02 15:04:05"), e.Kind, e.Port.Protocol, e.Port.PID,
+				truncate(e.Port.ProcessName, 15), e.Port.User)
+			if e.Kind == events.Close {
+				line = dimStyle.Render(line)
+			}
+			lines = append(lines, line)
+		}
+		if limit < len(evs) {
+			lines = append(lines, dimStyle.Render(fmt.Sprintf("… %d older events (portpilot history --port %d)", len(evs)-limit, port)))
+		}
+	}
+
+	lines = append(lines, "", dimStyle.Render("Press Esc or h to close"))
+	return detailBorderStyle.Width(width - 4).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
@@ -0,0 +1,272 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"github.com/AbdullahTarakji/portpilot/internal/action"
+	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/process"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+	"github.com/AbdullahTarakji/portpilot/internal/systemd"
+)
+
+// menuKind identifies what choosing a menu item does.
+type menuKind int
+
+const (
+	menuSignalPicker menuKind = iota
+	menuSignal
+	menuSuspend
+	menuResume
+	menuUserAction
+	menuView
+	menuUnit
+)
+
+// menuItem is one entry of the action menu or the signal picker.
+type menuItem struct {
+	label  string
+	kind   menuKind
+	signal string
+	verb   string // systemctl verb of a menuUnit item
+	action config.Action
+	view   config.View
+}
+
+// menuMaxRows caps how many menu entries are shown at once.
+const menuMaxRows = 12
+
+type userActionMsg struct {
+	name    string
+	results []actionResult
+	output  string
+}
+
+// actionMenu returns the entries of the action menu: signal, suspend and
+// resume, stop and restart through systemd when a target belongs to a unit,
+// followed by the user-defined actions from the config.
+func actionMenu(cfg *config.Config, targets []scanner.PortInfo) []menuItem {
+	items := []menuItem{
+		{label: "Send signal…", kind: menuSignalPicker},
+		{label: "Suspend (SIGSTOP)", kind: menuSuspend, signal: "SIGSTOP"},
+		{label: "Resume (SIGCONT)", kind: menuResume, signal: "SIGCONT"},
+	}
+	if units, _ := systemd.Units(targets); len(units) > 0 {
+		items = append(items,
+			menuItem{label: "Stop unit (systemctl stop)", kind: menuUnit, verb: systemd.Stop},
+			menuItem{label: "Restart unit (systemctl restart)", kind: menuUnit, verb: systemd.Restart},
+		)
+	}
+	for _, a := range cfg.Actions {
+		items = append(items, menuItem{label: "Run: " + a.Name, kind: menuUserAction, action: a})
+	}
+	return items
+}
+
+// signalMenu lists every signal the platform supports.
+func signalMenu() []menuItem {
+	sigs := process.Signals()
+	items := make([]menuItem, 0, len(sigs))
+	for _, s := range sigs {
+		items = append(items, menuItem{
+			label:  fmt.Sprintf("%-10s %2d", s.Name, int(s.Num)),
+			kind:   menuSignal,
+			signal: s.Name,
+		})
+	}
+	return items
+}
+
+// viewPicker lists the saved views from the config, preceded by the default
+// view. Each entry is numbered so it can be chosen with its digit.
+func viewPicker(cfg *config.Config) []menuItem {
+	items := []menuItem{{label: "0  Default (all ports)", kind: menuView}}
+	for i, v := range cfg.Views {
+		label := fmt.Sprintf("%d  %s", i+1, v.Name)
+		if i >= 9 {
+			label = "   " + v.Name
+		}
+		items = append(items, menuItem{label: label, kind: menuView, view: v})
+	}
+	return items
+}
+
+func (m Model) openMenu(title string, items []menuItem) Model {
+	m.menu = items
+	m.menuTitle = title
+	m.menuCursor = 0
+	m.view = viewMenu
+	return m
+}
+
+func (m Model) closeMenu() Model {
+	m.menu = nil
+	m.menuTitle = ""
+	m.menuCursor = 0
+	m.view = viewTable
+	return m
+}
+
+func (m Model) handleMenuKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q", "a":
+		return m.closeMenu(), nil
+	case "up", "k":
+		if m.menuCursor > 0 {
+			m.menuCursor--
+		}
+	case "down", "j":
+		if m.menuCursor < len(m.menu)-1 {
+			m.menuCursor++
+		}
+	case "enter":
+		if m.menuCursor < len(m.menu) {
+			return m.chooseMenuItem(m.menu[m.menuCursor])
+		}
+	case "0", "1", "2", "3", "4", "5", "6", "7", "8", "9":
+		i := int(msg.String()[0] - '0')
+		if i < len(m.menu) && m.menu[i].kind == menuView {
+			return m.chooseMenuItem(m.menu[i])
+		}
+	}
+	return m, nil
+}
+
+// chooseMenuItem performs the chosen menu entry against the current action
+// targets.
+func (m Model) chooseMenuItem(item menuItem) (tea.Model, tea.Cmd) {
+	if item.kind == menuView {
+		return m.closeMenu().switchView(item.view), nil
+	}
+
+	targets, label := m.actionTargets()
+	if len(targets) == 0 {
+		return m.closeMenu(), nil
+	}
+
+	switch item.kind {
+	case menuSignalPicker:
+		return m.openMenu("Send signal", signalMenu()), nil
+	case menuSignal:
+		m = m.closeMenu()
+		m.targets, m.targetLabel = targets, label
+		m.signal = item.signal
+		m.view = viewConfirmKill
+		return m, nil
+	case menuSuspend:
+		m = m.closeMenu()
+		m.statusMsg = killStatus(signalTargets(targets, process.Suspend), item.signal)
+		return m, doScan(m.scanner)
+	case menuResume:
+		m = m.closeMenu()
+		m.statusMsg = killStatus(signalTargets(targets, process.Resume), item.signal)
+		return m, doScan(m.scanner)
+	case menuUserAction:
+		m = m.closeMenu()
+		m.statusMsg = fmt.Sprintf("Running %s...", item.action.Name)
+		return m, runUserAction(item.action, targets, m.config)
+	case menuUnit:
+		m = m.closeMenu()
+		m.statusMsg = fmt.Sprintf("Running systemctl %s...", item.verb)
+		return m, runUnitAction(item.verb, targets)
+	}
+	return m, nil
+}
+
+// runUserAction runs a user action once per target. Interactive actions
+// take over the terminal one after another; the rest run in the background
+// and report their outcome in the status bar.
+func runUserAction(a config.Action, targets []scanner.PortInfo, cfg *config.Config) tea.Cmd {
+	if a.Interactive {
+		var cmds []tea.Cmd
+		for _, p := range targets {
+			cmd, err := action.Command(a.Command, p, cfg.GroupForPort(p.Port))
+			if err != nil {
+				return func() tea.Msg {
+					return userActionMsg{name: a.Name, results: []actionResult{{port: p, err: err}}}
+				}
+			}
+			cmds = append(cmds, tea.ExecProcess(cmd, func(err error) tea.Msg {
+				return userActionMsg{name: a.Name, results: []actionResult{{port: p, err: err}}}
+			}))
+		}
+		return tea.Sequence(cmds...)
+	}
+
+	return func() tea.Msg {
+		msg := userActionMsg{name: a.Name}
+		for _, p := range targets {
+			cmd, err := action.Command(a.Command, p, cfg.GroupForPort(p.Port))
+			if err == nil {
+				var out []byte
+				out, err = cmd.CombinedOutput()
+				if msg.output == "" {
+					msg.output = strings.TrimSpace(string(out))
+				}
+			}
+			msg.results = append(msg.results, actionResult{port: p, err: err})
+		}
+		return msg
+	}
+}
+
+// runUnitAction runs systemctl verb once per unit among the targets, in the
+// background. Targets outside systemd fail with systemd.ErrNotManaged.
+func runUnitAction(verb string, targets []scanner.PortInfo) tea.Cmd {
+	return func() tea.Msg {
+		msg := userActionMsg{name: "systemctl " + verb}
+		units, unmanaged := systemd.Units(targets)
+		for _, u := range units {
+			_, err := systemd.Run(verb, u)
+			for _, p := range u.Ports {
+				msg.results = append(msg.results, actionResult{port: p, err: err})
+			}
+		}
+		for _, p := range unmanaged {
+			msg.results = append(msg.results, actionResult{port: p, err: systemd.ErrNotManaged})
+		}
+		return msg
+	}
+}
+
+// userActionStatus summarizes a user action for the status bar.
+func userActionStatus(msg userActionMsg) string {
+	if len(msg.results) == 1 {
+		if err := msg.results[0].err; err != nil {
+			return fmt.Sprintf("%s failed: %v", msg.name, err)
+		}
+		if line, _, _ := strings.Cut(msg.output, "\n"); line != "" {
+			return fmt.Sprintf("%s: %s", msg.name, line)
+		}
+		return fmt.Sprintf("%s: done", msg.name)
+	}
+	return summarizeResults(msg.name, msg.results)
+}
+
+// renderMenu draws a menu box, scrolling so the cursor stays visible.
+func renderMenu(title string, items []menuItem, cursor int, width int) string {
+	start := 0
+	if cursor >= menuMaxRows {
+		start = cursor - menuMaxRows + 1
+	}
+	end := min(start+menuMaxRows, len(items))
+
+	lines := []string{titleStyle.Render(title), ""}
+	for i := start; i < end; i++ {
+		line := "  " + items[i].label
+		if i == cursor {
+			line = selectedRowStyle.Render("▸ " + items[i].label)
+		}
+		lines = append(lines, line)
+	}
+	if len(items) > menuMaxRows {
+		lines = append(lines, "", dimStyle.Render(fmt.Sprintf("%d/%d", cursor+1, len(items))))
+	}
+	lines = append(lines, "", dimStyle.Render("↑/↓ move  Enter choose  Esc close"))
+
+	return helpStyle.Width(min(width-4, 50)).Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
@@ -0,0 +1,92 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/AbdullahTarakji/portpilot/internal/columns"
+	"github.com/AbdullahTarakji/portpilot/internal/saturation"
+	"github.com/AbdullahTarakji/portpilot/internal/scanner"
+)
+
+// queueSamples is how many scans of accept-queue history the TUI keeps per
+// listener, four minutes at the default refresh interval.
+const queueSamples = 120
+
+// queueChartHeight is the tallest the queue chart gets.
+const queueChartHeight = 8
+
+// queueHistory is what the Queue tab shows of one listener.
+type queueHistory struct {
+	samples   []saturation.Sample
+	rule      saturation.Rule
+	saturated bool
+}
+
+// bars are the eighths of a chart cell, from empty to full.
+var bars = []rune(" ▁▂▃▄▅▆▇█")
+
+// renderQueue charts the accept-queue depth of a listener over the scans
+// the TUI has seen, scaled to its backlog.
+func renderQueue(p scanner.PortInfo, q queueHistory, width, height int) []string {
+	if p.Protocol != "TCP" || p.SendQ <= 0 {
+		return []string{dimStyle.Render("Only TCP listeners with a known backlog have an accept queue.")}
+	}
+
+	now := fmt.Sprintf("%d waiting / backlog %d (%.0f%%)", p.RecvQ, p.SendQ, p.Saturation()*100)
+	status := detailValueStyle.Render(now)
+	if q.saturated {
+		status = warningStyle.Render(fmt.Sprintf("%s  ⚠ at %.0f%% of the backlog or more for %d scans",
+			now, q.rule.Threshold*100, q.rule.Scans))
+	}
+	lines := []string{detailLine("Accept queue", status), ""}
+
+	if len(q.samples) < 2 {
+		return append(lines, dimStyle.Render("Collecting samples; the chart fills in with each refresh."))
+	}
+	chartHeight := min(queueChartHeight, max(1, height-len(lines)-2))
+	lines = append(lines, queueChart(q.samples, q.rule.Threshold, width, chartHeight)...)
+	return lines
+}
+
+// queueChart draws samples as a bar chart of the given height, newest on
+// the right, with as many samples as fit in width. Bars at or above
+// threshold of the backlog are highlighted.
+func queueChart(samples []saturation.Sample, threshold float64, width, height int) []string {
+	const axisWidth = 7 // "  1024 ┤"
+	if n := width - axisWidth - 1; n > 0 && len(samples) > n {
+		samples = samples[len(samples)-n:]
+	}
+
+	top := 1
+	for _, s := range samples {
+		top = max(top, s.Backlog, s.Depth)
+	}
+
+	lines := make([]string, 0, height+1)
+	for row := height - 1; row >= 0; row-- {
+		label := ""
+		switch row {
+		case height - 1:
+			label = fmt.Sprintf("%d", top)
+		case 0:
+			label = "0"
+		}
+		var b strings.Builder
+		for _, s := range samples {
+			// The bar height in eighths of a row.
+			level := s.Depth * height * 8 / top
+			fill := min(max(level-row*8, 0), 8)
+			cell := string(bars[fill])
+			if fill > 0 && s.Ratio() >= threshold {
+				cell = warningStyle.Render(cell)
+			}
+			b.WriteString(cell)
+		}
+		lines = append(lines, dimStyle.Render(fmt.Sprintf("%*s ┤", axisWidth-2, label))+b.String())
+	}
+
+	span := samples[len(samples)-1].Time.Sub(samples[0].Time)
+	lines = append(lines, dimStyle.Render(fmt.Sprintf("%*s └ last %d scans, %s", axisWidth-2, "", len(samples), columns.FormatDuration(span))))
+	return lines
+}
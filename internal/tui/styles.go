@@ -25,6 +25,9 @@ var (
 			Foreground(colorMagenta).
 			Padding(0, 1)
 
+	hostStatusStyle = lipgloss.NewStyle().
+			Padding(0, 1)
+
 	// Status bar
 	statusBarStyle = lipgloss.NewStyle().
 			Foreground(colorDim).
@@ -46,6 +49,10 @@ var (
 				Foreground(colorWhite).
 				Bold(true)
 
+	markedRowStyle = lipgloss.NewStyle().
+			Foreground(colorMagenta).
+			Bold(true)
+
 	// Row coloring
 	conflictStyle = lipgloss.NewStyle().
 			Background(colorRed).
@@ -57,6 +64,9 @@ var (
 	healthyStyle = lipgloss.NewStyle().
 			Foreground(colorGreen)
 
+	downStyle = lipgloss.NewStyle().
+			Foreground(colorRed)
+
 	dimStyle = lipgloss.NewStyle().
 			Foreground(colorDim)
 
@@ -69,6 +79,10 @@ var (
 	searchInputStyle = lipgloss.NewStyle().
 				Foreground(colorWhite)
 
+	searchErrorStyle = lipgloss.NewStyle().
+				Foreground(colorRed).
+				PaddingLeft(2)
+
 	// Detail view
 	detailKeyStyle = lipgloss.NewStyle().
 			Foreground(colorCyan).
@@ -85,6 +99,10 @@ var (
 				BorderForeground(colorBlue).
 				Padding(1, 2)
 
+	envKeyStyle = lipgloss.NewStyle().
+			Foreground(colorCyan).
+			Bold(true)
+
 	// Help overlay
 	helpStyle = lipgloss.NewStyle().
 			Border(lipgloss.RoundedBorder()).
@@ -7,50 +7,191 @@ import (
 
 	"github.com/charmbracelet/lipgloss"
 
+	"github.com/AbdullahTarakji/portpilot/internal/columns"
 	"github.com/AbdullahTarakji/portpilot/internal/config"
+	"github.com/AbdullahTarakji/portpilot/internal/fleet"
+	"github.com/AbdullahTarakji/portpilot/internal/query"
 	"github.com/AbdullahTarakji/portpilot/internal/scanner"
 )
 
-type column struct {
-	title string
-	width int
+// sortOrder is the column key the table is sorted by. The column need not
+// be visible.
+type sortOrder struct {
+	key string
+	asc bool
 }
 
-var columns = []column{
-	{"Port", 7},
-	{"Proto", 6},
-	{"PID", 8},
-	{"Process", 16},
-	{"User", 12},
-	{"CPU%", 7},
-	{"Mem%", 7},
-	{"State", 8},
+// markWidth is the width of the gutter that shows marked rows.
+const markWidth = 2
+
+// otherGroup is the bucket for ports that don't belong to any configured
+// group or project.
+const otherGroup = "Other"
+
+// groupBucket holds the ports of one group in the grouped view, along with
+// their aggregate resource usage.
+type groupBucket struct {
+	name  string
+	ports []scanner.PortInfo
+	cpu   float64
+	mem   float64
 }
 
-type sortOrder struct {
-	column int
-	asc    bool
+// tableRow is a single line of the table body: either a port or, in the
+// grouped view, a group header.
+type tableRow struct {
+	port   scanner.PortInfo
+	group  string
+	bucket *groupBucket
+}
+
+func (r tableRow) isHeader() bool {
+	return r.bucket != nil
+}
+
+// groupPorts buckets ports by their configured group, or by their project
+// if they aren't in one. Named buckets come first in name order, followed
+// by the "Other" bucket. The order of ports
+// within each bucket is preserved, so callers should sort beforehand.
+func groupPorts(ports []scanner.PortInfo, cfg *config.Config) []groupBucket {
+	byName := make(map[string]*groupBucket)
+	for _, p := range ports {
+		name := cfg.GroupForPort(p.Port)
+		if name == "" {
+			name = p.Project
+		}
+		if name == "" {
+			name = otherGroup
+		}
+		b, ok := byName[name]
+		if !ok {
+			b = &groupBucket{name: name}
+			byName[name] = b
+		}
+		b.ports = append(b.ports, p)
+		b.cpu += p.CPU
+		b.mem += p.Mem
+	}
+
+	names := make([]string, 0, len(byName))
+	for name := range byName {
+		if name != otherGroup {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := byName[otherGroup]; ok {
+		names = append(names, otherGroup)
+	}
+
+	buckets := make([]groupBucket, 0, len(names))
+	for _, name := range names {
+		buckets = append(buckets, *byName[name])
+	}
+	return buckets
+}
+
+// buildRows filters and sorts ports and lays them out as table rows. In the
+// grouped view each bucket gets a header row, and the ports of collapsed
+// groups are left out.
+func buildRows(ports []scanner.PortInfo, filter string, sortCol sortOrder, env columns.Env, grouped bool, collapsed map[string]bool, cfg *config.Config) []tableRow {
+	sorted := columns.Sort(filterPorts(ports, filter, cfg), sortCol.key, sortCol.asc, env)
+
+	if !grouped {
+		rows := make([]tableRow, len(sorted))
+		for i, p := range sorted {
+			rows[i] = tableRow{port: p}
+		}
+		return rows
+	}
+
+	var rows []tableRow
+	for _, b := range groupPorts(sorted, cfg) {
+		rows = append(rows, tableRow{group: b.name, bucket: &b})
+		if collapsed[b.name] {
+			continue
+		}
+		for _, p := range b.ports {
+			rows = append(rows, tableRow{port: p, group: b.name})
+		}
+	}
+	return rows
+}
+
+// tableView holds the state renderTable draws. Only rows[offset:offset+height]
+// are rendered; a height of zero renders every row.
+type tableView struct {
+	rows      []tableRow
+	cursor    int
+	offset    int
+	height    int
+	sortCol   sortOrder
+	cols      []columns.Column
+	env       columns.Env
+	filter    string
+	collapsed map[string]bool
+	marked    map[portKey]bool
+	cfg       *config.Config
+	width     int
+}
+
+// window returns the bounds of the rows that fit in the viewport.
+func (tv tableView) window() (start, end int) {
+	if tv.height <= 0 || len(tv.rows) <= tv.height {
+		return 0, len(tv.rows)
+	}
+	start = max(0, min(tv.offset, len(tv.rows)-tv.height))
+	return start, start + tv.height
+}
+
+// scrollOffset returns the viewport offset that keeps the cursor visible,
+// moving the current offset as little as possible.
+func scrollOffset(offset, cursor, height, total int) int {
+	if height <= 0 || total <= height {
+		return 0
+	}
+	if cursor < offset {
+		offset = cursor
+	}
+	if cursor >= offset+height {
+		offset = cursor - height + 1
+	}
+	return max(0, min(offset, total-height))
+}
+
+// conflictingPorts returns the ports held by more than one process on the
+// same host, and the ports whose address is bound on several hosts.
+func conflictingPorts(ports []scanner.PortInfo) map[scanner.HostPort]bool {
+	conflicts := scanner.Conflicts(ports)
+	for _, c := range fleet.Clashes(ports) {
+		for _, host := range c.Hosts {
+			conflicts[scanner.HostPort{Host: host, Port: c.Port}] = true
+		}
+	}
+	return conflicts
 }
 
 // renderTable renders the port table with the current state.
-func renderTable(ports []scanner.PortInfo, cursor int, sortCol sortOrder, filter string, showGroups bool, cfg *config.Config, width int) string {
-	filtered := filterPorts(ports, filter)
-	sorted := sortPorts(filtered, sortCol)
+func renderTable(tv tableView) string {
+	rows, cursor, sortCol, cols, filter, cfg, width := tv.rows, tv.cursor, tv.sortCol, tv.cols, tv.filter, tv.cfg, tv.width
+
+	var visible []scanner.PortInfo
+	for _, r := range rows {
+		if !r.isHeader() {
+			visible = append(visible, r.port)
+		}
+	}
 
-	// Detect conflicts (same port, different PID)
-	conflicts := findConflicts(sorted)
+	conflicts := conflictingPorts(visible)
 
 	// Calculate dynamic process column width
-	remainingWidth := width - 4 // borders/padding
+	remainingWidth := width - 4 - markWidth // borders/padding and mark gutter
 	fixedWidth := 0
-	for i, c := range columns {
-		if i != 3 { // skip Process column
-			fixedWidth += c.width + 2 // +2 for padding
+	for _, c := range cols {
+		if c.Key != "proc" {
+			fixedWidth += c.Width + 2 // +2 for padding
 		}
 	}
-	if showGroups {
-		fixedWidth += 12 // group column
-	}
 	processWidth := remainingWidth - fixedWidth
 	if processWidth < 10 {
 		processWidth = 10
@@ -60,14 +201,14 @@ func renderTable(ports []scanner.PortInfo, cursor int, sortCol sortOrder, filter
 	}
 
 	// Header
-	var headerCells []string
-	for i, c := range columns {
-		w := c.width
-		if i == 3 {
+	headerCells := []string{tableHeaderStyle.Width(markWidth).Padding(0).Render("")}
+	for _, c := range cols {
+		w := c.Width
+		if c.Key == "proc" {
 			w = processWidth
 		}
-		title := c.title
-		if i == sortCol.column {
+		title := c.Title
+		if c.Key == sortCol.key {
 			if sortCol.asc {
 				title += " ▲"
 			} else {
@@ -76,57 +217,53 @@ func renderTable(ports []scanner.PortInfo, cursor int, sortCol sortOrder, filter
 		}
 		headerCells = append(headerCells, tableHeaderStyle.Width(w).Render(title))
 	}
-	if showGroups {
-		headerCells = append(headerCells, tableHeaderStyle.Width(10).Render("Group"))
-	}
 	header := lipgloss.JoinHorizontal(lipgloss.Top, headerCells...)
 
 	// Rows
-	var rows []string
-	for i, p := range sorted {
+	start, end := tv.window()
+	var lines []string
+	for i := start; i < end; i++ {
+		r := rows[i]
 		isSelected := i == cursor
-		isConflict := conflicts[p.Port]
+		if r.isHeader() {
+			lines = append(lines, renderGroupHeader(r.bucket, tv.collapsed[r.group], isSelected, cfg))
+			continue
+		}
+
+		p := r.port
+		isConflict := conflicts[scanner.HostPort{Host: p.Host, Port: p.Port}]
 		isHighCPU := p.CPU > 50
 		isHighMem := p.Mem > 10
+		isSaturated := tv.env.IsSaturated(p)
 		isSystem := p.PID > 0 && p.PID < 100
 
-		var cells []string
-		values := []string{
-			fmt.Sprintf("%d", p.Port),
-			p.Protocol,
-			fmt.Sprintf("%d", p.PID),
-			truncate(p.ProcessName, processWidth),
-			truncate(p.User, columns[4].width),
-			fmt.Sprintf("%.1f", p.CPU),
-			fmt.Sprintf("%.1f", p.Mem),
-			p.State,
-		}
-
-		for j, v := range values {
-			w := columns[j].width
-			if j == 3 {
+		isMarked := tv.marked[keyOf(p)]
+
+		mark := ""
+		if isMarked {
+			mark = "●"
+		}
+		cells := []string{lipgloss.NewStyle().Width(markWidth).Render(mark)}
+		for _, c := range cols {
+			w := c.Width
+			if c.Key == "proc" {
 				w = processWidth
 			}
-			cell := lipgloss.NewStyle().Width(w).Padding(0, 1).Render(v)
+			cell := lipgloss.NewStyle().Width(w).Padding(0, 1).Render(truncate(c.Value(p, tv.env), w-2))
 			cells = append(cells, cell)
 		}
 
-		if showGroups {
-			groupName := cfg.GroupForPort(p.Port)
-			groupColor := cfg.GroupColor(groupName)
-			groupCell := groupLabelStyle(groupColor).Width(10).Padding(0, 1).Render(groupName)
-			cells = append(cells, groupCell)
-		}
-
 		row := lipgloss.JoinHorizontal(lipgloss.Top, cells...)
 
 		// Apply row-level styling
 		switch {
 		case isSelected:
 			row = selectedRowStyle.Render(row)
+		case isMarked:
+			row = markedRowStyle.Render(row)
 		case isConflict:
 			row = conflictStyle.Render(row)
-		case isHighCPU || isHighMem:
+		case isHighCPU || isHighMem || isSaturated:
 			row = warningStyle.Render(row)
 		case isSystem:
 			row = dimStyle.Render(row)
@@ -134,15 +271,15 @@ func renderTable(ports []scanner.PortInfo, cursor int, sortCol sortOrder, filter
 			row = healthyStyle.Render(row)
 		}
 
-		rows = append(rows, row)
+		lines = append(lines, row)
 	}
 
 	var parts []string
 	parts = append(parts, header)
 	parts = append(parts, strings.Repeat("─", width-2))
-	parts = append(parts, rows...)
+	parts = append(parts, lines...)
 
-	if len(sorted) == 0 {
+	if len(rows) == 0 {
 		msg := "No ports found"
 		if filter != "" {
 			msg = fmt.Sprintf("No ports matching %q", filter)
@@ -150,78 +287,55 @@ func renderTable(ports []scanner.PortInfo, cursor int, sortCol sortOrder, filter
 		parts = append(parts, dimStyle.Padding(1, 2).Render(msg))
 	}
 
+	if start > 0 || end < len(rows) {
+		parts = append(parts, renderScrollIndicator(start, end, len(rows)))
+	}
+
 	return lipgloss.JoinVertical(lipgloss.Left, parts...)
 }
 
-func filterPorts(ports []scanner.PortInfo, filter string) []scanner.PortInfo {
-	if filter == "" {
-		return ports
+// renderScrollIndicator shows which rows are in view and whether there is
+// more above or below.
+func renderScrollIndicator(start, end, total int) string {
+	up, down := " ", " "
+	if start > 0 {
+		up = "↑"
 	}
-	lower := strings.ToLower(filter)
-	var result []scanner.PortInfo
-	for _, p := range ports {
-		portStr := fmt.Sprintf("%d", p.Port)
-		if strings.Contains(portStr, lower) ||
-			strings.Contains(strings.ToLower(p.ProcessName), lower) ||
-			strings.Contains(strings.ToLower(p.User), lower) ||
-			strings.Contains(strings.ToLower(p.Command), lower) {
-			result = append(result, p)
-		}
+	if end < total {
+		down = "↓"
 	}
-	return result
+	return dimStyle.Padding(0, 1).Render(fmt.Sprintf("%s%s rows %d–%d of %d", up, down, start+1, end, total))
 }
 
-func sortPorts(ports []scanner.PortInfo, so sortOrder) []scanner.PortInfo {
-	sorted := make([]scanner.PortInfo, len(ports))
-	copy(sorted, ports)
-
-	sort.Slice(sorted, func(i, j int) bool {
-		var less bool
-		switch so.column {
-		case 0:
-			less = sorted[i].Port < sorted[j].Port
-		case 1:
-			less = sorted[i].Protocol < sorted[j].Protocol
-		case 2:
-			less = sorted[i].PID < sorted[j].PID
-		case 3:
-			less = strings.ToLower(sorted[i].ProcessName) < strings.ToLower(sorted[j].ProcessName)
-		case 4:
-			less = strings.ToLower(sorted[i].User) < strings.ToLower(sorted[j].User)
-		case 5:
-			less = sorted[i].CPU < sorted[j].CPU
-		case 6:
-			less = sorted[i].Mem < sorted[j].Mem
-		case 7:
-			less = sorted[i].State < sorted[j].State
-		default:
-			less = sorted[i].Port < sorted[j].Port
-		}
-		if !so.asc {
-			return !less
-		}
-		return less
-	})
+// renderGroupHeader renders the header line of a bucket in the grouped view.
+func renderGroupHeader(b *groupBucket, collapsed, selected bool, cfg *config.Config) string {
+	marker := "▼"
+	if collapsed {
+		marker = "▶"
+	}
+	noun := "ports"
+	if len(b.ports) == 1 {
+		noun = "port"
+	}
 
-	return sorted
-}
+	label := groupLabelStyle(cfg.GroupColor(b.name)).Render(fmt.Sprintf("%s %s", marker, b.name))
+	stats := dimStyle.Render(fmt.Sprintf("  %d %s  CPU %.1f%%  Mem %.1f%%", len(b.ports), noun, b.cpu, b.mem))
+	line := lipgloss.NewStyle().Padding(0, 1).Render(label + stats)
 
-func findConflicts(ports []scanner.PortInfo) map[int]bool {
-	portPIDs := make(map[int]map[int]bool)
-	for _, p := range ports {
-		if _, ok := portPIDs[p.Port]; !ok {
-			portPIDs[p.Port] = make(map[int]bool)
-		}
-		portPIDs[p.Port][p.PID] = true
+	if selected {
+		return selectedRowStyle.Render(line)
 	}
+	return line
+}
 
-	conflicts := make(map[int]bool)
-	for port, pids := range portPIDs {
-		if len(pids) > 1 {
-			conflicts[port] = true
-		}
+// filterPorts returns the ports matching the filter query. An invalid query
+// filters nothing; the filter bar shows the parse error instead.
+func filterPorts(ports []scanner.PortInfo, filter string, cfg *config.Config) []scanner.PortInfo {
+	q, err := query.Parse(filter)
+	if err != nil {
+		return ports
 	}
-	return conflicts
+	return q.Filter(ports, cfg.GroupForPort)
 }
 
 func truncate(s string, maxLen int) string {
@@ -0,0 +1,46 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/AbdullahTarakji/portpilot/internal/columns"
+	"github.com/AbdullahTarakji/portpilot/internal/config"
+)
+
+// applyView replaces the filter, sort, visible columns and grouping with
+// those of v. The zero View is the default: the configured columns, sorted
+// by port.
+func (m Model) applyView(v config.View) Model {
+	cols, err := columns.Select(m.config.ColumnsFor(v))
+	if err != nil {
+		cols = columns.Default()
+	}
+	key, asc := v.SortKey()
+	if c, ok := columns.Lookup(key); ok {
+		key = c.Key
+	}
+
+	m.cols = cols
+	m.sortCol = sortOrder{key: key, asc: asc}
+	m.filter = v.Filter
+	m.filterMode = false
+	m.showGroups = v.Grouped
+	m.viewName = v.Name
+	m.cursor, m.offset = 0, 0
+	return m
+}
+
+// switchView applies v and remembers it as the last-used view.
+func (m Model) switchView(v config.View) Model {
+	m = m.applyView(v)
+	m.statusMsg = "View: default"
+	if v.Name != "" {
+		m.statusMsg = "View: " + v.Name
+	}
+	if m.statePath != "" {
+		if err := config.SaveState(m.statePath, config.State{LastView: v.Name}); err != nil {
+			m.statusMsg = fmt.Sprintf("Saving view failed: %v", err)
+		}
+	}
+	return m
+}